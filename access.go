@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	allowedCIDRs = kingpin.Flag("web.allowed-cidr", "Network allowed to scrape the metrics and query the API, e.g. 10.0.0.0/8.  Repeat to allow several networks, all the networks are allowed if unset.").Strings()
+	rateLimit    = kingpin.Flag("web.rate-limit", "Maximum number of requests per second to the metrics and the API from each client address, 0 is unlimited.").Default("0").Float64()
+	rateBurst    = kingpin.Flag("web.rate-burst", "Number of requests allowed at once before --web.rate-limit applies.").Default("5").Int()
+)
+
+// requestsRejected counts the requests rejected by accessHandler by reason
+var requestsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "smartmon_exporter_http_requests_rejected_total",
+	Help: "number of HTTP requests rejected by the access control, by reason",
+}, []string{"reason"})
+
+// parseCIDRs parses the networks of the allowlist
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.New("Unable to parse network " + cidr + ": " + err.Error())
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// rateLimiter keeps a token bucket refilled at rate tokens per second for
+// every client, so a misbehaving client does not use up the requests of
+// the others
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	// mtx protects buckets and pruned
+	mtx     sync.Mutex
+	buckets map[string]*tokenBucket
+	// pruned is the last time the full buckets were dropped
+	pruned time.Time
+}
+
+// tokenBucket is the tokens left to a client after its last request
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// allow takes a token of the client, or returns false and the time until
+// its next token if none is left
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.prune(now)
+	b, found := l.buckets[client]
+	if !found {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops the buckets refilled up to the burst, which are the same as
+// the bucket of a new client, at most once per time an empty bucket takes
+// to refill.  The caller must hold l.mtx.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned).Seconds()*l.rate < l.burst {
+		return
+	}
+	l.pruned = now
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// accessHandler serves the requests of the clients in the allowed networks,
+// all of them if networks is empty, up to the rate of the limiter for each
+// client, which is unlimited if nil.  The requests over unix sockets are
+// local and always allowed by the networks, they share a single bucket.
+func accessHandler(h http.Handler, networks []*net.IPNet, limiter *rateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(networks) > 0 && !allowedAddr(r.RemoteAddr, networks) {
+			requestsRejected.WithLabelValues("network").Inc()
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if limiter != nil {
+			if ok, wait := limiter.allow(remoteHost(r.RemoteAddr), time.Now()); !ok {
+				requestsRejected.WithLabelValues("rate_limit").Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// allowedAddr returns true if the remote address of a request is in one of
+// the networks or is not an IP address, i.e. a unix socket
+func allowedAddr(addr string, networks []*net.IPNet) bool {
+	ip := net.ParseIP(remoteHost(addr))
+	if ip == nil {
+		return true
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost returns the host of the remote address of a request without
+// its port, the address itself if it has none, e.g. of a unix socket
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return strings.Trim(host, "[]")
+}
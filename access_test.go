@@ -0,0 +1,160 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	if _, err := parseCIDRs([]string{"10.0.0.0/8", "10.0.0.1"}); err == nil {
+		t.Error("expected an error for an address without prefix length")
+	}
+	networks, err := parseCIDRs([]string{"10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 2 || networks[1].String() != "fd00::/8" {
+		t.Errorf("expected 2 networks, got %v", networks)
+	}
+}
+
+func TestAllowedAddr(t *testing.T) {
+	networks, err := parseCIDRs([]string{"10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		addr    string
+		allowed bool
+	}{
+		{"10.1.2.3:51234", true},
+		{"192.168.1.1:51234", false},
+		{"[fd00::1]:51234", true},
+		{"[2001:db8::1]:51234", false},
+		// an IPv4 client of a dual-stack listener
+		{"[::ffff:10.1.2.3]:51234", true},
+		// without a port
+		{"10.1.2.3", true},
+		{"192.168.1.1", false},
+		// the clients of a unix socket are local
+		{"@", true},
+		{"", true},
+	} {
+		if allowed := allowedAddr(test.addr, networks); allowed != test.allowed {
+			t.Errorf("expected %q to be allowed %v, got %v", test.addr, test.allowed, allowed)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	l := newRateLimiter(2, 3)
+	for _, test := range []struct {
+		after   time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		// the burst
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 500 * time.Millisecond},
+		// refilled at 2 tokens per second
+		{250 * time.Millisecond, false, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0},
+		// up to the burst
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, false, 500 * time.Millisecond},
+	} {
+		allowed, wait := l.allow("10.1.2.3", start.Add(test.after))
+		if allowed != test.allowed || wait != test.wait {
+			t.Errorf("expected allowed %v and wait %v after %v, got %v and %v", test.allowed, test.wait, test.after, allowed, wait)
+		}
+	}
+
+	// a burst below 1 still allows a request
+	if allowed, _ := newRateLimiter(1, 0).allow("10.1.2.3", start); !allowed {
+		t.Error("expected a request to be allowed with a burst of 0")
+	}
+}
+
+func TestRateLimiterPerClient(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	l := newRateLimiter(1, 1)
+	if allowed, _ := l.allow("10.1.2.3", start); !allowed {
+		t.Fatal("expected the first request to be allowed")
+	}
+	if allowed, _ := l.allow("10.1.2.3", start); allowed {
+		t.Fatal("expected the second request of the client to be limited")
+	}
+	// another client has a bucket of its own
+	if allowed, _ := l.allow("10.1.2.4", start); !allowed {
+		t.Fatal("expected the request of another client to be allowed")
+	}
+
+	// the buckets refilled are dropped
+	if allowed, _ := l.allow("10.1.2.5", start.Add(time.Minute)); !allowed {
+		t.Fatal("expected the request of a new client to be allowed")
+	}
+	if len(l.buckets) != 1 {
+		t.Errorf("expected the bucket of the last client only, got %d buckets", len(l.buckets))
+	}
+}
+
+func TestAccessHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	networks, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := accessHandler(ok, networks, newRateLimiter(1, 1))
+	for _, test := range []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"192.168.1.1:51234", http.StatusForbidden},
+		{"10.1.2.3:51234", http.StatusOK},
+		// the burst of 1 is used
+		{"10.1.2.3:51235", http.StatusTooManyRequests},
+		// by this client only
+		{"10.1.2.4:51234", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("expected status %d for %s, got %d", test.status, test.remoteAddr, w.Code)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("expected to retry after 1s, got %q", w.Header().Get("Retry-After"))
+		}
+	}
+
+	// no restriction without networks nor limiter
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.RemoteAddr = "192.168.1.1:51234"
+	accessHandler(ok, nil, nil).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 without restrictions, got %d", w.Code)
+	}
+}
@@ -0,0 +1,339 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentx
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The PDU types of RFC 2741 handled by the subagent
+const (
+	pduOpen       = 1
+	pduClose      = 2
+	pduRegister   = 3
+	pduGet        = 5
+	pduGetNext    = 6
+	pduGetBulk    = 7
+	pduTestSet    = 8
+	pduCommitSet  = 9
+	pduUndoSet    = 10
+	pduCleanupSet = 11
+	pduResponse   = 18
+)
+
+// The flags of the PDU header
+const (
+	flagNonDefaultContext = 0x08
+	flagNetworkByteOrder  = 0x10
+)
+
+// The errors of the response PDU
+const (
+	errorNone        = 0
+	errorNotWritable = 17
+)
+
+// headerLength is the length of the PDU header
+const headerLength = 20
+
+// Type is the type of a variable
+type Type uint16
+
+// The types of the variables
+const (
+	TypeInteger          Type = 2
+	TypeOctetString      Type = 4
+	TypeNull             Type = 5
+	TypeObjectIdentifier Type = 6
+	TypeCounter32        Type = 65
+	TypeGauge32          Type = 66
+	TypeTimeTicks        Type = 67
+	TypeCounter64        Type = 70
+	typeNoSuchObject     Type = 128
+	typeEndOfMibView     Type = 130
+)
+
+// OID is an object identifier, e.g. 1.3.6.1.4.1
+type OID []uint32
+
+// internetPrefix is the prefix 1.3.6.1 of the OIDs compressed by the
+// prefix field of their encoding
+var internetPrefix = OID{1, 3, 6, 1}
+
+// ParseOID parses the dotted notation of an OID
+func ParseOID(s string) (OID, error) {
+	oid := OID{}
+	for _, part := range strings.Split(strings.TrimPrefix(s, "."), ".") {
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid OID " + s + ": " + err.Error())
+		}
+		oid = append(oid, uint32(id))
+	}
+	return oid, nil
+}
+
+// String returns the dotted notation of the OID
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, id := range o {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Append returns the OID followed by the ids, without modifying o
+func (o OID) Append(ids ...uint32) OID {
+	return append(append(OID{}, o...), ids...)
+}
+
+// Compare returns -1, 0 or 1 if the OID sorts before, equal to or after
+// other in lexicographic order
+func (o OID) Compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		switch {
+		case o[i] < other[i]:
+			return -1
+		case o[i] > other[i]:
+			return 1
+		}
+	}
+	switch {
+	case len(o) < len(other):
+		return -1
+	case len(o) > len(other):
+		return 1
+	}
+	return 0
+}
+
+// Variable is a variable served by the subagent.  Value is an int32 for
+// TypeInteger, a uint32 for TypeCounter32, TypeGauge32 and TypeTimeTicks,
+// a uint64 for TypeCounter64, a string for TypeOctetString and an OID for
+// TypeObjectIdentifier.
+type Variable struct {
+	OID   OID
+	Type  Type
+	Value interface{}
+}
+
+// header is the header of a PDU
+type header struct {
+	version       uint8
+	pduType       uint8
+	flags         uint8
+	sessionID     uint32
+	transactionID uint32
+	packetID      uint32
+	payloadLength uint32
+}
+
+// order returns the byte order of the PDU
+func (h *header) order() binary.ByteOrder {
+	if h.flags&flagNetworkByteOrder != 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// readPDU reads the header and the payload of a PDU
+func readPDU(r io.Reader) (*header, []byte, error) {
+	buf := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, nil, err
+	}
+	h := &header{version: buf[0], pduType: buf[1], flags: buf[2]}
+	order := h.order()
+	h.sessionID = order.Uint32(buf[4:])
+	h.transactionID = order.Uint32(buf[8:])
+	h.packetID = order.Uint32(buf[12:])
+	h.payloadLength = order.Uint32(buf[16:])
+	if h.version != 1 {
+		return nil, nil, errors.New("unsupported AgentX version " + strconv.Itoa(int(h.version)))
+	}
+	if h.payloadLength > 1<<20 {
+		return nil, nil, errors.New("AgentX PDU too large")
+	}
+	payload := make([]byte, h.payloadLength)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+	return h, payload, nil
+}
+
+// encoder encodes the payload of a PDU in network byte order
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.buf = append(e.buf, byte(v>>8), byte(v))
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.uint32(uint32(v >> 32))
+	e.uint32(uint32(v))
+}
+
+// oid encodes the OID, compressing the 1.3.6.1 prefix
+func (e *encoder) oid(o OID, include bool) {
+	prefix := uint8(0)
+	if len(o) > 4 && o[:4].Compare(internetPrefix) == 0 && o[4] > 0 && o[4] < 256 {
+		prefix = uint8(o[4])
+		o = o[5:]
+	}
+	e.uint8(uint8(len(o)))
+	e.uint8(prefix)
+	if include {
+		e.uint8(1)
+	} else {
+		e.uint8(0)
+	}
+	e.uint8(0)
+	for _, id := range o {
+		e.uint32(id)
+	}
+}
+
+// octetString encodes the string padded to a multiple of 4 bytes
+func (e *encoder) octetString(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	for len(e.buf)%4 != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// variable encodes the variable as a VarBind
+func (e *encoder) variable(v Variable) {
+	e.uint16(uint16(v.Type))
+	e.uint16(0)
+	e.oid(v.OID, false)
+	switch v.Type {
+	case TypeInteger:
+		value, _ := v.Value.(int32)
+		e.uint32(uint32(value))
+	case TypeCounter32, TypeGauge32, TypeTimeTicks:
+		value, _ := v.Value.(uint32)
+		e.uint32(value)
+	case TypeCounter64:
+		value, _ := v.Value.(uint64)
+		e.uint64(value)
+	case TypeOctetString:
+		value, _ := v.Value.(string)
+		e.octetString(value)
+	case TypeObjectIdentifier:
+		value, _ := v.Value.(OID)
+		e.oid(value, false)
+	}
+}
+
+// pdu returns the PDU with the header and the encoded payload
+func (e *encoder) pdu(pduType uint8, sessionID, transactionID, packetID uint32) []byte {
+	h := encoder{}
+	h.uint8(1)
+	h.uint8(pduType)
+	h.uint8(flagNetworkByteOrder)
+	h.uint8(0)
+	h.uint32(sessionID)
+	h.uint32(transactionID)
+	h.uint32(packetID)
+	h.uint32(uint32(len(e.buf)))
+	return append(h.buf, e.buf...)
+}
+
+// decoder decodes the payload of a PDU, the first error is kept
+type decoder struct {
+	buf   []byte
+	order binary.ByteOrder
+	err   error
+}
+
+// next returns the next n bytes of the payload
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if len(d.buf) < n {
+		d.err = errors.New("truncated AgentX PDU")
+		return make([]byte, n)
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	return d.order.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return d.order.Uint32(d.next(4))
+}
+
+// oid decodes an OID and its include field
+func (d *decoder) oid() (OID, bool) {
+	b := d.next(4)
+	n, prefix, include := int(b[0]), b[1], b[2] != 0
+	o := OID{}
+	if prefix != 0 {
+		o = append(internetPrefix.Append(), uint32(prefix))
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		o = append(o, d.uint32())
+	}
+	return o, include
+}
+
+// octetString decodes a string padded to a multiple of 4 bytes
+func (d *decoder) octetString() string {
+	n := int(d.uint32())
+	if d.err == nil && n > len(d.buf) {
+		d.err = errors.New("truncated AgentX PDU")
+		return ""
+	}
+	s := string(d.next(n))
+	d.next((4 - n%4) % 4)
+	return s
+}
+
+// searchRange is a range of OIDs requested by the master agent, End is
+// unbounded if empty
+type searchRange struct {
+	Start   OID
+	Include bool
+	End     OID
+}
+
+// searchRanges decodes the SearchRangeList ending the payload
+func (d *decoder) searchRanges() []searchRange {
+	ranges := []searchRange{}
+	for len(d.buf) > 0 && d.err == nil {
+		start, include := d.oid()
+		end, _ := d.oid()
+		ranges = append(ranges, searchRange{Start: start, Include: include, End: end})
+	}
+	return ranges
+}
@@ -0,0 +1,228 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agentx implements a minimal read-only AgentX subagent, RFC 2741,
+// which registers a subtree with the SNMP master agent, e.g. snmpd, and
+// answers its get, get-next and get-bulk requests from a list of variables.
+package agentx
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Session is a session of the subagent with the master agent
+type Session struct {
+	conn      io.ReadWriter
+	sessionID uint32
+	packetID  uint32
+	started   time.Time
+	// variables returns the variables of the registered subtree sorted by
+	// OID, it is called for every request
+	variables func() []Variable
+}
+
+// Open opens a session with the master agent on the connection and
+// registers the subtree, whose variables are returned by variables sorted
+// by OID
+func Open(conn io.ReadWriter, description string, subtree OID, variables func() []Variable) (*Session, error) {
+	s := &Session{conn: conn, started: time.Now(), variables: variables}
+	open := encoder{}
+	// default timeout, no id
+	open.uint32(0)
+	open.oid(nil, false)
+	open.octetString(description)
+	h, err := s.request(open.pdu(pduOpen, 0, 0, s.nextPacketID()))
+	if err != nil {
+		return nil, errors.New("Unable to open AgentX session: " + err.Error())
+	}
+	s.sessionID = h.sessionID
+
+	register := encoder{}
+	// default timeout and priority
+	register.uint8(0)
+	register.uint8(127)
+	register.uint8(0)
+	register.uint8(0)
+	register.oid(subtree, false)
+	if _, err := s.request(register.pdu(pduRegister, s.sessionID, 0, s.nextPacketID())); err != nil {
+		return nil, errors.New("Unable to register " + subtree.String() + ": " + err.Error())
+	}
+	return s, nil
+}
+
+// nextPacketID returns the id of the next PDU sent by the subagent
+func (s *Session) nextPacketID() uint32 {
+	s.packetID++
+	return s.packetID
+}
+
+// request sends the PDU and reads the response of the master agent
+func (s *Session) request(pdu []byte) (*header, error) {
+	if _, err := s.conn.Write(pdu); err != nil {
+		return nil, err
+	}
+	h, payload, err := readPDU(s.conn)
+	if err != nil {
+		return nil, err
+	}
+	if h.pduType != pduResponse {
+		return nil, errors.New("unexpected AgentX PDU type " + strconv.Itoa(int(h.pduType)))
+	}
+	d := decoder{buf: payload, order: h.order()}
+	d.uint32()
+	status := d.uint16()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if status != errorNone {
+		return nil, errors.New("AgentX error " + strconv.Itoa(int(status)))
+	}
+	return h, nil
+}
+
+// Serve answers the requests of the master agent until it closes the
+// session or the connection fails
+func (s *Session) Serve() error {
+	for {
+		h, payload, err := readPDU(s.conn)
+		if err != nil {
+			return err
+		}
+		d := decoder{buf: payload, order: h.order()}
+		if h.flags&flagNonDefaultContext != 0 {
+			// a single context is served whatever the context requested
+			d.octetString()
+		}
+		var response []Variable
+		status := uint16(errorNone)
+		switch h.pduType {
+		case pduGet:
+			response = s.get(d.searchRanges())
+		case pduGetNext:
+			response = s.getNext(d.searchRanges())
+		case pduGetBulk:
+			nonRepeaters, maxRepetitions := d.uint16(), d.uint16()
+			response = s.getBulk(d.searchRanges(), int(nonRepeaters), int(maxRepetitions))
+		case pduTestSet:
+			status = errorNotWritable
+		case pduCommitSet, pduUndoSet:
+		case pduCleanupSet, pduResponse:
+			continue
+		case pduClose:
+			return nil
+		default:
+			continue
+		}
+		if d.err != nil {
+			return d.err
+		}
+		if err := s.respond(h, status, response); err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the session with the master agent
+func (s *Session) Close() error {
+	closePDU := encoder{}
+	// reasonShutdown
+	closePDU.uint32(5 << 24)
+	_, err := s.conn.Write(closePDU.pdu(pduClose, s.sessionID, 0, s.nextPacketID()))
+	return err
+}
+
+// respond sends the response to the request
+func (s *Session) respond(request *header, status uint16, variables []Variable) error {
+	response := encoder{}
+	response.uint32(uint32(time.Since(s.started) / (10 * time.Millisecond)))
+	response.uint16(status)
+	if status != errorNone {
+		response.uint16(1)
+	} else {
+		response.uint16(0)
+	}
+	for _, v := range variables {
+		response.variable(v)
+	}
+	_, err := s.conn.Write(response.pdu(pduResponse, request.sessionID, request.transactionID, request.packetID))
+	return err
+}
+
+// get returns the variables named by the start of the ranges
+func (s *Session) get(ranges []searchRange) []Variable {
+	variables := s.variables()
+	response := make([]Variable, 0, len(ranges))
+	for _, r := range ranges {
+		i := sort.Search(len(variables), func(i int) bool { return variables[i].OID.Compare(r.Start) >= 0 })
+		if i < len(variables) && variables[i].OID.Compare(r.Start) == 0 {
+			response = append(response, variables[i])
+		} else {
+			response = append(response, Variable{OID: r.Start, Type: typeNoSuchObject})
+		}
+	}
+	return response
+}
+
+// next returns the first variable in the range, or endOfMibView
+func next(variables []Variable, r searchRange) Variable {
+	i := sort.Search(len(variables), func(i int) bool {
+		c := variables[i].OID.Compare(r.Start)
+		return c > 0 || c == 0 && r.Include
+	})
+	if i < len(variables) && (len(r.End) == 0 || variables[i].OID.Compare(r.End) < 0) {
+		return variables[i]
+	}
+	return Variable{OID: r.Start, Type: typeEndOfMibView}
+}
+
+// getNext returns the first variable in each range
+func (s *Session) getNext(ranges []searchRange) []Variable {
+	variables := s.variables()
+	response := make([]Variable, 0, len(ranges))
+	for _, r := range ranges {
+		response = append(response, next(variables, r))
+	}
+	return response
+}
+
+// getBulk returns the first variable of the non repeating ranges, then up
+// to maxRepetitions variables of each of the other ranges, interleaved
+func (s *Session) getBulk(ranges []searchRange, nonRepeaters, maxRepetitions int) []Variable {
+	variables := s.variables()
+	if nonRepeaters > len(ranges) {
+		nonRepeaters = len(ranges)
+	}
+	response := []Variable{}
+	for _, r := range ranges[:nonRepeaters] {
+		response = append(response, next(variables, r))
+	}
+	repeaters := append([]searchRange{}, ranges[nonRepeaters:]...)
+	for i := 0; i < maxRepetitions && len(repeaters) > 0; i++ {
+		done := true
+		for j, r := range repeaters {
+			v := next(variables, r)
+			response = append(response, v)
+			if v.Type != typeEndOfMibView {
+				done = false
+				repeaters[j].Start, repeaters[j].Include = v.OID, false
+			}
+		}
+		if done {
+			break
+		}
+	}
+	return response
+}
@@ -0,0 +1,168 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentx
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+)
+
+var testSubtree = OID{1, 3, 6, 1, 4, 1, 8072, 9999, 9999, 151}
+
+var testVariables = []Variable{
+	{OID: testSubtree.Append(1, 0), Type: TypeInteger, Value: int32(2)},
+	{OID: testSubtree.Append(2, 1, 2, 1), Type: TypeOctetString, Value: "/dev/sda"},
+	{OID: testSubtree.Append(2, 1, 2, 2), Type: TypeOctetString, Value: "/dev/sdb"},
+}
+
+// master plays the master agent on the other end of a pipe
+type master struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+// respond reads a PDU of the subagent and answers it
+func (m *master) respond(pduType uint8, sessionID uint32) *header {
+	h, _, err := readPDU(m.conn)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	if h.pduType != pduType {
+		m.t.Fatalf("got PDU type %d, expected %d", h.pduType, pduType)
+	}
+	e := encoder{}
+	e.uint32(0)
+	e.uint16(errorNone)
+	e.uint16(0)
+	m.conn.Write(e.pdu(pduResponse, sessionID, h.transactionID, h.packetID))
+	return h
+}
+
+// request sends a request with the search ranges and returns the variables
+// of the response
+func (m *master) request(pduType uint8, prefix []uint16, ranges ...searchRange) []Variable {
+	e := encoder{}
+	for _, v := range prefix {
+		e.uint16(v)
+	}
+	for _, r := range ranges {
+		e.oid(r.Start, r.Include)
+		e.oid(r.End, false)
+	}
+	m.conn.Write(e.pdu(pduType, 42, 7, 1))
+	h, payload, err := readPDU(m.conn)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	if h.pduType != pduResponse || h.sessionID != 42 || h.transactionID != 7 {
+		m.t.Fatalf("unexpected response header %+v", h)
+	}
+	d := decoder{buf: payload, order: binary.BigEndian}
+	d.uint32()
+	if status := d.uint16(); status != errorNone {
+		m.t.Fatalf("got error %d", status)
+	}
+	d.uint16()
+	variables := []Variable{}
+	for len(d.buf) > 0 && d.err == nil {
+		v := Variable{Type: Type(d.uint16())}
+		d.uint16()
+		v.OID, _ = d.oid()
+		switch v.Type {
+		case TypeInteger:
+			v.Value = int32(d.uint32())
+		case TypeOctetString:
+			v.Value = d.octetString()
+		}
+		variables = append(variables, v)
+	}
+	if d.err != nil {
+		m.t.Fatal(d.err)
+	}
+	return variables
+}
+
+func openTestSession(t *testing.T) (*master, chan error) {
+	subagent, conn := net.Pipe()
+	m := &master{t: t, conn: conn}
+	served := make(chan error, 1)
+	go func() {
+		s, err := Open(subagent, "test", testSubtree, func() []Variable { return testVariables })
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- s.Serve()
+	}()
+	m.respond(pduOpen, 42)
+	m.respond(pduRegister, 42)
+	return m, served
+}
+
+func TestSession(t *testing.T) {
+	m, served := openTestSession(t)
+
+	got := m.request(pduGet, nil, searchRange{Start: testSubtree.Append(1, 0)}, searchRange{Start: testSubtree.Append(3, 0)})
+	expected := []Variable{testVariables[0], {OID: testSubtree.Append(3, 0), Type: typeNoSuchObject}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("get: got %v, expected %v", got, expected)
+	}
+
+	got = m.request(pduGetNext, nil, searchRange{Start: testSubtree.Append(1, 0)}, searchRange{Start: testSubtree, Include: true, End: testSubtree.Append(2)})
+	expected = []Variable{testVariables[1], testVariables[0]}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("get-next: got %v, expected %v", got, expected)
+	}
+
+	got = m.request(pduGetBulk, []uint16{1, 5}, searchRange{Start: testSubtree}, searchRange{Start: testSubtree.Append(2)})
+	expected = []Variable{testVariables[0], testVariables[1], testVariables[2], {OID: testSubtree.Append(2, 1, 2, 2), Type: typeEndOfMibView}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("get-bulk: got %v, expected %v", got, expected)
+	}
+
+	closePDU := encoder{}
+	closePDU.uint32(0)
+	m.conn.Write(closePDU.pdu(pduClose, 42, 0, 2))
+	if err := <-served; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOIDCompressedPrefix(t *testing.T) {
+	e := encoder{}
+	e.oid(testSubtree, true)
+	// n_subid, prefix 4, include, reserved then 1.8072.9999.9999.151
+	if e.buf[0] != 5 || e.buf[1] != 4 || e.buf[2] != 1 {
+		t.Fatalf("got %v", e.buf[:4])
+	}
+	d := decoder{buf: e.buf, order: binary.BigEndian}
+	got, include := d.oid()
+	if !reflect.DeepEqual(got, testSubtree) || !include {
+		t.Errorf("got %v %v, expected %v true", got, include, testSubtree)
+	}
+}
+
+func TestParseOID(t *testing.T) {
+	got, err := ParseOID(".1.3.6.1.4.1.8072.9999.9999.151")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Compare(testSubtree) != 0 || got.String() != "1.3.6.1.4.1.8072.9999.9999.151" {
+		t.Errorf("got %v", got)
+	}
+	if _, err := ParseOID("1.3.x"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,429 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// The paths of the API endpoints
+const (
+	apiDevicesPath = "/api/v1/devices"
+	apiHistoryPath = "/api/v1/attributes/history"
+)
+
+var (
+	enableAdminAPI    = kingpin.Flag("web.enable-admin-api", "Enable the API endpoints for administrative actions, e.g. starting self-tests.").Default("false").Bool()
+	adminAPITokenFile = kingpin.Flag("web.admin-api.token-file", "File containing the bearer token required by the admin API.").Default("").String()
+)
+
+// api serves the device endpoints under /api/v1/devices
+type api struct {
+	// devices reads the devices, the device endpoints are not implemented
+	// if nil
+	devices deviceReader
+	// adminToken is the bearer token of the admin endpoints, which are
+	// disabled if empty
+	adminToken string
+	// history keeps the history of the attributes, which is empty if nil
+	history attributeHistorian
+}
+
+// deviceReader is implemented by the collectors querying the devices.  The
+// API reads the devices through the collector to share its scan cache, the
+// options of the devices, their quarantine and the serialization of the
+// devices of a controller.
+type deviceReader interface {
+	ScannedDevices(ctx context.Context) ([]smart.Device, error)
+	WithDevice(d smart.Device, read func(opts *smart.Options) error) error
+}
+
+// attributeHistorian is implemented by the collectors keeping the history
+// of the key attributes of the devices
+type attributeHistorian interface {
+	AttributeHistory() map[string]map[string][]smart.Sample
+}
+
+// deviceResponse describes a device.  The info and attributes are only
+// read from active devices, unless waking up the device is requested.
+type deviceResponse struct {
+	smart.Device
+	// Key identifies the device, the devices behind a RAID controller
+	// share its name
+	Key        string            `json:"key"`
+	PowerMode  smart.PowerMode   `json:"power_mode"`
+	Info       *smart.DeviceInfo `json:"info,omitempty"`
+	Attributes []smart.Attribute `json:"attributes,omitempty"`
+	// Temperature in Celsius and Wear, the percentage of the endurance
+	// used, are read from the attributes if the device reports them
+	Temperature *float64 `json:"temperature,omitempty"`
+	Wear        *float64 `json:"wear_percent,omitempty"`
+	// LastSelfTest is the most recent completed self-test, only read if
+	// requested
+	LastSelfTest *selfTestResult `json:"last_self_test,omitempty"`
+}
+
+// selfTestResult is an entry of the self-test log of a device
+type selfTestResult struct {
+	Description   string `json:"description"`
+	Status        string `json:"status"`
+	Passed        bool   `json:"passed"`
+	LifetimeHours int    `json:"lifetime_hours"`
+}
+
+// selfTestResponse is returned when a self-test was started
+type selfTestResponse struct {
+	Device              string    `json:"device"`
+	Key                 string    `json:"key"`
+	Type                string    `json:"type"`
+	Test                string    `json:"test"`
+	DurationSeconds     float64   `json:"duration_seconds"`
+	EstimatedCompletion time.Time `json:"estimated_completion"`
+}
+
+// newAPI creates the API handler reading the devices through devices, the
+// admin endpoints are enabled with the token of the admin token file
+func newAPI(devices deviceReader) (*api, error) {
+	a := &api{devices: devices}
+	if !*enableAdminAPI {
+		return a, nil
+	}
+	if *adminAPITokenFile == "" {
+		return nil, errors.New("--web.enable-admin-api requires --web.admin-api.token-file")
+	}
+	token, err := ioutil.ReadFile(*adminAPITokenFile)
+	if err != nil {
+		return nil, err
+	}
+	a.adminToken = strings.TrimSpace(string(token))
+	if a.adminToken == "" {
+		return nil, errors.New("empty admin API token in " + *adminAPITokenFile)
+	}
+	return a, nil
+}
+
+// ServeHTTP routes the requests for /api/v1/devices, /api/v1/devices/{dev}
+// and /api/v1/devices/{dev}/selftest
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, apiDevicesPath), "/")
+	if dev := strings.TrimSuffix(rest, "/selftest"); dev != rest {
+		if a.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		a.selfTest(w, r, dev)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.devices == nil {
+		http.Error(w, "The devices are only queried by the smartctl collector", http.StatusNotImplemented)
+		return
+	}
+	if rest == "" {
+		a.listDevices(w, r)
+	} else {
+		a.deviceDetails(w, r, rest)
+	}
+}
+
+// listDevices lists the scanned devices and their power mode, which is
+// empty for the quarantined devices
+func (a *api) listDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := a.devices.ScannedDevices(r.Context())
+	if err != nil {
+		http.Error(w, "Unable to scan devices: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	response := make([]deviceResponse, 0, len(devices))
+	for _, d := range devices {
+		var mode smart.PowerMode
+		a.devices.WithDevice(d, func(opts *smart.Options) error {
+			mode, _ = d.PowerMode(r.Context(), opts)
+			return nil
+		})
+		response = append(response, deviceResponse{Device: d, Key: d.Key(), PowerMode: mode})
+	}
+	writeJSON(w, response)
+}
+
+// deviceDetails describes the device including its info and attributes,
+// and its most recent self-test if the selftest query parameter is true.
+// A device in standby or sleep is only woken up if the wake query
+// parameter is true.  The devices sharing a name are selected by the type
+// query parameter, or addressed by their key.
+func (a *api) deviceDetails(w http.ResponseWriter, r *http.Request, dev string) {
+	device, err := findDevice(r.Context(), a.devices, dev, r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, err.Error(), findDeviceStatus(err))
+		return
+	}
+	wake, _ := strconv.ParseBool(r.URL.Query().Get("wake"))
+	selfTest, _ := strconv.ParseBool(r.URL.Query().Get("selftest"))
+	var response *deviceResponse
+	err = a.devices.WithDevice(*device, func(opts *smart.Options) error {
+		var err error
+		if response, err = readDevice(r.Context(), opts, device, wake); err != nil {
+			return err
+		}
+		if selfTest && response.Info != nil {
+			response.LastSelfTest = readLastSelfTest(r.Context(), opts, device)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), readDeviceStatus(err))
+		return
+	}
+	writeJSON(w, response)
+}
+
+// readLastSelfTest reads the most recent completed self-test of the device,
+// nil if it has none or its self-test log could not be read, e.g. as it
+// does not support self-tests
+func readLastSelfTest(ctx context.Context, opts *smart.Options, device *smart.Device) *selfTestResult {
+	tests, err := device.SelfTests(ctx, opts)
+	if err != nil {
+		log.With("device", device.Name).Debugln("Unable to read the self-test log:", err)
+		return nil
+	}
+	for _, test := range tests {
+		if strings.Contains(test.Status, "in progress") {
+			continue
+		}
+		return &selfTestResult{
+			Description:   test.Description,
+			Status:        test.Status,
+			Passed:        test.Passed,
+			LifetimeHours: test.LifetimeHours,
+		}
+	}
+	return nil
+}
+
+// attributeHistory returns the last samples of the key attributes by
+// device and attribute, of the device query parameter only if set
+func (a *api) attributeHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	history := map[string]map[string][]smart.Sample{}
+	if a.history != nil {
+		history = a.history.AttributeHistory()
+	}
+	if dev := r.URL.Query().Get("device"); dev != "" {
+		name := dev
+		if _, found := history[name]; !found {
+			name = "/dev/" + dev
+		}
+		samples, found := history[name]
+		if !found {
+			http.Error(w, "No history of device: "+dev, http.StatusNotFound)
+			return
+		}
+		history = map[string]map[string][]smart.Sample{name: samples}
+	}
+	writeJSON(w, history)
+}
+
+// authorized returns true if the request has the bearer token of the admin
+// API, a token without the Bearer scheme is rejected
+func (a *api) authorized(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) == 1
+}
+
+// selfTest starts a self-test of the device, the type of test is set by
+// the test query parameter and defaults to short.  The devices sharing a
+// name are selected by the type query parameter, or addressed by their
+// key, a self-test is never started on an ambiguous name.
+func (a *api) selfTest(w http.ResponseWriter, r *http.Request, dev string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	test := smart.SelfTestType(r.URL.Query().Get("test"))
+	if test == "" {
+		test = smart.SelfTestShort
+	}
+	if test != smart.SelfTestShort && test != smart.SelfTestLong {
+		http.Error(w, "Unsupported self-test type: "+string(test), http.StatusBadRequest)
+		return
+	}
+	if a.devices == nil {
+		http.Error(w, "The devices are only queried by the smartctl collector", http.StatusNotImplemented)
+		return
+	}
+	device, err := findDevice(r.Context(), a.devices, dev, r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, err.Error(), findDeviceStatus(err))
+		return
+	}
+	var duration time.Duration
+	err = a.devices.WithDevice(*device, func(opts *smart.Options) error {
+		var err error
+		duration, err = device.StartSelfTest(r.Context(), opts, test)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Unable to start self-test: "+err.Error(), readDeviceStatus(err))
+		return
+	}
+	log.Infoln("Started", test, "self-test of", device.Key(), "requested by", r.RemoteAddr)
+	writeJSON(w, selfTestResponse{
+		Device:              device.Name,
+		Key:                 device.Key(),
+		Type:                device.Type,
+		Test:                string(test),
+		DurationSeconds:     duration.Seconds(),
+		EstimatedCompletion: time.Now().Add(duration),
+	})
+}
+
+// readDevice reads the power mode, info and attributes of the device.  The
+// info and attributes of a device in standby or sleep are only read if
+// wake is true.
+func readDevice(ctx context.Context, opts *smart.Options, device *smart.Device, wake bool) (*deviceResponse, error) {
+	mode, _ := device.PowerMode(ctx, opts)
+	response := &deviceResponse{Device: *device, Key: device.Key(), PowerMode: mode}
+	if !wake && (mode == smart.PowerModeStandby || mode == smart.PowerModeSleep) {
+		return response, nil
+	}
+	var err error
+	if response.Info, err = device.Info(ctx, opts); err != nil {
+		return nil, errors.New("Unable to read device info: " + err.Error())
+	}
+	if response.Attributes, err = device.Attributes(ctx, opts); err != nil {
+		return nil, errors.New("Unable to read device attributes: " + err.Error())
+	}
+	if t, found := smart.Temperature(response.Attributes); found {
+		response.Temperature = &t
+	}
+	if wear, found := smart.Wear(response.Attributes); found {
+		response.Wear = &wear
+	}
+	return response, nil
+}
+
+// ambiguousDeviceError is returned by findDevice when several devices match,
+// e.g. the disks behind a RAID controller which share its name
+type ambiguousDeviceError struct {
+	dev  string
+	keys []string
+}
+
+func (e *ambiguousDeviceError) Error() string {
+	return "Several devices match " + e.dev + ", select one by its key or with the type parameter: " + strings.Join(e.keys, ", ")
+}
+
+// scanError is returned by findDevice when the devices could not be scanned
+type scanError struct {
+	err error
+}
+
+func (e *scanError) Error() string {
+	return "Unable to scan devices: " + e.err.Error()
+}
+
+// findDevice finds the device named dev, or with the key dev, see
+// smart.Device.Key, with or without the /dev/ prefix, among the devices
+// scanned by the collector.  The devices sharing the name are told apart
+// by deviceType if not empty, otherwise an *ambiguousDeviceError is
+// returned.  A *scanError is returned if the scan failed.
+func findDevice(ctx context.Context, devices deviceReader, dev, deviceType string) (*smart.Device, error) {
+	scanned, err := devices.ScannedDevices(ctx)
+	if err != nil {
+		return nil, &scanError{err: err}
+	}
+	return matchDevice(scanned, dev, deviceType)
+}
+
+// matchDevice returns the device of findDevice among the devices
+func matchDevice(devices []smart.Device, dev, deviceType string) (*smart.Device, error) {
+	found := []smart.Device{}
+	for _, d := range devices {
+		if deviceType != "" && d.Type != deviceType {
+			continue
+		}
+		for _, name := range []string{d.Name, d.Key()} {
+			if name == dev || name == "/dev/"+dev {
+				found = append(found, d)
+				break
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, errors.New("Device not found: " + dev)
+	case 1:
+		return &found[0], nil
+	}
+	keys := make([]string, 0, len(found))
+	for _, d := range found {
+		keys = append(keys, d.Key())
+	}
+	return nil, &ambiguousDeviceError{dev: dev, keys: keys}
+}
+
+// findDeviceStatus returns the HTTP status of an error of findDevice
+func findDeviceStatus(err error) int {
+	switch err.(type) {
+	case *ambiguousDeviceError:
+		return http.StatusConflict
+	case *scanError:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusNotFound
+}
+
+// readDeviceStatus returns the HTTP status of an error reading a device
+// through deviceReader.WithDevice
+func readDeviceStatus(err error) int {
+	if err == smart.ErrQuarantined {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes the value as the JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorln("Unable to write API response:", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+func TestNewAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { *enableAdminAPI, *adminAPITokenFile = false, "" }()
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	*enableAdminAPI, *adminAPITokenFile = false, tokenFile
+	if a, err := newAPI(nil); err != nil {
+		t.Error(err)
+	} else if a.adminToken != "" {
+		t.Errorf("expected the admin API disabled, got token %q", a.adminToken)
+	}
+	*enableAdminAPI = true
+	if a, err := newAPI(nil); err != nil {
+		t.Error(err)
+	} else if a.adminToken != "s3cret" {
+		t.Errorf("expected the token s3cret, got %q", a.adminToken)
+	}
+	for _, file := range []string{"", emptyFile, filepath.Join(dir, "missing")} {
+		*adminAPITokenFile = file
+		if _, err := newAPI(nil); err == nil {
+			t.Errorf("expected an error with the token file %q", file)
+		}
+	}
+}
+
+func TestAPISelfTestRouting(t *testing.T) {
+	for _, test := range []struct {
+		adminToken    string
+		method        string
+		url           string
+		authorization string
+		status        int
+	}{
+		// the admin endpoints do not exist without a token
+		{"", http.MethodPost, "/api/v1/devices/sda/selftest", "Bearer s3cret", http.StatusNotFound},
+		{"s3cret", http.MethodGet, "/api/v1/devices/sda/selftest", "Bearer s3cret", http.StatusMethodNotAllowed},
+		{"s3cret", http.MethodPost, "/api/v1/devices/sda/selftest", "", http.StatusUnauthorized},
+		{"s3cret", http.MethodPost, "/api/v1/devices/sda/selftest", "Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.MethodPost, "/api/v1/devices/sda/selftest?test=conveyance", "Bearer s3cret", http.StatusBadRequest},
+	} {
+		a := &api{adminToken: test.adminToken}
+		r := httptest.NewRequest(test.method, test.url, nil)
+		if test.authorization != "" {
+			r.Header.Set("Authorization", test.authorization)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("expected status %d for %s %s with token %q, got %d", test.status, test.method, test.url, test.adminToken, w.Code)
+		}
+		if w.Code == http.StatusMethodNotAllowed && w.Header().Get("Allow") != http.MethodPost {
+			t.Errorf("expected to allow POST, got %q", w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestAPIAuthorized(t *testing.T) {
+	a := &api{adminToken: "s3cret"}
+	for authorization, expected := range map[string]bool{
+		"Bearer s3cret": true,
+		"s3cret":        false,
+		"bearer s3cret": false,
+		"Bearer s3cre":  false,
+		"Basic s3cret":  false,
+		"":              false,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/devices/sda/selftest", nil)
+		r.Header.Set("Authorization", authorization)
+		if authorized := a.authorized(r); authorized != expected {
+			t.Errorf("expected authorized %t with %q, got %t", expected, authorization, authorized)
+		}
+	}
+}
+
+func TestAPIRouting(t *testing.T) {
+	networks, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := accessHandler(&api{}, networks, nil)
+	for _, test := range []struct {
+		method     string
+		url        string
+		remoteAddr string
+		status     int
+	}{
+		{http.MethodPost, "/api/v1/devices", "10.1.2.3:51234", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/v1/devices/sda", "10.1.2.3:51234", http.StatusMethodNotAllowed},
+		// the requests on a unix socket are allowed whatever the networks
+		{http.MethodPost, "/api/v1/devices", "@", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/devices", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/devices", "192.168.1.1:51234", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/devices/sda/selftest", "192.168.1.1:51234", http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(test.method, test.url, nil)
+		r.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("expected status %d for %s %s from %q, got %d", test.status, test.method, test.url, test.remoteAddr, w.Code)
+		}
+		if w.Code == http.StatusMethodNotAllowed && w.Header().Get("Allow") != http.MethodGet {
+			t.Errorf("expected to allow GET, got %q", w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestMatchDevice(t *testing.T) {
+	devices := []smart.Device{
+		{Name: "/dev/sda", Type: "sat"},
+		{Name: "/dev/bus/0", Type: "megaraid,0"},
+		{Name: "/dev/bus/0", Type: "megaraid,1"},
+		{Name: "/dev/nvme0", Type: "nvme"},
+	}
+	for _, test := range []struct {
+		dev        string
+		deviceType string
+		expected   string
+	}{
+		{"sda", "", "/dev/sda"},
+		{"/dev/sda", "", "/dev/sda"},
+		{"nvme0", "nvme", "/dev/nvme0"},
+		{"bus/0:megaraid,1", "", "/dev/bus/0:megaraid,1"},
+		{"/dev/bus/0:megaraid,0", "", "/dev/bus/0:megaraid,0"},
+		{"bus/0", "megaraid,1", "/dev/bus/0:megaraid,1"},
+	} {
+		device, err := matchDevice(devices, test.dev, test.deviceType)
+		if err != nil {
+			t.Errorf("unexpected error matching %s of type %q: %v", test.dev, test.deviceType, err)
+			continue
+		}
+		if device.Key() != test.expected {
+			t.Errorf("expected %s matching %s of type %q, got %s", test.expected, test.dev, test.deviceType, device.Key())
+		}
+	}
+
+	// the disks behind the controller share its name
+	_, err := matchDevice(devices, "bus/0", "")
+	ambiguous, ok := err.(*ambiguousDeviceError)
+	if !ok {
+		t.Fatalf("expected an ambiguous device, got %v", err)
+	}
+	if expected := []string{"/dev/bus/0:megaraid,0", "/dev/bus/0:megaraid,1"}; !reflect.DeepEqual(ambiguous.keys, expected) {
+		t.Errorf("expected the keys %v, got %v", expected, ambiguous.keys)
+	}
+	if status := findDeviceStatus(err); status != http.StatusConflict {
+		t.Errorf("expected status %d of an ambiguous device, got %d", http.StatusConflict, status)
+	}
+
+	for _, test := range []struct{ dev, deviceType string }{
+		{"sdb", ""},
+		{"sda", "nvme"},
+		{"bus/0", "megaraid,2"},
+	} {
+		_, err := matchDevice(devices, test.dev, test.deviceType)
+		if err == nil {
+			t.Errorf("expected %s of type %q not to be found", test.dev, test.deviceType)
+			continue
+		}
+		if status := findDeviceStatus(err); status != http.StatusNotFound {
+			t.Errorf("expected status %d of %s of type %q, got %d", http.StatusNotFound, test.dev, test.deviceType, status)
+		}
+	}
+}
+
+// fakeDeviceReader scans the devices, or fails with scanErr, and never
+// reads them, as they are all quarantined
+type fakeDeviceReader struct {
+	devices []smart.Device
+	scanErr error
+}
+
+func (f *fakeDeviceReader) ScannedDevices(ctx context.Context) ([]smart.Device, error) {
+	return f.devices, f.scanErr
+}
+
+func (f *fakeDeviceReader) WithDevice(d smart.Device, read func(opts *smart.Options) error) error {
+	return smart.ErrQuarantined
+}
+
+func TestAPIDeviceStatus(t *testing.T) {
+	devices := []smart.Device{
+		{Name: "/dev/sda", Type: "sat"},
+		{Name: "/dev/bus/0", Type: "megaraid,0"},
+		{Name: "/dev/bus/0", Type: "megaraid,1"},
+	}
+	for _, test := range []struct {
+		reader deviceReader
+		url    string
+		status int
+	}{
+		{nil, "/api/v1/devices", http.StatusNotImplemented},
+		{&fakeDeviceReader{scanErr: errors.New("exit status 2")}, "/api/v1/devices", http.StatusServiceUnavailable},
+		{&fakeDeviceReader{scanErr: errors.New("exit status 2")}, "/api/v1/devices/sda", http.StatusServiceUnavailable},
+		{&fakeDeviceReader{devices: devices}, "/api/v1/devices", http.StatusOK},
+		{&fakeDeviceReader{devices: devices}, "/api/v1/devices/sdb", http.StatusNotFound},
+		{&fakeDeviceReader{devices: devices}, "/api/v1/devices/bus/0", http.StatusConflict},
+		// the device found is quarantined
+		{&fakeDeviceReader{devices: devices}, "/api/v1/devices/bus/0?type=megaraid,1", http.StatusServiceUnavailable},
+	} {
+		w := httptest.NewRecorder()
+		(&api{devices: test.reader}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if w.Code != test.status {
+			t.Errorf("expected status %d for %s, got %d: %s", test.status, test.url, w.Code, w.Body)
+		}
+	}
+}
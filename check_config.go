@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/config"
+	"github.com/pgier/smartmon-exporter/smart"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	checkConfigCmd     = kingpin.Command("check-config", "Validate the configuration file and query the devices it names with smartctl, exiting non-zero on any problem.")
+	checkConfigOffline = checkConfigCmd.Flag("check-config.offline", "Only validate the configuration file, without looking for the devices it names.").Default("false").Bool()
+	checkConfigTimeout = checkConfigCmd.Flag("check-config.timeout", "Time to wait for each device to respond.").Default("30s").Duration()
+)
+
+// runCheckConfig validates the configuration file then, unless offline,
+// checks that the devices entries naming devices match existing devices
+// which respond to smartctl.  The problems are written to w and counted.
+func runCheckConfig(w io.Writer, filename string, opts *smart.Options, offline bool, timeout time.Duration) int {
+	cfg, err := config.LoadFile(filename)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", filename, err)
+		return 1
+	}
+	fmt.Fprintf(w, "%s: valid\n", filename)
+	if offline {
+		return 0
+	}
+	problems := 0
+	for i, entry := range cfg.Devices {
+		if entry.Name == "" {
+			continue
+		}
+		names, _ := filepath.Glob(entry.Name)
+		if len(names) == 0 {
+			fmt.Fprintf(w, "devices[%d]: no device matches %s, check the name or remove the entry\n", i, entry.Name)
+			problems++
+			continue
+		}
+		deviceOpts := *opts
+		if entry.Backend != "" {
+			deviceOpts.Backend = entry.Backend
+		}
+		if entry.Tolerance != "" {
+			deviceOpts.Tolerance = entry.Tolerance
+		}
+		if args := strings.Fields(entry.ExtraArgs); len(args) > 0 {
+			deviceOpts.ExtraArgs = append(append([]string{}, deviceOpts.ExtraArgs...), args...)
+		}
+		for _, name := range names {
+			d := smart.Device{Name: name, Type: entry.Type}
+			if d.Type == "" {
+				d.Type = "auto"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			info, err := d.Info(ctx, &deviceOpts)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(w, "devices[%d]: %s (-d %s) does not respond: %v\n", i, name, d.Type, err)
+				problems++
+				continue
+			}
+			if !info.Available {
+				fmt.Fprintf(w, "devices[%d]: %s (-d %s) responds but SMART is not available, check the type\n", i, name, d.Type)
+				problems++
+				continue
+			}
+			fmt.Fprintf(w, "devices[%d]: %s (-d %s) ok\n", i, name, d.Type)
+		}
+	}
+	return problems
+}
+
+// checkConfig runs the check-config command and exits
+func checkConfig(opts *smart.Options) {
+	if *configFile == "" {
+		fmt.Fprintln(os.Stderr, "check-config requires --config.file")
+		os.Exit(2)
+	}
+	if problems := runCheckConfig(os.Stdout, *configFile, opts, *checkConfigOffline, *checkConfigTimeout); problems > 0 {
+		fmt.Fprintln(os.Stderr, problems, "problems found")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
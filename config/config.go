@@ -0,0 +1,240 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config loads the YAML configuration file of the exporter
+package config
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/notify"
+	"github.com/pgier/smartmon-exporter/smart"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the configuration file of the exporter, e.g.
+//
+//	collect_standby: false
+//	collection_interval: 5m
+//	standby_interval: 6h
+//	rescan_interval: 1h
+//	concurrency: 4
+//	max_labels: 64
+//	max_series_per_device: 500
+//	canonical_attribute_names: true
+//	low_power: true
+//	standby_check_interval: 2h
+//	pause_on_battery: true
+//	skip_idle: 30m
+//	temperature_threshold: 55
+//	collectors:
+//	  attributes: true
+//	  selftest: true
+//	attribute_names:
+//	  202: percent_lifetime_remain
+//	raw_value_rules:
+//	  - model: ST*
+//	    id: 195
+//	    shift: 32
+//	    bits: 16
+//	    total_bits: 32
+//	devices:
+//	  - name: /dev/sd[ab]
+//	    collect_standby: true
+//	  - type: nvme
+//	    collection_interval: 1m
+//	  - type: sat
+//	    collection_interval: 10m
+//	    backend: native
+//	  - name: /dev/sdc
+//	    tolerance: permissive
+//	  - name: /dev/sdd
+//	    extra_args: -d sat,12 --nocheck=never
+//	  - name: /dev/bus/[01]
+//	    controller: megaraid
+//	notifications:
+//	  webhooks:
+//	    - url: https://hooks.example.com/smart
+//	mqtt:
+//	  broker: tcp://homeassistant.local:1883
+//	  on_change: true
+type Config struct {
+	// CollectStandby collects the metrics of devices in standby, waking them up
+	CollectStandby bool `yaml:"collect_standby"`
+	// CollectionInterval collects the metrics in the background on this
+	// interval instead of on every scrape
+	CollectionInterval time.Duration `yaml:"collection_interval"`
+	// StandbyInterval is the background collection interval of devices in
+	// standby or sleep
+	StandbyInterval time.Duration `yaml:"standby_interval"`
+	// RescanInterval reuses the devices found by a scan for this interval,
+	// unless a block device is added or removed
+	RescanInterval time.Duration `yaml:"rescan_interval"`
+	// Concurrency is the number of devices collected in parallel
+	Concurrency int `yaml:"concurrency"`
+	// MaxLabels limits the number of labels of the metrics
+	MaxLabels int `yaml:"max_labels"`
+	// MaxSeriesPerDevice limits the number of series collected from a device
+	MaxSeriesPerDevice int `yaml:"max_series_per_device"`
+	// LowPower never wakes up the devices in standby, checks their power
+	// mode on StandbyCheckInterval only and batches the smartctl commands
+	LowPower bool `yaml:"low_power"`
+	// StandbyCheckInterval is the interval of the power mode checks of the
+	// devices in standby in low power mode
+	StandbyCheckInterval time.Duration `yaml:"standby_check_interval"`
+	// PauseOnBattery pauses the collection of the devices on battery
+	PauseOnBattery bool `yaml:"pause_on_battery"`
+	// SkipIdle skips all the smartctl commands to the devices which were
+	// idle for this duration
+	SkipIdle time.Duration `yaml:"skip_idle"`
+	// TemperatureThreshold is the temperature in Celsius above which a
+	// device is over temperature
+	TemperatureThreshold float64 `yaml:"temperature_threshold"`
+	// HealthScore enables and weights smartmon_device_health_score
+	HealthScore HealthScore `yaml:"health_score"`
+	// LegacyMetricNames collects the metrics renamed after their base unit
+	// under their previous names too
+	LegacyMetricNames bool `yaml:"legacy_metric_names"`
+	// AttributeHistory keeps the last samples of the key attributes of the
+	// devices in memory for /api/v1/attributes/history
+	AttributeHistory AttributeHistory `yaml:"attribute_history"`
+	// FailureRisk collects the heuristic failure risk of the ATA devices
+	FailureRisk bool `yaml:"failure_risk"`
+	// AttributeIncreases counts the increases of the critical ATA attributes
+	AttributeIncreases bool `yaml:"attribute_increases"`
+	// CanonicalAttributeNames names the ATA attribute metrics after the
+	// attribute ID instead of the names reported for the drive
+	CanonicalAttributeNames bool `yaml:"canonical_attribute_names"`
+	// AttributeNames overrides the names of the ATA attribute metrics by ID
+	AttributeNames map[int]string `yaml:"attribute_names"`
+	// RawValueRules interpret the raw values of the ATA attributes
+	RawValueRules []smart.RawValueRule `yaml:"raw_value_rules"`
+	// Collectors enables or disables the collectors of device metrics
+	Collectors Collectors `yaml:"collectors"`
+	// Devices overrides the global settings for the matching devices
+	Devices []smart.DeviceOptions `yaml:"devices"`
+	// Notifications configures the receivers of the changes of the health
+	// of the devices
+	Notifications notify.Config `yaml:"notifications"`
+	// MQTT publishes the health of the devices to an MQTT broker
+	MQTT MQTT `yaml:"mqtt"`
+}
+
+// Collectors enables or disables the collectors of device metrics like the
+// --collector.* flags, the info and attributes are collected unless disabled
+type Collectors struct {
+	Info         *bool `yaml:"info"`
+	Attributes   *bool `yaml:"attributes"`
+	SelfTest     bool  `yaml:"selftest"`
+	ErrorLog     bool  `yaml:"errorlog"`
+	Security     bool  `yaml:"security"`
+	Capabilities bool  `yaml:"capabilities"`
+}
+
+// HealthScore enables the health score of the devices and overrides the
+// weights of its signals, e.g. crc_errors: 5
+type HealthScore struct {
+	Enabled bool               `yaml:"enabled"`
+	Weights map[string]float64 `yaml:"weights"`
+}
+
+// AttributeHistory keeps the last Size samples of the Attributes of every
+// device, smart.DefaultHistoryAttributes if empty.  No history is kept if
+// Size is 0.
+type AttributeHistory struct {
+	Size       int      `yaml:"size"`
+	Attributes []string `yaml:"attributes"`
+}
+
+// MQTT publishes the health and the temperature of the devices to an MQTT
+// broker, with the Home Assistant discovery of the devices as sensors.
+// Publishing is disabled if Broker is empty.
+type MQTT struct {
+	// Broker is the URL of the broker, e.g. tcp://localhost:1883 or
+	// ssl://broker.example.com:8883
+	Broker string `yaml:"broker"`
+	// ClientID defaults to smartmon-exporter-<hostname>
+	ClientID     string `yaml:"client_id"`
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file"`
+	// TopicPrefix prefixes the state topics, <prefix>/<hostname>/<device>,
+	// defaults to smartmon
+	TopicPrefix string `yaml:"topic_prefix"`
+	// DiscoveryPrefix is the prefix of the Home Assistant discovery topics,
+	// defaults to homeassistant
+	DiscoveryPrefix string `yaml:"discovery_prefix"`
+	// Interval is the interval on which the devices are read, defaults
+	// to 5m
+	Interval time.Duration `yaml:"interval"`
+	// OnChange only publishes the states which changed since they were last
+	// published instead of every state on every interval
+	OnChange bool `yaml:"on_change"`
+}
+
+// LoadFile reads and validates the configuration file
+func LoadFile(filename string) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Load(content)
+}
+
+// Load parses and validates the YAML configuration, unknown fields are
+// rejected to catch typos
+func Load(content []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// CollectorOptions returns the options of the smart.Collector configured
+// by the file
+func (c *Config) CollectorOptions() *smart.CollectorOptions {
+	return &smart.CollectorOptions{
+		CollectStandby:          c.CollectStandby,
+		CollectionInterval:      c.CollectionInterval,
+		StandbyInterval:         c.StandbyInterval,
+		RescanInterval:          c.RescanInterval,
+		Concurrency:             c.Concurrency,
+		MaxLabels:               c.MaxLabels,
+		MaxSeriesPerDevice:      c.MaxSeriesPerDevice,
+		LowPower:                c.LowPower,
+		StandbyCheckInterval:    c.StandbyCheckInterval,
+		PauseOnBattery:          c.PauseOnBattery,
+		SkipIdle:                c.SkipIdle,
+		TemperatureThreshold:    c.TemperatureThreshold,
+		HealthScore:             c.HealthScore.Enabled,
+		HealthScoreWeights:      c.HealthScore.Weights,
+		FailureRisk:             c.FailureRisk,
+		AttributeIncreases:      c.AttributeIncreases,
+		LegacyMetricNames:       c.LegacyMetricNames,
+		HistorySize:             c.AttributeHistory.Size,
+		HistoryAttributes:       c.AttributeHistory.Attributes,
+		CanonicalAttributeNames: c.CanonicalAttributeNames,
+		AttributeNames:          c.AttributeNames,
+		RawValueRules:           c.RawValueRules,
+		DisableInfo:             c.Collectors.Info != nil && !*c.Collectors.Info,
+		DisableAttributes:       c.Collectors.Attributes != nil && !*c.Collectors.Attributes,
+		SelfTests:               c.Collectors.SelfTest,
+		ErrorLog:                c.Collectors.ErrorLog,
+		Security:                c.Collectors.Security,
+		Capabilities:            c.Collectors.Capabilities,
+		Devices:                 c.Devices,
+	}
+}
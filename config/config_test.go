@@ -0,0 +1,193 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load([]byte(`
+collect_standby: false
+devices:
+  - name: /dev/sd[ab]
+    collect_standby: true
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	if len(cfg.Devices) != 1 || cfg.Devices[0].Name != "/dev/sd[ab]" {
+		t.Fatal("unexpected devices", cfg.Devices)
+	}
+	if cfg.Devices[0].CollectStandby == nil || !*cfg.Devices[0].CollectStandby {
+		t.Fatal("collect_standby should be set for", cfg.Devices[0].Name)
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	if _, err := Load([]byte("collect_stanby: true\n")); err == nil {
+		t.Fatal("expected an error loading an unknown field")
+	}
+}
+
+func TestLoadIntervals(t *testing.T) {
+	cfg, err := Load([]byte(`
+collection_interval: 5m
+standby_interval: 6h
+devices:
+  - type: nvme
+    collection_interval: 1m
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if opts.CollectionInterval != 5*time.Minute || opts.StandbyInterval != 6*time.Hour {
+		t.Fatal("unexpected intervals", opts.CollectionInterval, opts.StandbyInterval)
+	}
+	if len(opts.Devices) != 1 || opts.Devices[0].Type != "nvme" || opts.Devices[0].CollectionInterval != time.Minute {
+		t.Fatal("unexpected devices", opts.Devices)
+	}
+}
+
+func TestLoadAttributeNames(t *testing.T) {
+	cfg, err := Load([]byte(`
+canonical_attribute_names: true
+attribute_names:
+  202: percent_lifetime_remain
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if !opts.CanonicalAttributeNames || opts.AttributeNames[202] != "percent_lifetime_remain" {
+		t.Fatal("unexpected attribute names", opts.CanonicalAttributeNames, opts.AttributeNames)
+	}
+}
+
+func TestLoadRawValueRules(t *testing.T) {
+	cfg, err := Load([]byte(`
+raw_value_rules:
+  - model: ST*
+    id: 195
+    shift: 32
+    bits: 16
+    total_bits: 32
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	rules := cfg.CollectorOptions().RawValueRules
+	if len(rules) != 1 || rules[0].Model != "ST*" || rules[0].ID != 195 || rules[0].Shift != 32 || rules[0].Bits != 16 || rules[0].TotalBits != 32 {
+		t.Fatal("unexpected raw value rules", rules)
+	}
+}
+
+func TestLoadConcurrency(t *testing.T) {
+	cfg, err := Load([]byte(`
+concurrency: 4
+devices:
+  - name: /dev/bus/0
+    controller: megaraid
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if opts.Concurrency != 4 || opts.Devices[0].Controller != "megaraid" {
+		t.Fatal("unexpected options", opts)
+	}
+}
+
+func TestLoadCollectors(t *testing.T) {
+	cfg, err := Load([]byte(`
+collectors:
+  info: false
+  selftest: true
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if !opts.DisableInfo || opts.DisableAttributes || !opts.SelfTests || opts.ErrorLog {
+		t.Fatal("unexpected collectors", opts)
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	cfg, err := Load([]byte(`
+max_labels: 32
+max_series_per_device: 200
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if opts.MaxLabels != 32 || opts.MaxSeriesPerDevice != 200 {
+		t.Fatal("unexpected limits", opts.MaxLabels, opts.MaxSeriesPerDevice)
+	}
+	if _, err := Load([]byte("max_labels: -1\n")); err == nil {
+		t.Fatal("expected an error loading a negative limit")
+	}
+}
+
+func TestLoadLowPower(t *testing.T) {
+	cfg, err := Load([]byte(`
+low_power: true
+standby_check_interval: 2h
+pause_on_battery: true
+skip_idle: 30m
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if !opts.LowPower || opts.StandbyCheckInterval != 2*time.Hour || !opts.PauseOnBattery || opts.SkipIdle != 30*time.Minute {
+		t.Fatal("unexpected low power options", opts)
+	}
+}
+
+func TestLoadHealthScore(t *testing.T) {
+	cfg, err := Load([]byte(`
+health_score:
+  enabled: true
+  weights:
+    crc_errors: 5
+failure_risk: true
+attribute_increases: true
+legacy_metric_names: true
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if !opts.HealthScore || opts.HealthScoreWeights["crc_errors"] != 5 || !opts.FailureRisk || !opts.AttributeIncreases || !opts.LegacyMetricNames {
+		t.Fatal("unexpected health score options", opts)
+	}
+}
+
+func TestLoadAttributeHistory(t *testing.T) {
+	cfg, err := Load([]byte(`
+attribute_history:
+  size: 60
+  attributes: [reallocated_sector_ct, temperature]
+`))
+	if err != nil {
+		t.Fatal("unable to load config", err)
+	}
+	opts := cfg.CollectorOptions()
+	if opts.HistorySize != 60 || len(opts.HistoryAttributes) != 2 || opts.HistoryAttributes[1] != "temperature" {
+		t.Fatal("unexpected attribute history options", opts)
+	}
+}
@@ -0,0 +1,156 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+// rawValueBits is the size of the raw value of the ATA attributes
+const rawValueBits = 48
+
+// Validate checks the settings which parse but cannot work, e.g. a device
+// name which is not a valid pattern, and returns an error listing all of them
+func (c *Config) Validate() error {
+	problems := []string{}
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	intervals := map[string]time.Duration{
+		"collection_interval":    c.CollectionInterval,
+		"standby_interval":       c.StandbyInterval,
+		"standby_check_interval": c.StandbyCheckInterval,
+		"skip_idle":              c.SkipIdle,
+		"rescan_interval":        c.RescanInterval,
+	}
+	for name, interval := range intervals {
+		if interval < 0 {
+			problem("%s: %s is negative", name, interval)
+		}
+	}
+	if c.StandbyInterval > 0 && c.CollectionInterval == 0 {
+		problem("standby_interval: requires collection_interval to collect in the background")
+	}
+	if c.TemperatureThreshold < 0 {
+		problem("temperature_threshold: %v is negative", c.TemperatureThreshold)
+	}
+	if err := smart.ValidHealthScoreWeights(c.HealthScore.Weights); err != nil {
+		problem("health_score: %v", err)
+	}
+	limits := map[string]int{
+		"concurrency":           c.Concurrency,
+		"max_labels":            c.MaxLabels,
+		"max_series_per_device": c.MaxSeriesPerDevice,
+	}
+	for name, limit := range limits {
+		if limit < 0 {
+			problem("%s: %d is negative", name, limit)
+		}
+	}
+	if c.AttributeHistory.Size < 0 {
+		problem("attribute_history: size %d is negative", c.AttributeHistory.Size)
+	}
+	for id, name := range c.AttributeNames {
+		if id < 1 || id > 255 {
+			problem("attribute_names: %d is not an attribute ID, 1-255", id)
+		}
+		if name == "" {
+			problem("attribute_names: the name of %d is empty", id)
+		}
+	}
+	for i, rule := range c.RawValueRules {
+		if _, err := path.Match(rule.Model, ""); err != nil {
+			problem("raw_value_rules[%d]: invalid model pattern %q: %v", i, rule.Model, err)
+		}
+		if rule.ID < 1 || rule.ID > 255 {
+			problem("raw_value_rules[%d]: %d is not an attribute ID, 1-255", i, rule.ID)
+		}
+		if rule.Shift+rule.Bits > rawValueBits || rule.TotalShift+rule.TotalBits > rawValueBits {
+			problem("raw_value_rules[%d]: the bits selected exceed the %d bits of the raw value", i, rawValueBits)
+		}
+	}
+	for i, d := range c.Devices {
+		if _, err := path.Match(d.Name, ""); err != nil {
+			problem("devices[%d]: invalid name pattern %q: %v", i, d.Name, err)
+		}
+		if d.Name != "" && !strings.HasPrefix(d.Name, "/") {
+			problem("devices[%d]: name %q must be an absolute path, e.g. /dev/sda", i, d.Name)
+		}
+		if d.Backend != "" {
+			if _, err := smart.NewBackend(&smart.Options{Backend: d.Backend}); err != nil {
+				problem("devices[%d]: %v", i, err)
+			}
+		}
+		if d.Tolerance != "" {
+			if err := smart.ValidTolerance(d.Tolerance); err != nil {
+				problem("devices[%d]: %v", i, err)
+			}
+		}
+		if args := strings.Fields(d.ExtraArgs); len(args) > 0 && args[len(args)-1] == "-d" {
+			problem("devices[%d]: extra_args: -d requires a device type", i)
+		}
+		if d.CollectionInterval < 0 || d.StandbyInterval < 0 || d.SkipIdle < 0 {
+			problem("devices[%d]: the intervals must not be negative", i)
+		}
+		if d.TemperatureThreshold < 0 {
+			problem("devices[%d]: temperature_threshold: %v is negative", i, d.TemperatureThreshold)
+		}
+	}
+	for i, w := range c.Notifications.Webhooks {
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("notifications: webhooks[%d]: url %q is not an http or https URL", i, w.URL)
+		}
+		if w.Timeout < 0 {
+			problem("notifications: webhooks[%d]: timeout %s is negative", i, w.Timeout)
+		}
+	}
+	for i, e := range c.Notifications.Emails {
+		if _, _, err := net.SplitHostPort(e.Smarthost); err != nil {
+			problem("notifications: emails[%d]: smarthost %q must be host:port", i, e.Smarthost)
+		}
+		if e.From == "" || len(e.To) == 0 {
+			problem("notifications: emails[%d]: from and to are required", i)
+		}
+		if e.Username != "" && e.PasswordFile == "" {
+			problem("notifications: emails[%d]: username requires password_file", i)
+		}
+		if e.RepeatInterval < 0 {
+			problem("notifications: emails[%d]: repeat_interval %s is negative", i, e.RepeatInterval)
+		}
+	}
+	if c.MQTT.Broker != "" {
+		if u, err := url.Parse(c.MQTT.Broker); err != nil || u.Host == "" {
+			problem("mqtt: broker %q is not a URL, e.g. tcp://localhost:1883", c.MQTT.Broker)
+		} else if u.Scheme != "tcp" && u.Scheme != "ssl" && u.Scheme != "tls" && u.Scheme != "ws" && u.Scheme != "wss" {
+			problem("mqtt: broker %q: unsupported scheme %q, one of tcp, ssl, tls, ws, wss", c.MQTT.Broker, u.Scheme)
+		}
+		if c.MQTT.Username != "" && c.MQTT.PasswordFile == "" {
+			problem("mqtt: username requires password_file")
+		}
+		if c.MQTT.Interval < 0 {
+			problem("mqtt: interval %s is negative", c.MQTT.Interval)
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
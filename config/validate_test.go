@@ -0,0 +1,78 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	_, err := Load([]byte(`
+standby_interval: 1h
+concurrency: -1
+health_score:
+  weights:
+    wear: -1
+    heat: 10
+attribute_names:
+  300: foo
+attribute_history:
+  size: -1
+raw_value_rules:
+  - id: 1
+    shift: 32
+    bits: 32
+devices:
+  - name: /dev/sd[a
+  - name: sda
+  - type: sat
+    backend: ioctl
+  - type: scsi
+    tolerance: lenient
+  - type: usbjmicron
+    extra_args: -T permissive -d
+notifications:
+  webhooks:
+    - url: hooks.example.com/smart
+  emails:
+    - smarthost: smtp.example.com
+      to: [admin@example.com]
+mqtt:
+  broker: mqtt://localhost:1883
+`))
+	if err == nil {
+		t.Fatal("expected an invalid configuration")
+	}
+	for _, expected := range []string{
+		"standby_interval: requires collection_interval",
+		"concurrency: -1 is negative",
+		"health_score: ",
+		"attribute_names: 300 is not an attribute ID",
+		"attribute_history: size -1 is negative",
+		"raw_value_rules[0]: the bits selected exceed",
+		"devices[0]: invalid name pattern",
+		"devices[1]: name \"sda\" must be an absolute path",
+		"devices[2]: unknown backend: ioctl",
+		"devices[3]: unknown tolerance: lenient",
+		"devices[4]: extra_args: -d requires a device type",
+		"notifications: webhooks[0]: url \"hooks.example.com/smart\" is not an http or https URL",
+		"notifications: emails[0]: smarthost \"smtp.example.com\" must be host:port",
+		"notifications: emails[0]: from and to are required",
+		"mqtt: broker \"mqtt://localhost:1883\": unsupported scheme \"mqtt\"",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("expected", expected, "in", err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+#!/bin/sh
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Appends the warnings issued by smartd to the file read by the exporter
+# with --smartd.warnings-file, e.g. in smartd.conf
+#
+#   DEVICESCAN -m <nomailer> -M exec /usr/local/bin/smartd-warning.sh
+WARNINGS_FILE=${SMARTMON_WARNINGS_FILE:-/var/lib/smartmon-exporter/smartd-warnings}
+printf '%s\t%s\n' "$SMARTD_DEVICE" "$SMARTD_FAILTYPE" >> "$WARNINGS_FILE"
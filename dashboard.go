@@ -0,0 +1,169 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// dashboardPath serves the Grafana dashboard of the metrics
+const dashboardPath = "/dashboard.json"
+
+var dashboardCmd = kingpin.Command("dashboard", "Print a Grafana dashboard of the metrics as named with the current flags and configuration file, also served at "+dashboardPath+".")
+
+// dashboard is the subset of the Grafana dashboard model used
+type dashboard struct {
+	Title         string     `json:"title"`
+	UID           string     `json:"uid"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	Query      string `json:"query"`
+	Datasource string `json:"datasource,omitempty"`
+	Refresh    int    `json:"refresh,omitempty"`
+	Multi      bool   `json:"multi,omitempty"`
+	IncludeAll bool   `json:"includeAll,omitempty"`
+}
+
+type panel struct {
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Type        string      `json:"type"`
+	Datasource  string      `json:"datasource"`
+	GridPos     gridPos     `json:"gridPos"`
+	Targets     []target    `json:"targets"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+// dashboardSelector selects the disks chosen in the dashboard
+const dashboardSelector = `{disk=~"$disk"}`
+
+// grafanaDashboard returns the dashboard of the metrics.  The names of the
+// ATA attribute metrics depend on the options, like the alerting rules.
+func grafanaDashboard(collectorOpts *smart.CollectorOptions) dashboard {
+	attr := func(id int, name string, suffix string) string {
+		return collectorOpts.AttributeMetricName(smart.Attribute{ID: id, Name: name}) + suffix + dashboardSelector
+	}
+	type graph struct {
+		title, kind, unit, expr string
+	}
+	graphs := []graph{
+		{"Health", "stat", "bool_yes_no", "smartmon_device_smart_healthy" + dashboardSelector},
+		{"Temperature", "timeseries", "celsius", attr(194, "Temperature_Celsius", "_raw_value")},
+		{"Reallocated sectors", "timeseries", "short", attr(5, "Reallocated_Sector_Ct", "_raw_value")},
+		{"Pending sectors", "timeseries", "short", attr(197, "Current_Pending_Sector", "_raw_value")},
+		{"Power on hours", "timeseries", "h", attr(9, "Power_On_Hours", "_raw_value")},
+		{"SSD endurance left", "timeseries", "percent", attr(177, "Wear_Leveling_Count", "_value") + " or " + attr(233, "Media_Wearout_Indicator", "_value")},
+		{"NVMe data written", "timeseries", "Bps", "rate(smartmon_nvme_data_written_bytes_total" + dashboardSelector + "[5m])"},
+		{"Collector errors", "timeseries", "short", "smartmon_collector_error" + dashboardSelector},
+	}
+	if collectorOpts.DiskStats {
+		graphs = append(graphs,
+			graph{"Read latency", "timeseries", "s", "rate(smartmon_device_io_read_time_seconds_total" + dashboardSelector + "[5m]) / rate(smartmon_device_io_reads_completed_total" + dashboardSelector + "[5m])"},
+			graph{"Write latency", "timeseries", "s", "rate(smartmon_device_io_write_time_seconds_total" + dashboardSelector + "[5m]) / rate(smartmon_device_io_writes_completed_total" + dashboardSelector + "[5m])"})
+	}
+
+	panels := make([]panel, 0, len(graphs))
+	for i, g := range graphs {
+		panels = append(panels, panel{
+			ID:          i + 1,
+			Title:       g.title,
+			Type:        g.kind,
+			Datasource:  "$datasource",
+			GridPos:     gridPos{H: 8, W: 12, X: (i % 2) * 12, Y: (i / 2) * 8},
+			Targets:     []target{{RefID: "A", Expr: g.expr, LegendFormat: "{{disk}}"}},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Unit: g.unit}},
+		})
+	}
+	return dashboard{
+		Title:         "S.M.A.R.T.",
+		UID:           "smartmon",
+		SchemaVersion: 27,
+		Refresh:       "5m",
+		Time:          timeRange{From: "now-7d", To: "now"},
+		Templating: templating{List: []variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{Name: "disk", Label: "Disk", Type: "query", Query: "label_values(smartmon_device_smart_healthy, disk)", Datasource: "$datasource", Refresh: 2, Multi: true, IncludeAll: true},
+		}},
+		Panels: panels,
+	}
+}
+
+// writeDashboard writes the dashboard as JSON
+func writeDashboard(w io.Writer, collectorOpts *smart.CollectorOptions) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(grafanaDashboard(collectorOpts))
+}
+
+// dashboardHandler serves the dashboard
+func dashboardHandler(collectorOpts *smart.CollectorOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		writeDashboard(w, collectorOpts)
+	})
+}
+
+// printDashboard runs the dashboard command and exits
+func printDashboard(collectorOpts *smart.CollectorOptions) {
+	if err := writeDashboard(os.Stdout, collectorOpts); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to print the dashboard:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
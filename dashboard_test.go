@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// metricNameRegex matches the metric names of a query
+	metricNameRegex = regexp.MustCompile(`smartmon_[a-z0-9_]+`)
+	// descNameRegex matches the name of a descriptor
+	descNameRegex = regexp.MustCompile(`fqName: "([^"]*)"`)
+)
+
+// wearAttributes are the SSD wear attributes queried by the dashboard, which
+// the disk of the e2e outputs lacks
+const wearAttributes = `177 Wear_Leveling_Count     0x0013   095   095   000    Pre-fail  Always       -       57
+233 Media_Wearout_Indicator 0x0032   099   099   000    Old_age   Always       -       0
+`
+
+// fakeE2ESmartctl writes a smartctl answering with the outputs of the end to
+// end tests, see e2e/fakesmartctl, and the wear attributes for the SATA disk
+func fakeE2ESmartctl(t *testing.T, dir string) string {
+	e2e, err := filepath.Abs(filepath.Join("e2e", "testdata", "smartctl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributes, err := ioutil.ReadFile(filepath.Join(e2e, "12-e2e0-attributes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := filepath.Join(dir, "outputs")
+	if err := os.MkdirAll(outputs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(outputs, "12-e2e0-attributes"), append(bytes.TrimRight(attributes, "\n"), "\n"+wearAttributes...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "smartctl")
+	script := `#!/bin/sh
+for f in ` + outputs + `/* ` + e2e + `/*; do
+	pattern=$(sed -n 's/^# args: //p' "$f")
+	case "$*" in
+	$pattern)
+		status=$(sed -n 's/^# status: //p' "$f")
+		grep -v -e '^# args: ' -e '^# status: ' "$f"
+		exit ${status:-0} ;;
+	esac
+done
+echo "fakesmartctl: no output for $*"
+exit 1
+`
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// emittedMetrics returns the names of the metrics collected from the e2e
+// outputs and of the metrics described by the collector
+func emittedMetrics(t *testing.T, smartctl string, collectorOpts *smart.CollectorOptions) map[string]bool {
+	collector, err := smart.NewCollector(&smart.Options{SmartctlPath: smartctl}, collectorOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, mf := range families {
+		names[mf.GetName()] = true
+	}
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		collector.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		if match := descNameRegex.FindStringSubmatch(desc.String()); match != nil {
+			names[match[1]] = true
+		}
+	}
+	return names
+}
+
+func TestWriteDashboard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	smartctl := fakeE2ESmartctl(t, dir)
+
+	for name, collectorOpts := range map[string]*smart.CollectorOptions{
+		"default":                   {},
+		"legacy metric names":       {LegacyMetricNames: true},
+		"canonical attribute names": {CanonicalAttributeNames: true},
+		"disk stats":                {DiskStats: true},
+	} {
+		var out bytes.Buffer
+		if err := writeDashboard(&out, collectorOpts); err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(out.Bytes()) {
+			t.Fatalf("expected a valid JSON dashboard with %s, got %s", name, out.String())
+		}
+		var written dashboard
+		if err := json.Unmarshal(out.Bytes(), &written); err != nil {
+			t.Fatal(err)
+		}
+		if len(written.Panels) == 0 {
+			t.Fatalf("expected panels with %s", name)
+		}
+
+		emitted := emittedMetrics(t, smartctl, collectorOpts)
+		queries := []string{}
+		for _, v := range written.Templating.List {
+			queries = append(queries, v.Query)
+		}
+		for _, p := range written.Panels {
+			if len(p.Targets) == 0 {
+				t.Errorf("expected a query in panel %q with %s", p.Title, name)
+			}
+			for _, target := range p.Targets {
+				queries = append(queries, target.Expr)
+			}
+		}
+		for _, query := range queries {
+			for _, metric := range metricNameRegex.FindAllString(query, -1) {
+				if !emitted[metric] {
+					t.Errorf("the query %q references %s, which is not collected with %s", query, metric, name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	serveCmd = kingpin.Command("serve", "Serve the metrics, the default command.").Default()
+	debugCmd = kingpin.Command("debug", "Scan the devices, collect the metrics once and print them along with every smartctl command executed and its output, without starting the server.")
+)
+
+// invocations records the smartctl commands executed
+type invocations struct {
+	mtx  sync.Mutex
+	list []smart.Invocation
+}
+
+func (i *invocations) trace(inv smart.Invocation) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.list = append(i.list, inv)
+}
+
+// device returns the device queried by the command, the last argument of
+// the commands with a -d option, or "" for the other commands, e.g. the scan
+func device(inv smart.Invocation) string {
+	for _, arg := range inv.Args {
+		if arg == "-d" {
+			return inv.Args[len(inv.Args)-1]
+		}
+	}
+	return ""
+}
+
+// runDebug collects the metrics once and prints the smartctl commands
+// executed with their output, the time spent querying every device and the
+// metrics, to troubleshoot the parsing of the output of a device
+func runDebug(w io.Writer, opts *smart.Options, collectorOpts *smart.CollectorOptions) error {
+	recorded := &invocations{}
+	traced := *opts
+	traced.Trace = recorded.trace
+	// collect once on gather without side effects on the host
+	debugOpts := *collectorOpts
+	debugOpts.CollectionInterval = 0
+	debugOpts.DriveDBUpdateInterval = 0
+	debugOpts.StateFile = ""
+
+	collector, err := smart.NewCollector(&traced, &debugOpts)
+	if err != nil {
+		return err
+	}
+	defer collector.Close()
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		return err
+	}
+	start := time.Now()
+	families, gatherErr := registry.Gather()
+	elapsed := time.Since(start)
+
+	fmt.Fprintln(w, "# smartctl commands")
+	timing := map[string]time.Duration{}
+	for _, inv := range recorded.list {
+		fmt.Fprintf(w, "$ %s %s\n", inv.Command, strings.Join(inv.Args, " "))
+		status := fmt.Sprint(int(inv.Status))
+		if inv.Status != 0 {
+			status += " (" + inv.Status.String() + ")"
+		}
+		fmt.Fprintf(w, "# exit status %s in %s\n", status, inv.Duration)
+		if inv.Err != nil {
+			fmt.Fprintln(w, "# error:", inv.Err)
+		}
+		w.Write(inv.Output)
+		fmt.Fprintln(w)
+		timing[device(inv)] += inv.Duration
+	}
+
+	fmt.Fprintln(w, "# time spent by device")
+	devices := make([]string, 0, len(timing))
+	for d := range timing {
+		devices = append(devices, d)
+	}
+	sort.Strings(devices)
+	for _, d := range devices {
+		name := d
+		if name == "" {
+			name = "(global)"
+		}
+		fmt.Fprintf(w, "%-24s %s\n", name, timing[d])
+	}
+	fmt.Fprintf(w, "%-24s %s\n\n", "(total)", elapsed)
+
+	fmt.Fprintln(w, "# metrics")
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return gatherErr
+}
+
+// debug runs the debug command and exits
+func debug(opts *smart.Options, collectorOpts *smart.CollectorOptions) {
+	if err := runDebug(os.Stdout, opts, collectorOpts); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to collect the metrics:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
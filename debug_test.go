@@ -0,0 +1,82 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+func TestRunDebug(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a SATA disk in standby
+	path := filepath.Join(dir, "smartctl")
+	script := `#!/bin/sh
+case "$*" in
+-V) echo 'smartctl 7.1 2019-12-30 r5022 [x86_64-linux-5.4.0] (local build)' ;;
+*--scan*) echo '/dev/sda -d sat # /dev/sda, ATA device' ;;
+*) echo 'Device is in STANDBY mode, exit(2)'; exit 2 ;;
+esac
+`
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runDebug(&out, &smart.Options{SmartctlPath: path, DisableJSON: true}, &smart.CollectorOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	output := out.String()
+	commands := strings.Index(output, "# smartctl commands\n")
+	timing := strings.Index(output, "# time spent by device\n")
+	metrics := strings.Index(output, "# metrics\n")
+	if commands != 0 || timing < commands || metrics < timing {
+		t.Fatal("expected the commands, timing and metrics sections in order, got", output)
+	}
+
+	for _, expected := range []string{
+		"$ " + path + " -V\n",
+		"$ " + path + " --scan",
+		"/dev/sda -d sat # /dev/sda, ATA device\n",
+		"# exit status 2 (",
+		"Device is in STANDBY mode, exit(2)\n",
+	} {
+		if !strings.Contains(output[:timing], expected) {
+			t.Errorf("expected %q in the commands, got %s", expected, output[:timing])
+		}
+	}
+	for _, expected := range []string{"/dev/sda ", "(global) ", "(total) "} {
+		if !strings.Contains(output[timing:metrics], "\n"+expected) {
+			t.Errorf("expected the time of %q, got %s", expected, output[timing:metrics])
+		}
+	}
+	for _, expected := range []string{
+		"smartmon_exporter_privileged ",
+		"smartmon_device_node_readable{",
+		`disk="/dev/sda"`,
+	} {
+		if !strings.Contains(output[metrics:], expected) {
+			t.Errorf("expected %q in the metrics, got %s", expected, output[metrics:])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package e2e runs the exporter built from the tree against fakesmartctl and
+// compares the metrics scraped with golden files, so the exporter is tested
+// end to end without hardware.  Run with -update to rewrite the golden files.
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the metrics scraped")
+
+// ignoredFamilies are the metric families which depend on the host or the
+// build rather than on the output of smartctl
+var ignoredFamilies = regexp.MustCompile(`^(go_|process_|promhttp_|smartmon_exporter_build_info|smartmon_exporter_privileged|smartmon_collector_environment_info)`)
+
+// maskedRegex matches the value of the series which vary on every run, the
+// timestamps, the CPU times and the durations of the scan
+var maskedRegex = regexp.MustCompile(`^((?:\w+_timestamp_seconds|smartmon_smartctl_cpu_seconds_total|smartmon_device_scan_\w+_seconds)(?:\{.*\})?) \S+$`)
+
+// build builds the exporter and fakesmartctl, installed as smartctl, in dir
+func build(t *testing.T, dir string) string {
+	for pkg, name := range map[string]string{"..": "smartmon-exporter", "./fakesmartctl": "smartctl"} {
+		cmd := exec.Command("go", "build", "-o", filepath.Join(dir, name), pkg)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal("unable to build", pkg, err, string(output))
+		}
+	}
+	return filepath.Join(dir, "smartmon-exporter")
+}
+
+// freeAddress returns a local address nothing listens on
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// start runs the exporter with fakesmartctl serving the outputs of the
+// directory, first on the PATH
+func start(t *testing.T, dir, outputs, address string, args ...string) *exec.Cmd {
+	outputs, err := filepath.Abs(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(filepath.Join(dir, "smartmon-exporter"), append([]string{"--web.listen-address=" + address}, args...)...)
+	cmd.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"), "FAKE_SMARTCTL_DIR="+outputs)
+	if err := cmd.Start(); err != nil {
+		t.Fatal("unable to start the exporter", err)
+	}
+	return cmd
+}
+
+// scrape gets the metrics of the exporter, waiting for it to listen
+func scrape(t *testing.T, address string) []byte {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := http.Get("http://" + address + "/metrics")
+		if err == nil {
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatal("unable to scrape the exporter", resp.Status, err)
+			}
+			return body
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the exporter did not start", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// normalize drops the ignoredFamilies and masks the values varying on every
+// run
+func normalize(metrics []byte) []byte {
+	var normalized bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(metrics))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name := strings.TrimPrefix(strings.TrimPrefix(line, "# HELP "), "# TYPE ")
+		if ignoredFamilies.MatchString(name) {
+			continue
+		}
+		normalized.WriteString(maskedRegex.ReplaceAllString(line, "$1 MASKED"))
+		normalized.WriteByte('\n')
+	}
+	return normalized.Bytes()
+}
+
+func TestMetrics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the exporter")
+	}
+	dir, err := ioutil.TempDir("", "e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	build(t, dir)
+
+	for _, test := range []struct {
+		name    string
+		outputs string
+		args    []string
+	}{
+		{"smartctl", "testdata/smartctl", nil},
+		{"health", "testdata/smartctl", []string{"--smart.health-score", "--smart.failure-risk"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			address := freeAddress(t)
+			cmd := start(t, dir, test.outputs, address, test.args...)
+			defer func() {
+				cmd.Process.Kill()
+				cmd.Wait()
+			}()
+			metrics := normalize(scrape(t, address))
+			golden := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, metrics, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal("unable to read the golden file, run with -update to create it", err)
+			}
+			if !bytes.Equal(metrics, expected) {
+				t.Errorf("the metrics differ from %s, run with -update to accept them:\n%s", golden, metrics)
+			}
+		})
+	}
+}
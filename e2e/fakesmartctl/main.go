@@ -0,0 +1,129 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// fakesmartctl answers the smartctl commands of the exporter with canned
+// outputs, so the exporter can be tested end to end without hardware.
+//
+// The outputs are the files of the directory named by FAKE_SMARTCTL_DIR,
+// tried in name order.  A file starts with the arguments it answers, a shell
+// pattern matched against the arguments joined with spaces, optionally
+// followed by the exit status, e.g.
+//
+//	# args: -i -H -A * /dev/e2e0
+//	# status: 4
+//	smartctl 7.1 2019-12-30 r5022 [x86_64-linux-5.4.0] (local build)
+//	...
+//
+// The arguments of every command are appended to FAKE_SMARTCTL_LOG if set.
+// A command no file answers fails with the exit status of an invalid
+// command line, like the options unknown to the smartctl emulated.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	argsHeader   = "# args: "
+	statusHeader = "# status: "
+)
+
+// response is the canned output of the commands matching args
+type response struct {
+	args   string
+	status int
+	output []byte
+}
+
+// parseResponse reads the headers and the output of a file
+func parseResponse(content []byte) (*response, error) {
+	r := &response{}
+	reader := bufio.NewReader(bytes.NewReader(content))
+	for {
+		line, err := reader.ReadString('\n')
+		switch {
+		case strings.HasPrefix(line, argsHeader):
+			r.args = strings.TrimSpace(strings.TrimPrefix(line, argsHeader))
+		case strings.HasPrefix(line, statusHeader):
+			status, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, statusHeader)))
+			if err != nil {
+				return nil, err
+			}
+			r.status = status
+		default:
+			rest, _ := ioutil.ReadAll(reader)
+			r.output = append([]byte(line), rest...)
+			if r.args == "" {
+				return nil, fmt.Errorf("missing %q header", strings.TrimSpace(argsHeader))
+			}
+			return r, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("missing output after the headers")
+		}
+	}
+}
+
+// respond finds the response to the arguments in the directory
+func respond(dir string, args string) (*response, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		r, err := parseResponse(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if matched, err := path.Match(r.args, args); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		} else if matched {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func main() {
+	args := strings.Join(os.Args[1:], " ")
+	if log := os.Getenv("FAKE_SMARTCTL_LOG"); log != "" {
+		if f, err := os.OpenFile(log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			fmt.Fprintln(f, args)
+			f.Close()
+		}
+	}
+	r, err := respond(os.Getenv("FAKE_SMARTCTL_DIR"), args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fakesmartctl:", err)
+		os.Exit(1)
+	}
+	if r == nil {
+		fmt.Println("fakesmartctl: no output for", args)
+		os.Exit(1)
+	}
+	os.Stdout.Write(r.output)
+	os.Exit(r.status)
+}
@@ -0,0 +1,269 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/pgier/smartmon-exporter/smartpb"
+	"github.com/prometheus/common/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// defaultWatchInterval is the interval of WatchDevices if none is requested
+const defaultWatchInterval = time.Minute
+
+var (
+	grpcListenAddress    = kingpin.Flag("grpc.listen-address", "Address on which to expose the gRPC service, disabled if empty.  It is served with the --web.tls.* certificates and the --web.allowed-cidr and --web.rate-limit access control of the HTTP server.").Default("").String()
+	grpcMinWatchInterval = kingpin.Flag("grpc.min-watch-interval", "Shortest interval of the updates of WatchDevices, the shorter intervals requested are raised to it.").Default("1m").Duration()
+	grpcAllowWake        = kingpin.Flag("grpc.allow-wake", "Allow the gRPC clients to wake up the devices in standby to read them.").Default("false").Bool()
+)
+
+// grpcServer implements the smartmon.v1.SmartMon gRPC service
+type grpcServer struct {
+	smartpb.UnimplementedSmartMonServer
+	devices deviceReader
+	// minInterval is the shortest interval of WatchDevices, and allowWake
+	// allows the clients to wake up the devices
+	minInterval time.Duration
+	allowWake   bool
+}
+
+// serveGRPC serves the gRPC service on the gRPC listen address until the
+// returned server is stopped, reading the devices through devices.  The
+// service is served over TLS with the certificates of the reloader unless
+// nil, to the clients in the networks up to the rate of the limiter, as
+// the HTTP server.
+func serveGRPC(devices deviceReader, reloader *tlsReloader, networks []*net.IPNet, limiter *rateLimiter) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", *grpcListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := grpcAccess(ctx, networks, limiter); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := grpcAccess(stream.Context(), networks, limiter); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+	if reloader != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(reloader.tlsConfig("h2"))))
+	}
+	server := grpc.NewServer(serverOpts...)
+	smartpb.RegisterSmartMonServer(server, &grpcServer{
+		devices:     devices,
+		minInterval: *grpcMinWatchInterval,
+		allowWake:   *grpcAllowWake,
+	})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Errorln("gRPC server failed:", err)
+		}
+	}()
+	return server, nil
+}
+
+// grpcAccess returns the error of a call of a client outside the networks,
+// all of them if networks is empty, or beyond the rate of the limiter for
+// the client, which is unlimited if nil, as accessHandler
+func grpcAccess(ctx context.Context, networks []*net.IPNet, limiter *rateLimiter) error {
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	if len(networks) > 0 && !allowedAddr(addr, networks) {
+		requestsRejected.WithLabelValues("network").Inc()
+		return status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	if limiter != nil {
+		if ok, wait := limiter.allow(remoteHost(addr), time.Now()); !ok {
+			requestsRejected.WithLabelValues("rate_limit").Inc()
+			return status.Error(codes.ResourceExhausted, "Too many requests, retry after "+wait.String())
+		}
+	}
+	return nil
+}
+
+// checkWake returns the error of a request to wake up the devices unless
+// the server allows it
+func (s *grpcServer) checkWake(wake bool) error {
+	if wake && !s.allowWake {
+		return status.Error(codes.PermissionDenied, "Waking up the devices requires --grpc.allow-wake")
+	}
+	return nil
+}
+
+// ListDevices lists the scanned devices and their power mode, which is
+// empty for the quarantined devices
+func (s *grpcServer) ListDevices(ctx context.Context, req *smartpb.ListDevicesRequest) (*smartpb.ListDevicesResponse, error) {
+	devices, err := s.devices.ScannedDevices(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, "Unable to scan devices: "+err.Error())
+	}
+	response := &smartpb.ListDevicesResponse{}
+	for _, d := range devices {
+		var mode smart.PowerMode
+		s.devices.WithDevice(d, func(opts *smart.Options) error {
+			mode, _ = d.PowerMode(ctx, opts)
+			return nil
+		})
+		response.Devices = append(response.Devices, toProtoDevice(d, mode))
+	}
+	return response, nil
+}
+
+// GetDevice reads the info and attributes of a device
+func (s *grpcServer) GetDevice(ctx context.Context, req *smartpb.GetDeviceRequest) (*smartpb.DeviceHealth, error) {
+	if err := s.checkWake(req.Wake); err != nil {
+		return nil, err
+	}
+	device, err := findDevice(ctx, s.devices, req.Name, "")
+	if err != nil {
+		return nil, status.Error(findDeviceCode(err), err.Error())
+	}
+	response, err := s.readDevice(ctx, device, req.Wake)
+	if err != nil {
+		return nil, err
+	}
+	return toProtoHealth(response), nil
+}
+
+// readDevice reads the device with its options, the error is a gRPC status
+func (s *grpcServer) readDevice(ctx context.Context, device *smart.Device, wake bool) (*deviceResponse, error) {
+	var response *deviceResponse
+	err := s.devices.WithDevice(*device, func(opts *smart.Options) error {
+		var err error
+		response, err = readDevice(ctx, opts, device, wake)
+		return err
+	})
+	if err == smart.ErrQuarantined {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return response, nil
+}
+
+// findDeviceCode returns the gRPC code of an error of findDevice
+func findDeviceCode(err error) codes.Code {
+	switch err.(type) {
+	case *ambiguousDeviceError:
+		return codes.InvalidArgument
+	case *scanError:
+		return codes.Unavailable
+	}
+	return codes.NotFound
+}
+
+// WatchDevices sends the health of every device on the requested interval,
+// no shorter than the minimum interval of the server, until the client
+// cancels the stream.  Devices which cannot be read are skipped until the
+// next interval.
+func (s *grpcServer) WatchDevices(req *smartpb.WatchDevicesRequest, stream smartpb.SmartMon_WatchDevicesServer) error {
+	if err := s.checkWake(req.Wake); err != nil {
+		return err
+	}
+	interval := s.watchInterval(req.IntervalSeconds)
+	ctx := stream.Context()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		devices, err := s.devices.ScannedDevices(ctx)
+		if err != nil {
+			log.Errorln("Unable to scan devices:", err)
+		}
+		for i := range devices {
+			response, err := s.readDevice(ctx, &devices[i], req.Wake)
+			if err != nil {
+				log.Errorln("Unable to read", devices[i].Name+":", err)
+				continue
+			}
+			if err := stream.Send(toProtoHealth(response)); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// watchInterval returns the interval of WatchDevices, the default if none
+// is requested, raised to the minimum interval of the server
+func (s *grpcServer) watchInterval(seconds uint32) time.Duration {
+	interval := time.Duration(seconds) * time.Second
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	if interval < s.minInterval {
+		interval = s.minInterval
+	}
+	return interval
+}
+
+// toProtoDevice converts the device and its power mode
+func toProtoDevice(d smart.Device, mode smart.PowerMode) *smartpb.Device {
+	return &smartpb.Device{
+		Name:      d.Name,
+		InfoName:  d.InfoName,
+		Type:      d.Type,
+		Protocol:  d.Protocol,
+		PowerMode: string(mode),
+	}
+}
+
+// toProtoHealth converts the device read for the API
+func toProtoHealth(r *deviceResponse) *smartpb.DeviceHealth {
+	health := &smartpb.DeviceHealth{
+		Device:      toProtoDevice(r.Device, r.PowerMode),
+		TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if r.Info != nil {
+		health.Info = &smartpb.DeviceInfo{
+			Available:  r.Info.Available,
+			Enabled:    r.Info.Enabled,
+			Healthy:    r.Info.Healthy,
+			Attributes: r.Info.Attributes,
+		}
+	}
+	for _, attr := range r.Attributes {
+		health.Attributes = append(health.Attributes, &smartpb.Attribute{
+			Id:         int32(attr.ID),
+			Name:       attr.Name,
+			Flags:      attr.Flags,
+			Value:      attr.Value,
+			Worst:      attr.Worst,
+			Threshold:  attr.Threshold,
+			WhenFailed: attr.WhenFailed,
+			Raw:        attr.Raw,
+			RawString:  attr.RawString,
+		})
+	}
+	return health
+}
@@ -0,0 +1,216 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/pgier/smartmon-exporter/smartpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// standbyReader reads the devices with a fake smartctl reporting them in
+// standby
+type standbyReader struct {
+	devices []smart.Device
+	opts    *smart.Options
+}
+
+func (r *standbyReader) ScannedDevices(ctx context.Context) ([]smart.Device, error) {
+	return r.devices, nil
+}
+
+func (r *standbyReader) WithDevice(d smart.Device, read func(opts *smart.Options) error) error {
+	return read(r.opts)
+}
+
+// newStandbyReader returns a standbyReader of the devices and the function
+// removing its fake smartctl
+func newStandbyReader(t *testing.T, devices []smart.Device) (*standbyReader, func()) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\necho 'Device is in STANDBY mode, exit(2)'\nexit 2\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	opts := &smart.Options{SmartctlPath: path, DisableJSON: true}
+	return &standbyReader{devices: devices, opts: opts}, func() { os.RemoveAll(dir) }
+}
+
+// grpcDevices are a SATA disk and two disks behind a RAID controller
+var grpcDevices = []smart.Device{
+	{Name: "/dev/sda", Type: "sat"},
+	{Name: "/dev/bus/0", Type: "megaraid,0"},
+	{Name: "/dev/bus/0", Type: "megaraid,1"},
+}
+
+func TestGRPCListDevices(t *testing.T) {
+	reader, cleanup := newStandbyReader(t, grpcDevices)
+	defer cleanup()
+	s := &grpcServer{devices: reader}
+	response, err := s.ListDevices(context.Background(), &smartpb.ListDevicesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Devices) != len(grpcDevices) {
+		t.Fatalf("expected %d devices, got %d", len(grpcDevices), len(response.Devices))
+	}
+	for i, d := range response.Devices {
+		if d.Name != grpcDevices[i].Name || d.Type != grpcDevices[i].Type || d.PowerMode != string(smart.PowerModeStandby) {
+			t.Errorf("expected %s of type %s in standby, got %+v", grpcDevices[i].Name, grpcDevices[i].Type, d)
+		}
+	}
+
+	s = &grpcServer{devices: &fakeDeviceReader{scanErr: errors.New("exit status 2")}}
+	if _, err := s.ListDevices(context.Background(), &smartpb.ListDevicesRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected %s, got %v", codes.Unavailable, err)
+	}
+}
+
+func TestGRPCGetDevice(t *testing.T) {
+	reader, cleanup := newStandbyReader(t, grpcDevices)
+	defer cleanup()
+	health, err := (&grpcServer{devices: reader}).GetDevice(context.Background(), &smartpb.GetDeviceRequest{Name: "sda"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the device in standby is not woken up to read its info
+	if health.Device.Name != "/dev/sda" || health.Device.PowerMode != string(smart.PowerModeStandby) || health.Info != nil {
+		t.Errorf("expected /dev/sda in standby without info, got %+v", health)
+	}
+
+	for _, test := range []struct {
+		server *grpcServer
+		req    *smartpb.GetDeviceRequest
+		code   codes.Code
+	}{
+		{&grpcServer{devices: reader}, &smartpb.GetDeviceRequest{Name: "sda", Wake: true}, codes.PermissionDenied},
+		{&grpcServer{devices: reader}, &smartpb.GetDeviceRequest{Name: "sdb"}, codes.NotFound},
+		{&grpcServer{devices: reader}, &smartpb.GetDeviceRequest{Name: "bus/0"}, codes.InvalidArgument},
+		{&grpcServer{devices: &fakeDeviceReader{scanErr: errors.New("exit status 2")}}, &smartpb.GetDeviceRequest{Name: "sda"}, codes.Unavailable},
+		{&grpcServer{devices: &fakeDeviceReader{devices: grpcDevices}}, &smartpb.GetDeviceRequest{Name: "sda"}, codes.Unavailable},
+	} {
+		if _, err := test.server.GetDevice(context.Background(), test.req); status.Code(err) != test.code {
+			t.Errorf("expected %s getting %+v, got %v", test.code, test.req, err)
+		}
+	}
+
+	// the server may allow the clients to wake up the devices
+	if _, err := (&grpcServer{devices: reader, allowWake: true}).GetDevice(context.Background(), &smartpb.GetDeviceRequest{Name: "sda", Wake: true}); status.Code(err) == codes.PermissionDenied {
+		t.Errorf("expected waking up the device to be allowed, got %v", err)
+	}
+}
+
+// fakeWatchStream records the health sent and cancels the stream once
+// limit were sent
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	sent   []*smartpb.DeviceHealth
+	limit  int
+}
+
+func (f *fakeWatchStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeWatchStream) Send(health *smartpb.DeviceHealth) error {
+	f.sent = append(f.sent, health)
+	if len(f.sent) == f.limit {
+		f.cancel()
+	}
+	return nil
+}
+
+func TestGRPCWatchDevices(t *testing.T) {
+	reader, cleanup := newStandbyReader(t, grpcDevices[:1])
+	defer cleanup()
+	s := &grpcServer{devices: reader, minInterval: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeWatchStream{ctx: ctx, cancel: cancel, limit: 1}
+	done := make(chan error)
+	go func() {
+		done <- s.WatchDevices(&smartpb.WatchDevicesRequest{IntervalSeconds: 1}, stream)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the stream to end once cancelled")
+	}
+	if len(stream.sent) != 1 || stream.sent[0].Device.Name != "/dev/sda" {
+		t.Errorf("expected the health of /dev/sda, got %v", stream.sent)
+	}
+
+	err := s.WatchDevices(&smartpb.WatchDevicesRequest{Wake: true}, &fakeWatchStream{ctx: context.Background()})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected %s waking up the devices, got %v", codes.PermissionDenied, err)
+	}
+}
+
+func TestGRPCWatchInterval(t *testing.T) {
+	s := &grpcServer{minInterval: 30 * time.Second}
+	for seconds, expected := range map[uint32]time.Duration{
+		0:   defaultWatchInterval,
+		1:   30 * time.Second,
+		300: 5 * time.Minute,
+	} {
+		if interval := s.watchInterval(seconds); interval != expected {
+			t.Errorf("expected an interval of %s for %ds, got %s", expected, seconds, interval)
+		}
+	}
+}
+
+func TestGRPCAccess(t *testing.T) {
+	networks, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := newRateLimiter(1, 1)
+	from := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 51234}})
+	}
+	for _, test := range []struct {
+		ctx  context.Context
+		code codes.Code
+	}{
+		{from("192.168.1.1"), codes.PermissionDenied},
+		{from("10.1.2.3"), codes.OK},
+		{from("10.1.2.3"), codes.ResourceExhausted},
+		{from("10.1.2.4"), codes.OK},
+	} {
+		if err := grpcAccess(test.ctx, networks, limiter); status.Code(err) != test.code {
+			t.Errorf("expected %s, got %v", test.code, err)
+		}
+	}
+}
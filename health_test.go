@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// readyCollector collects nothing and is ready unless err is set
+type readyCollector struct {
+	err error
+}
+
+func (c *readyCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *readyCollector) Collect(ch chan<- prometheus.Metric) {}
+
+func (c *readyCollector) Ready(ctx context.Context) error {
+	return c.err
+}
+
+func (c *readyCollector) Close() {}
+
+func TestHealthyHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthyHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/healthy", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Healthy" {
+		t.Fatalf("expected healthy, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	c := &readyCollector{err: errors.New("smartctl not found")}
+	h := readyHandler(c)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "smartctl not found") {
+		t.Fatalf("expected not ready, got %d %q", w.Code, w.Body.String())
+	}
+
+	c.err = nil
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Ready" {
+		t.Fatalf("expected ready, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,167 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	influxDBURL       = kingpin.Flag("influxdb.url", "Send metrics as line protocol to the InfluxDB 2 API at this URL, e.g. http://localhost:8086, instead of serving them over HTTP.").Default("").String()
+	influxDBOrg       = kingpin.Flag("influxdb.org", "Organization of the InfluxDB bucket.").Default("").String()
+	influxDBBucket    = kingpin.Flag("influxdb.bucket", "Bucket receiving the metrics, a database/retention-policy of InfluxDB 1.8.").Default("smartmon").String()
+	influxDBTokenFile = kingpin.Flag("influxdb.token-file", "File containing the API token authorizing the writes to the bucket.").Default("").String()
+	influxDBInsecure  = kingpin.Flag("influxdb.tls.insecure-skip-verify", "Disable verification of the InfluxDB certificate.").Default("false").Bool()
+	influxDBInterval  = kingpin.Flag("influxdb.interval", "Interval between sends, 0 sends once and exits.").Default("0s").Duration()
+	influxDBTimeout   = kingpin.Flag("influxdb.timeout", "Timeout of a request to InfluxDB.").Default("30s").Duration()
+)
+
+// influxDBWriter sends the gathered metrics to the write API of InfluxDB
+type influxDBWriter struct {
+	url      string
+	token    string
+	client   *http.Client
+	gatherer prometheus.Gatherer
+}
+
+// newInfluxDBWriter creates a writer configured by the influxdb flags
+func newInfluxDBWriter(gatherer prometheus.Gatherer) (*influxDBWriter, error) {
+	cfg := config.HTTPClientConfig{
+		TLSConfig: config.TLSConfig{InsecureSkipVerify: *influxDBInsecure},
+	}
+	client, err := config.NewClientFromConfig(cfg, "influxdb", false, false)
+	if err != nil {
+		return nil, err
+	}
+	token := ""
+	if *influxDBTokenFile != "" {
+		content, err := ioutil.ReadFile(*influxDBTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	params := url.Values{}
+	params.Set("bucket", *influxDBBucket)
+	if *influxDBOrg != "" {
+		params.Set("org", *influxDBOrg)
+	}
+	params.Set("precision", "ms")
+	return &influxDBWriter{
+		url:      strings.TrimSuffix(*influxDBURL, "/") + "/api/v2/write?" + params.Encode(),
+		token:    token,
+		client:   client,
+		gatherer: gatherer,
+	}, nil
+}
+
+// runInfluxDB sends the metrics once, or on every interval until the
+// exporter is terminated
+func runInfluxDB(gatherer prometheus.Gatherer) error {
+	writer, err := newInfluxDBWriter(gatherer)
+	if err != nil {
+		return err
+	}
+	return runPeriodically(*influxDBInterval, writer.send, *influxDBURL)
+}
+
+// send gathers the metrics and writes them as line protocol
+func (w *influxDBWriter) send() error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	data := toLineProtocol(toWriteRequest(families, time.Now()).Timeseries)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *influxDBTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		httpReq.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// toLineProtocol formats the series as InfluxDB line protocol, a point per
+// series measured by the metric name, tagged with the labels and with the
+// sample as its value field, e.g.
+//
+//	smartmon_device_smart_healthy,disk=/dev/sda,type=sat value=1 1600000000000
+//
+// The empty labels, which InfluxDB rejects as tags, and the NaN and
+// infinite values, which fields cannot represent, are left out.
+func toLineProtocol(series []prompb.TimeSeries) []byte {
+	var buf bytes.Buffer
+	for _, ts := range series {
+		value := ts.Samples[0].Value
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		measurement, tags := "", ""
+		for _, l := range ts.Labels {
+			switch {
+			case l.Name == model.MetricNameLabel:
+				measurement = escapeLineProtocol(l.Value, false)
+			case l.Value != "":
+				tags += "," + escapeLineProtocol(l.Name, true) + "=" + escapeLineProtocol(l.Value, true)
+			}
+		}
+		buf.WriteString(measurement)
+		buf.WriteString(tags)
+		buf.WriteString(" value=")
+		buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		buf.WriteString(" ")
+		buf.WriteString(strconv.FormatInt(ts.Samples[0].Timestamp, 10))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// escapeLineProtocol escapes the commas and spaces of a measurement, and
+// also the equal signs of a tag key or value
+func escapeLineProtocol(s string, tag bool) string {
+	replacements := []string{",", `\,`, " ", `\ `, "\n", `\n`}
+	if tag {
+		replacements = append(replacements, "=", `\=`)
+	}
+	return strings.NewReplacer(replacements...).Replace(s)
+}
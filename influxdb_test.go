@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeLineProtocol(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		tag      bool
+		expected string
+	}{
+		{"smartmon_device_info", false, "smartmon_device_info"},
+		{"a,b c=d", false, `a\,b\ c=d`},
+		{"a,b c=d", true, `a\,b\ c\=d`},
+		{"ST4000DM000 1F2168", true, `ST4000DM000\ 1F2168`},
+		{"line\nbreak", true, `line\nbreak`},
+	} {
+		if escaped := escapeLineProtocol(test.s, test.tag); escaped != test.expected {
+			t.Errorf("expected %q escaped as %q, got %q", test.s, test.expected, escaped)
+		}
+	}
+}
+
+func TestToLineProtocol(t *testing.T) {
+	families := parseFamilies(t, `# TYPE smartmon_device_info gauge
+smartmon_device_info{disk="/dev/sda",model="ST4000DM000 1F2168",serial="",firmware="CC54,x=1"} 1
+# TYPE smartmon_temperature_celsius_raw_value gauge
+smartmon_temperature_celsius_raw_value{disk="/dev/sda"} NaN
+# TYPE smartmon_power_on_hours_raw_value gauge
+smartmon_power_on_hours_raw_value{disk="/dev/sda"} 25811
+`)
+	// the empty serial and the NaN are left out
+	expected := `smartmon_device_info,disk=/dev/sda,firmware=CC54\,x\=1,model=ST4000DM000\ 1F2168 value=1 1700000000000
+smartmon_power_on_hours_raw_value,disk=/dev/sda value=25811 1700000000000
+`
+	series := toWriteRequest(families, time.Unix(1700000000, 0)).Timeseries
+	if lines := string(toLineProtocol(series)); lines != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, lines)
+	}
+}
+
+func TestInfluxDBWriterSend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var authorization, query, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		authorization, query, body = r.Header.Get("Authorization"), r.URL.RawQuery, string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	*influxDBURL, *influxDBOrg, *influxDBBucket, *influxDBTokenFile = server.URL+"/", "home", "smartmon", tokenFile
+
+	families := parseFamilies(t, `# TYPE smartmon_devices_total gauge
+smartmon_devices_total 2
+`)
+	writer, err := newInfluxDBWriter(staticGatherer(families))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.send(); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Token secret" {
+		t.Errorf("expected the token, got %q", authorization)
+	}
+	if query != "bucket=smartmon&org=home&precision=ms" {
+		t.Errorf("unexpected query %q", query)
+	}
+	if !strings.HasPrefix(body, "smartmon_devices_total value=2 ") {
+		t.Errorf("unexpected body %q", body)
+	}
+}
@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	nodeName    = kingpin.Flag("metrics.node-name", "Value of the node label added to every smartmon metric, e.g. the Kubernetes node name exposed through the downward API.").Envar("NODE_NAME").Default("").String()
+	extraLabels = kingpin.Flag("metrics.label", "Label added to every smartmon metric as name=value. May be repeated.").StringMap()
+	labelsFile  = kingpin.Flag("metrics.labels-file", "File of labels added to every smartmon metric, in the name=\"value\" format of the Kubernetes downward API, e.g. the pod labels.").Default("").String()
+)
+
+// constLabels returns the labels added to every smartmon metric, the labels
+// of the labels file are overridden by the --metrics.label flags and the
+// node label
+func constLabels() (prometheus.Labels, error) {
+	labels := prometheus.Labels{}
+	if *labelsFile != "" {
+		f, err := os.Open(*labelsFile)
+		if err != nil {
+			return nil, errors.New("Unable to open labels file: " + err.Error())
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				return nil, errors.New("Unable to parse labels file line: " + line)
+			}
+			value, err := strconv.Unquote(parts[1])
+			if err != nil {
+				return nil, errors.New("Unable to parse labels file line: " + line)
+			}
+			labels[labelName(parts[0])] = value
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, errors.New("Unable to read labels file: " + err.Error())
+		}
+	}
+	for name, value := range *extraLabels {
+		labels[labelName(name)] = value
+	}
+	if *nodeName != "" {
+		labels["node"] = *nodeName
+	}
+	return labels, nil
+}
+
+// labelName converts a Kubernetes label key, e.g. app.kubernetes.io/name,
+// to a Prometheus label name
+func labelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
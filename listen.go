@@ -0,0 +1,56 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixScheme prefixes the listen addresses of unix sockets
+const unixScheme = "unix://"
+
+// listen opens a listener for every address, host:port for TCP or
+// unix:///path/to/socket for a unix socket.  A socket file left over by a
+// previous run is replaced.  The listeners already opened are closed on
+// error.
+func listen(addresses []string) ([]net.Listener, error) {
+	listeners := []net.Listener{}
+	for _, address := range addresses {
+		network := "tcp"
+		if strings.HasPrefix(address, unixScheme) {
+			network = "unix"
+			address = strings.TrimPrefix(address, unixScheme)
+			if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+				closeListeners(listeners)
+				return nil, errors.New("Unable to remove socket " + address + ": " + err.Error())
+			}
+		}
+		l, err := net.Listen(network, address)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// closeListeners closes the listeners
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "smartmon.sock")
+	// left over by a previous run
+	if err := ioutil.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	listeners, err := listen([]string{"127.0.0.1:0", unixScheme + socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(listeners)
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	for i, network := range []string{"tcp", "unix"} {
+		l := listeners[i]
+		if l.Addr().Network() != network {
+			t.Errorf("expected listener %d on %s, got %s", i, network, l.Addr().Network())
+		}
+		conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+		if err != nil {
+			t.Errorf("unable to connect to %s: %v", l.Addr(), err)
+			continue
+		}
+		conn.Close()
+	}
+}
+
+func TestListenError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "smartmon.sock")
+	busy := filepath.Join(dir, "busy")
+	if err := os.Mkdir(busy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(busy, "file"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addresses := range [][]string{
+		{unixScheme + socket, "invalid address"},
+		// a directory is not a socket left over by a previous run
+		{unixScheme + busy},
+	} {
+		if listeners, err := listen(addresses); err == nil {
+			closeListeners(listeners)
+			t.Errorf("expected an error listening on %v", addresses)
+		}
+	}
+	// the listeners opened before the error are closed
+	if _, err := net.Dial("unix", socket); err == nil {
+		t.Errorf("expected the listener on %s to be closed", socket)
+	}
+}
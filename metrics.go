@@ -0,0 +1,79 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsHandler serves the metrics of all the registered collectors.  Like
+// node_exporter, the collect[] and device query parameters restrict the
+// collection to some collectors and devices, e.g.
+// /metrics?collect[]=attributes&device=/dev/sda, so a second scrape job can
+// collect the expensive collectors less often.  The filtered metrics are
+// served from a registry of their own with the same constant labels.
+func metricsHandler(c collector, labels prometheus.Labels, handlerOpts promhttp.HandlerOpts) http.Handler {
+	unfiltered := promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, handlerOpts),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := smart.Filter{Collectors: query["collect[]"], Devices: query["device"]}
+		if len(filter.Collectors) == 0 && len(filter.Devices) == 0 {
+			unfiltered.ServeHTTP(w, r)
+			return
+		}
+		smartctlCollector, ok := c.(*smart.Collector)
+		if !ok {
+			http.Error(w, "collect[] and device are not supported when reading the smartd attribute logs", http.StatusBadRequest)
+			return
+		}
+		filtered, err := smartctlCollector.Filtered(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		registry := prometheus.NewRegistry()
+		if err := prometheus.WrapRegistererWith(labels, registry).Register(filtered); err != nil {
+			http.Error(w, "Unable to register collector: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		promhttp.HandlerFor(registry, handlerOpts).ServeHTTP(w, r)
+	})
+}
+
+// limitInFlight serves at most max requests in parallel, like the
+// MaxRequestsInFlight option of promhttp but for the filtered and the
+// unfiltered scrapes together.  Unlimited if max is 0.
+func limitInFlight(h http.Handler, max int) http.Handler {
+	if max <= 0 {
+		return h
+	}
+	inFlight := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+		default:
+			http.Error(w, fmt.Sprintf("Limit of concurrent requests reached (%d), try again later.", max), http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
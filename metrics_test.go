@@ -0,0 +1,78 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimitInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := limitInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}), 1)
+	serve := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve() }()
+	<-entered
+	// the request beyond --web.max-requests is rejected
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Fatal("expected the second request to be rejected, got", code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatal("expected the first request to be served, got", code)
+	}
+
+	// the slot is released once the request is served
+	go func() { <-entered }()
+	if code := serve(); code != http.StatusOK {
+		t.Fatal("expected the request to be served, got", code)
+	}
+}
+
+func TestLimitInFlightUnlimited(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := limitInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}), 0)
+	done := make(chan int)
+	for i := 0; i < 3; i++ {
+		go func() {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			done <- w.Code
+		}()
+	}
+	// all the requests are served in parallel
+	for i := 0; i < 3; i++ {
+		<-entered
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Fatal("expected the requests to be served, got", code)
+		}
+	}
+}
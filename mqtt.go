@@ -0,0 +1,245 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pgier/smartmon-exporter/config"
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/common/log"
+)
+
+// The defaults of the MQTT configuration
+const (
+	defaultMQTTTopicPrefix     = "smartmon"
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+	defaultMQTTInterval        = 5 * time.Minute
+	mqttTimeout                = 10 * time.Second
+)
+
+// mqttInvalidChars are the characters replaced in the topic levels and
+// the Home Assistant object ids
+var mqttInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// mqttState is the JSON state of a device published to its state topic
+type mqttState struct {
+	Device string `json:"device"`
+	Model  string `json:"model,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	// Health is smart.StatePassed or smart.StateFailed
+	Health      string   `json:"health"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	PowerMode   string   `json:"power_mode"`
+}
+
+// mqttPublisher publishes the state of the devices on an interval
+type mqttPublisher struct {
+	cfg         config.MQTT
+	snapshotter deviceSnapshotter
+	client      mqtt.Client
+	hostname    string
+	// published are the last states published by device id, and
+	// discovered the devices whose discovery config was published
+	published  map[string]string
+	discovered map[string]bool
+}
+
+// runMQTT connects to the broker and publishes the state of the devices
+// kept by the collector on the interval of the configuration until the
+// context is done
+func runMQTT(ctx context.Context, cfg config.MQTT, snapshotter deviceSnapshotter) error {
+	p := &mqttPublisher{
+		cfg:         cfg,
+		snapshotter: snapshotter,
+		published:   map[string]string{},
+		discovered:  map[string]bool{},
+	}
+	p.hostname, _ = os.Hostname()
+	if p.cfg.TopicPrefix == "" {
+		p.cfg.TopicPrefix = defaultMQTTTopicPrefix
+	}
+	if p.cfg.DiscoveryPrefix == "" {
+		p.cfg.DiscoveryPrefix = defaultMQTTDiscoveryPrefix
+	}
+	if p.cfg.Interval <= 0 {
+		p.cfg.Interval = defaultMQTTInterval
+	}
+	clientOpts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetAutoReconnect(true).
+		SetWill(p.availabilityTopic(), "offline", 1, true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			c.Publish(p.availabilityTopic(), 1, true, "online")
+		})
+	if cfg.ClientID == "" {
+		clientOpts.SetClientID("smartmon-exporter-" + p.hostname)
+	}
+	if cfg.Username != "" {
+		password, err := ioutil.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return err
+		}
+		clientOpts.SetUsername(cfg.Username).SetPassword(strings.TrimSpace(string(password)))
+	}
+	p.client = mqtt.NewClient(clientOpts)
+	if token := p.client.Connect(); !token.WaitTimeout(mqttTimeout) {
+		return errors.New("timed out connecting to " + cfg.Broker)
+	} else if token.Error() != nil {
+		return token.Error()
+	}
+	go func() {
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			p.publish()
+			select {
+			case <-ctx.Done():
+				p.client.Publish(p.availabilityTopic(), 1, true, "offline").WaitTimeout(mqttTimeout)
+				p.client.Disconnect(uint(mqttTimeout / time.Millisecond))
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// availabilityTopic is the topic telling if the exporter is online
+func (p *mqttPublisher) availabilityTopic() string {
+	return p.cfg.TopicPrefix + "/" + mqttInvalidChars.ReplaceAllString(p.hostname, "_") + "/status"
+}
+
+// stateTopic is the topic of the state of the device
+func (p *mqttPublisher) stateTopic(id string) string {
+	return p.cfg.TopicPrefix + "/" + mqttInvalidChars.ReplaceAllString(p.hostname, "_") + "/" + id
+}
+
+// publish publishes the state of the devices as of their last collection,
+// and their discovery config the first time they are found.  The devices
+// are collected first unless the exporter was scraped within the interval.
+func (p *mqttPublisher) publish() {
+	p.snapshotter.Refresh(p.cfg.Interval)
+	for _, s := range p.snapshotter.DeviceSnapshots() {
+		state, ok := mqttStateOf(s)
+		if !ok {
+			continue
+		}
+		id := mqttDeviceID(s.Key, state)
+		if !p.discovered[id] {
+			if err := p.publishDiscovery(id, state); err != nil {
+				log.With("device", s.Key).Errorln("Unable to publish the discovery config to", p.cfg.Broker+":", err)
+				continue
+			}
+			p.discovered[id] = true
+		}
+		payload, _ := json.Marshal(state)
+		if last, found := p.published[id]; found && p.cfg.OnChange && last == string(payload) {
+			continue
+		}
+		if err := p.send(p.stateTopic(id), payload); err != nil {
+			log.With("device", s.Key).Errorln("Unable to publish to", p.cfg.Broker+":", err)
+			continue
+		}
+		p.published[id] = string(payload)
+	}
+}
+
+// send publishes the retained message and waits for it to be sent
+func (p *mqttPublisher) send(topic string, payload []byte) error {
+	token := p.client.Publish(topic, 1, true, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		return errors.New("timed out publishing to " + topic)
+	}
+	return token.Error()
+}
+
+// publishDiscovery publishes the Home Assistant discovery config of the
+// health of the device as a problem binary sensor, and of its temperature
+func (p *mqttPublisher) publishDiscovery(id string, state mqttState) error {
+	for topic, payload := range p.discoveryConfigs(id, state) {
+		if err := p.send(topic, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discoveryConfigs returns the Home Assistant discovery configs of the
+// device by topic
+func (p *mqttPublisher) discoveryConfigs(id string, state mqttState) map[string][]byte {
+	objectID := "smartmon_" + mqttInvalidChars.ReplaceAllString(p.hostname, "_") + "_" + id
+	device := map[string]interface{}{
+		"identifiers":  []string{objectID},
+		"name":         p.hostname + " " + state.Device,
+		"model":        state.Model,
+		"manufacturer": "smartmon-exporter",
+	}
+	configs := map[string]map[string]interface{}{
+		"binary_sensor/" + objectID + "/health": {
+			"name":           "SMART health",
+			"device_class":   "problem",
+			"value_template": "{{ 'ON' if value_json.health == '" + smart.StateFailed + "' else 'OFF' }}",
+		},
+		"sensor/" + objectID + "/temperature": {
+			"name":                "Temperature",
+			"device_class":        "temperature",
+			"state_class":         "measurement",
+			"unit_of_measurement": "°C",
+			"value_template":      "{{ value_json.temperature }}",
+		},
+	}
+	payloads := make(map[string][]byte, len(configs))
+	for topic, cfg := range configs {
+		cfg["unique_id"] = objectID + "_" + filepath.Base(topic)
+		cfg["state_topic"] = p.stateTopic(id)
+		cfg["availability_topic"] = p.availabilityTopic()
+		cfg["device"] = device
+		payloads[p.cfg.DiscoveryPrefix+"/"+topic+"/config"], _ = json.Marshal(cfg)
+	}
+	return payloads
+}
+
+// mqttDeviceID identifies the device in the topics, by its serial number
+// if known so it survives renames, or by its key, see smart.Device.Key
+func mqttDeviceID(key string, state mqttState) string {
+	if state.Serial != "" {
+		return mqttInvalidChars.ReplaceAllString(state.Serial, "_")
+	}
+	return mqttInvalidChars.ReplaceAllString(strings.TrimPrefix(key, "/dev/"), "_")
+}
+
+// mqttStateOf returns the state of the device as of its last collection,
+// false until its health was collected
+func mqttStateOf(s smart.DeviceSnapshot) (mqttState, bool) {
+	if s.Health == smart.StateUnknown {
+		return mqttState{}, false
+	}
+	return mqttState{
+		Device:      s.Key,
+		Model:       s.Model,
+		Serial:      s.Serial,
+		Health:      s.Health,
+		Temperature: s.Temperature,
+		PowerMode:   string(s.PowerMode),
+	}, true
+}
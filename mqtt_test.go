@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pgier/smartmon-exporter/config"
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+func testMQTTPublisher() *mqttPublisher {
+	return &mqttPublisher{
+		cfg: config.MQTT{
+			TopicPrefix:     defaultMQTTTopicPrefix,
+			DiscoveryPrefix: defaultMQTTDiscoveryPrefix,
+		},
+		hostname: "nas.example.com",
+	}
+}
+
+func TestMQTTTopics(t *testing.T) {
+	p := testMQTTPublisher()
+	if topic := p.availabilityTopic(); topic != "smartmon/nas_example_com/status" {
+		t.Errorf("expected the availability topic smartmon/nas_example_com/status, got %s", topic)
+	}
+	if topic := p.stateTopic("Z302SXYZ"); topic != "smartmon/nas_example_com/Z302SXYZ" {
+		t.Errorf("expected the state topic smartmon/nas_example_com/Z302SXYZ, got %s", topic)
+	}
+}
+
+func TestMQTTDeviceID(t *testing.T) {
+	for _, test := range []struct {
+		key      string
+		serial   string
+		expected string
+	}{
+		{"/dev/sda", "Z302SXYZ", "Z302SXYZ"},
+		{"/dev/sda", "WD-WCC4 N0XXXX", "WD-WCC4_N0XXXX"},
+		{"/dev/sda", "", "sda"},
+		{"/dev/bus/0:megaraid,1", "", "bus_0_megaraid_1"},
+	} {
+		if id := mqttDeviceID(test.key, mqttState{Serial: test.serial}); id != test.expected {
+			t.Errorf("expected the id %s of %s with serial %q, got %s", test.expected, test.key, test.serial, id)
+		}
+	}
+}
+
+func TestMQTTStateOf(t *testing.T) {
+	// the devices whose health was never collected are not published
+	if state, ok := mqttStateOf(testSnapshots[1]); ok {
+		t.Errorf("expected no state of %s, got %+v", testSnapshots[1].Key, state)
+	}
+	temperature := 34.0
+	snapshot := testSnapshots[2]
+	snapshot.Temperature = &temperature
+	state, ok := mqttStateOf(snapshot)
+	if !ok {
+		t.Fatalf("expected the state of %s", snapshot.Key)
+	}
+	expected := mqttState{
+		Device:      "/dev/sda",
+		Model:       "ST4000DM000-1F2168",
+		Serial:      "Z302SXYZ",
+		Health:      smart.StatePassed,
+		Temperature: &temperature,
+		PowerMode:   string(smart.PowerModeStandby),
+	}
+	if !reflect.DeepEqual(state, expected) {
+		t.Errorf("expected %+v, got %+v", expected, state)
+	}
+}
+
+func TestMQTTDiscoveryConfigs(t *testing.T) {
+	p := testMQTTPublisher()
+	state, _ := mqttStateOf(testSnapshots[0])
+	payloads := p.discoveryConfigs("Z1Z0ABCD", state)
+
+	objectID := "smartmon_nas_example_com_Z1Z0ABCD"
+	device := map[string]interface{}{
+		"identifiers":  []interface{}{objectID},
+		"name":         "nas.example.com /dev/bus/0:megaraid,0",
+		"model":        "ST4000NM0033",
+		"manufacturer": "smartmon-exporter",
+	}
+	expected := map[string]map[string]interface{}{
+		"homeassistant/binary_sensor/" + objectID + "/health/config": {
+			"name":               "SMART health",
+			"device_class":       "problem",
+			"value_template":     "{{ 'ON' if value_json.health == 'FAILED' else 'OFF' }}",
+			"unique_id":          objectID + "_health",
+			"state_topic":        "smartmon/nas_example_com/Z1Z0ABCD",
+			"availability_topic": "smartmon/nas_example_com/status",
+			"device":             device,
+		},
+		"homeassistant/sensor/" + objectID + "/temperature/config": {
+			"name":                "Temperature",
+			"device_class":        "temperature",
+			"state_class":         "measurement",
+			"unit_of_measurement": "°C",
+			"value_template":      "{{ value_json.temperature }}",
+			"unique_id":           objectID + "_temperature",
+			"state_topic":         "smartmon/nas_example_com/Z1Z0ABCD",
+			"availability_topic":  "smartmon/nas_example_com/status",
+			"device":              device,
+		},
+	}
+	configs := make(map[string]map[string]interface{}, len(payloads))
+	for topic, payload := range payloads {
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(payload, &cfg); err != nil {
+			t.Fatalf("unable to parse the config of %s: %v", topic, err)
+		}
+		configs[topic] = cfg
+	}
+	if !reflect.DeepEqual(configs, expected) {
+		t.Errorf("expected %v, got %v", expected, configs)
+	}
+}
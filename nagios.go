@@ -0,0 +1,63 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// nagiosStatus are the service states of the exit codes of a Nagios plugin
+var nagiosStatus = map[int]string{
+	oneshotOK:       "OK",
+	oneshotWarning:  "WARNING",
+	oneshotCritical: "CRITICAL",
+	oneshotUnknown:  "UNKNOWN",
+}
+
+// formatNagios formats the health of the devices as the output of a Nagios
+// or Icinga check plugin: a status line with the fleet summary as perfdata,
+// followed by the unhealthy devices, e.g.
+//
+//	SMART CRITICAL - 1 of 4 devices unhealthy | devices=4 unhealthy=1 failing_prefail=0 over_temperature=0
+//	/dev/sdb failed the SMART overall-health self-assessment
+func formatNagios(families []*dto.MetricFamily) []byte {
+	h := checkHealth(families)
+	var buf bytes.Buffer
+	if !h.collected {
+		fmt.Fprintf(&buf, "SMART %s - the devices could not be collected\n", nagiosStatus[h.status])
+		return buf.Bytes()
+	}
+	summary := []string{}
+	if h.unhealthy > 0 {
+		summary = append(summary, fmt.Sprintf("%g of %g devices unhealthy", h.unhealthy, h.devices))
+	}
+	if h.failing > 0 {
+		summary = append(summary, fmt.Sprintf("%g failing a pre-failure attribute", h.failing))
+	}
+	if h.hot > 0 {
+		summary = append(summary, fmt.Sprintf("%g over temperature", h.hot))
+	}
+	if len(summary) == 0 {
+		summary = append(summary, fmt.Sprintf("%g devices healthy", h.devices))
+	}
+	fmt.Fprintf(&buf, "SMART %s - %s | devices=%g unhealthy=%g failing_prefail=%g over_temperature=%g\n",
+		nagiosStatus[h.status], strings.Join(summary, ", "), h.devices, h.unhealthy, h.failing, h.hot)
+	for _, name := range h.unhealthyDevices {
+		fmt.Fprintf(&buf, "%s failed the SMART overall-health self-assessment\n", name)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,41 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestFormatNagios(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		metrics  string
+		expected string
+	}{
+		{"healthy", fleetMetrics("0", "0", "0"),
+			"SMART OK - 4 devices healthy | devices=4 unhealthy=0 failing_prefail=0 over_temperature=0\n"},
+		{"over temperature", fleetMetrics("0", "0", "2"),
+			"SMART WARNING - 2 over temperature | devices=4 unhealthy=0 failing_prefail=0 over_temperature=2\n"},
+		{"unhealthy", fleetMetrics("1", "1", "0") + `# TYPE smartmon_device_smart_healthy gauge
+smartmon_device_smart_healthy{disk="/dev/sdb"} 0
+`,
+			"SMART CRITICAL - 1 of 4 devices unhealthy, 1 failing a pre-failure attribute | devices=4 unhealthy=1 failing_prefail=1 over_temperature=0\n" +
+				"/dev/sdb failed the SMART overall-health self-assessment\n"},
+		{"not collected", "",
+			"SMART UNKNOWN - the devices could not be collected\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if output := string(formatNagios(parseFamilies(t, test.metrics))); output != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, output)
+			}
+		})
+	}
+}
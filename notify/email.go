@@ -0,0 +1,137 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+// defaultRepeatInterval is the minimum time between the emails about the
+// same failure if none is configured
+const defaultRepeatInterval = time.Hour
+
+// sendMail sends an email, replaced by the tests
+var sendMail = smtp.SendMail
+
+// Email sends the failures, of the health self-assessment, of an attribute
+// or of a self-test, by email through an SMTP server.  The devices found
+// healthy again are not mailed.
+type Email struct {
+	// Smarthost is the host:port of the SMTP server, STARTTLS is used if
+	// the server supports it
+	Smarthost string   `yaml:"smarthost"`
+	From      string   `yaml:"from"`
+	To        []string `yaml:"to"`
+	// Username and the password read from PasswordFile authenticate with
+	// the server, which must use TLS unless it is localhost
+	Username     string `yaml:"username,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	// RepeatInterval is the minimum time between two emails about the same
+	// failure of a device, so a flapping device does not flood the
+	// mailbox, defaults to 1h
+	RepeatInterval time.Duration `yaml:"repeat_interval,omitempty"`
+}
+
+// repeatInterval returns the minimum time between two emails about the
+// same failure
+func (e *Email) repeatInterval() time.Duration {
+	if e.RepeatInterval > 0 {
+		return e.RepeatInterval
+	}
+	return defaultRepeatInterval
+}
+
+// throttleKey identifies the failure of the notification for throttling
+func throttleKey(n smart.Notification) string {
+	key := n.Device + " " + n.Event
+	if n.Attribute != nil {
+		key += " " + n.Attribute.Name
+	}
+	return key
+}
+
+// sendEmail mails the failure unless it was mailed less than the repeat
+// interval ago, returns false if the email was not sent
+func (d *Dispatcher) sendEmail(i int, e Email, n smart.Notification) (bool, error) {
+	if n.NewState != smart.StateFailed {
+		return false, nil
+	}
+	key := throttleKey(n)
+	if last, found := d.mailed[i][key]; found && n.Time.Sub(last) < e.repeatInterval() {
+		return false, nil
+	}
+	var auth smtp.Auth
+	if e.Username != "" {
+		password, err := ioutil.ReadFile(e.PasswordFile)
+		if err != nil {
+			return false, err
+		}
+		host, _, _ := net.SplitHostPort(e.Smarthost)
+		auth = smtp.PlainAuth("", e.Username, strings.TrimSpace(string(password)), host)
+	}
+	if err := sendMail(e.Smarthost, auth, e.From, e.To, emailMessage(e, n, d.hostname)); err != nil {
+		return false, err
+	}
+	d.mailed[i][key] = n.Time
+	return true, nil
+}
+
+// emailMessage returns the email describing the failure of the device
+func emailMessage(e Email, n smart.Notification, hostname string) []byte {
+	var subject, body bytes.Buffer
+	fmt.Fprintf(&subject, "[smartmon] %s on %s: ", n.Device, hostname)
+	switch n.Event {
+	case smart.EventAttribute:
+		fmt.Fprintf(&subject, "attribute %s %s", n.Attribute.Name, n.NewState)
+	case smart.EventSelfTest:
+		fmt.Fprintf(&subject, "self-test %s", n.NewState)
+	default:
+		fmt.Fprintf(&subject, "SMART health %s", n.NewState)
+	}
+
+	fmt.Fprintf(&body, "Host: %s\r\n", hostname)
+	fmt.Fprintf(&body, "Device: %s (%s)\r\n", n.Device, n.Type)
+	if n.Serial != "" {
+		fmt.Fprintf(&body, "Serial number: %s\r\n", n.Serial)
+	}
+	if n.WWN != "" {
+		fmt.Fprintf(&body, "WWN: %s\r\n", n.WWN)
+	}
+	fmt.Fprintf(&body, "State: %s, previously %s\r\n", n.NewState, n.OldState)
+	if n.Attribute != nil {
+		fmt.Fprintf(&body, "Attribute: %d %s, value %v, worst %v, threshold %v, raw value %s\r\n",
+			n.Attribute.ID, n.Attribute.Name, n.Attribute.Value, n.Attribute.Worst, n.Attribute.Threshold, n.Attribute.RawString)
+	}
+	if n.SelfTest != "" {
+		fmt.Fprintf(&body, "Self-test: %s\r\n", n.SelfTest)
+	}
+	fmt.Fprintf(&body, "Time: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "\r\nFurther emails about this failure are held back for %s.\r\n", e.repeatInterval())
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject.String())
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
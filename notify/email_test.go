@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+func TestEmailThrottling(t *testing.T) {
+	sent := []string{}
+	sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "localhost:25" || from != "nas@example.com" || len(to) != 1 {
+			t.Error("unexpected email", addr, from, to)
+		}
+		sent = append(sent, string(msg))
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	d := New(Config{Emails: []Email{{Smarthost: "localhost:25", From: "nas@example.com", To: []string{"admin@example.com"}}}})
+	start := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	failed := smart.Notification{Time: start, Device: "/dev/sda", Type: "sat", Event: smart.EventHealth, OldState: smart.StatePassed, NewState: smart.StateFailed}
+	passed := failed
+	passed.OldState, passed.NewState = smart.StateFailed, smart.StatePassed
+	attribute := failed
+	attribute.Event, attribute.Attribute = smart.EventAttribute, &smart.Attribute{ID: 5, Name: "reallocated_sector_ct"}
+
+	// the device flaps, the failures within the repeat interval are held
+	// back while the other failures are sent
+	for _, n := range []smart.Notification{failed, passed, failed, attribute} {
+		d.Notify(n)
+	}
+	failed.Time = start.Add(time.Hour)
+	d.Notify(failed)
+	d.Close()
+
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 emails, got %d: %v", len(sent), sent)
+	}
+	for i, subject := range []string{
+		"Subject: [smartmon] /dev/sda on " + d.hostname + ": SMART health FAILED",
+		"Subject: [smartmon] /dev/sda on " + d.hostname + ": attribute reallocated_sector_ct FAILED",
+		"Subject: [smartmon] /dev/sda on " + d.hostname + ": SMART health FAILED",
+	} {
+		if !strings.Contains(sent[i], subject+"\r\n") {
+			t.Errorf("expected %q in %q", subject, sent[i])
+		}
+	}
+	if !strings.Contains(sent[0], "State: FAILED, previously PASSED\r\n") {
+		t.Errorf("unexpected email %q", sent[0])
+	}
+}
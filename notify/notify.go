@@ -0,0 +1,181 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify sends the changes of the health of the devices found by
+// the collector to receivers, webhooks and emails, for setups without
+// Alertmanager
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/common/log"
+)
+
+// queueSize is the number of notifications waiting to be sent, beyond
+// which the notifications are dropped rather than blocking the collection
+const queueSize = 100
+
+// defaultTimeout is the timeout of a webhook request if none is configured
+const defaultTimeout = 10 * time.Second
+
+// Config configures the receivers of the notifications, e.g.
+//
+//	webhooks:
+//	  - url: https://hooks.example.com/smart
+//	    headers:
+//	      Authorization: Bearer 0123456789abcdef
+//	    timeout: 5s
+//	emails:
+//	  - smarthost: smtp.example.com:587
+//	    from: nas@example.com
+//	    to: [admin@example.com]
+//	    username: nas@example.com
+//	    password_file: /etc/smartmon/smtp-password
+//	    repeat_interval: 6h
+type Config struct {
+	// Webhooks receive every notification as a JSON POST request
+	Webhooks []Webhook `yaml:"webhooks"`
+	// Emails receive the failures by email
+	Emails []Email `yaml:"emails"`
+}
+
+// Webhook is an HTTP endpoint receiving the notifications, the body of
+// the requests is the JSON of a smart.Notification
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Headers are added to the requests, e.g. an Authorization header
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Timeout of a request, defaults to 10s
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Enabled returns true if a receiver is configured
+func (c *Config) Enabled() bool {
+	return len(c.Webhooks) > 0 || len(c.Emails) > 0
+}
+
+// Dispatcher sends the notifications to the receivers in the background,
+// it implements smart.Notifier
+type Dispatcher struct {
+	cfg      Config
+	client   *http.Client
+	hostname string
+	queue    chan smart.Notification
+	wg       sync.WaitGroup
+	// mailed is when each failure was last mailed by key, for each of
+	// the Emails of the configuration
+	mailed []map[string]time.Time
+}
+
+// New starts a dispatcher sending the notifications to the receivers of
+// the configuration until it is closed
+func New(cfg Config) *Dispatcher {
+	d := &Dispatcher{
+		cfg:    cfg,
+		client: &http.Client{},
+		queue:  make(chan smart.Notification, queueSize),
+		mailed: make([]map[string]time.Time, len(cfg.Emails)),
+	}
+	d.hostname, _ = os.Hostname()
+	for i := range d.mailed {
+		d.mailed[i] = map[string]time.Time{}
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for n := range d.queue {
+			d.send(n)
+		}
+	}()
+	return d
+}
+
+// Notify queues the notification, it is dropped if the queue is full
+func (d *Dispatcher) Notify(n smart.Notification) {
+	select {
+	case d.queue <- n:
+	default:
+		log.With("device", n.Device).Warnln("Notification queue full, dropping the notification", n.OldState, "to", n.NewState)
+	}
+}
+
+// Close sends the queued notifications and stops the dispatcher, a nil
+// dispatcher is closed
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	close(d.queue)
+	d.wg.Wait()
+}
+
+// send sends the notification to every receiver
+func (d *Dispatcher) send(n smart.Notification) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		log.Errorln("Unable to encode notification:", err)
+		return
+	}
+	for _, w := range d.cfg.Webhooks {
+		if err := d.sendWebhook(w, body); err != nil {
+			log.With("device", n.Device).Errorln("Unable to send notification to", w.URL+":", err)
+		}
+	}
+	for i, e := range d.cfg.Emails {
+		sent, err := d.sendEmail(i, e, n)
+		if err != nil {
+			log.With("device", n.Device).Errorln("Unable to send email through", e.Smarthost+":", err)
+		} else if sent {
+			log.With("device", n.Device).Infoln("Sent email about the", n.Event, "failure to", strings.Join(e.To, ", "))
+		}
+	}
+}
+
+// sendWebhook posts the JSON notification to the webhook
+func (d *Dispatcher) sendWebhook(w Webhook, body []byte) error {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+func TestWebhook(t *testing.T) {
+	received := make(chan smart.Notification, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Error("missing header", r.Header)
+		}
+		var n smart.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		received <- n
+	}))
+	defer server.Close()
+
+	d := New(Config{Webhooks: []Webhook{{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}}})
+	d.Notify(smart.Notification{Device: "/dev/sda", Type: "sat", OldState: smart.StatePassed, NewState: smart.StateFailed})
+	d.Notify(smart.Notification{
+		Device:    "/dev/sda",
+		OldState:  smart.StatePassed,
+		NewState:  smart.StateFailed,
+		Attribute: &smart.Attribute{ID: 5, Name: "reallocated_sector_ct", Value: 5, Threshold: 10},
+	})
+	d.Close()
+
+	n := <-received
+	if n.Device != "/dev/sda" || n.OldState != smart.StatePassed || n.NewState != smart.StateFailed || n.Attribute != nil {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	n = <-received
+	if n.Attribute == nil || n.Attribute.ID != 5 {
+		t.Errorf("unexpected notification %+v", n)
+	}
+}
+
+func TestCloseNil(t *testing.T) {
+	var d *Dispatcher
+	d.Close()
+}
@@ -0,0 +1,110 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var oneshot = kingpin.Flag("oneshot", "Collect the metrics once, print them to stdout in the --output-format and exit with 2 if a device is unhealthy or fails a pre-failure attribute, 1 if a device is over temperature, 3 if the devices could not be collected.").Default("false").Bool()
+
+// The exit codes of the one-shot mode, those of the Nagios plugins
+const (
+	oneshotOK       = 0
+	oneshotWarning  = 1
+	oneshotCritical = 2
+	oneshotUnknown  = 3
+)
+
+// health is the health of the devices found in the gathered metrics
+type health struct {
+	// status is the exit code of the one-shot mode
+	status int
+	// collected is false if the scan failed and the devices were not
+	// collected
+	collected bool
+	// the values of the fleet summary metrics
+	devices, unhealthy, failing, hot float64
+	// unhealthyDevices lists the devices failing their health
+	// self-assessment
+	unhealthyDevices []string
+}
+
+// runOneshot gathers the metrics once, prints them in the format and
+// returns the exit code reflecting the health of the devices
+func runOneshot(w io.Writer, format string, g prometheus.Gatherer) int {
+	families, err := g.Gather()
+	if err != nil {
+		fmt.Fprintln(w, "Unable to collect the metrics:", err)
+		return oneshotUnknown
+	}
+	content, err := formatOutput(format, families)
+	if err != nil {
+		fmt.Fprintln(w, "Unable to format the metrics:", err)
+		return oneshotUnknown
+	}
+	w.Write(content)
+	return checkHealth(families).status
+}
+
+// checkHealth finds the health of the devices in the gathered metrics
+func checkHealth(families []*dto.MetricFamily) health {
+	h := health{}
+	h.unhealthy, h.collected = gaugeValue(families, "smartmon_devices_unhealthy")
+	if !h.collected {
+		h.status = oneshotUnknown
+		return h
+	}
+	h.devices, _ = gaugeValue(families, "smartmon_devices_total")
+	h.failing, _ = gaugeValue(families, "smartmon_devices_failing_prefail_attributes")
+	h.hot, _ = gaugeValue(families, "smartmon_devices_over_temperature")
+	for _, mf := range families {
+		if mf.GetName() != "smartmon_device_smart_healthy" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetGauge().GetValue() != 0 {
+				continue
+			}
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "disk" {
+					h.unhealthyDevices = append(h.unhealthyDevices, l.GetValue())
+				}
+			}
+		}
+	}
+	switch {
+	case h.unhealthy > 0 || h.failing > 0:
+		h.status = oneshotCritical
+	case h.hot > 0:
+		h.status = oneshotWarning
+	default:
+		h.status = oneshotOK
+	}
+	return h
+}
+
+// gaugeValue returns the value of the gauge without labels of its own
+func gaugeValue(families []*dto.MetricFamily, name string) (float64, bool) {
+	for _, mf := range families {
+		if mf.GetName() == name && len(mf.GetMetric()) > 0 {
+			return mf.GetMetric()[0].GetGauge().GetValue(), true
+		}
+	}
+	return 0, false
+}
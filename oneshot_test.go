@@ -0,0 +1,91 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fleetMetrics returns the fleet summary metrics with the values
+func fleetMetrics(unhealthy, failing, hot string) string {
+	return `# TYPE smartmon_devices_total gauge
+smartmon_devices_total 4
+# TYPE smartmon_devices_unhealthy gauge
+smartmon_devices_unhealthy ` + unhealthy + `
+# TYPE smartmon_devices_failing_prefail_attributes gauge
+smartmon_devices_failing_prefail_attributes ` + failing + `
+# TYPE smartmon_devices_over_temperature gauge
+smartmon_devices_over_temperature ` + hot + `
+`
+}
+
+func TestCheckHealth(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		metrics string
+		status  int
+		devices []string
+	}{
+		{"healthy", fleetMetrics("0", "0", "0"), oneshotOK, nil},
+		{"over temperature", fleetMetrics("0", "0", "1"), oneshotWarning, nil},
+		{"failing prefail", fleetMetrics("0", "1", "1"), oneshotCritical, nil},
+		{"unhealthy", fleetMetrics("1", "0", "0") + `# TYPE smartmon_device_smart_healthy gauge
+smartmon_device_smart_healthy{disk="/dev/sda"} 1
+smartmon_device_smart_healthy{disk="/dev/sdb"} 0
+`, oneshotCritical, []string{"/dev/sdb"}},
+		// the scan failed
+		{"not collected", "", oneshotUnknown, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := checkHealth(parseFamilies(t, test.metrics))
+			if h.status != test.status {
+				t.Errorf("expected status %d, got %d", test.status, h.status)
+			}
+			if !reflect.DeepEqual(h.unhealthyDevices, test.devices) {
+				t.Errorf("expected unhealthy devices %v, got %v", test.devices, h.unhealthyDevices)
+			}
+		})
+	}
+}
+
+func TestRunOneshot(t *testing.T) {
+	var buf bytes.Buffer
+	families := parseFamilies(t, fleetMetrics("0", "0", "1"))
+	if status := runOneshot(&buf, outputFormatProm, staticGatherer(families)); status != oneshotWarning {
+		t.Errorf("expected status %d, got %d", oneshotWarning, status)
+	}
+	if !strings.Contains(buf.String(), "smartmon_devices_over_temperature 1") {
+		t.Errorf("expected the metrics on the output, got %s", buf.String())
+	}
+
+	buf.Reset()
+	if status := runOneshot(&buf, outputFormatProm, failingGatherer{}); status != oneshotUnknown {
+		t.Errorf("expected status %d, got %d", oneshotUnknown, status)
+	}
+	if !strings.HasPrefix(buf.String(), "Unable to collect the metrics:") {
+		t.Errorf("expected the error on the output, got %s", buf.String())
+	}
+}
+
+// failingGatherer fails to gather the metrics
+type failingGatherer struct{}
+
+func (failingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	return nil, errors.New("collection failed")
+}
@@ -0,0 +1,236 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	telemetryMode = kingpin.Flag("telemetry.mode", "How metrics are exported, either served or sent in the Prometheus format, or pushed to an OpenTelemetry collector.").Default("prometheus").Enum("prometheus", "otlp")
+	otlpEndpoint  = kingpin.Flag("otlp.endpoint", "OTLP/HTTP metrics endpoint of the OpenTelemetry collector.").Default("http://localhost:4318/v1/metrics").String()
+	otlpInterval  = kingpin.Flag("otlp.interval", "Interval between pushes to the OpenTelemetry collector, 0 pushes once and exits.").Default("60s").Duration()
+	otlpTimeout   = kingpin.Flag("otlp.timeout", "Timeout of a request to the OpenTelemetry collector.").Default("30s").Duration()
+)
+
+// The otlp types model the JSON encoding of an OTLP ExportMetricsServiceRequest.
+// 64 bit integers are encoded as strings as required by the protobuf JSON mapping.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Gauge       *otlpData `json:"gauge,omitempty"`
+	Sum         *otlpData `json:"sum,omitempty"`
+	Summary     *otlpData `json:"summary,omitempty"`
+	Histogram   *otlpData `json:"histogram,omitempty"`
+}
+
+type otlpData struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality,omitempty"`
+	IsMonotonic            bool            `json:"isMonotonic,omitempty"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+	Count             string          `json:"count,omitempty"`
+	Sum               *float64        `json:"sum,omitempty"`
+	QuantileValues    []otlpQuantile  `json:"quantileValues,omitempty"`
+	BucketCounts      []string        `json:"bucketCounts,omitempty"`
+	ExplicitBounds    []float64       `json:"explicitBounds,omitempty"`
+}
+
+type otlpQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// aggregationTemporalityCumulative is the OTLP temporality of Prometheus
+// counters, summaries and histograms
+const aggregationTemporalityCumulative = 2
+
+func newOTLPAttribute(key, value string) otlpAttribute {
+	attr := otlpAttribute{Key: key}
+	attr.Value.StringValue = value
+	return attr
+}
+
+// runOTLP pushes the metrics to the OpenTelemetry collector once, or on
+// every interval until the exporter is terminated.  The cumulative metrics
+// start with the first collection.
+func runOTLP(gatherer prometheus.Gatherer) error {
+	client := &http.Client{Timeout: *otlpTimeout}
+	start := time.Now()
+	return runPeriodically(*otlpInterval, func() error {
+		return sendOTLP(client, gatherer, start)
+	}, *otlpEndpoint)
+}
+
+// sendOTLP gathers the metrics and posts them to the OTLP/HTTP endpoint
+func sendOTLP(client *http.Client, gatherer prometheus.Gatherer, start time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(toOTLPRequest(families, start, time.Now()))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, *otlpEndpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// toOTLPRequest converts the gathered metric families to OTLP metrics.
+// Gauges and untyped metrics become gauges, counters become cumulative
+// monotonic sums and summaries and histograms keep their type.  The points
+// of the cumulative metrics start at start, which the consumers require to
+// detect the resets.
+func toOTLPRequest(families []*dto.MetricFamily, start, now time.Time) *otlpRequest {
+	scope := otlpScopeMetrics{}
+	scope.Scope.Name = "smartmon_exporter"
+	scope.Scope.Version = version.Version
+	for _, family := range families {
+		metric := otlpMetric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+		data := &otlpData{}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			data.AggregationTemporality = aggregationTemporalityCumulative
+			data.IsMonotonic = true
+			metric.Sum = data
+		case dto.MetricType_SUMMARY:
+			metric.Summary = data
+		case dto.MetricType_HISTOGRAM:
+			data.AggregationTemporality = aggregationTemporalityCumulative
+			metric.Histogram = data
+		default:
+			metric.Gauge = data
+		}
+		for _, m := range family.GetMetric() {
+			timestamp := now.UnixNano()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs() * int64(time.Millisecond)
+			}
+			point := otlpDataPoint{TimeUnixNano: strconv.FormatInt(timestamp, 10)}
+			if family.GetType() != dto.MetricType_GAUGE && family.GetType() != dto.MetricType_UNTYPED {
+				// the cached metrics may be timestamped before the start
+				startTime := start.UnixNano()
+				if timestamp < startTime {
+					startTime = timestamp
+				}
+				point.StartTimeUnixNano = strconv.FormatInt(startTime, 10)
+			}
+			for _, lp := range m.GetLabel() {
+				point.Attributes = append(point.Attributes, newOTLPAttribute(lp.GetName(), lp.GetValue()))
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value := m.GetCounter().GetValue()
+				point.AsDouble = &value
+			case dto.MetricType_GAUGE:
+				value := m.GetGauge().GetValue()
+				point.AsDouble = &value
+			case dto.MetricType_SUMMARY:
+				sum := m.GetSummary().GetSampleSum()
+				point.Sum = &sum
+				point.Count = strconv.FormatUint(m.GetSummary().GetSampleCount(), 10)
+				for _, q := range m.GetSummary().GetQuantile() {
+					point.QuantileValues = append(point.QuantileValues, otlpQuantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+			case dto.MetricType_HISTOGRAM:
+				sum := m.GetHistogram().GetSampleSum()
+				point.Sum = &sum
+				point.Count = strconv.FormatUint(m.GetHistogram().GetSampleCount(), 10)
+				// OTLP bucket counts are not cumulative and include the +Inf bucket
+				previous := uint64(0)
+				for _, b := range m.GetHistogram().GetBucket() {
+					point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+					point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-previous, 10))
+					previous = b.GetCumulativeCount()
+				}
+				point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(m.GetHistogram().GetSampleCount()-previous, 10))
+			default:
+				value := m.GetUntyped().GetValue()
+				point.AsDouble = &value
+			}
+			data.DataPoints = append(data.DataPoints, point)
+		}
+		scope.Metrics = append(scope.Metrics, metric)
+	}
+
+	resource := otlpResourceMetrics{ScopeMetrics: []otlpScopeMetrics{scope}}
+	resource.Resource.Attributes = []otlpAttribute{newOTLPAttribute("service.name", "smartmon_exporter")}
+	if hostname, err := os.Hostname(); err == nil {
+		resource.Resource.Attributes = append(resource.Resource.Attributes, newOTLPAttribute("host.name", hostname))
+	}
+	return &otlpRequest{ResourceMetrics: []otlpResourceMetrics{resource}}
+}
@@ -0,0 +1,117 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestOTLPRequest checks the metrics against the protobuf JSON mapping of
+// the OTLP ExportMetricsServiceRequest: camelCase field names, 64 bit
+// integers as strings and the enums as their numbers
+func TestOTLPRequest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_errors_total", Help: "errors"}, []string{"disk"})
+	counter.WithLabelValues("/dev/sda").Add(3)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_temperature_celsius", Help: "temperature"})
+	gauge.Set(36)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds", Help: "duration", Buckets: []float64{1, 10}})
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	histogram.Observe(50)
+	registry.MustRegister(counter, gauge, histogram)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	now := start.Add(time.Minute)
+	data, err := json.Marshal(toOTLPRequest(families, start, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Scope   map[string]interface{}   `json:"scope"`
+				Metrics []map[string]interface{} `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatal(err)
+	}
+	if len(request.ResourceMetrics) != 1 || len(request.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("expected a single resource and scope, got %s", data)
+	}
+	scope := request.ResourceMetrics[0].ScopeMetrics[0]
+	if scope.Scope["name"] != "smartmon_exporter" {
+		t.Errorf("expected the smartmon_exporter scope, got %v", scope.Scope)
+	}
+
+	var expected []map[string]interface{}
+	if err := json.Unmarshal([]byte(`[
+		{"name": "test_duration_seconds", "description": "duration", "histogram": {
+			"aggregationTemporality": 2,
+			"dataPoints": [{"startTimeUnixNano": "1700000000000000000", "timeUnixNano": "1700000060000000000",
+				"count": "3", "sum": 55.5, "bucketCounts": ["1", "1", "1"], "explicitBounds": [1, 10]}]}},
+		{"name": "test_errors_total", "description": "errors", "sum": {
+			"aggregationTemporality": 2, "isMonotonic": true,
+			"dataPoints": [{"attributes": [{"key": "disk", "value": {"stringValue": "/dev/sda"}}],
+				"startTimeUnixNano": "1700000000000000000", "timeUnixNano": "1700000060000000000", "asDouble": 3}]}},
+		{"name": "test_temperature_celsius", "description": "temperature", "gauge": {
+			"dataPoints": [{"timeUnixNano": "1700000060000000000", "asDouble": 36}]}}
+	]`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scope.Metrics, expected) {
+		got, _ := json.Marshal(scope.Metrics)
+		t.Errorf("unexpected metrics %s", got)
+	}
+}
+
+func TestOTLPStartTimeOfCachedMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collected := time.Unix(1700000000, 0)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "test"})
+	counter.Inc()
+	registry.MustRegister(cachedCollector{prometheus.NewMetricWithTimestamp(collected, counter)})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := toOTLPRequest(families, collected.Add(time.Minute), collected.Add(2*time.Minute))
+	point := request.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Sum.DataPoints[0]
+	if point.StartTimeUnixNano != "1700000000000000000" || point.TimeUnixNano != "1700000000000000000" {
+		t.Errorf("expected a point starting at its timestamp, got %+v", point)
+	}
+}
+
+// cachedCollector collects a single metric, e.g. cached with its timestamp
+type cachedCollector struct {
+	metric prometheus.Metric
+}
+
+func (c cachedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.metric.Desc()
+}
+
+func (c cachedCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- c.metric
+}
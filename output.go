@@ -0,0 +1,176 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// The formats of the --output-file
+const (
+	outputFormatProm = "prom"
+	outputFormatJSON = "json"
+	outputFormatCSV  = "csv"
+	// outputFormatNagios is the output of a Nagios check plugin, for the
+	// one-shot mode
+	outputFormatNagios = "nagios"
+	// outputFormatZabbix is the low-level discovery JSON of a Zabbix check
+	// with the values of the items
+	outputFormatZabbix = "zabbix"
+)
+
+var outputFormat = kingpin.Flag("output-format", "Format of the --output-file and of the --oneshot output, prom for the node_exporter textfile collector, json or csv with one entry per series for other tools, nagios for the status of the devices as a Nagios or Icinga check, zabbix for the low-level discovery of the devices by Zabbix with the values of the items.").Default(outputFormatProm).Enum(outputFormatProm, outputFormatJSON, outputFormatCSV, outputFormatNagios, outputFormatZabbix)
+
+// outputSeries is a series of the json output
+type outputSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	// Value is a number, or a string for NaN and the infinities which JSON
+	// numbers cannot represent
+	Value interface{} `json:"value"`
+}
+
+// writeOutputFile writes the gathered metrics to the file in the format,
+// the file is replaced atomically so a reader never sees a partial file
+func writeOutputFile(filename, format string, g prometheus.Gatherer) error {
+	if format == outputFormatProm {
+		return prometheus.WriteToTextfile(filename, g)
+	}
+	families, err := g.Gather()
+	if err != nil {
+		return err
+	}
+	content, err := formatOutput(format, families)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// formatOutput formats the gathered metrics in the format
+func formatOutput(format string, families []*dto.MetricFamily) ([]byte, error) {
+	if format == outputFormatProm {
+		var buf bytes.Buffer
+		for _, mf := range families {
+			if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+	if format == outputFormatNagios {
+		return formatNagios(families), nil
+	}
+	if format == outputFormatZabbix {
+		return formatZabbix(families)
+	}
+	// summaries and histograms are expanded into series like remote_write
+	series := toWriteRequest(families, time.Now()).Timeseries
+	if format == outputFormatJSON {
+		return outputJSON(series)
+	}
+	return outputCSV(series)
+}
+
+// outputJSON formats the series as a JSON array
+func outputJSON(series []prompb.TimeSeries) ([]byte, error) {
+	out := make([]outputSeries, 0, len(series))
+	for _, ts := range series {
+		s := outputSeries{Labels: map[string]string{}}
+		for _, l := range ts.Labels {
+			if l.Name == model.MetricNameLabel {
+				s.Name = l.Value
+			} else {
+				s.Labels[l.Name] = l.Value
+			}
+		}
+		value := ts.Samples[0].Value
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			s.Value = formatFloat(value)
+		} else {
+			s.Value = value
+		}
+		out = append(out, s)
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// outputCSV formats the series as CSV with a row per series, the columns
+// are the name, the value and every label name found in any series, so
+// the file opens as a table in a spreadsheet
+func outputCSV(series []prompb.TimeSeries) ([]byte, error) {
+	columns := map[string]int{}
+	names := []string{}
+	for _, ts := range series {
+		for _, l := range ts.Labels {
+			if _, found := columns[l.Name]; !found && l.Name != model.MetricNameLabel {
+				columns[l.Name] = 0
+				names = append(names, l.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	header := append([]string{"name", "value"}, names...)
+	for i, name := range names {
+		columns[name] = i + 2
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, ts := range series {
+		row := make([]string, len(header))
+		row[1] = formatFloat(ts.Samples[0].Value)
+		for _, l := range ts.Labels {
+			if l.Name == model.MetricNameLabel {
+				row[0] = l.Value
+			} else {
+				row[columns[l.Name]] = l.Value
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,136 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// parseFamilies parses the metrics in the text format, sorted by name as
+// gathered
+func parseFamilies(t *testing.T, text string) []*dto.MetricFamily {
+	parsed, err := (&expfmt.TextParser{}).TextToMetricFamilies(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, mf := range parsed {
+		families = append(families, mf)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families
+}
+
+const outputMetrics = `# TYPE smartmon_device_smart_healthy gauge
+smartmon_device_smart_healthy{disk="/dev/nvme0",type="nvme"} 0
+smartmon_device_smart_healthy{disk="/dev/sda",type="sat"} 1
+# TYPE smartmon_temperature_celsius_raw_value gauge
+smartmon_temperature_celsius_raw_value{disk="/dev/sda",smart_id="194"} NaN
+# TYPE smartmon_devices_total gauge
+smartmon_devices_total 2
+`
+
+func TestOutputJSON(t *testing.T) {
+	content, err := formatOutput(outputFormatJSON, parseFamilies(t, outputMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series []map[string]interface{}
+	if err := json.Unmarshal(content, &series); err != nil {
+		t.Fatalf("invalid JSON %s: %v", content, err)
+	}
+	expected := []map[string]interface{}{
+		{"name": "smartmon_device_smart_healthy", "labels": map[string]interface{}{"disk": "/dev/nvme0", "type": "nvme"}, "value": 0.0},
+		{"name": "smartmon_device_smart_healthy", "labels": map[string]interface{}{"disk": "/dev/sda", "type": "sat"}, "value": 1.0},
+		{"name": "smartmon_devices_total", "labels": map[string]interface{}{}, "value": 2.0},
+		// JSON numbers cannot be NaN
+		{"name": "smartmon_temperature_celsius_raw_value", "labels": map[string]interface{}{"disk": "/dev/sda", "smart_id": "194"}, "value": "NaN"},
+	}
+	if !reflect.DeepEqual(series, expected) {
+		t.Errorf("expected %v, got %v", expected, series)
+	}
+}
+
+func TestOutputCSV(t *testing.T) {
+	content, err := formatOutput(outputFormatCSV, parseFamilies(t, outputMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a column per label name, empty for the series without the label
+	expected := `name,value,disk,smart_id,type
+smartmon_device_smart_healthy,0,/dev/nvme0,,nvme
+smartmon_device_smart_healthy,1,/dev/sda,,sat
+smartmon_devices_total,2,,,
+smartmon_temperature_celsius_raw_value,NaN,/dev/sda,194,
+`
+	if string(content) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, content)
+	}
+}
+
+func TestOutputCSVQuoting(t *testing.T) {
+	families := parseFamilies(t, `# TYPE smartmon_device_info gauge
+smartmon_device_info{model="ST4000DM000, \"desktop\""} 1
+`)
+	content, err := formatOutput(outputFormatCSV, families)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,value,model\nsmartmon_device_info,1,\"ST4000DM000, \"\"desktop\"\"\"\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "smartmon.csv")
+	families := parseFamilies(t, outputMetrics)
+	if err := writeOutputFile(filename, outputFormatCSV, staticGatherer(families)); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "name,value,disk,smart_id,type\n") {
+		t.Errorf("unexpected content %s", content)
+	}
+	// the temporary file is renamed
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("expected a single file, got %d", len(files))
+	}
+}
+
+// staticGatherer gathers the same metric families every time
+type staticGatherer []*dto.MetricFamily
+
+func (g staticGatherer) Gather() ([]*dto.MetricFamily, error) {
+	return g, nil
+}
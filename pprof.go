@@ -0,0 +1,32 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof serves the Go runtime profiles on /debug/pprof/ with
+// --web.enable-pprof, to the clients allowed like on the other endpoints
+func registerPprof(mux *http.ServeMux, networks []*net.IPNet, limiter *rateLimiter) {
+	if !*enablePprof {
+		return
+	}
+	mux.Handle("/debug/pprof/", accessHandler(http.HandlerFunc(pprof.Index), networks, limiter))
+	mux.Handle("/debug/pprof/cmdline", accessHandler(http.HandlerFunc(pprof.Cmdline), networks, limiter))
+	mux.Handle("/debug/pprof/profile", accessHandler(http.HandlerFunc(pprof.Profile), networks, limiter))
+	mux.Handle("/debug/pprof/symbol", accessHandler(http.HandlerFunc(pprof.Symbol), networks, limiter))
+	mux.Handle("/debug/pprof/trace", accessHandler(http.HandlerFunc(pprof.Trace), networks, limiter))
+}
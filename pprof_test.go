@@ -0,0 +1,50 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterPprof(t *testing.T) {
+	defer func(enabled bool) { *enablePprof = enabled }(*enablePprof)
+	networks, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		enabled    bool
+		url        string
+		remoteAddr string
+		status     int
+	}{
+		{false, "/debug/pprof/", "10.1.2.3:51234", http.StatusNotFound},
+		{false, "/debug/pprof/cmdline", "10.1.2.3:51234", http.StatusNotFound},
+		{true, "/debug/pprof/", "10.1.2.3:51234", http.StatusOK},
+		{true, "/debug/pprof/cmdline", "10.1.2.3:51234", http.StatusOK},
+		{true, "/debug/pprof/", "192.168.1.1:51234", http.StatusForbidden},
+	} {
+		*enablePprof = test.enabled
+		mux := http.NewServeMux()
+		registerPprof(mux, networks, nil)
+		r := httptest.NewRequest(http.MethodGet, test.url, nil)
+		r.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("expected %d for %s from %s with pprof enabled %t, got %d", test.status, test.url, test.remoteAddr, test.enabled, w.Code)
+		}
+	}
+}
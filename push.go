@@ -0,0 +1,82 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	pushGatewayURL = kingpin.Flag("push.gateway-url", "Push metrics to this Pushgateway instead of serving them over HTTP.").Default("").String()
+	pushJob        = kingpin.Flag("push.job", "Job label of the pushed metrics.").Default("smartmon_exporter").String()
+	pushGrouping   = kingpin.Flag("push.grouping", "Grouping label of the pushed metrics as name=value, defaults to instance=<hostname>. May be repeated.").StringMap()
+	pushInterval   = kingpin.Flag("push.interval", "Interval between pushes, 0 pushes once and exits.").Default("0s").Duration()
+)
+
+// newPusher creates a pusher for the metrics of the gatherer using the
+// job and grouping configured by the push flags
+func newPusher(gatherer prometheus.Gatherer) *push.Pusher {
+	pusher := push.New(*pushGatewayURL, *pushJob).Gatherer(gatherer)
+	grouping := *pushGrouping
+	if len(grouping) == 0 {
+		if hostname, err := os.Hostname(); err == nil {
+			grouping = map[string]string{"instance": hostname}
+		}
+	}
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	return pusher
+}
+
+// runPush pushes the metrics once, or on every push interval until the
+// exporter is terminated
+func runPush(gatherer prometheus.Gatherer) error {
+	pusher := newPusher(gatherer)
+	return runPeriodically(*pushInterval, func() error {
+		return pusher.Push()
+	}, *pushGatewayURL)
+}
+
+// runPeriodically calls send once if interval is 0 and returns its error.
+// Otherwise send is called on every interval until the exporter is
+// terminated, errors are logged but do not stop the loop.
+func runPeriodically(interval time.Duration, send func() error, destination string) error {
+	if interval <= 0 {
+		return send()
+	}
+
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := send(); err != nil {
+			log.Errorln("Unable to send metrics to", destination+":", err)
+		}
+		select {
+		case <-ticker.C:
+		case sig := <-term:
+			log.Infoln("Received", sig, "shutting down")
+			return nil
+		}
+	}
+}
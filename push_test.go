@@ -0,0 +1,75 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRunPush(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip("no hostname:", err)
+	}
+	for _, test := range []struct {
+		name     string
+		grouping map[string]string
+		path     string
+	}{
+		{"default grouping", nil, "/metrics/job/smartmon_exporter/instance/" + hostname},
+		{"grouping", map[string]string{"rack": "r1"}, "/metrics/job/smartmon_exporter/rack/r1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var method, path, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := ioutil.ReadAll(r.Body)
+				method, path, body = r.Method, r.URL.Path, string(data)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+			*pushGatewayURL, *pushJob, *pushGrouping, *pushInterval = server.URL, "smartmon_exporter", test.grouping, 0
+
+			registry := prometheus.NewRegistry()
+			gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "smartmon_test", Help: "test"})
+			registry.MustRegister(gauge)
+			if err := runPush(registry); err != nil {
+				t.Fatal(err)
+			}
+			if method != http.MethodPut || path != test.path {
+				t.Errorf("expected PUT %s, got %s %s", test.path, method, path)
+			}
+			if !strings.Contains(body, "smartmon_test") {
+				t.Errorf("expected the pushed metrics to contain smartmon_test, got %q", body)
+			}
+		})
+	}
+}
+
+func TestRunPeriodicallyOnce(t *testing.T) {
+	calls := 0
+	err := runPeriodically(0, func() error {
+		calls++
+		return errors.New("unreachable")
+	}, "test")
+	if calls != 1 || err == nil || err.Error() != "unreachable" {
+		t.Errorf("expected a single call returning its error, got %d calls and %v", calls, err)
+	}
+}
@@ -0,0 +1,186 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	remoteWriteURL          = kingpin.Flag("remote-write.url", "Send metrics to this Prometheus remote_write endpoint instead of serving them over HTTP.").Default("").String()
+	remoteWriteUsername     = kingpin.Flag("remote-write.username", "Username for basic authentication with the remote_write endpoint.").Default("").String()
+	remoteWritePasswordFile = kingpin.Flag("remote-write.password-file", "File containing the password for basic authentication with the remote_write endpoint.").Default("").String()
+	remoteWriteCAFile       = kingpin.Flag("remote-write.tls.ca-file", "CA certificate to verify the remote_write endpoint.").Default("").String()
+	remoteWriteCertFile     = kingpin.Flag("remote-write.tls.cert-file", "Client certificate for TLS authentication with the remote_write endpoint.").Default("").String()
+	remoteWriteKeyFile      = kingpin.Flag("remote-write.tls.key-file", "Client key for TLS authentication with the remote_write endpoint.").Default("").String()
+	remoteWriteInsecure     = kingpin.Flag("remote-write.tls.insecure-skip-verify", "Disable verification of the remote_write endpoint certificate.").Default("false").Bool()
+	remoteWriteInterval     = kingpin.Flag("remote-write.interval", "Interval between sends, 0 sends once and exits.").Default("0s").Duration()
+	remoteWriteTimeout      = kingpin.Flag("remote-write.timeout", "Timeout of a request to the remote_write endpoint.").Default("30s").Duration()
+)
+
+// remoteWriter sends the gathered metrics to a remote_write endpoint
+type remoteWriter struct {
+	url      string
+	client   *http.Client
+	gatherer prometheus.Gatherer
+}
+
+// newRemoteWriter creates a remote writer configured by the remote-write flags
+func newRemoteWriter(gatherer prometheus.Gatherer) (*remoteWriter, error) {
+	cfg := config.HTTPClientConfig{
+		TLSConfig: config.TLSConfig{
+			CAFile:             *remoteWriteCAFile,
+			CertFile:           *remoteWriteCertFile,
+			KeyFile:            *remoteWriteKeyFile,
+			InsecureSkipVerify: *remoteWriteInsecure,
+		},
+	}
+	if *remoteWriteUsername != "" {
+		cfg.BasicAuth = &config.BasicAuth{
+			Username:     *remoteWriteUsername,
+			PasswordFile: *remoteWritePasswordFile,
+		}
+	}
+	client, err := config.NewClientFromConfig(cfg, "remote_write", false, false)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteWriter{
+		url:      *remoteWriteURL,
+		client:   client,
+		gatherer: gatherer,
+	}, nil
+}
+
+// runRemoteWrite sends the metrics once, or on every interval until the
+// exporter is terminated
+func runRemoteWrite(gatherer prometheus.Gatherer) error {
+	writer, err := newRemoteWriter(gatherer)
+	if err != nil {
+		return err
+	}
+	return runPeriodically(*remoteWriteInterval, writer.send, *remoteWriteURL)
+}
+
+// send gathers the metrics and sends them as a snappy compressed
+// protobuf WriteRequest
+func (w *remoteWriter) send() error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	req := toWriteRequest(families, time.Now())
+	data, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *remoteWriteTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := w.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// toWriteRequest converts the gathered metric families to a WriteRequest.
+// Summaries and histograms are expanded into their _sum, _count, quantile
+// and bucket series like Prometheus does when scraping.
+func toWriteRequest(families []*dto.MetricFamily, now time.Time) *prompb.WriteRequest {
+	req := &prompb.WriteRequest{}
+	defaultTimestamp := now.UnixNano() / int64(time.Millisecond)
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			timestamp := defaultTimestamp
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			add := func(suffix string, value float64, extraName, extraValue string) {
+				labels := []prompb.Label{{Name: model.MetricNameLabel, Value: name + suffix}}
+				for _, lp := range m.GetLabel() {
+					labels = append(labels, prompb.Label{Name: lp.GetName(), Value: lp.GetValue()})
+				}
+				if extraName != "" {
+					labels = append(labels, prompb.Label{Name: extraName, Value: extraValue})
+				}
+				sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+				req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+					Labels:  labels,
+					Samples: []prompb.Sample{{Value: value, Timestamp: timestamp}},
+				})
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue(), "", "")
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue(), "", "")
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					add("", q.GetValue(), model.QuantileLabel, formatFloat(q.GetQuantile()))
+				}
+				add("_sum", m.GetSummary().GetSampleSum(), "", "")
+				add("_count", float64(m.GetSummary().GetSampleCount()), "", "")
+			case dto.MetricType_HISTOGRAM:
+				for _, b := range m.GetHistogram().GetBucket() {
+					add("_bucket", float64(b.GetCumulativeCount()), model.BucketLabel, formatFloat(b.GetUpperBound()))
+				}
+				add("_bucket", float64(m.GetHistogram().GetSampleCount()), model.BucketLabel, "+Inf")
+				add("_sum", m.GetHistogram().GetSampleSum(), "", "")
+				add("_count", float64(m.GetHistogram().GetSampleCount()), "", "")
+			default:
+				add("", m.GetUntyped().GetValue(), "", "")
+			}
+		}
+	}
+	return req
+}
+
+// formatFloat formats a quantile or bucket bound the same way as the
+// text exposition format
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
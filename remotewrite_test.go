@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// writeRequestSeries formats the series of the request as
+// name{label="value",...} value @timestamp
+func writeRequestSeries(req *prompb.WriteRequest) []string {
+	series := []string{}
+	for _, ts := range req.Timeseries {
+		name, labels := "", []string{}
+		for _, l := range ts.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			labels = append(labels, l.Name+"="+strconv.Quote(l.Value))
+		}
+		for _, s := range ts.Samples {
+			series = append(series, name+"{"+strings.Join(labels, ",")+"} "+formatFloat(s.Value)+" @"+strconv.FormatInt(s.Timestamp, 10))
+		}
+	}
+	return series
+}
+
+func TestToWriteRequest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_temperature_celsius", Help: "temperature"}, []string{"type", "disk"})
+	gauge.WithLabelValues("sat", "/dev/sda").Set(36)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds", Help: "duration", Buckets: []float64{1}})
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "test_size_bytes", Help: "size", Objectives: map[float64]float64{0.5: 0.05}})
+	summary.Observe(512)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_errors_total", Help: "errors"})
+	counter.Add(2)
+	cached := prometheus.NewMetricWithTimestamp(time.Unix(1600000000, 0), counter)
+	registry.MustRegister(gauge, histogram, summary, cachedCollector{cached})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	expected := []string{
+		`test_duration_seconds_bucket{le="1"} 1 @1700000000000`,
+		`test_duration_seconds_bucket{le="+Inf"} 2 @1700000000000`,
+		`test_duration_seconds_sum{} 2.5 @1700000000000`,
+		`test_duration_seconds_count{} 2 @1700000000000`,
+		// the timestamp of a cached metric is kept
+		`test_errors_total{} 2 @1600000000000`,
+		`test_size_bytes{quantile="0.5"} 512 @1700000000000`,
+		`test_size_bytes_sum{} 512 @1700000000000`,
+		`test_size_bytes_count{} 1 @1700000000000`,
+		// the labels are sorted by name
+		`test_temperature_celsius{disk="/dev/sda",type="sat"} 36 @1700000000000`,
+	}
+	if series := writeRequestSeries(toWriteRequest(families, now)); !reflect.DeepEqual(series, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(series, "\n"))
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for _, test := range []struct {
+		f        float64
+		expected string
+	}{
+		{0.5, "0.5"},
+		{1, "1"},
+		{1e-05, "1e-05"},
+		{math.Inf(+1), "+Inf"},
+	} {
+		if s := formatFloat(test.f); s != test.expected {
+			t.Errorf("expected %v to be formatted as %s, got %s", test.f, test.expected, s)
+		}
+	}
+}
+
+func TestRemoteWriterSend(t *testing.T) {
+	var headers http.Header
+	var req prompb.WriteRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		compressed, _ := ioutil.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err == nil {
+			err = req.Unmarshal(data)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	*remoteWriteURL = server.URL
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "smartmon_test", Help: "test"})
+	gauge.Set(1)
+	registry.MustRegister(gauge)
+	writer, err := newRemoteWriter(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.send(); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if headers.Get(name) != value {
+			t.Errorf("expected header %s: %s, got %q", name, value, headers.Get(name))
+		}
+	}
+	if len(req.Timeseries) != 1 || req.Timeseries[0].Samples[0].Value != 1 {
+		t.Errorf("expected the smartmon_test series, got %v", req.Timeseries)
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	})
+	if err := writer.send(); err == nil || !strings.Contains(err.Error(), "out of order sample") {
+		t.Errorf("expected the error of the endpoint, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pgier/smartmon-exporter/smart"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	rulesCmd            = kingpin.Command("rules", "Print recommended Prometheus alerting rules for the metrics as named with the current flags and configuration file.")
+	rulesMaxTemperature = rulesCmd.Flag("rules.max-temperature", "Temperature in degrees Celsius above which the devices are alerted on.").Default("55").Int()
+)
+
+// ruleGroups is a Prometheus rule file
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+func newRule(alert, expr, duration, severity, summary string) rule {
+	return rule{
+		Alert:       alert,
+		Expr:        expr,
+		For:         duration,
+		Labels:      map[string]string{"severity": severity},
+		Annotations: map[string]string{"summary": summary},
+	}
+}
+
+// alertingRules returns the recommended alerting rules.  The names of the
+// ATA attribute metrics depend on the options, e.g. attribute 5 is
+// smartmon_reallocated_sector_ct unless it is renamed in the configuration.
+func alertingRules(collectorOpts *smart.CollectorOptions, maxTemperature int) ruleGroups {
+	attr := func(id int, name string) string {
+		return collectorOpts.AttributeMetricName(smart.Attribute{ID: id, Name: name})
+	}
+	rules := []rule{
+		newRule("SmartDeviceUnhealthy",
+			"smartmon_device_smart_healthy == 0", "",
+			"critical", "{{ $labels.disk }} failed its SMART health self-assessment"),
+		newRule("SmartReallocatedSectorsIncreasing",
+			"increase("+attr(5, "Reallocated_Sector_Ct")+"_raw_value[1d]) > 0", "",
+			"warning", "{{ $labels.disk }} reallocated {{ $value }} sectors in the last day"),
+		newRule("SmartPendingSectors",
+			attr(197, "Current_Pending_Sector")+"_raw_value > 0", "1h",
+			"warning", "{{ $labels.disk }} has {{ $value }} sectors pending reallocation"),
+		newRule("SmartUncorrectableSectors",
+			attr(198, "Offline_Uncorrectable")+"_raw_value > 0", "1h",
+			"warning", "{{ $labels.disk }} has {{ $value }} uncorrectable sectors"),
+		newRule("SmartTemperatureHigh",
+			fmt.Sprintf("%s_raw_value > %d", attr(194, "Temperature_Celsius"), maxTemperature), "15m",
+			"warning", "{{ $labels.disk }} is at {{ $value }} degrees Celsius"),
+		newRule("SmartWearout",
+			fmt.Sprintf("%s_value < 10 or %s_value < 10", attr(177, "Wear_Leveling_Count"), attr(233, "Media_Wearout_Indicator")), "",
+			"warning", "{{ $labels.disk }} has {{ $value }}% of its endurance left"),
+		newRule("MMCWearout",
+			"smartmon_mmc_life_time_estimate >= 10 or smartmon_mmc_pre_eol_info >= 2", "",
+			"warning", "the eMMC {{ $labels.disk }} is near the end of its life"),
+		newRule("SmartmonCollectorError",
+			"smartmon_collector_error == 1", "1h",
+			"warning", "smartmon failed collecting {{ $labels.collector }} of {{ $labels.disk }}"),
+	}
+	if collectorOpts.AttributeIncreases {
+		rules = append(rules, newRule("SmartCriticalAttributeIncreasing",
+			"increase(smartmon_attribute_increase_total[1d]) > 0", "",
+			"warning", "attribute {{ $labels.smart_id }} of {{ $labels.disk }} increased by {{ $value }} in the last day"))
+	}
+	if collectorOpts.QuarantineFailures > 0 {
+		rules = append(rules, newRule("SmartDeviceQuarantined",
+			"smartmon_device_quarantined == 1", "",
+			"warning", "{{ $labels.disk }} keeps failing and is no longer queried"))
+	}
+	return ruleGroups{Groups: []ruleGroup{{Name: "smartmon", Rules: rules}}}
+}
+
+// writeRules prints the alerting rules as a Prometheus rule file
+func writeRules(w io.Writer, collectorOpts *smart.CollectorOptions, maxTemperature int) error {
+	content, err := yaml.Marshal(alertingRules(collectorOpts, maxTemperature))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// printRules runs the rules command and exits
+func printRules(collectorOpts *smart.CollectorOptions) {
+	if err := writeRules(os.Stdout, collectorOpts, *rulesMaxTemperature); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to print the rules:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,43 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// shutdownOnSignal shuts the server down on the first signal received,
+// waiting up to timeout for the in-flight scrapes to complete, then closes
+// the collector to kill the smartctl commands still running.  The channel
+// returned is closed once the collector is closed.
+func shutdownOnSignal(server *http.Server, c collector, term <-chan os.Signal, timeout time.Duration) <-chan struct{} {
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		sig := <-term
+		log.Infoln("Received", sig, "shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warnln("Scrapes still in progress after", timeout, "cancelling them:", err)
+		}
+		// kill and wait for any smartctl commands still running
+		c.Close()
+	}()
+	return shutdown
+}
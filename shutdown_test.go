@@ -0,0 +1,115 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// closingCollector records when it is closed
+type closingCollector struct {
+	readyCollector
+	closed chan struct{}
+}
+
+func (c *closingCollector) Close() {
+	close(c.closed)
+}
+
+// serveBlocking serves requests which block until release is closed, the
+// entered channel receives every request served
+func serveBlocking(t *testing.T, release chan struct{}) (*http.Server, string, chan struct{}) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entered := make(chan struct{}, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.Write([]byte("scraped"))
+	})}
+	go server.Serve(l)
+	return server, "http://" + l.Addr().String() + "/metrics", entered
+}
+
+func TestShutdownWaitsForScrapes(t *testing.T) {
+	release := make(chan struct{})
+	server, url, entered := serveBlocking(t, release)
+	c := &closingCollector{closed: make(chan struct{})}
+	term := make(chan os.Signal, 1)
+	shutdown := shutdownOnSignal(server, c, term, time.Minute)
+
+	scraped := make(chan error)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		scraped <- err
+	}()
+	<-entered
+	term <- os.Interrupt
+	// the collector is not closed while the scrape is in flight
+	select {
+	case <-c.closed:
+		t.Fatal("expected the collector to be closed after the scrape")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-scraped; err != nil {
+		t.Fatal("expected the scrape in flight to complete, got", err)
+	}
+	select {
+	case <-shutdown:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the server to shut down")
+	}
+	select {
+	case <-c.closed:
+	default:
+		t.Fatal("expected the collector to be closed")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, url, entered := serveBlocking(t, release)
+	c := &closingCollector{closed: make(chan struct{})}
+	term := make(chan os.Signal, 1)
+	shutdown := shutdownOnSignal(server, c, term, 50*time.Millisecond)
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-entered
+	term <- os.Interrupt
+	// the collector is closed to cancel the scrape still in progress
+	select {
+	case <-shutdown:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the server to shut down after the timeout")
+	}
+	select {
+	case <-c.closed:
+	default:
+		t.Fatal("expected the collector to be closed")
+	}
+}
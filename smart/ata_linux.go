@@ -0,0 +1,194 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build linux
+// +build linux
+
+package smart
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"unsafe"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sgIO is the SG_IO ioctl sending a SCSI command to a device
+	sgIO = 0x2285
+	// sgDxferNone and sgDxferFromDev are the transfer directions of SG_IO
+	sgDxferNone    = -1
+	sgDxferFromDev = -3
+	// sgTimeoutMs is the time the kernel waits for the command to complete
+	sgTimeoutMs = 20000
+	// senseSize is the size of the sense buffer, large enough for the ATA
+	// Status Return descriptor
+	senseSize = 32
+
+	// ataPassThrough16 is the opcode of the SCSI ATA PASS-THROUGH(16) command
+	ataPassThrough16 = 0x85
+	// ataProtocolNonData and ataProtocolPIODataIn are the protocols of the
+	// ATA PASS-THROUGH command, shifted into the second byte of the CDB
+	ataProtocolNonData   = 3 << 1
+	ataProtocolPIODataIn = 4 << 1
+	// ataDataIn transfers a single block from the device
+	// (T_DIR=1, BYT_BLOK=1, T_LENGTH=sector count)
+	ataDataIn = 0x0e
+	// ataCheckCondition returns the ATA registers in the sense data (CK_COND=1)
+	ataCheckCondition = 0x20
+
+	// the ATA commands and SMART features used
+	ataIdentifyDevice   = 0xec
+	ataCheckPowerMode   = 0xe5
+	ataSmart            = 0xb0
+	ataSmartReadData    = 0xd0
+	ataSmartReadLimits  = 0xd1
+	ataSmartReturnState = 0xda
+	// ataSmartLBAMid and ataSmartLBAHigh are the signature of the SMART
+	// commands, the device returns them swapped if a threshold is exceeded
+	ataSmartLBAMid      = 0x4f
+	ataSmartLBAHigh     = 0xc2
+	ataSmartFailLBAMid  = 0xf4
+	ataSmartFailLBAHigh = 0x2c
+)
+
+// sgIOHdr is struct sg_io_hdr of scsi/sg.h
+type sgIOHdr struct {
+	interfaceID    int32
+	dxferDirection int32
+	cmdLen         uint8
+	mxSbLen        uint8
+	iovecCount     uint16
+	dxferLen       uint32
+	dxferp         uintptr
+	cmdp           uintptr
+	sbp            uintptr
+	timeout        uint32
+	flags          uint32
+	packID         int32
+	usrPtr         uintptr
+	status         uint8
+	maskedStatus   uint8
+	msgStatus      uint8
+	sbLenWr        uint8
+	hostStatus     uint16
+	driverStatus   uint16
+	resid          int32
+	duration       uint32
+	info           uint32
+}
+
+// ataRegisters are the ATA registers returned by a command with CK_COND set
+type ataRegisters struct {
+	count   uint8
+	lbaMid  uint8
+	lbaHigh uint8
+}
+
+// ataCommand sends an ATA command through SCSI ATA PASS-THROUGH(16).  The
+// command reads a block into data if it is not nil, otherwise the ATA
+// registers returned by the device are read from the sense data.
+func ataCommand(name string, command, feature, lbaMid, lbaHigh uint8, data []byte) (ataRegisters, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return ataRegisters{}, err
+	}
+	defer f.Close()
+
+	cdb := [16]byte{0: ataPassThrough16, 4: feature, 6: 1, 10: lbaMid, 12: lbaHigh, 14: command}
+	sense := make([]byte, senseSize)
+	hdr := sgIOHdr{
+		interfaceID: 'S',
+		cmdLen:      uint8(len(cdb)),
+		mxSbLen:     uint8(len(sense)),
+		cmdp:        uintptr(unsafe.Pointer(&cdb[0])),
+		sbp:         uintptr(unsafe.Pointer(&sense[0])),
+		timeout:     sgTimeoutMs,
+	}
+	if data != nil {
+		cdb[1], cdb[2] = ataProtocolPIODataIn, ataDataIn
+		hdr.dxferDirection = sgDxferFromDev
+		hdr.dxferLen = uint32(len(data))
+		hdr.dxferp = uintptr(unsafe.Pointer(&data[0]))
+	} else {
+		cdb[1], cdb[2] = ataProtocolNonData, ataCheckCondition
+		hdr.dxferDirection = sgDxferNone
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), sgIO, uintptr(unsafe.Pointer(&hdr)))
+	runtime.KeepAlive(cdb)
+	runtime.KeepAlive(sense)
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return ataRegisters{}, errors.New("SG_IO failed on " + name + ": " + errno.Error())
+	}
+	if data != nil {
+		if hdr.status != 0 || hdr.hostStatus != 0 {
+			return ataRegisters{}, errors.New("ATA command 0x" + strconv.FormatUint(uint64(command), 16) + " failed on " + name)
+		}
+		return ataRegisters{}, nil
+	}
+	// the ATA Status Return descriptor follows the descriptor format sense header
+	if sense[0] != 0x72 || sense[8] != 0x09 {
+		return ataRegisters{}, errors.New("no ATA registers returned by " + name)
+	}
+	return ataRegisters{count: sense[8+5], lbaMid: sense[8+9], lbaHigh: sense[8+11]}, nil
+}
+
+// readATASmartData reads the SMART attributes and thresholds
+func readATASmartData(name string) ([]byte, []byte, error) {
+	data := make([]byte, parser.ATASectorSize)
+	if _, err := ataCommand(name, ataSmart, ataSmartReadData, ataSmartLBAMid, ataSmartLBAHigh, data); err != nil {
+		return nil, nil, err
+	}
+	thresholds := make([]byte, parser.ATASectorSize)
+	if _, err := ataCommand(name, ataSmart, ataSmartReadLimits, ataSmartLBAMid, ataSmartLBAHigh, thresholds); err != nil {
+		return nil, nil, err
+	}
+	return data, thresholds, nil
+}
+
+// readATAIdentify reads the IDENTIFY DEVICE data
+func readATAIdentify(name string) ([]byte, error) {
+	data := make([]byte, parser.ATASectorSize)
+	_, err := ataCommand(name, ataIdentifyDevice, 0, 0, 0, data)
+	return data, err
+}
+
+// readATAHealthy returns false if SMART RETURN STATUS reports an exceeded threshold
+func readATAHealthy(name string) (bool, error) {
+	regs, err := ataCommand(name, ataSmart, ataSmartReturnState, ataSmartLBAMid, ataSmartLBAHigh, nil)
+	if err != nil {
+		return false, err
+	}
+	return !(regs.lbaMid == ataSmartFailLBAMid && regs.lbaHigh == ataSmartFailLBAHigh), nil
+}
+
+// readATAPowerMode reads the power mode with CHECK POWER MODE, which does
+// not wake up the device
+func readATAPowerMode(name string) (PowerMode, error) {
+	regs, err := ataCommand(name, ataCheckPowerMode, 0, 0, 0, nil)
+	if err != nil {
+		return PowerModeUnknown, err
+	}
+	switch regs.count {
+	case 0x00:
+		return PowerModeStandby, nil
+	case 0x80:
+		return PowerModeIdle, nil
+	case 0xff:
+		return PowerModeActive, nil
+	}
+	return PowerModeUnknown, nil
+}
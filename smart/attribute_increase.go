@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// increaseAttributes are the IDs of the ATA attributes whose increases are
+// counted: the reallocated sectors, the reported uncorrectable errors, the
+// pending sectors and the offline uncorrectable sectors
+var increaseAttributes = []int{5, 187, 197, 198}
+
+var smartMonAttributeIncreaseDesc = prometheus.NewDesc("smartmon_attribute_increase_total", "sum of the increases of the raw value of the ATA attributes 5, 187, 197 and 198 between collections, the decreases are ignored", []string{"disk", "type", "by_id", "wwn", "serial", "smart_id"}, noConstLabels)
+
+// attributeIncrease is the last raw value of an attribute and the sum of
+// its increases
+type attributeIncrease struct {
+	Raw   float64 `json:"raw"`
+	Total float64 `json:"total"`
+}
+
+// recordIncreases adds the increases of the raw values of the
+// increaseAttributes since the last collection of the device and returns
+// their sums by ID.  The first raw value of an attribute is its baseline,
+// so its sum starts at 0, and a decrease, e.g. a pending sector remapped,
+// only lowers the baseline.
+func (c *Collector) recordIncreases(d Device, attrs []Attribute) map[int]float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	totals := map[int]float64{}
+	for _, id := range increaseAttributes {
+		for _, attr := range attrs {
+			if attr.ID != id {
+				continue
+			}
+			if st.increases == nil {
+				st.increases = map[int]attributeIncrease{}
+			}
+			increase, found := st.increases[id]
+			if found && attr.Raw > increase.Raw {
+				increase.Total += attr.Raw - increase.Raw
+			}
+			increase.Raw = attr.Raw
+			st.increases[id] = increase
+			totals[id] = increase.Total
+			break
+		}
+	}
+	return totals
+}
+
+// collectAttributeIncreases collects the sums of the increases of the
+// increaseAttributes reported by an ATA device
+func (c *Collector) collectAttributeIncreases(ch chan<- prometheus.Metric, d Device, attrs []Attribute) {
+	for id, total := range c.recordIncreases(d, attrs) {
+		c.constMetric(ch, smartMonAttributeIncreaseDesc, prometheus.CounterValue, total, append(c.labelValues(d), strconv.Itoa(id))...)
+	}
+}
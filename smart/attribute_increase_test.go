@@ -0,0 +1,43 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordIncreases(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{AttributeIncreases: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+
+	for _, test := range []struct {
+		attrs  []Attribute
+		totals map[int]float64
+	}{
+		// the first collection is the baseline, whatever the raw values
+		{[]Attribute{{ID: 5, Raw: 100}, {ID: 9, Raw: 1000}, {ID: 197, Raw: 8}}, map[int]float64{5: 0, 197: 0}},
+		{[]Attribute{{ID: 5, Raw: 104}, {ID: 9, Raw: 1001}, {ID: 197, Raw: 10}}, map[int]float64{5: 4, 197: 2}},
+		// the pending sectors were remapped, the decrease is ignored and
+		// the next increase counted from the lower value
+		{[]Attribute{{ID: 5, Raw: 110}, {ID: 197, Raw: 0}}, map[int]float64{5: 10, 197: 2}},
+		{[]Attribute{{ID: 5, Raw: 110}, {ID: 197, Raw: 3}, {ID: 198, Raw: 1}}, map[int]float64{5: 10, 197: 5, 198: 0}},
+	} {
+		if totals := c.recordIncreases(d, test.attrs); !reflect.DeepEqual(totals, test.totals) {
+			t.Errorf("expected %v for %+v, got %v", test.totals, test.attrs, totals)
+		}
+	}
+}
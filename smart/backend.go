@@ -0,0 +1,186 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Backend reads the SMART data of the devices
+type Backend interface {
+	// Scan gets the list of available devices
+	Scan(ctx context.Context) ([]Device, error)
+	// PowerMode gets the power mode of the device without waking it up
+	PowerMode(ctx context.Context, d *Device) (PowerMode, error)
+	// Info gets the identity and health of the device
+	Info(ctx context.Context, d *Device) (*DeviceInfo, error)
+	// Attributes gets the SMART attributes of the device
+	Attributes(ctx context.Context, d *Device) ([]Attribute, error)
+	// SelfTests gets the self-test log of the device
+	SelfTests(ctx context.Context, d *Device) ([]SelfTest, error)
+}
+
+// The smartctl backends, see also BackendNative
+const (
+	// BackendSmartctl parses the JSON output of smartctl if the installed
+	// build outputs valid JSON according to its Features and
+	// Options.DisableJSON is not set, the text output otherwise
+	BackendSmartctl = "smartctl"
+	// BackendSmartctlText always parses the text output of smartctl
+	BackendSmartctlText = "smartctl-text"
+	// BackendSmartctlJSON always parses the JSON output of smartctl
+	BackendSmartctlJSON = "smartctl-json"
+)
+
+var (
+	// backendsMtx protects backends
+	backendsMtx sync.RWMutex
+	// backends creates the registered backends by name
+	backends = map[string]func(o *Options) Backend{
+		BackendSmartctl:     func(o *Options) Backend { return &smartctlBackend{o: o} },
+		BackendSmartctlText: func(o *Options) Backend { return &textBackend{o: o} },
+		BackendSmartctlJSON: func(o *Options) Backend { return &jsonBackend{o: o} },
+		BackendNative:       func(o *Options) Backend { return &nativeBackend{fallback: &smartctlBackend{o: o}} },
+	}
+)
+
+// RegisterBackend registers a backend selectable with Options.Backend, e.g.
+// a fake backend replaying recorded data in tests.  A backend registered
+// with the name of an existing one replaces it.
+func RegisterBackend(name string, create func(o *Options) Backend) {
+	backendsMtx.Lock()
+	defer backendsMtx.Unlock()
+	backends[name] = create
+}
+
+// NewBackend creates the backend selected by the options, BackendSmartctl
+// if none is selected.  Returns an error if the backend is not registered.
+func NewBackend(o *Options) (Backend, error) {
+	name := BackendSmartctl
+	if o != nil && o.Backend != "" {
+		name = o.Backend
+	}
+	backendsMtx.RLock()
+	create, found := backends[name]
+	backendsMtx.RUnlock()
+	if !found {
+		return nil, errors.New("unknown backend: " + name)
+	}
+	return create(o), nil
+}
+
+// smartctlBackend selects the JSON or the text backend on every call, so a
+// smartctl upgrade is picked up without restarting.  A command whose JSON
+// output fails is run again with the text output.
+type smartctlBackend struct {
+	o *Options
+}
+
+func (b *smartctlBackend) backend(ctx context.Context) Backend {
+	if b.o.json(ctx) {
+		return &jsonBackend{o: b.o}
+	}
+	return &textBackend{o: b.o}
+}
+
+func (b *smartctlBackend) Scan(ctx context.Context) ([]Device, error) {
+	backend := b.backend(ctx)
+	devices, err := backend.Scan(ctx)
+	if _, ok := backend.(*jsonBackend); ok && fallback(ctx, "scan", nil, err) {
+		return (&textBackend{o: b.o}).Scan(ctx)
+	}
+	return devices, err
+}
+
+func (b *smartctlBackend) PowerMode(ctx context.Context, d *Device) (PowerMode, error) {
+	return b.backend(ctx).PowerMode(ctx, d)
+}
+
+func (b *smartctlBackend) Info(ctx context.Context, d *Device) (*DeviceInfo, error) {
+	backend := b.backend(ctx)
+	info, err := backend.Info(ctx, d)
+	if _, ok := backend.(*jsonBackend); ok && fallback(ctx, "info", d, err) {
+		return (&textBackend{o: b.o}).Info(ctx, d)
+	}
+	return info, err
+}
+
+func (b *smartctlBackend) Attributes(ctx context.Context, d *Device) ([]Attribute, error) {
+	backend := b.backend(ctx)
+	attrs, err := backend.Attributes(ctx, d)
+	if _, ok := backend.(*jsonBackend); ok && fallback(ctx, "attributes", d, err) {
+		return (&textBackend{o: b.o}).Attributes(ctx, d)
+	}
+	return attrs, err
+}
+
+func (b *smartctlBackend) SelfTests(ctx context.Context, d *Device) ([]SelfTest, error) {
+	backend := b.backend(ctx)
+	tests, err := backend.SelfTests(ctx, d)
+	if _, ok := backend.(*jsonBackend); ok && fallback(ctx, "selftest", d, err) {
+		return (&textBackend{o: b.o}).SelfTests(ctx, d)
+	}
+	return tests, err
+}
+
+// textBackend parses the text output of smartctl
+type textBackend struct {
+	o *Options
+}
+
+func (b *textBackend) Scan(ctx context.Context) ([]Device, error) {
+	return scanDevices(ctx, b.o)
+}
+
+func (b *textBackend) PowerMode(ctx context.Context, d *Device) (PowerMode, error) {
+	return d.powerMode(ctx, b.o)
+}
+
+func (b *textBackend) Info(ctx context.Context, d *Device) (*DeviceInfo, error) {
+	return d.info(ctx, b.o)
+}
+
+func (b *textBackend) Attributes(ctx context.Context, d *Device) ([]Attribute, error) {
+	return d.attributes(ctx, b.o)
+}
+
+func (b *textBackend) SelfTests(ctx context.Context, d *Device) ([]SelfTest, error) {
+	return d.selfTests(ctx, b.o)
+}
+
+// jsonBackend parses the JSON output of smartctl.  The power mode is only
+// reported by the text output.
+type jsonBackend struct {
+	o *Options
+}
+
+func (b *jsonBackend) Scan(ctx context.Context) ([]Device, error) {
+	return scanDevicesJSON(ctx, b.o)
+}
+
+func (b *jsonBackend) PowerMode(ctx context.Context, d *Device) (PowerMode, error) {
+	return d.powerMode(ctx, b.o)
+}
+
+func (b *jsonBackend) Info(ctx context.Context, d *Device) (*DeviceInfo, error) {
+	return d.infoJSON(ctx, b.o)
+}
+
+func (b *jsonBackend) Attributes(ctx context.Context, d *Device) ([]Attribute, error) {
+	return d.attributesJSON(ctx, b.o)
+}
+
+func (b *jsonBackend) SelfTests(ctx context.Context, d *Device) ([]SelfTest, error) {
+	return d.selfTestsJSON(ctx, b.o)
+}
@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeBackend returns fixed data without running smartctl
+type fakeBackend struct{}
+
+func (b *fakeBackend) Scan(ctx context.Context) ([]Device, error) {
+	return []Device{{Name: "/dev/sda", Type: "sat"}}, nil
+}
+
+func (b *fakeBackend) PowerMode(ctx context.Context, d *Device) (PowerMode, error) {
+	return PowerModeStandby, nil
+}
+
+func (b *fakeBackend) Info(ctx context.Context, d *Device) (*DeviceInfo, error) {
+	return &DeviceInfo{Healthy: true, Attributes: map[string]string{"device_model": "fake"}}, nil
+}
+
+func (b *fakeBackend) Attributes(ctx context.Context, d *Device) ([]Attribute, error) {
+	return []Attribute{{ID: 194, Name: "Temperature_Celsius", Raw: 36}}, nil
+}
+
+func (b *fakeBackend) SelfTests(ctx context.Context, d *Device) ([]SelfTest, error) {
+	return nil, nil
+}
+
+func TestRegisterBackend(t *testing.T) {
+	RegisterBackend("fake", func(o *Options) Backend { return &fakeBackend{} })
+	opts := &Options{Backend: "fake"}
+	ctx := context.Background()
+
+	devices, err := Scan(ctx, opts)
+	if err != nil || len(devices) != 1 || devices[0].Name != "/dev/sda" {
+		t.Fatal("unexpected devices", devices, err)
+	}
+	if active, err := devices[0].Active(ctx, opts); err != nil || active {
+		t.Fatal("device in standby should not be active", err)
+	}
+	if info, err := devices[0].Info(ctx, opts); err != nil || info.Attributes["device_model"] != "fake" {
+		t.Fatal("unexpected info", info, err)
+	}
+	if attrs, err := devices[0].Attributes(ctx, opts); err != nil || len(attrs) != 1 || attrs[0].Raw != 36 {
+		t.Fatal("unexpected attributes", attrs, err)
+	}
+
+	if _, err := Scan(ctx, &Options{Backend: "unknown"}); err == nil {
+		t.Fatal("expected an error using an unknown backend")
+	}
+	if _, err := NewCollector(nil, &CollectorOptions{Devices: []DeviceOptions{{Name: "/dev/sda", Backend: "unknown"}}}); err == nil {
+		t.Fatal("expected an error configuring an unknown device backend")
+	}
+}
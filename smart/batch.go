@@ -0,0 +1,95 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// smartctlBatchOpts reads the info, the health and the attributes of a
+// device with a single command in a batched collection
+var smartctlBatchOpts = []string{"-i", "-H", "-A"}
+
+type batchKey struct{}
+
+// batch holds the output of the batched commands of a collection, by
+// device and output format
+type batch struct {
+	mtx     sync.Mutex
+	outputs map[string]*batchOutput
+}
+
+// batchOutput is the output of a batched command, once ran
+type batchOutput struct {
+	once   sync.Once
+	output []byte
+	err    error
+}
+
+// withBatch returns a context whose collection reads the info and the
+// attributes of a device with a single smartctl command, e.g. to keep a
+// device busy for a shorter time in low power mode
+func withBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchKey{}, &batch{outputs: map[string]*batchOutput{}})
+}
+
+// batchFrom returns the batch of the context, nil if not batched
+func batchFrom(ctx context.Context) *batch {
+	b, _ := ctx.Value(batchKey{}).(*batch)
+	return b
+}
+
+// batched returns true if the options are read by the batched command
+func batched(opts []string) bool {
+	key := strings.Join(opts, " ")
+	return key == strings.Join(smartctlDeviceInfoOpts, " ") || key == strings.Join(smartctlDeviceMetricOpts, " ")
+}
+
+// deviceSmartCtl runs smartctl with the options for the device and returns
+// its output.  The messages of the JSON output are recorded.  In a batched
+// collection the info and the attributes are read by a single command, run
+// once for both.
+func (d *Device) deviceSmartCtl(ctx context.Context, o *Options, opts []string, json bool) ([]byte, error) {
+	run := func(opts []string) ([]byte, error) {
+		args := append(append([]string{}, opts...), "-d", d.Type, d.Name)
+		if json {
+			args = useJSON(args)
+		}
+		output, _, err := smartCtl(ctx, o, args...)
+		if json {
+			recordMessages(d, output)
+		}
+		return output, err
+	}
+	b := batchFrom(ctx)
+	if b == nil || !batched(opts) {
+		return run(opts)
+	}
+	key := d.Name
+	if json {
+		key += " " + smartctlJSONOption
+	}
+	b.mtx.Lock()
+	out, found := b.outputs[key]
+	if !found {
+		out = &batchOutput{}
+		b.outputs[key] = out
+	}
+	b.mtx.Unlock()
+	out.once.Do(func() {
+		out.output, out.err = run(smartctlBatchOpts)
+	})
+	return out.output, out.err
+}
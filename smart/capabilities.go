@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// smartctlCapabilitiesOpts reads the General SMART Values of the device
+var smartctlCapabilitiesOpts = []string{"-c"}
+
+// The metrics of the General SMART Values of ATA devices, e.g. the progress
+// of a self-test
+var (
+	smartMonSelfTestProgressDesc   = newDesc("smartmon_self_test_progress_ratio", deviceLabelNames)
+	smartMonSelfTestInProgressDesc = prometheus.NewDesc("smartmon_self_test_in_progress", "1 if a self-test is in progress", deviceLabelNames, noConstLabels)
+	smartMonSelfTestStatusDesc     = prometheus.NewDesc("smartmon_self_test_execution_status", "self-test execution status reported by smartctl -c, 0 if the last self-test completed without error, 15 if one is in progress", deviceLabelNames, noConstLabels)
+	smartMonSelfTestPollingDesc    = newDesc("smartmon_self_test_polling_seconds", []string{"disk", "type", "by_id", "wwn", "serial", "test"})
+	smartMonOfflineStatusDesc      = prometheus.NewDesc("smartmon_offline_collection_status", "offline data collection status reported by smartctl -c, 0 if never started, 2 if completed without error, 3 if in progress, 4 if suspended, 5 and 6 if aborted", deviceLabelNames, noConstLabels)
+	smartMonOfflineAutoDesc        = prometheus.NewDesc("smartmon_offline_collection_auto_enabled", "1 if the automatic offline data collection is enabled", deviceLabelNames, noConstLabels)
+	smartMonOfflineSecondsDesc     = prometheus.NewDesc("smartmon_offline_collection_duration_seconds", "time to complete the offline data collection", deviceLabelNames, noConstLabels)
+)
+
+// capabilities gets the General SMART Values reported by 'smartctl -c'
+func (d *Device) capabilities(ctx context.Context, o *Options) (*parser.Capabilities, error) {
+	opts := append(smartctlCapabilitiesOpts, "-d", d.Type, d.Name)
+	output, _, err := smartCtl(ctx, o, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseCapabilities(output), nil
+}
+
+// collectCapabilities collects the offline data collection and self-test
+// execution status of the device, nothing if the device reports none
+func (c *Collector) collectCapabilities(ctx context.Context, ch chan<- prometheus.Metric, d Device) error {
+	caps, err := d.capabilities(ctx, c.deviceOpts(d))
+	if err != nil || caps == nil {
+		return err
+	}
+	labels := c.labelValues(d)
+	done := float64(100 - caps.SelfTestRemainingPercent())
+	c.constMetric(ch, smartMonSelfTestProgressDesc, prometheus.GaugeValue, done/100, labels...)
+	c.legacyMetric(ch, legacySelfTestProgressDesc, prometheus.GaugeValue, done, labels...)
+	c.constMetric(ch, smartMonSelfTestInProgressDesc, prometheus.GaugeValue, boolToMetric(caps.SelfTestInProgress()), labels...)
+	c.constMetric(ch, smartMonSelfTestStatusDesc, prometheus.GaugeValue, float64(caps.SelfTestStatus>>4), labels...)
+	for test, minutes := range caps.PollingMinutes {
+		c.constMetric(ch, smartMonSelfTestPollingDesc, prometheus.GaugeValue, float64(minutes)*60, append(c.labelValues(d), test)...)
+		c.legacyMetric(ch, legacySelfTestPollingDesc, prometheus.GaugeValue, float64(minutes), append(c.labelValues(d), test)...)
+	}
+	c.constMetric(ch, smartMonOfflineStatusDesc, prometheus.GaugeValue, float64(caps.OfflineStatus&0x7f), labels...)
+	c.constMetric(ch, smartMonOfflineAutoDesc, prometheus.GaugeValue, boolToMetric(caps.OfflineStatus&0x80 != 0), labels...)
+	if caps.OfflineSeconds > 0 {
+		c.constMetric(ch, smartMonOfflineSecondsDesc, prometheus.GaugeValue, float64(caps.OfflineSeconds), labels...)
+	}
+	return nil
+}
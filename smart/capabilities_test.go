@@ -0,0 +1,37 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := fakeSmartctl(t, dir, "Self-test execution status:      ( 249)\tSelf-test routine in progress...\n", 0)
+	d := &Device{Name: "/dev/sda", Type: "sat"}
+	caps, err := d.capabilities(context.Background(), &Options{SmartctlPath: path})
+	if err != nil {
+		t.Fatal("unable to read the capabilities", err)
+	}
+	if caps == nil || 100-caps.SelfTestRemainingPercent() != 10 {
+		t.Fatal("expected a self-test 10% done", caps)
+	}
+}
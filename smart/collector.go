@@ -13,10 +13,16 @@
 package smart
 
 import (
+	"context"
 	"errors"
+	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/pgier/smartmon-exporter/smart/parser"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 )
@@ -24,85 +30,1022 @@ import (
 var (
 	noLabels      = []string{}
 	noConstLabels = prometheus.Labels{}
+	// deviceLabelNames identify the device of a metric
+	deviceLabelNames = []string{"disk", "type", "by_id", "wwn", "serial"}
+	// namespaceLabelNames identify an NVMe namespace of a device
+	namespaceLabelNames = []string{"disk", "type", "by_id", "wwn", "serial", "namespace"}
 
-	smartMonVersionDesc = prometheus.NewDesc("smartmon_version", "version reported by smartctl -V", []string{"vesion"}, prometheus.Labels{})
-	smartMonRunDesc     = prometheus.NewDesc("smartmon_smartctl_run", "contains current unix time", []string{"disk", "type"}, noConstLabels)
-	smartMonActiveDesc  = prometheus.NewDesc("smartmon_device_active", "shows result of smartctl -n standby", []string{"disk", "type"}, noConstLabels)
+	smartMonVersionDesc        = prometheus.NewDesc("smartmon_version", "version reported by smartctl -V", []string{"version", "svn_revision"}, prometheus.Labels{})
+	smartMonRunDesc            = prometheus.NewDesc("smartmon_smartctl_run", "contains current unix time", []string{"disk", "type"}, noConstLabels)
+	smartMonActiveDesc         = prometheus.NewDesc("smartmon_device_active", "shows result of smartctl -n standby", deviceLabelNames, noConstLabels)
+	smartMonPrivilegedDesc     = prometheus.NewDesc("smartmon_exporter_privileged", "1 if smartctl has the privileges required to access the devices", noLabels, noConstLabels)
+	smartMonNodeReadableDesc   = prometheus.NewDesc("smartmon_device_node_readable", "1 if the device node exists and can be read by smartctl, 0 if it is missing or the permissions are insufficient", deviceLabelNames, noConstLabels)
+	smartMonPowerModeDesc      = prometheus.NewDesc("smartmon_device_power_mode", "power mode of the device reported by smartctl -n standby, 1 for the current mode", []string{"disk", "type", "by_id", "wwn", "serial", "mode"}, noConstLabels)
+	smartMonWakeupsAvoidedDesc = prometheus.NewDesc("smartmon_device_wakeups_avoided_total", "number of scrapes which skipped the device to avoid waking it up", deviceLabelNames, noConstLabels)
+	smartMonWokenDesc          = prometheus.NewDesc("smartmon_device_woken_total", "number of times the exporter woke up the device from standby or sleep to collect its metrics", deviceLabelNames, noConstLabels)
+	smartMonLastCollectedDesc  = prometheus.NewDesc("smartmon_device_last_collected_timestamp_seconds", "unix time the metrics of the device were last collected without error", deviceLabelNames, noConstLabels)
+	smartMonIntervalDesc       = prometheus.NewDesc("smartmon_device_collection_interval_seconds", "interval on which the metrics of the device are collected in the background", deviceLabelNames, noConstLabels)
+	smartMonBuildErrorsDesc    = prometheus.NewDesc("smartmon_metric_build_errors_total", "number of metrics which could not be built, e.g. because of invalid attributes reported by a device", noLabels, noConstLabels)
+	smartMonErrorDesc          = prometheus.NewDesc("smartmon_collector_error", "error encountered while collecting smartmon metrics, by collector and reason: timeout, permission, not_found, exit_status, parse or other", []string{"disk", "type", "by_id", "wwn", "serial", "collector", "reason"}, noConstLabels)
 )
 
+// The metrics of the link of the device reported by the -i option
+var (
+	smartMonInterfaceSpeedDesc    = prometheus.NewDesc("smartmon_device_interface_speed_bytes_per_second", "speed negotiated by the link of the device, lower than the maximum speed if the link negotiated down", deviceLabelNames, noConstLabels)
+	smartMonInterfaceMaxSpeedDesc = prometheus.NewDesc("smartmon_device_interface_max_speed_bytes_per_second", "maximum speed of the link supported by the device", deviceLabelNames, noConstLabels)
+)
+
+// The capacity and the block sizes of the device reported by the -i option
+var (
+	smartMonCapacityDesc  = prometheus.NewDesc("smartmon_device_capacity_bytes", "user capacity of the device", deviceLabelNames, noConstLabels)
+	smartMonBlockSizeDesc = prometheus.NewDesc("smartmon_device_block_size_bytes", "size of the logical and physical blocks of the device", []string{"disk", "type", "by_id", "wwn", "serial", "block"}, noConstLabels)
+)
+
+var smartMonBelowThresholdDesc = prometheus.NewDesc("smartmon_attribute_below_threshold", "1 if the normalized value of the ATA attribute is at or below its nonzero threshold, which smartctl reports as failing", []string{"disk", "type", "by_id", "wwn", "serial", "smart_id"}, noConstLabels)
+
+var smartMonFirmwareChangedDesc = prometheus.NewDesc("smartmon_device_firmware_changed_timestamp_seconds", "unix time the exporter found the firmware version of the device to change", []string{"disk", "type", "by_id", "wwn", "serial", "firmware_version"}, noConstLabels)
+
+// The metrics of the NVMe namespaces reported by the -i option
+var (
+	smartMonNamespaceSizeDesc        = prometheus.NewDesc("smartmon_nvme_namespace_size_bytes", "total size of the NVMe namespace", namespaceLabelNames, noConstLabels)
+	smartMonNamespaceCapacityDesc    = prometheus.NewDesc("smartmon_nvme_namespace_capacity_bytes", "maximum number of bytes which may be allocated in the NVMe namespace", namespaceLabelNames, noConstLabels)
+	smartMonNamespaceUtilizationDesc = prometheus.NewDesc("smartmon_nvme_namespace_utilization_bytes", "number of bytes currently allocated in the NVMe namespace", namespaceLabelNames, noConstLabels)
+	smartMonNamespaceLBASizeDesc     = prometheus.NewDesc("smartmon_nvme_namespace_formatted_lba_size_bytes", "size of the logical blocks the NVMe namespace is formatted with", namespaceLabelNames, noConstLabels)
+)
+
+// smartMonAttributeCounterDesc is the raw value of the counterAttributes.
+// Their _raw_value stays a gauge like the raw value of the other attributes:
+// the families are named after the attribute name, which vendors reuse for
+// other IDs, e.g. Unknown_Attribute, so a family could mix both types.
+var smartMonAttributeCounterDesc = prometheus.NewDesc("smartmon_attribute_raw_value_total", "raw value of the ATA attributes which only ever increase: 4, 9, 12, 192, 193, 199, 241 and 242", []string{"disk", "type", "by_id", "wwn", "serial", "smart_id", "attribute"}, noConstLabels)
+
+var (
+	// counterAttributes are the IDs of ATA attributes whose raw value only
+	// ever increases, their raw value is also exposed as a counter
+	counterAttributes = map[int]bool{
+		4:   true, // Start_Stop_Count
+		9:   true, // Power_On_Hours
+		12:  true, // Power_Cycle_Count
+		192: true, // Power-Off_Retract_Count
+		193: true, // Load_Cycle_Count
+		199: true, // UDMA_CRC_Error_Count
+		241: true, // Total_LBAs_Written
+		242: true, // Total_LBAs_Read
+	}
+	// nvmeCounterAttributes are the normalized names of the NVMe health
+	// information which only ever increases, as reported by the text and
+	// the JSON output of smartctl
+	nvmeCounterAttributes = map[string]bool{
+		"data_units_read":                 true,
+		"data_units_written":              true,
+		"host_read_commands":              true,
+		"host_reads":                      true,
+		"host_write_commands":             true,
+		"host_writes":                     true,
+		"controller_busy_time":            true,
+		"power_cycles":                    true,
+		"power_on_hours":                  true,
+		"unsafe_shutdowns":                true,
+		"media_and_data_integrity_errors": true,
+		"media_errors":                    true,
+		"error_information_log_entries":   true,
+		"num_err_log_entries":             true,
+	}
+)
+
+// CollectorOptions configures what the Collector collects.  A nil
+// *CollectorOptions is valid and uses the defaults.
+type CollectorOptions struct {
+	// CacheStandby serves the metrics last collected from a device while it
+	// is in standby, with explicit timestamps of when they were collected
+	CacheStandby bool
+	// CollectStandby collects the metrics of devices in standby or sleep,
+	// which wakes them up
+	CollectStandby bool
+	// CollectionInterval collects the metrics in the background on this
+	// interval and serves the last collected metrics on scrape.  The metrics
+	// are collected on every scrape if 0.
+	CollectionInterval time.Duration
+	// RescanInterval reuses the devices found by a scan for this duration,
+	// unless a block device is added or removed.  The devices are scanned
+	// on every collection if 0.
+	RescanInterval time.Duration
+	// StandbyInterval is the background collection interval of devices
+	// which were in standby or sleep, defaults to CollectionInterval
+	StandbyInterval time.Duration
+	// Filesystems reports the filesystems mounted from the devices
+	Filesystems bool
+	// Volumes reports the md RAID arrays and LVM volumes the devices are
+	// members of
+	Volumes bool
+	// Enclosures reports the enclosure slots the devices are installed in
+	Enclosures bool
+	// DiskStats reports the I/O statistics of the devices from
+	// /proc/diskstats
+	DiskStats bool
+	// QuarantineFailures stops querying a device for QuarantineDuration
+	// once it failed this number of consecutive collections, the devices
+	// are never quarantined if 0
+	QuarantineFailures int
+	// QuarantineDuration is the time a failing device is not queried,
+	// defaults to 30m
+	QuarantineDuration time.Duration
+	// NVMeVendorLogs collects the vendor specific log pages of the NVMe
+	// devices supporting them, e.g. the OCP SMART log page
+	NVMeVendorLogs bool
+	// DisableInfo does not collect the identity and health of the devices
+	// reported by the -i and -H options
+	DisableInfo bool
+	// DisableAttributes does not collect the SMART attributes of the devices
+	DisableAttributes bool
+	// SelfTests collects the result of the most recent self-test of the
+	// devices
+	SelfTests bool
+	// ErrorLog collects the number of errors logged by the devices
+	ErrorLog bool
+	// Security collects the ATA Security status of the devices and the
+	// support of the security commands of self-encrypting NVMe devices
+	Security bool
+	// Capabilities collects the offline data collection and self-test
+	// execution status of the ATA devices, e.g. the progress of a self-test
+	Capabilities bool
+	// CanonicalAttributeNames names the ATA attribute metrics after the
+	// attribute ID, with the names smartctl uses for drives missing from
+	// its drive database, instead of the names reported for the drive
+	CanonicalAttributeNames bool
+	// AttributeNames overrides the names of the ATA attribute metrics by
+	// attribute ID
+	AttributeNames map[int]string
+	// DriveDBUpdateInterval runs update-smart-drivedb on this interval,
+	// the drive database is not updated if 0
+	DriveDBUpdateInterval time.Duration
+	// RawValueRules interpret the raw values of the ATA attributes, the
+	// first matching rule applies and the default rules apply last
+	RawValueRules []RawValueRule
+	// StateFile saves the state of the devices, e.g. the wakeup counters,
+	// so it survives restarts of the exporter.  The state is only kept in
+	// memory if empty.
+	StateFile string
+	// DeviceLabel selects the value of the disk label, one of DeviceLabels,
+	// DeviceLabelKernel if empty.  With another identifier the kernel name
+	// is reported by smartmon_device_kernel_name_info.
+	DeviceLabel string
+	// Concurrency is the number of devices collected in parallel, the
+	// devices attached through the same RAID controller or SAS expander
+	// are still collected one at a time.  The devices are collected
+	// sequentially if 0 or 1.
+	Concurrency int
+	// MaxLabels limits the number of labels of the metrics, e.g. of
+	// smartmon_attributes which has a label for every NVMe attribute.  The
+	// labels identifying the device are kept and the other labels beyond
+	// the limit dropped.  Unlimited if 0.
+	MaxLabels int
+	// MaxSeriesPerDevice limits the number of series collected from a
+	// device, the series beyond the limit are dropped.  Unlimited if 0.
+	MaxSeriesPerDevice int
+	// LowPower never wakes up the devices in standby, whatever
+	// CollectStandby, checks the power mode of the devices in standby on
+	// StandbyCheckInterval only and reads the info and the attributes of a
+	// device with a single smartctl command
+	LowPower bool
+	// StandbyCheckInterval is the interval of the power mode checks of the
+	// devices in standby or sleep in low power mode, defaults to 1h
+	StandbyCheckInterval time.Duration
+	// SkipIdle skips all the smartctl commands to the devices which
+	// completed no I/O for this duration according to /proc/diskstats, as
+	// they are likely spun down.  The devices are always contacted if 0.
+	SkipIdle time.Duration
+	// TemperatureThreshold is the temperature in Celsius above which a
+	// device is counted by smartmon_devices_over_temperature, defaults
+	// to 60
+	TemperatureThreshold float64
+	// HealthScore collects smartmon_device_health_score, the health of the
+	// devices from 100 down to 0
+	HealthScore bool
+	// HealthScoreWeights overrides the DefaultHealthScoreWeights of the
+	// signals of the health score
+	HealthScoreWeights map[string]float64
+	// LegacyMetricNames collects the metrics renamed after their base unit
+	// under their previous names too, e.g. smartmon_self_test_polling_minutes
+	LegacyMetricNames bool
+	// FailureRisk collects smartmon_device_failure_risk, the number of the
+	// ATA attributes correlated with drive failures which are nonzero
+	FailureRisk bool
+	// AttributeIncreases collects smartmon_attribute_increase_total, the
+	// sum of the increases of the raw values of the ATA attributes 5, 187,
+	// 197 and 198 between collections
+	AttributeIncreases bool
+	// PauseOnBattery pauses the collection of the devices while the system
+	// runs on battery according to /sys/class/power_supply
+	PauseOnBattery bool
+	// HistorySize is the number of samples of the HistoryAttributes kept in
+	// memory for each device, returned by Collector.AttributeHistory.  No
+	// history is kept if 0.
+	HistorySize int
+	// HistoryAttributes are the names of the attributes kept in the
+	// history, defaults to DefaultHistoryAttributes
+	HistoryAttributes []string
+	// Notifier is notified when the health self-assessment of a device
+	// changes or an attribute crosses its threshold, nil notifies nothing
+	Notifier Notifier
+	// Devices overrides the options for the matching devices, the first
+	// matching entry is used
+	Devices []DeviceOptions
+}
+
+// DeviceOptions overrides the CollectorOptions of the devices matching Name
+// and Type.  Fields which are not set fall back to the CollectorOptions.
+type DeviceOptions struct {
+	// Name is a shell pattern matched against the device name, e.g. /dev/sd*,
+	// any name matches if empty
+	Name string `yaml:"name,omitempty"`
+	// Type is matched against the device type, e.g. nvme or sat, any type
+	// matches if empty
+	Type string `yaml:"type,omitempty"`
+	// CollectStandby overrides CollectorOptions.CollectStandby
+	CollectStandby *bool `yaml:"collect_standby,omitempty"`
+	// CollectionInterval overrides CollectorOptions.CollectionInterval
+	CollectionInterval time.Duration `yaml:"collection_interval,omitempty"`
+	// StandbyInterval overrides CollectorOptions.StandbyInterval
+	StandbyInterval time.Duration `yaml:"standby_interval,omitempty"`
+	// SkipIdle overrides CollectorOptions.SkipIdle
+	SkipIdle time.Duration `yaml:"skip_idle,omitempty"`
+	// TemperatureThreshold overrides CollectorOptions.TemperatureThreshold
+	TemperatureThreshold float64 `yaml:"temperature_threshold,omitempty"`
+	// Backend overrides Options.Backend
+	Backend string `yaml:"backend,omitempty"`
+	// Tolerance overrides Options.Tolerance
+	Tolerance string `yaml:"tolerance,omitempty"`
+	// ExtraArgs are passed to the smartctl commands querying the matching
+	// devices after Options.ExtraArgs, split on whitespace, e.g.
+	// "-d sat,12" for a USB dock.  A -d type replaces the type of the device.
+	ExtraArgs string `yaml:"extra_args,omitempty"`
+	// Controller groups the matching devices, which are collected one at a
+	// time, overriding the controller detected for the device
+	Controller string `yaml:"controller,omitempty"`
+}
+
+// matches returns true if the options apply to the device
+func (o *DeviceOptions) matches(d Device) bool {
+	if o.Type != "" && o.Type != d.Type {
+		return false
+	}
+	if o.Name == "" {
+		return true
+	}
+	matched, _ := path.Match(o.Name, d.Name)
+	return matched
+}
+
+// device returns the options of the first entry matching the device
+func (o *CollectorOptions) device(d Device) DeviceOptions {
+	for _, opts := range o.Devices {
+		if opts.matches(d) {
+			return opts
+		}
+	}
+	return DeviceOptions{}
+}
+
+// collectStandby returns true if the device is collected in standby
+func (o *CollectorOptions) collectStandby(d Device) bool {
+	if o.LowPower {
+		return false
+	}
+	if opts := o.device(d); opts.CollectStandby != nil {
+		return *opts.CollectStandby
+	}
+	return o.CollectStandby
+}
+
+// interval returns the background collection interval of the device in
+// the given power mode
+func (o *CollectorOptions) interval(d Device, mode PowerMode) time.Duration {
+	opts := o.device(d)
+	if mode == parser.PowerModeStandby || mode == parser.PowerModeSleep {
+		if opts.StandbyInterval > 0 {
+			return opts.StandbyInterval
+		}
+		if o.StandbyInterval > 0 {
+			return o.StandbyInterval
+		}
+	}
+	if opts.CollectionInterval > 0 {
+		return opts.CollectionInterval
+	}
+	return o.CollectionInterval
+}
+
+// tick returns the shortest of the configured collection intervals, which
+// the background collection checks for devices due to be collected
+func (o *CollectorOptions) tick() time.Duration {
+	tick := o.CollectionInterval
+	for _, opts := range o.Devices {
+		for _, interval := range []time.Duration{opts.CollectionInterval, opts.StandbyInterval} {
+			if interval > 0 && interval < tick {
+				tick = interval
+			}
+		}
+	}
+	if o.StandbyInterval > 0 && o.StandbyInterval < tick {
+		tick = o.StandbyInterval
+	}
+	return tick
+}
+
 // Collector collects smartmon metrics for Prometheus
 type Collector struct {
+	// buildErrors counts the metrics which could not be built, it is the
+	// first field to be 64-bit aligned for the atomic operations
+	buildErrors uint64
+	// seriesLimited counts the series dropped by the limit of series per
+	// device, following buildErrors to be 64-bit aligned
+	seriesLimited uint64
+
+	opts          *Options
+	collectorOpts CollectorOptions
+
+	// mtx protects devices, the state of the devices by Device.Key, and
+	// the snapshot
+	mtx     sync.Mutex
+	devices map[string]*deviceState
+	// snapshot holds the global metrics of the last background collection
+	// and snapshotDevices the devices it found
+	snapshot        []prometheus.Metric
+	snapshotDevices []Device
+	// collected is the end of the last complete collection driven by a
+	// scrape, protected by mtx, and refreshMtx serializes the Refresh calls
+	collected  time.Time
+	refreshMtx sync.Mutex
+	// scanned is set to 1 once a scan for devices succeeded
+	scanned int32
+	// versionChecked is set to 1 once smartctl was found in a supported
+	// version, which is not checked again by Ready
+	versionChecked int32
+
+	// ctx is cancelled by Close to kill the running smartctl commands
+	ctx    context.Context
+	cancel context.CancelFunc
+	// running tracks the calls to Collect and the background collection in progress
+	running sync.WaitGroup
+
+	// descs caches the descriptors of the device and attribute metrics
+	descs descCache
+
+	// identitiesMtx protects identities, the stable identifiers of the
+	// devices resolved on every collection
+	identitiesMtx sync.Mutex
+	identities    map[string]Identity
+
+	// driveDBUpdated is the time of the last update of the drive database
+	// and driveDBUpdateErr its error, protected by mtx
+	driveDBUpdated   time.Time
+	driveDBUpdateErr error
+
+	// container is the container runtime the exporter runs in, detected
+	// when the collector is created
+	container string
+
+	// scans caches the devices found by the last scan
+	scans scanCache
+	// controllers serializes the commands to the devices of a controller
+	controllers controllerLocks
 }
 
 // NewCollector initializes a new prometheus collector for
-// smartmon metrics
-func NewCollector() (*Collector, error) {
-	return &Collector{}, nil
+// smartmon metrics.  opts and collectorOpts may be nil to use the defaults.
+func NewCollector(opts *Options, collectorOpts *CollectorOptions) (*Collector, error) {
+	if _, err := NewBackend(opts); err != nil {
+		return nil, err
+	}
+	if collectorOpts != nil {
+		for _, d := range collectorOpts.Devices {
+			if d.Tolerance != "" {
+				if err := ValidTolerance(d.Tolerance); err != nil {
+					return nil, errors.New("invalid options of device " + d.Name + ": " + err.Error())
+				}
+			}
+			if d.Backend == "" {
+				continue
+			}
+			if _, err := NewBackend(&Options{Backend: d.Backend}); err != nil {
+				return nil, errors.New("invalid options of device " + d.Name + ": " + err.Error())
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Collector{
+		opts:       opts,
+		devices:    map[string]*deviceState{},
+		identities: map[string]Identity{},
+		ctx:        ctx,
+		cancel:     cancel,
+		container:  detectContainer(),
+	}
+	if collectorOpts != nil {
+		c.collectorOpts = *collectorOpts
+	}
+	c.descs.maxLabels = c.collectorOpts.MaxLabels
+	if c.collectorOpts.StateFile != "" {
+		if err := c.loadState(); err != nil {
+			log.Warnln(err, "starting without state")
+		}
+	}
+	if c.collectorOpts.CollectionInterval > 0 {
+		c.running.Add(1)
+		go c.schedule()
+	}
+	if c.collectorOpts.DriveDBUpdateInterval > 0 {
+		c.running.Add(1)
+		go c.scheduleDriveDBUpdates()
+	}
+	return c, nil
+}
+
+// deviceOpts returns the options reading the device, with the backend and
+// the tolerance overridden and the extra arguments extended by the device
+// options
+func (c *Collector) deviceOpts(d Device) *Options {
+	deviceOpts := c.collectorOpts.device(d)
+	if deviceOpts.Backend == "" && deviceOpts.Tolerance == "" && deviceOpts.ExtraArgs == "" {
+		return c.opts
+	}
+	opts := Options{}
+	if c.opts != nil {
+		opts = *c.opts
+	}
+	if deviceOpts.Backend != "" {
+		opts.Backend = deviceOpts.Backend
+	}
+	if deviceOpts.Tolerance != "" {
+		opts.Tolerance = deviceOpts.Tolerance
+	}
+	if args := strings.Fields(deviceOpts.ExtraArgs); len(args) > 0 {
+		opts.ExtraArgs = append(append([]string{}, opts.ExtraArgs...), args...)
+	}
+	return &opts
+}
+
+// identity returns the identifiers of the device resolved by collectDevice
+func (c *Collector) identity(d Device) Identity {
+	c.identitiesMtx.Lock()
+	defer c.identitiesMtx.Unlock()
+	return c.identities[d.Name]
+}
+
+// labelValues returns the values of the deviceLabelNames of the device
+func (c *Collector) labelValues(d Device) []string {
+	identity := c.identity(d)
+	return []string{identity.label(d, c.collectorOpts.DeviceLabel), d.Type, identity.ByID, identity.WWN, sanitizeValue(identity.Serial)}
+}
+
+// labels returns the deviceLabelNames of the device as constant labels
+func (c *Collector) labels(d Device) prometheus.Labels {
+	labels := prometheus.Labels{}
+	for i, value := range c.labelValues(d) {
+		labels[deviceLabelNames[i]] = value
+	}
+	return labels
+}
+
+// Close cancels the smartctl commands started by Collect and the background
+// collection, and waits for them to return
+func (c *Collector) Close() {
+	c.cancel()
+	c.running.Wait()
 }
 
 // Collect implements the prometheus.Collector interface and
 // reads the smartmon metrics
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	version, _ := Version()
-	ch <- prometheus.MustNewConstMetric(smartMonVersionDesc, prometheus.GaugeValue, 1.0, version)
-	devices, err := getDeviceList()
+	c.collect(ch, nil)
+}
+
+// collect reads the metrics selected by the filter, all of them if nil
+func (c *Collector) collect(ch chan<- prometheus.Metric, f *Filter) {
+	c.running.Add(1)
+	defer c.running.Done()
+	defer c.collectBuildErrors(ch)
+	defer c.collectLimited(ch)
+	if c.collectorOpts.CollectionInterval > 0 {
+		c.collectSnapshot(ch, f)
+		return
+	}
+
+	c.descs.sweep()
+	ctx := withFilter(c.ctx, f)
+	devices, ok := c.collectGlobal(ctx, ch)
+	if !ok {
+		return
+	}
+	if f == nil {
+		defer func() {
+			c.mtx.Lock()
+			c.collected = time.Now()
+			c.mtx.Unlock()
+		}()
+	}
+	if c.paused() {
+		c.collectSummary(ch, devices)
+		return
+	}
+	c.eachDevice(f.devices(devices), func(d Device) {
+		c.collectDevice(ctx, ch, d)
+	})
+	c.collectSummary(ch, devices)
+	c.saveState()
+}
+
+// collectGlobal collects the metrics which are not specific to a device
+// and scans for the devices.  Returns false if the scan failed.
+func (c *Collector) collectGlobal(ctx context.Context, ch chan<- prometheus.Metric) ([]Device, bool) {
+	f, err := features(ctx, c.opts)
 	if err != nil {
-		log.Infoln("unable to scan smart devices: ", err)
+		c.collectError(ch, Device{}, "version", err)
+	} else {
+		c.collectFeatures(ch, f)
+	}
+	devices, err := c.scan(ctx)
+	if err != nil {
+		c.collectError(ch, Device{}, "scan", err)
+		return nil, false
+	}
+	atomic.StoreInt32(&c.scanned, 1)
+	c.collectScan(ch)
+	c.collectEnvironment(ch, devices)
+	c.collectDriveDB(ch)
+	c.collectMessages(ch)
+	c.collectRetries(ch)
+	c.collectJSONFallbacks(ch)
+	c.collectInvocations(ch)
+	c.collectMMC(ch)
+	c.collectAbsent(ch, devices)
+	c.collectOnBattery(ch)
+	c.constMetric(ch, smartMonPrivilegedDesc, prometheus.GaugeValue, boolToMetric(c.opts.Privileged(devices)))
+	return devices, true
+}
+
+// collectDevice collects the metrics of a device, unless it is in standby,
+// up to the limit of series per device
+func (c *Collector) collectDevice(ctx context.Context, ch chan<- prometheus.Metric, d Device) {
+	unlock := c.lockController(d)
+	defer unlock()
+	if c.collectorOpts.MaxSeriesPerDevice <= 0 {
+		c.collectDeviceSeries(ctx, ch, d)
 		return
 	}
-	for _, d := range devices {
-		active, _ := d.active()
+	metrics := record(func(ch chan<- prometheus.Metric) {
+		c.collectDeviceSeries(ctx, ch, d)
+	})
+	c.limitSeries(ch, d, metrics)
+}
+
+// collectDeviceSeries collects all the metrics of a device
+func (c *Collector) collectDeviceSeries(ctx context.Context, ch chan<- prometheus.Metric, d Device) {
+	identity := d.ResolveIdentity()
+	c.identitiesMtx.Lock()
+	c.identities[d.Name] = identity
+	c.identitiesMtx.Unlock()
+	c.collectPresent(ch, d)
+	c.collectKernelName(ch, d)
+	c.collectPaths(ch, d)
+
+	if err := c.opts.checkDeviceNode(d.Name); err != nil {
+		log.Warnln("Device", d.Name, "is not accessible:", err)
+		c.constMetric(ch, smartMonNodeReadableDesc, prometheus.GaugeValue, 0.0, c.labelValues(d)...)
+	} else {
+		c.constMetric(ch, smartMonNodeReadableDesc, prometheus.GaugeValue, 1.0, c.labelValues(d)...)
+	}
 
-		if active {
-			ch <- prometheus.MustNewConstMetric(smartMonActiveDesc, prometheus.GaugeValue, 1.0, d.Name, d.Type)
-			CollectInfoMetrics(ch, d)
-			CollectVendorAttributes(ch, d)
-		} else { // don't collect from inactive devices to avoid waking them up
-			ch <- prometheus.MustNewConstMetric(smartMonActiveDesc, prometheus.GaugeValue, 0.0, d.Name, d.Type)
+	if c.quarantined(d) {
+		c.constMetric(ch, smartMonQuarantinedDesc, prometheus.GaugeValue, 1.0, c.labelValues(d)...)
+	} else if c.unsupported(d) {
+		c.collectSupported(ch, d, false)
+	} else {
+		ok := c.collectSMART(ctx, ch, d)
+		c.collectQuarantine(ch, d, ok)
+		c.collectSupported(ch, d, !c.unsupported(d))
+	}
+	c.collectLastCollected(ch, d)
+	f := filterFrom(ctx)
+	if c.collectorOpts.Filesystems && f.collector(CollectorFilesystems) {
+		c.collectFilesystems(ch, d)
+	}
+	if c.collectorOpts.Volumes && f.collector(CollectorVolumes) {
+		c.collectVolumes(ch, d)
+	}
+	if c.collectorOpts.Enclosures && f.collector(CollectorEnclosures) {
+		c.collectEnclosures(ch, d)
+	}
+	if c.collectorOpts.DiskStats && f.collector(CollectorDiskStats) {
+		c.collectDiskStats(ch, d)
+	}
+}
+
+// record calls collect and returns the metrics it sent
+func record(collect func(ch chan<- prometheus.Metric)) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	recorded := make(chan []prometheus.Metric)
+	go func() {
+		metrics := []prometheus.Metric{}
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		recorded <- metrics
+	}()
+	collect(ch)
+	close(ch)
+	return <-recorded
+}
+
+// collectSMART collects the power mode of the device, and its SMART data
+// unless it is in standby.  Returns false if smartctl failed.
+func (c *Collector) collectSMART(ctx context.Context, ch chan<- prometheus.Metric, d Device) bool {
+	defer c.collectStandbyAudit(ch, d)
+	// an idle device is likely spun down, even checking its power mode
+	// issues a command to it
+	if c.idle(d) {
+		c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 0.0, c.labelValues(d)...)
+		c.collectSkipped(ch, d)
+		return true
+	}
+	mode, err := c.powerMode(ctx, d)
+	for _, m := range parser.PowerModes {
+		c.constMetric(ch, smartMonPowerModeDesc, prometheus.GaugeValue, boolToMetric(m == mode), append(c.labelValues(d), string(m))...)
+	}
+	active := mode == parser.PowerModeActive || mode == parser.PowerModeIdle
+	c.mtx.Lock()
+	c.state(d.Key()).mode = mode
+	c.mtx.Unlock()
+
+	ok := err == nil
+	if active {
+		c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 1.0, c.labelValues(d)...)
+		ok = c.collectActive(ctx, ch, d) && ok
+	} else if c.collectorOpts.collectStandby(d) {
+		c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 0.0, c.labelValues(d)...)
+		c.collectWoken(ch, d, mode)
+		ok = c.collectActive(ctx, ch, d) && ok
+	} else { // don't collect from inactive devices to avoid waking them up
+		c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 0.0, c.labelValues(d)...)
+		c.collectStandby(ch, d, mode)
+	}
+	return ok
+}
+
+// collectActive collects the metrics of a device which is not in standby
+// and remembers when they were collected.  Returns false if they could not
+// be collected.
+func (c *Collector) collectActive(ctx context.Context, ch chan<- prometheus.Metric, d Device) bool {
+	ok := true
+	f := filterFrom(ctx)
+	if c.collectorOpts.LowPower {
+		ctx = withBatch(ctx)
+	}
+	metrics := record(func(ch chan<- prometheus.Metric) {
+		if !c.collectorOpts.DisableInfo && f.collector(CollectorInfo) {
+			if err := c.collectInfo(ctx, ch, d); err != nil {
+				c.collectError(ch, d, "info", err)
+				ok = false
+			}
+			// the other data of a device lacking SMART capability would
+			// only fail to be read
+			if c.unsupported(d) {
+				return
+			}
+		}
+		if !c.collectorOpts.DisableAttributes && f.collector(CollectorAttributes) {
+			if err := c.collectAttributes(ctx, ch, d); err != nil {
+				c.collectError(ch, d, "attributes", err)
+				ok = false
+			}
+		}
+		// the devices are not required to support the vendor log pages,
+		// failing to read them does not fail the collection
+		if c.collectorOpts.NVMeVendorLogs && strings.HasPrefix(d.Type, "nvme") && f.collector(CollectorVendorLogs) {
+			if err := c.collectVendorLogs(ctx, ch, d); err != nil {
+				c.collectError(ch, d, "vendor_logs", err)
+			}
+		}
+		// like the vendor log pages, the logs are optional
+		if c.collectorOpts.SelfTests && f.collector(CollectorSelfTest) {
+			if err := c.collectSelfTests(ctx, ch, d); err != nil {
+				c.collectError(ch, d, CollectorSelfTest, err)
+			}
+		}
+		if c.collectorOpts.ErrorLog && f.collector(CollectorErrorLog) {
+			if err := c.collectErrorLog(ctx, ch, d); err != nil {
+				c.collectError(ch, d, CollectorErrorLog, err)
+			}
+		}
+		if c.collectorOpts.Security && f.collector(CollectorSecurity) {
+			if err := c.collectSecurity(ctx, ch, d); err != nil {
+				c.collectError(ch, d, CollectorSecurity, err)
+			}
+		}
+		if c.collectorOpts.Capabilities && f.collector(CollectorCapability) {
+			if err := c.collectCapabilities(ctx, ch, d); err != nil {
+				c.collectError(ch, d, CollectorCapability, err)
+			}
+		}
+		// the score combines the data collected above, which a filtered
+		// collection may have skipped
+		if c.collectorOpts.HealthScore && f == nil {
+			c.collectHealthScore(ch, d)
+		}
+	})
+	for _, m := range metrics {
+		ch <- m
+	}
+	if !ok {
+		return false
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	st.lastCollected = time.Now()
+	// the metrics of a filtered collection are incomplete, keep serving
+	// the complete metrics cached before
+	if c.collectorOpts.CacheStandby && f == nil {
+		st.metrics = metrics
+	}
+	return true
+}
+
+// collectStandby counts the avoided wakeup of a device in standby or sleep
+// and serves its cached metrics
+func (c *Collector) collectStandby(ch chan<- prometheus.Metric, d Device, mode PowerMode) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	if mode == parser.PowerModeStandby || mode == parser.PowerModeSleep {
+		st.wakeupsAvoided++
+	}
+	c.constMetric(ch, smartMonWakeupsAvoidedDesc, prometheus.CounterValue, float64(st.wakeupsAvoided), c.labelValues(d)...)
+	c.collectCached(ch, st)
+}
+
+// collectCached serves the cached metrics of a device, timestamped with the
+// time they were collected.  c.mtx must be held.
+func (c *Collector) collectCached(ch chan<- prometheus.Metric, st *deviceState) {
+	if !c.collectorOpts.CacheStandby {
+		return
+	}
+	for _, m := range st.metrics {
+		ch <- prometheus.NewMetricWithTimestamp(st.lastCollected, m)
+	}
+}
+
+// collectWoken counts the wakeup of a device in standby or sleep caused by
+// collecting its metrics
+func (c *Collector) collectWoken(ch chan<- prometheus.Metric, d Device, mode PowerMode) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	if mode == parser.PowerModeStandby || mode == parser.PowerModeSleep {
+		st.woken++
+	}
+	c.constMetric(ch, smartMonWokenDesc, prometheus.CounterValue, float64(st.woken), c.labelValues(d)...)
+}
+
+// collectLastCollected reports when the metrics of the device were last
+// collected, so the staleness of cached or skipped devices is visible
+func (c *Collector) collectLastCollected(ch chan<- prometheus.Metric, d Device) {
+	c.mtx.Lock()
+	lastCollected := c.state(d.Key()).lastCollected
+	c.mtx.Unlock()
+	if lastCollected.IsZero() {
+		return
+	}
+	c.constMetric(ch, smartMonLastCollectedDesc, prometheus.GaugeValue, float64(lastCollected.UnixNano())/1e9, c.labelValues(d)...)
+}
+
+// Ready returns an error until smartctl is available in a supported
+// version and at least one scan for devices succeeded.  The version is only
+// checked until it is supported and a scan is run if none succeeded yet, so
+// a ready collector does not run smartctl.
+func (c *Collector) Ready(ctx context.Context) error {
+	if atomic.LoadInt32(&c.versionChecked) == 0 {
+		if err := checkSupportedVersion(ctx, c.opts); err != nil {
+			return err
 		}
+		atomic.StoreInt32(&c.versionChecked, 1)
 	}
+	if atomic.LoadInt32(&c.scanned) == 1 {
+		return nil
+	}
+	if _, err := c.scan(ctx); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&c.scanned, 1)
+	return nil
+}
+
+// collectError logs an error of the named collector and reports it as
+// a smartmon_collector_error metric, so a single misbehaving device does
+// not prevent the other devices from being collected.  The metric only has
+// the reason of the error, the message is logged.
+func (c *Collector) collectError(ch chan<- prometheus.Metric, dev Device, collector string, err error) {
+	reason := errorReason(err)
+	log.With("reason", reason).Infoln("error collecting "+collector+" for "+dev.Name+":", err)
+	c.constMetric(ch, smartMonErrorDesc, prometheus.GaugeValue, 1.0, append(c.labelValues(dev), collector, reason)...)
 }
 
-func getDeviceList() ([]Device, error) {
-	if JSONCapable() {
-		return scanDevicesJSON()
+// constMetric sends a constant metric.  A metric which cannot be built,
+// e.g. because a device reported the same attribute twice, is logged and
+// counted instead of panicking the collection.
+func (c *Collector) constMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	sendConstMetric(ch, &c.buildErrors, desc, valueType, value, labelValues...)
+}
+
+// sendConstMetric sends a constant metric, or logs the error and adds 1 to
+// buildErrors if it cannot be built
+func sendConstMetric(ch chan<- prometheus.Metric, buildErrors *uint64, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	m, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+	if err != nil {
+		log.Warnln("Unable to build metric", desc, ":", err)
+		atomic.AddUint64(buildErrors, 1)
+		return
 	}
-	return scanDevices()
+	ch <- m
+}
+
+// collectBuildErrors reports the number of metrics which could not be built
+func (c *Collector) collectBuildErrors(ch chan<- prometheus.Metric) {
+	c.constMetric(ch, smartMonBuildErrorsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.buildErrors)))
 }
 
 // Describe implements the prometheus.Collector interface
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	prometheus.DescribeByCollect(c, ch)
+	for _, desc := range []*prometheus.Desc{
+		smartMonVersionDesc,
+		smartMonFeaturesDesc,
+		smartMonActiveDesc,
+		smartMonPrivilegedDesc,
+		smartMonNodeReadableDesc,
+		smartMonPresentDesc,
+		smartMonPowerModeDesc,
+		smartMonWakeupsAvoidedDesc,
+		smartMonWokenDesc,
+		smartMonLastCollectedDesc,
+		smartMonIntervalDesc,
+		smartMonErrorDesc,
+		smartMonInterfaceSpeedDesc,
+		smartMonInterfaceMaxSpeedDesc,
+		smartMonCapacityDesc,
+		smartMonBlockSizeDesc,
+		smartMonFirmwareChangedDesc,
+		smartMonNamespaceSizeDesc,
+		smartMonNamespaceCapacityDesc,
+		smartMonNamespaceUtilizationDesc,
+		smartMonNamespaceLBASizeDesc,
+		smartMonBuildErrorsDesc,
+		smartMonMessagesDesc,
+		smartMonRetriesDesc,
+		smartMonJSONFallbacksDesc,
+		smartMonInvocationsDesc,
+		smartMonScanDurationDesc,
+		smartMonScanAgeDesc,
+		smartMonPathsDesc,
+		smartMonKernelNameDesc,
+		smartMonFirstSeenDesc,
+		smartMonLastSeenDesc,
+		smartMonFailuresDesc,
+		smartMonCPUDesc,
+		smartMonQuarantinedDesc,
+		smartMonEnvironmentDesc,
+		smartMonScanEmptyDesc,
+		smartMonDriveDBDesc,
+		smartMonDriveDBModifiedDesc,
+		smartMonDriveDBUpdateDesc,
+		smartMonDriveDBUpdateFailDesc,
+		smartMonFilesystemDesc,
+		smartMonMDDesc,
+		smartMonLVMDesc,
+		smartMonEnclosureDesc,
+		smartMonMMCInfoDesc,
+		smartMonMMCLifeTimeDesc,
+		smartMonMMCPreEOLDesc,
+		smartMonIOReadsDesc,
+		smartMonIOWritesDesc,
+		smartMonIOReadBytesDesc,
+		smartMonIOWrittenBytesDesc,
+		smartMonIOReadTimeDesc,
+		smartMonIOWriteTimeDesc,
+		smartMonIOInFlightDesc,
+		smartMonIOTimeDesc,
+		smartMonSelfTestPassedDesc,
+		smartMonSelfTestHoursDesc,
+		smartMonErrorLogDesc,
+		smartMonSecurityDesc,
+		smartMonSelfTestProgressDesc,
+		smartMonSelfTestInProgressDesc,
+		smartMonSelfTestStatusDesc,
+		smartMonSelfTestPollingDesc,
+		legacySelfTestProgressDesc,
+		legacySelfTestPollingDesc,
+		legacySelfTestHoursDesc,
+		smartMonOfflineStatusDesc,
+		smartMonOfflineAutoDesc,
+		smartMonOfflineSecondsDesc,
+		smartMonSeriesLimitedDesc,
+		smartMonOnBatteryDesc,
+		smartMonStandbyCommandsDesc,
+		smartMonSkippedStandbyDesc,
+		smartMonSupportedDesc,
+		smartMonDevicesDesc,
+		smartMonDevicesUnhealthyDesc,
+		smartMonDevicesFailingPrefailDesc,
+		smartMonDevicesOverTempDesc,
+		smartMonHealthScoreDesc,
+		smartMonFailureRiskDesc,
+		smartMonAttributeIncreaseDesc,
+		smartMonBelowThresholdDesc,
+		smartMonAttributeCounterDesc,
+	} {
+		ch <- desc
+	}
 }
 
-// CollectInfoMetrics collects metrics based on output of
+// collectInfo collects metrics based on output of
 // 'smartctl -i -H -d <type> <dev>'
-func CollectInfoMetrics(ch chan<- prometheus.Metric, device Device) {
-	info, err := getDevInfo(device)
+func (c *Collector) collectInfo(ctx context.Context, ch chan<- prometheus.Metric, device Device) error {
+	info, err := device.Info(ctx, c.deviceOpts(device))
 	if err != nil {
-		log.Infoln("error collecting device info for "+device.Name+":", err)
-		return
-	}
-	commonLabels := map[string]string{
-		"disk": device.Name,
-		"type": device.Type,
+		return err
 	}
+	commonLabels := c.labels(device)
 	infoLabels := mergeMaps(commonLabels, info.Attributes)
-	descInfo := prometheus.NewDesc("smartmon_device_info", "smartmon_device_info", noLabels, infoLabels)
-	ch <- prometheus.MustNewConstMetric(descInfo, prometheus.GaugeValue, 1.0)
-	descAvailable := prometheus.NewDesc("smartmon_device_smart_available", "smartmon_device_smart_available", noLabels, commonLabels)
-	ch <- prometheus.MustNewConstMetric(descAvailable, prometheus.GaugeValue, boolToMetric(info.Available))
-	descEnabled := prometheus.NewDesc("smartmon_device_smart_enabled", "smartmon_device_smart_enabled", noLabels, commonLabels)
-	ch <- prometheus.MustNewConstMetric(descEnabled, prometheus.GaugeValue, boolToMetric(info.Enabled))
-	descHealthy := prometheus.NewDesc("smartmon_device_smart_healthy", "smartmon_device_smart_healthy", noLabels, commonLabels)
-	ch <- prometheus.MustNewConstMetric(descHealthy, prometheus.GaugeValue, boolToMetric(info.Healthy))
+	// the data of a device queried with a tolerance above the default may
+	// be incomplete or wrong
+	if tolerance := c.deviceOpts(device).tolerance(); tolerance != "" {
+		infoLabels["smartctl_tolerance"] = tolerance
+	}
+	descInfo := c.descs.get("smartmon_device_info", infoLabels)
+	c.constMetric(ch, descInfo, prometheus.GaugeValue, 1.0)
+	descAvailable := c.descs.get("smartmon_device_smart_available", commonLabels)
+	c.constMetric(ch, descAvailable, prometheus.GaugeValue, boolToMetric(info.Available))
+	descEnabled := c.descs.get("smartmon_device_smart_enabled", commonLabels)
+	c.constMetric(ch, descEnabled, prometheus.GaugeValue, boolToMetric(info.Enabled))
+	descHealthy := c.descs.get("smartmon_device_smart_healthy", commonLabels)
+	c.constMetric(ch, descHealthy, prometheus.GaugeValue, boolToMetric(info.Healthy))
+	if info.Unsupported {
+		c.markUnsupported(device)
+	}
+	c.recordHealth(device, info)
+	c.collectFirmware(ch, device, info)
+	if info.InterfaceSpeed > 0 {
+		c.constMetric(ch, smartMonInterfaceSpeedDesc, prometheus.GaugeValue, info.InterfaceSpeed/8, c.labelValues(device)...)
+	}
+	if info.MaxInterfaceSpeed > 0 {
+		c.constMetric(ch, smartMonInterfaceMaxSpeedDesc, prometheus.GaugeValue, info.MaxInterfaceSpeed/8, c.labelValues(device)...)
+	}
+	if info.CapacityBytes > 0 {
+		c.constMetric(ch, smartMonCapacityDesc, prometheus.GaugeValue, info.CapacityBytes, c.labelValues(device)...)
+	}
+	if info.LogicalBlockSize > 0 {
+		c.constMetric(ch, smartMonBlockSizeDesc, prometheus.GaugeValue, info.LogicalBlockSize, append(c.labelValues(device), "logical")...)
+	}
+	if info.PhysicalBlockSize > 0 {
+		c.constMetric(ch, smartMonBlockSizeDesc, prometheus.GaugeValue, info.PhysicalBlockSize, append(c.labelValues(device), "physical")...)
+	}
+	for _, ns := range info.Namespaces {
+		labels := append(c.labelValues(device), strconv.Itoa(ns.ID))
+		c.constMetric(ch, smartMonNamespaceSizeDesc, prometheus.GaugeValue, ns.SizeBytes, labels...)
+		c.constMetric(ch, smartMonNamespaceCapacityDesc, prometheus.GaugeValue, ns.CapacityBytes, labels...)
+		c.constMetric(ch, smartMonNamespaceUtilizationDesc, prometheus.GaugeValue, ns.UtilizationBytes, labels...)
+		c.constMetric(ch, smartMonNamespaceLBASizeDesc, prometheus.GaugeValue, ns.FormattedLBASize, labels...)
+	}
+	return nil
 }
 
-func getDevInfo(device Device) (*DeviceInfo, error) {
-	if JSONCapable() {
-		return device.infoJSON()
+// collectFirmware reports when the firmware version reported by the -i
+// option last changed, and remembers the model of the device to interpret
+// the raw values of its attributes
+func (c *Collector) collectFirmware(ch chan<- prometheus.Metric, d Device, info *DeviceInfo) {
+	firmware := info.Attributes["firmware_version"]
+	serial := info.Attributes["serial_number"]
+	c.mtx.Lock()
+	st := c.state(d.Key())
+	changed := st.updateFirmware(firmware, serial, time.Now())
+	st.model = deviceModel(info)
+	c.mtx.Unlock()
+	if !changed.IsZero() {
+		labels := append(c.labelValues(d), sanitizeValue(firmware))
+		c.constMetric(ch, smartMonFirmwareChangedDesc, prometheus.GaugeValue, float64(changed.UnixNano())/1e9, labels...)
 	}
-	return device.info()
+}
+
+// deviceModel returns the model reported by the -i option of ATA
+// ("device_model"), NVMe ("model_number") or JSON ("model_name") output
+func deviceModel(info *DeviceInfo) string {
+	for _, key := range []string{"device_model", "model_name", "model_number"} {
+		if model := info.Attributes[key]; model != "" {
+			return model
+		}
+	}
+	return ""
 }
 
 // boolToMetric converts a boolean value to a metric float value of 1.0 or 0.0
@@ -113,98 +1056,155 @@ func boolToMetric(val bool) float64 {
 	return 0.0
 }
 
-// CollectVendorAttributes collects smart Attributes based on output of
+// collectAttributes collects smart Attributes based on output of
 // 'smartctl -A -d <type> <device>'
-func CollectVendorAttributes(ch chan<- prometheus.Metric, dev Device) error {
+func (c *Collector) collectAttributes(ctx context.Context, ch chan<- prometheus.Metric, dev Device) error {
 	if strings.HasPrefix(dev.Type, "nvme") {
-		return CollectNvmeVendorAttributes(ch, dev)
+		return c.collectNvmeAttributes(ctx, ch, dev)
 	} else if strings.HasPrefix(dev.Type, "sat") {
-		return CollectSatVendorAttributes(ch, dev)
-	} // TODO: add support for scsi and megaraid devices
+		return c.collectSatAttributes(ctx, ch, dev)
+	} // TODO: add support for scsi and megaraid SAS/SATA devices
 	return errors.New("unrecognized device type: " + dev.Type)
 }
 
-// CollectNvmeVendorAttributes collects vendor specific attributes for nvme devices
-func CollectNvmeVendorAttributes(ch chan<- prometheus.Metric, dev Device) error {
-	opts := append(smartctlDeviceMetricOpts, "-d", dev.Type, dev.Name)
-	output, err := smartCtl(opts...)
+// collectNvmeAttributes collects vendor specific attributes for nvme devices
+func (c *Collector) collectNvmeAttributes(ctx context.Context, ch chan<- prometheus.Metric, dev Device) error {
+	attrs, err := dev.Attributes(ctx, c.deviceOpts(dev))
 	if err != nil {
-		log.Infoln("error collecting vendor specific attributes for "+dev.Name+":", err)
 		return err
 	}
 
-	labels := map[string]string{}
-	labels["disk"] = dev.Name
-	labels["type"] = dev.Type
-	for _, line := range strings.Split(string(output)[4:], "\n") {
-		fields := strings.Split(line, ":")
-		if len(fields) == 2 {
-			labels[sanitizeLabelName(fields[0])] = strings.TrimSpace(fields[1])
+	labels := c.labels(dev)
+	values := make(map[string]float64, len(attrs))
+	for _, attr := range attrs {
+		name := parser.NormalizeName(attr.Name)
+		labels[name] = attr.RawString
+		values[name] = attr.Raw
+		if nvmeCounterAttributes[name] {
+			metricName, value := nvmeCounter(name, attr.Raw)
+			counterDesc := c.descs.get(metricName, c.labels(dev))
+			c.constMetric(ch, counterDesc, prometheus.CounterValue, value)
+			if _, converted := nvmeCounterUnits[name]; converted && c.collectorOpts.LegacyMetricNames {
+				legacyName := "smartmon_nvme_" + name + "_total"
+				c.constMetric(ch, c.descs.get(legacyName, c.labels(dev)), prometheus.CounterValue, attr.Raw)
+			}
 		}
 	}
+	c.recordAttributes(dev, values)
 	metricName := "smartmon_attributes"
 
-	vendorAttrDesc := prometheus.NewDesc(metricName, metricName, noLabels, labels)
-	ch <- prometheus.MustNewConstMetric(vendorAttrDesc, prometheus.GaugeValue, 1.0)
+	vendorAttrDesc := c.descs.get(metricName, labels)
+	c.constMetric(ch, vendorAttrDesc, prometheus.GaugeValue, 1.0)
 	return nil
 }
 
-// CollectSatVendorAttributes collects smart Attributes based on output of
+// collectSatAttributes collects smart Attributes based on output of
 // 'smartctl -A -d <type> <device>'
-func CollectSatVendorAttributes(ch chan<- prometheus.Metric, dev Device) error {
-	opts := append(smartctlDeviceMetricOpts, "-d", dev.Type, dev.Name)
-	output, _ := smartCtl(opts...)
-
-	constLabels := prometheus.Labels{
-		"disk": dev.Name,
-		"type": dev.Type,
+func (c *Collector) collectSatAttributes(ctx context.Context, ch chan<- prometheus.Metric, dev Device) error {
+	attrs, err := dev.Attributes(ctx, c.deviceOpts(dev))
+	if err != nil {
+		return err
 	}
 
-	for _, line := range strings.Split(string(output)[1:], "\n") {
-		fields := strings.Fields(line)
-		if len(fields) < 10 {
+	constLabels := c.labels(dev)
+	c.mtx.Lock()
+	model := c.state(dev.Key()).model
+	c.mtx.Unlock()
+
+	names := c.collectorOpts.attributeNames(attrs)
+	values := make(map[string]float64, len(attrs))
+	defer c.recordAttributes(dev, values)
+	c.recordPrefail(dev, attrs)
+	c.recordWear(dev, attrs)
+	if c.collectorOpts.FailureRisk {
+		c.collectFailureRisk(ch, dev, attrs)
+	}
+	if c.collectorOpts.AttributeIncreases {
+		c.collectAttributeIncreases(ch, dev, attrs)
+	}
+	for i, attr := range attrs {
+		if names[i] == "" {
+			log.Debugln("Skipping duplicate attribute", attr.ID, attr.Name, "of", dev.Name)
 			continue
 		}
+		values[names[i]] = attr.Raw
 		labels := prometheus.Labels{}
 		for key, value := range constLabels {
 			labels[key] = value
 		}
-		labels["smart_id"] = fields[0]
-		metricPrefix := "smartmon_" + strings.ToLower(fields[1])
+		labels["smart_id"] = strconv.Itoa(attr.ID)
+		metricPrefix := "smartmon_" + names[i]
 
-		deviceValueAttrDesc := prometheus.NewDesc(metricPrefix+"_value", metricPrefix+"_value", noLabels, labels)
-		value, err := strconv.ParseFloat(fields[3], 64)
-		if err != nil {
-			return err
-		}
-		ch <- prometheus.MustNewConstMetric(deviceValueAttrDesc, prometheus.GaugeValue, value)
+		deviceValueAttrDesc := c.descs.get(metricPrefix+"_value", labels)
+		c.constMetric(ch, deviceValueAttrDesc, prometheus.GaugeValue, attr.Value)
 
-		deviceWorstAttrDesc := prometheus.NewDesc(metricPrefix+"_worst", metricPrefix+"_worst", noLabels, labels)
-		value, err = strconv.ParseFloat(fields[4], 64)
-		if err != nil {
-			return err
-		}
-		ch <- prometheus.MustNewConstMetric(deviceWorstAttrDesc, prometheus.GaugeValue, value)
+		deviceWorstAttrDesc := c.descs.get(metricPrefix+"_worst", labels)
+		c.constMetric(ch, deviceWorstAttrDesc, prometheus.GaugeValue, attr.Worst)
 
-		deviceThresholdAttrDesc := prometheus.NewDesc(metricPrefix+"_threshold", metricPrefix+"_threshold", noLabels, labels)
-		value, err = strconv.ParseFloat(fields[5], 64)
-		if err != nil {
-			return err
-		}
-		ch <- prometheus.MustNewConstMetric(deviceThresholdAttrDesc, prometheus.GaugeValue, value)
+		deviceThresholdAttrDesc := c.descs.get(metricPrefix+"_threshold", labels)
+		c.constMetric(ch, deviceThresholdAttrDesc, prometheus.GaugeValue, attr.Threshold)
 
-		deviceRawAttrDesc := prometheus.NewDesc(metricPrefix+"_raw_value", metricPrefix+"_raw_value", noLabels, labels)
-		value, err = strconv.ParseFloat(fields[9], 64)
-		if err != nil {
-			return err
+		deviceRawAttrDesc := c.descs.get(metricPrefix+"_raw_value", labels)
+		c.constMetric(ch, deviceRawAttrDesc, prometheus.GaugeValue, attr.Raw)
+		if counterAttributes[attr.ID] {
+			c.constMetric(ch, smartMonAttributeCounterDesc, prometheus.CounterValue, attr.Raw, append(c.labelValues(dev), labels["smart_id"], names[i])...)
 		}
-		ch <- prometheus.MustNewConstMetric(deviceRawAttrDesc, prometheus.GaugeValue, value)
+		c.collectInterpretedValue(ch, model, attr, metricPrefix, labels)
+		c.constMetric(ch, smartMonBelowThresholdDesc, prometheus.GaugeValue, boolToMetric(belowThreshold(attr)), append(c.labelValues(dev), labels["smart_id"])...)
 
 	}
 	return nil
 
 }
 
+// attributeName returns the metric name of an ATA attribute, the lower case
+// name overridden by AttributeNames, the canonical name of the ID if
+// CanonicalAttributeNames is set, or the name reported by smartctl
+func (o *CollectorOptions) attributeName(attr Attribute) string {
+	if name, found := o.AttributeNames[attr.ID]; found {
+		return strings.ToLower(name)
+	}
+	if o.CanonicalAttributeNames {
+		if name := parser.ATAAttributeName(attr.ID); name != "" {
+			return strings.ToLower(name)
+		}
+	}
+	return strings.ToLower(attr.Name)
+}
+
+// AttributeMetricName returns the prefix of the names of the metrics of an
+// ATA attribute, e.g. smartmon_reallocated_sector_ct, as named by the
+// collector configured with the options
+func (o *CollectorOptions) AttributeMetricName(attr Attribute) string {
+	return sanitizeName(smartMetricPrefix + o.attributeName(attr))
+}
+
+// attributeNames returns the metric names of the ATA attributes.  Some
+// drives report several attributes with the same name, e.g.
+// Unknown_Attribute, whose names are suffixed with their ID to keep the
+// series apart.  Attributes reported twice with the same ID are given an
+// empty name to be skipped.
+func (o *CollectorOptions) attributeNames(attrs []Attribute) []string {
+	names := make([]string, len(attrs))
+	counts := map[string]int{}
+	for i, attr := range attrs {
+		names[i] = o.attributeName(attr)
+		counts[names[i]]++
+	}
+	seen := map[int]bool{}
+	for i, attr := range attrs {
+		if seen[attr.ID] {
+			names[i] = ""
+			continue
+		}
+		seen[attr.ID] = true
+		if counts[names[i]] > 1 {
+			names[i] += "_" + strconv.Itoa(attr.ID)
+		}
+	}
+	return names
+}
+
 func mergeMaps(map1 map[string]string, map2 map[string]string) map[string]string {
 	combined := map[string]string{}
 	for key, val := range map1 {
@@ -0,0 +1,187 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestConstMetric(t *testing.T) {
+	c, err := NewCollector(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ch := make(chan prometheus.Metric, 2)
+	c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 1.0, "/dev/sda", "sat", "", "", "")
+	// missing label values must be counted instead of panicking
+	c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 1.0, "/dev/sda")
+	if len(ch) != 1 {
+		t.Fatal("expected 1 metric, found", len(ch))
+	}
+	if c.buildErrors != 1 {
+		t.Fatal("expected 1 build error, found", c.buildErrors)
+	}
+}
+
+func TestAttributeNames(t *testing.T) {
+	opts := &CollectorOptions{}
+	names := opts.attributeNames([]Attribute{
+		{ID: 5, Name: "Reallocated_Sector_Ct"},
+		{ID: 170, Name: "Unknown_Attribute"},
+		{ID: 171, Name: "Unknown_Attribute"},
+		{ID: 171, Name: "Unknown_Attribute"},
+	})
+	expected := []string{"reallocated_sector_ct", "unknown_attribute_170", "unknown_attribute_171", ""}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected name %q, found %q", expected[i], names[i])
+		}
+	}
+}
+
+func TestAttributeNamesOverrides(t *testing.T) {
+	attrs := []Attribute{
+		{ID: 194, Name: "Temperature_Internal"},
+		{ID: 241, Name: "Host_Writes_32MiB"},
+		{ID: 9, Name: "Power_On_Hours"},
+	}
+	opts := &CollectorOptions{CanonicalAttributeNames: true, AttributeNames: map[int]string{9: "Power_On_Time"}}
+	names := opts.attributeNames(attrs)
+	expected := []string{"temperature_celsius", "total_lbas_written", "power_on_time"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected name %q, found %q", expected[i], names[i])
+		}
+	}
+}
+
+func TestAttributeMetricName(t *testing.T) {
+	opts := &CollectorOptions{AttributeNames: map[int]string{194: "Temp-Internal"}}
+	if name := opts.AttributeMetricName(Attribute{ID: 184, Name: "End-to-End_Error"}); name != "smartmon_end_to_end_error" {
+		t.Fatal("unexpected name", name)
+	}
+	if name := opts.AttributeMetricName(Attribute{ID: 194, Name: "Temperature_Celsius"}); name != "smartmon_temp_internal" {
+		t.Fatal("unexpected name", name)
+	}
+}
+
+func TestReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysBlock = path }(sysBlock)
+	sysBlock = filepath.Join(dir, "block")
+
+	log := filepath.Join(dir, "log")
+	path := filepath.Join(dir, "smartctl")
+	writeScript := func(version string) {
+		script := "#!/bin/sh\necho \"$*\" >>" + log + "\n" +
+			"if [ \"$1\" = -V ]; then echo 'smartctl " + version + " 2019-12-30 r5022'; exit 0; fi\n" +
+			"echo '/dev/sda -d sat # /dev/sda, ATA device'\n"
+		if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := func() int {
+		out, _ := ioutil.ReadFile(log)
+		return strings.Count(string(out), "\n")
+	}
+
+	writeScript("6.5")
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Ready(context.Background()); err == nil {
+		t.Fatal("expected an unsupported version not to be ready")
+	}
+	writeScript("7.1")
+	if err := c.Ready(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// a ready collector does not run smartctl
+	n := runs()
+	for i := 0; i < 2; i++ {
+		if err := c.Ready(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs() != n {
+		t.Fatal("expected smartctl not to run once ready, ran", runs()-n, "times")
+	}
+}
+
+func TestCloseKillsSmartctl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	started := filepath.Join(dir, "started")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\ntouch " + started + "\nexec sleep 60\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		c.Collect(ch)
+	}()
+	go func() {
+		for range ch {
+		}
+	}()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("smartctl was not started")
+		}
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		c.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected Close to kill smartctl")
+	}
+	// Close waits for the collection to return
+	select {
+	case <-collected:
+	default:
+		t.Fatal("expected the collection to return before Close")
+	}
+}
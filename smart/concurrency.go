@@ -0,0 +1,98 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// controller returns the controller the device is attached through, the
+// devices of a controller are collected one at a time as some RAID
+// controllers misbehave when queried by several smartctl commands at once.
+// Returns "" if the device can be collected concurrently with any other.
+//
+// The devices behind a RAID controller share the name of the controller
+// and differ by the type, e.g. megaraid,0 and megaraid,1 of /dev/sda, and
+// the SAS disks behind an expander have the expander in their sysfs path.
+func (o *CollectorOptions) controller(d Device) string {
+	if controller := o.device(d).Controller; controller != "" {
+		return controller
+	}
+	// the NVMe drives of tri-mode controllers share the controller too
+	deviceType := strings.TrimPrefix(d.Type, "nvme,")
+	if comma := strings.Index(deviceType, ","); comma > 0 {
+		return deviceType[:comma] + ":" + d.Name
+	}
+	target, err := filepath.EvalSymlinks(d.Name)
+	if err != nil {
+		target = d.Name
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(sysBlock, filepath.Base(target), "device"))
+	if err != nil {
+		return ""
+	}
+	for _, dir := range strings.Split(path, string(filepath.Separator)) {
+		if strings.HasPrefix(dir, "expander-") {
+			return dir
+		}
+	}
+	return ""
+}
+
+// groupDevices groups the devices by controller in the order of the scan,
+// the devices without a controller are in a group of their own
+func (o *CollectorOptions) groupDevices(devices []Device) [][]Device {
+	groups := [][]Device{}
+	index := map[string]int{}
+	for _, d := range devices {
+		controller := o.controller(d)
+		if i, found := index[controller]; found && controller != "" {
+			groups[i] = append(groups[i], d)
+			continue
+		}
+		index[controller] = len(groups)
+		groups = append(groups, []Device{d})
+	}
+	return groups
+}
+
+// eachDevice calls collect for every device.  Up to Concurrency groups of
+// devices are collected in parallel, the devices of a group one at a time.
+func (c *Collector) eachDevice(devices []Device, collect func(d Device)) {
+	if c.collectorOpts.Concurrency <= 1 {
+		for _, d := range devices {
+			collect(d)
+		}
+		return
+	}
+	groups := make(chan []Device)
+	var wg sync.WaitGroup
+	for i := 0; i < c.collectorOpts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groups {
+				for _, d := range group {
+					collect(d)
+				}
+			}
+		}()
+	}
+	for _, group := range c.collectorOpts.groupDevices(devices) {
+		groups <- group
+	}
+	close(groups)
+	wg.Wait()
+}
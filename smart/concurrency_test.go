@@ -0,0 +1,119 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysBlock = path }(sysBlock)
+	sysBlock = filepath.Join(dir, "block")
+
+	// sdc and sdd are behind the same SAS expander
+	for _, disk := range []string{"sdc", "sdd"} {
+		target := filepath.Join(dir, "devices", "host0", "port-0:0", "expander-0:0", "port-0:0:"+disk, "end_device", "target", disk)
+		os.MkdirAll(target, 0755)
+		os.MkdirAll(filepath.Join(sysBlock, disk), 0755)
+		if err := os.Symlink(target, filepath.Join(sysBlock, disk, "device")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &CollectorOptions{Devices: []DeviceOptions{{Name: "/dev/sdf", Controller: "hba0"}, {Name: "/dev/sdg", Controller: "hba0"}}}
+	groups := opts.groupDevices([]Device{
+		{Name: "/dev/sda", Type: "megaraid,0"},
+		{Name: "/dev/sdb", Type: "sat"},
+		{Name: "/dev/sda", Type: "megaraid,1"},
+		{Name: "/dev/sda", Type: "nvme,megaraid,2"},
+		{Name: "/dev/sdc", Type: "scsi"},
+		{Name: "/dev/sdd", Type: "scsi"},
+		{Name: "/dev/sde", Type: "sat"},
+		{Name: "/dev/sdf", Type: "sat"},
+		{Name: "/dev/sdg", Type: "sat"},
+	})
+	expected := [][]string{
+		{"/dev/sda", "/dev/sda", "/dev/sda"},
+		{"/dev/sdb"},
+		{"/dev/sdc", "/dev/sdd"},
+		{"/dev/sde"},
+		{"/dev/sdf", "/dev/sdg"},
+	}
+	if len(groups) != len(expected) {
+		t.Fatal("unexpected groups", groups)
+	}
+	for i, group := range groups {
+		if len(group) != len(expected[i]) {
+			t.Fatal("unexpected group", group)
+		}
+		for j, d := range group {
+			if d.Name != expected[i][j] {
+				t.Fatal("unexpected group", group)
+			}
+		}
+	}
+}
+
+func TestEachDevice(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var mtx sync.Mutex
+	running := map[string]int{}
+	parallel, collected := 0, 0
+	c.eachDevice([]Device{
+		{Name: "/dev/sda", Type: "megaraid,0"},
+		{Name: "/dev/sda", Type: "megaraid,1"},
+		{Name: "/dev/sda", Type: "megaraid,2"},
+		{Name: "/dev/nvme0", Type: "nvme"},
+		{Name: "/dev/nvme1", Type: "nvme"},
+	}, func(d Device) {
+		mtx.Lock()
+		running[d.Name]++
+		if running[d.Name] > 1 {
+			t.Error("devices of", d.Name, "collected concurrently")
+		}
+		total := 0
+		for _, n := range running {
+			total += n
+		}
+		if total > parallel {
+			parallel = total
+		}
+		mtx.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mtx.Lock()
+		running[d.Name]--
+		collected++
+		mtx.Unlock()
+	})
+	if collected != 5 {
+		t.Fatal("expected 5 devices collected, found", collected)
+	}
+	if parallel < 2 {
+		t.Fatal("expected the controllers to be collected in parallel")
+	}
+}
@@ -0,0 +1,110 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descCache reuses the descriptors of the metrics with labels only known
+// while collecting, e.g. the per attribute metrics, instead of creating
+// them for every device on every collection
+type descCache struct {
+	mtx sync.Mutex
+	// generation is incremented by sweep
+	generation uint64
+	descs      map[string]*cachedDesc
+	// maxLabels limits the number of labels of the descriptors, the labels
+	// beyond the limit are dropped by limitLabels.  Unlimited if 0.
+	maxLabels int
+	// limited counts the descriptors returned with labels dropped
+	limited uint64
+}
+
+// cachedDesc is a descriptor and the generation it was last used in
+type cachedDesc struct {
+	desc       *prometheus.Desc
+	generation uint64
+	// limited is set if labels were dropped from the descriptor
+	limited bool
+}
+
+// get returns the descriptor of the metric without variable labels,
+// creating it on first use with the help of the metricMetadata.  The name
+// and labels are sanitized, so the descriptor is valid whatever the
+// attributes reported by the device.
+func (c *descCache) get(name string, constLabels prometheus.Labels) *prometheus.Desc {
+	key := descKey(name, constLabels)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.descs == nil {
+		c.descs = map[string]*cachedDesc{}
+	}
+	cached, found := c.descs[key]
+	if !found {
+		labels := constLabels
+		limited := c.maxLabels > 0 && len(labels) > c.maxLabels
+		if limited {
+			labels = limitLabels(labels, c.maxLabels)
+		}
+		cached = &cachedDesc{desc: prometheus.NewDesc(sanitizeName(name), metricHelp(name), noLabels, sanitizeLabels(labels)), limited: limited}
+		c.descs[key] = cached
+	}
+	cached.generation = c.generation
+	if cached.limited {
+		c.limited++
+	}
+	return cached.desc
+}
+
+// limitedCount returns the number of descriptors returned with labels dropped
+func (c *descCache) limitedCount() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.limited
+}
+
+// sweep starts a new generation and drops the descriptors which were not
+// used during the previous one, e.g. of removed devices or of labels whose
+// value changes on every collection
+func (c *descCache) sweep() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for key, cached := range c.descs {
+		if cached.generation < c.generation {
+			delete(c.descs, key)
+		}
+	}
+	c.generation++
+}
+
+// descKey identifies a descriptor by its name and sorted label pairs
+func descKey(name string, labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	key.WriteString(name)
+	for _, label := range names {
+		key.WriteByte(0xff)
+		key.WriteString(label)
+		key.WriteByte('=')
+		key.WriteString(labels[label])
+	}
+	return key.String()
+}
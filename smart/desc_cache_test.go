@@ -0,0 +1,37 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDescCache(t *testing.T) {
+	c := descCache{}
+	desc := c.get("smartmon_temperature_celsius_raw_value", prometheus.Labels{"disk": "/dev/sda", "type": "sat"})
+	if c.get("smartmon_temperature_celsius_raw_value", prometheus.Labels{"type": "sat", "disk": "/dev/sda"}) != desc {
+		t.Fatal("expected the cached descriptor to be reused")
+	}
+	if c.get("smartmon_temperature_celsius_raw_value", prometheus.Labels{"disk": "/dev/sdb", "type": "sat"}) == desc {
+		t.Fatal("expected a new descriptor for other labels")
+	}
+
+	// descriptors used during the previous generation are kept
+	c.sweep()
+	c.get("smartmon_temperature_celsius_raw_value", prometheus.Labels{"disk": "/dev/sda", "type": "sat"})
+	c.sweep()
+	if len(c.descs) != 1 {
+		t.Fatal("expected the unused descriptor to be dropped, found", len(c.descs))
+	}
+}
@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQuarantined is returned by WithDevice for a device which is not
+// queried because it failed too many consecutive collections
+var ErrQuarantined = errors.New("device quarantined after failing too many consecutive collections")
+
+// controllerLocks serializes the commands to the devices of a controller
+// across the collections and the queries of the API
+type controllerLocks struct {
+	mtx   sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lockController waits until no other device of the controller of d is queried and
+// returns the function releasing the controller.  The devices without a
+// controller are never waited for.
+func (c *Collector) lockController(d Device) func() {
+	controller := c.collectorOpts.controller(d)
+	if controller == "" {
+		return func() {}
+	}
+	c.controllers.mtx.Lock()
+	if c.controllers.locks == nil {
+		c.controllers.locks = map[string]*sync.Mutex{}
+	}
+	l, found := c.controllers.locks[controller]
+	if !found {
+		l = &sync.Mutex{}
+		c.controllers.locks[controller] = l
+	}
+	c.controllers.mtx.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// ScannedDevices returns the devices found by the scan of the collection,
+// see scan, so the devices queried outside of the collection share its
+// scan cache and the paths to the same disk are reduced to the first one
+func (c *Collector) ScannedDevices(ctx context.Context) ([]Device, error) {
+	return c.scan(ctx)
+}
+
+// WithDevice calls read with the options of the device, with the backend,
+// the tolerance and the extra arguments of its device options, once no
+// other device of its controller is queried.  Returns ErrQuarantined
+// without calling read while the device is quarantined.
+func (c *Collector) WithDevice(d Device, read func(opts *Options) error) error {
+	if c.quarantined(d) {
+		return ErrQuarantined
+	}
+	unlock := c.lockController(d)
+	defer unlock()
+	return read(c.deviceOpts(d))
+}
@@ -0,0 +1,81 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithDevice(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{
+		Devices: []DeviceOptions{{Name: "/dev/sdb", Tolerance: TolerancePermissive, ExtraArgs: "-d sat"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the device is read with its options
+	var opts *Options
+	if err := c.WithDevice(Device{Name: "/dev/sdb", Type: "sat"}, func(o *Options) error {
+		opts = o
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Tolerance != TolerancePermissive || len(opts.ExtraArgs) != 2 {
+		t.Errorf("expected the options of /dev/sdb, got %+v", opts)
+	}
+
+	// a quarantined device is not read
+	d := Device{Name: "/dev/sda", Type: "sat"}
+	c.mtx.Lock()
+	c.state(d.Key()).quarantinedUntil = time.Now().Add(time.Hour)
+	c.mtx.Unlock()
+	if err := c.WithDevice(d, func(*Options) error {
+		t.Error("expected the quarantined device not to be read")
+		return nil
+	}); err != ErrQuarantined {
+		t.Errorf("expected the device to be quarantined, got %v", err)
+	}
+}
+
+func TestLockController(t *testing.T) {
+	c, err := NewCollector(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the devices behind the controller are read one at a time
+	unlock := c.lockController(Device{Name: "/dev/bus/0", Type: "megaraid,0"})
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		c.lockController(Device{Name: "/dev/bus/0", Type: "megaraid,1"})()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("expected the second device of the controller to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// the other devices are not
+	c.lockController(Device{Name: "/dev/bus/1", Type: "megaraid,0"})()
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second device of the controller to be read once the first is")
+	}
+}
@@ -0,0 +1,116 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeviceSnapshot is the state of a device found by the last collection,
+// for the consumers which must not query the devices themselves
+type DeviceSnapshot struct {
+	Device
+	// Key identifies the device, see Device.Key
+	Key    string
+	Model  string
+	Serial string
+	WWN    string
+	// PowerMode is the power mode found by the last collection, empty if
+	// the device was skipped as idle before its mode was ever checked
+	PowerMode PowerMode
+	// Health is StatePassed or StateFailed after the health self-assessment
+	// was collected, StateUnknown before
+	Health string
+	// Temperature and Wear, the percentage of the endurance used, are nil
+	// unless the attributes of the device report them
+	Temperature *float64
+	Wear        *float64
+	// LastSelfTest is the most recent self-test which is not in progress,
+	// nil unless the self-tests are collected
+	LastSelfTest *SelfTest
+	// LastCollected is the last time the device was collected without
+	// error, zero if it never was
+	LastCollected time.Time
+}
+
+// DeviceSnapshots returns the state of the devices found by the last scan
+// as of their last collection, sorted by key.  No command is sent to the
+// devices, so the standby, filters, quarantine and concurrency of the
+// collection are all respected.
+func (c *Collector) DeviceSnapshots() []DeviceSnapshot {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	snapshots := []DeviceSnapshot{}
+	for key, st := range c.devices {
+		// the devices restored from the state file are not collected yet
+		if st.absent || st.device.Name == "" {
+			continue
+		}
+		s := DeviceSnapshot{
+			Device:        st.device,
+			Key:           key,
+			Model:         st.model,
+			Serial:        st.serial,
+			PowerMode:     st.mode,
+			Health:        StateUnknown,
+			LastSelfTest:  st.lastSelfTest,
+			LastCollected: st.lastCollected,
+		}
+		if len(st.labels) == len(deviceLabelNames) {
+			s.WWN = st.labels[3]
+			if s.Serial == "" {
+				s.Serial = st.labels[4]
+			}
+		}
+		if st.healthKnown {
+			s.Health = stateOf(st.unhealthy)
+		}
+		if t, found := temperatureOf(st.attributes); found {
+			s.Temperature = &t
+		}
+		if wear, found := st.attributes["percentage_used"]; found {
+			s.Wear = &wear
+		} else if st.wearKnown {
+			wear := st.wear
+			s.Wear = &wear
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Key < snapshots[j].Key
+	})
+	return snapshots
+}
+
+// Refresh collects the devices, as a scrape does, unless the last complete
+// collection is more recent than maxAge, so the DeviceSnapshots are
+// current when the exporter is not scraped.  The devices collected in the
+// background are always current.
+func (c *Collector) Refresh(maxAge time.Duration) {
+	if c.collectorOpts.CollectionInterval > 0 {
+		return
+	}
+	c.refreshMtx.Lock()
+	defer c.refreshMtx.Unlock()
+	c.mtx.Lock()
+	fresh := time.Since(c.collected) < maxAge
+	c.mtx.Unlock()
+	if !fresh {
+		record(func(ch chan<- prometheus.Metric) {
+			c.collect(ch, nil)
+		})
+	}
+}
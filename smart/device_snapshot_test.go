@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDeviceSnapshots(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sda := Device{Name: "/dev/sda", Type: "sat"}
+	disk0 := Device{Name: "/dev/bus/0", Type: "megaraid,0"}
+	disk1 := Device{Name: "/dev/bus/0", Type: "megaraid,1"}
+	for _, d := range []Device{sda, disk0, disk1} {
+		d := d
+		record(func(ch chan<- prometheus.Metric) {
+			c.collectPresent(ch, d)
+		})
+	}
+	c.recordHealth(sda, &DeviceInfo{Failed: true})
+	c.recordAttributes(sda, map[string]float64{"temperature_celsius": 41})
+	c.recordWear(sda, []Attribute{{ID: 177, Value: 90}})
+	c.recordSelfTest(sda, SelfTest{Description: "Short offline", Status: "Completed without error", Passed: true})
+	c.recordHealth(disk1, &DeviceInfo{})
+	c.recordAttributes(disk1, map[string]float64{"percentage_used": 3})
+	// a device restored from the state file is only listed once collected
+	c.mtx.Lock()
+	c.state("/dev/sdb").labels = []string{"/dev/sdb", "sat", "", "", ""}
+	c.mtx.Unlock()
+
+	snapshots := c.DeviceSnapshots()
+	if len(snapshots) != 3 {
+		t.Fatalf("expected 3 devices, got %v", snapshots)
+	}
+	for i, key := range []string{"/dev/bus/0:megaraid,0", "/dev/bus/0:megaraid,1", "/dev/sda"} {
+		if snapshots[i].Key != key {
+			t.Errorf("expected device %d to be %s, got %s", i, key, snapshots[i].Key)
+		}
+	}
+	if s := snapshots[0]; s.Health != StateUnknown || s.Temperature != nil || s.Wear != nil || s.LastSelfTest != nil {
+		t.Errorf("expected nothing known of %s, got %+v", s.Key, s)
+	}
+	if s := snapshots[1]; s.Health != StatePassed || s.Type != "megaraid,1" || s.Wear == nil || *s.Wear != 3 {
+		t.Errorf("expected %s to be healthy with 3%% used, got %+v", s.Key, s)
+	}
+	s := snapshots[2]
+	if s.Health != StateFailed || s.Temperature == nil || *s.Temperature != 41 || s.Wear == nil || *s.Wear != 10 {
+		t.Errorf("expected %s to be failed at 41 degrees with 10%% used, got %+v", s.Key, s)
+	}
+	if s.LastSelfTest == nil || !s.LastSelfTest.Passed {
+		t.Errorf("expected the passed self-test of %s, got %v", s.Key, s.LastSelfTest)
+	}
+}
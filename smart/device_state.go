@@ -0,0 +1,147 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// deviceState is what the collector remembers about a device between scrapes
+type deviceState struct {
+	// lastCollected is the last time the metrics of the device were
+	// collected without error
+	lastCollected time.Time
+	// metrics are the metrics of the last collection, served while the
+	// device is in standby if CollectorOptions.CacheStandby is set
+	metrics []prometheus.Metric
+	// wakeupsAvoided counts the scrapes which skipped the device because
+	// it was in standby or sleep
+	wakeupsAvoided uint64
+	// woken counts the scrapes which woke up the device to collect it
+	woken uint64
+	// mode is the power mode found by the last collection and modeChecked
+	// the last time it was checked
+	mode        PowerMode
+	modeChecked time.Time
+	// standbyCommands counts the smartctl commands issued to the device
+	// while it was in standby or sleep, and skippedStandby the collections
+	// which skipped the device as it was idle
+	standbyCommands uint64
+	skippedStandby  uint64
+	// unhealthy is true if the device failed its health self-assessment
+	// and failingPrefail if a pre-failure attribute fails, as found by the
+	// last collection.  healthKnown is true once the health was collected
+	// and failingAttributes are the attributes at or below their threshold
+	// by name, nil until the attributes were collected.
+	unhealthy         bool
+	healthKnown       bool
+	failingPrefail    bool
+	failingAttributes map[string]bool
+	// wear is the percentage of the endurance of an ATA device used if
+	// wearKnown, selfTestFailed true if its most recent self-test failed and
+	// selfTestKnown once a self-test was collected
+	wear           float64
+	wearKnown      bool
+	selfTestFailed bool
+	selfTestKnown  bool
+	// unsupported is the identity of the device, its label values, if it
+	// was found to lack SMART capability
+	unsupported string
+	// ioTotal is the number of I/Os completed by the device and ioChanged
+	// the time it was last found to change
+	ioTotal   float64
+	ioChanged time.Time
+	// scheduled is the last time the device was collected in the background
+	// and snapshot the metrics of that collection
+	scheduled time.Time
+	snapshot  []prometheus.Metric
+	// firmware is the firmware version last reported by the device with
+	// the serial number, and firmwareChanged the time it was found to
+	// differ from the version previously reported by the same device
+	firmware        string
+	serial          string
+	firmwareChanged time.Time
+	// labels are the values of the deviceLabelNames of the device,
+	// firstSeen and lastSeen the first and last time the device was found
+	// by a scan, and absent true once a scan did not find it
+	labels    []string
+	firstSeen time.Time
+	lastSeen  time.Time
+	absent    bool
+	// attributes are the last raw values of the attributes by name
+	attributes map[string]float64
+	// increases are the last raw values and the sums of the increases of
+	// the increaseAttributes by ID
+	increases map[int]attributeIncrease
+	// history are the last samples of the history attributes by name
+	history map[string]*history
+	// model is the model of the device reported by the last collection
+	model string
+	// device is the device as found by the last scan, and lastSelfTest its
+	// most recent self-test collected
+	device       Device
+	lastSelfTest *SelfTest
+	// failures counts the consecutive failed collections of the device,
+	// which is not queried until quarantinedUntil once they reach
+	// CollectorOptions.QuarantineFailures
+	failures         int
+	quarantinedUntil time.Time
+}
+
+// updateFirmware records the firmware version reported by the device and
+// returns the time the firmware was last found to change, which is zero if
+// no change was seen.  A different serial number is another device
+// installed in place of the previous one rather than a firmware update.
+func (st *deviceState) updateFirmware(firmware, serial string, now time.Time) time.Time {
+	if firmware == "" {
+		return st.firmwareChanged
+	}
+	if st.serial != serial {
+		st.firmwareChanged = time.Time{}
+	} else if st.firmware != "" && st.firmware != firmware {
+		st.firmwareChanged = now
+	}
+	st.firmware, st.serial = firmware, serial
+	return st.firmwareChanged
+}
+
+// recordCollection counts the consecutive failed collections and
+// quarantines the device until now+duration once they reach the limit.
+// The count is only reset by a successful collection, so a device still
+// failing after its quarantine is quarantined again after one failure.
+// Returns true if the device is quarantined.
+func (st *deviceState) recordCollection(ok bool, limit int, duration time.Duration, now time.Time) bool {
+	if ok {
+		st.failures = 0
+		return false
+	}
+	st.failures++
+	if limit <= 0 || st.failures < limit {
+		return false
+	}
+	st.quarantinedUntil = now.Add(duration)
+	return true
+}
+
+// state returns the state of the device with the key, see Device.Key,
+// creating it on first use.  The caller must hold c.mtx.
+func (c *Collector) state(key string) *deviceState {
+	st, found := c.devices[key]
+	if !found {
+		st = &deviceState{}
+		c.devices[key] = st
+	}
+	return st
+}
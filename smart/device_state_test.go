@@ -0,0 +1,61 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdateFirmware(t *testing.T) {
+	st := &deviceState{}
+	now := time.Now()
+	if changed := st.updateFirmware("CC43", "Z3YX2S30", now); !changed.IsZero() {
+		t.Fatal("expected no change on the first collection, found", changed)
+	}
+	if changed := st.updateFirmware("CC43", "Z3YX2S30", now.Add(time.Minute)); !changed.IsZero() {
+		t.Fatal("expected no change for the same firmware, found", changed)
+	}
+	if changed := st.updateFirmware("CC47", "Z3YX2S30", now.Add(2*time.Minute)); !changed.Equal(now.Add(2 * time.Minute)) {
+		t.Fatal("expected the firmware change to be recorded, found", changed)
+	}
+	if changed := st.updateFirmware("CC47", "Z3YX2S30", now.Add(3*time.Minute)); !changed.Equal(now.Add(2 * time.Minute)) {
+		t.Fatal("expected the time of the change to be kept, found", changed)
+	}
+	if changed := st.updateFirmware("CC43", "W4Z0ABCD", now.Add(4*time.Minute)); !changed.IsZero() {
+		t.Fatal("expected a replaced device not to be a firmware change, found", changed)
+	}
+}
+
+func TestRecordCollection(t *testing.T) {
+	st := &deviceState{}
+	now := time.Now()
+	if st.recordCollection(false, 2, time.Hour, now) {
+		t.Fatal("unexpected quarantine after 1 failure")
+	}
+	if !st.recordCollection(false, 2, time.Hour, now) {
+		t.Fatal("expected a quarantine after 2 failures")
+	}
+	if !st.quarantinedUntil.Equal(now.Add(time.Hour)) {
+		t.Fatal("unexpected end of quarantine", st.quarantinedUntil)
+	}
+	// still failing once the quarantine ended
+	if !st.recordCollection(false, 2, time.Hour, now.Add(2*time.Hour)) {
+		t.Fatal("expected a quarantine after another failure")
+	}
+	if st.recordCollection(true, 2, time.Hour, now) || st.failures != 0 {
+		t.Fatal("expected the failures to be reset by a successful collection")
+	}
+	if st.recordCollection(false, 0, time.Hour, now) || st.recordCollection(false, 0, time.Hour, now) {
+		t.Fatal("unexpected quarantine without a limit")
+	}
+}
@@ -0,0 +1,152 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// procDiskstats lists the I/O statistics of the block devices
+var procDiskstats = "/proc/diskstats"
+
+// nvmeNamespaceRegex matches the block devices of the namespaces of an NVMe
+// controller, e.g. nvme0n1 but not its partitions nvme0n1p1
+var nvmeNamespaceRegex = regexp.MustCompile(`^(nvme\d+)n\d+$`)
+
+// The I/O statistics of the devices read from /proc/diskstats
+var (
+	smartMonIOReadsDesc        = prometheus.NewDesc("smartmon_device_io_reads_completed_total", "number of reads completed by the device", deviceLabelNames, noConstLabels)
+	smartMonIOWritesDesc       = prometheus.NewDesc("smartmon_device_io_writes_completed_total", "number of writes completed by the device", deviceLabelNames, noConstLabels)
+	smartMonIOReadBytesDesc    = prometheus.NewDesc("smartmon_device_io_read_bytes_total", "number of bytes read from the device", deviceLabelNames, noConstLabels)
+	smartMonIOWrittenBytesDesc = prometheus.NewDesc("smartmon_device_io_written_bytes_total", "number of bytes written to the device", deviceLabelNames, noConstLabels)
+	smartMonIOReadTimeDesc     = prometheus.NewDesc("smartmon_device_io_read_time_seconds_total", "time spent by the reads of the device, divided by the reads completed gives the read latency", deviceLabelNames, noConstLabels)
+	smartMonIOWriteTimeDesc    = prometheus.NewDesc("smartmon_device_io_write_time_seconds_total", "time spent by the writes of the device, divided by the writes completed gives the write latency", deviceLabelNames, noConstLabels)
+	smartMonIOInFlightDesc     = prometheus.NewDesc("smartmon_device_io_in_flight", "number of I/Os currently in progress on the device", deviceLabelNames, noConstLabels)
+	smartMonIOTimeDesc         = prometheus.NewDesc("smartmon_device_io_time_seconds_total", "time the device spent doing I/Os", deviceLabelNames, noConstLabels)
+)
+
+// diskSectorSize is the unit of the sectors of /proc/diskstats regardless
+// of the sector size of the device
+const diskSectorSize = 512
+
+// diskStats are the I/O statistics of a block device from /proc/diskstats
+type diskStats struct {
+	reads, readSectors, readMillis      float64
+	writes, writtenSectors, writeMillis float64
+	inFlight, ioMillis                  float64
+}
+
+// add sums the statistics, e.g. of the namespaces of an NVMe controller
+func (s *diskStats) add(other diskStats) {
+	s.reads += other.reads
+	s.readSectors += other.readSectors
+	s.readMillis += other.readMillis
+	s.writes += other.writes
+	s.writtenSectors += other.writtenSectors
+	s.writeMillis += other.writeMillis
+	s.inFlight += other.inFlight
+	s.ioMillis += other.ioMillis
+}
+
+// parseDiskstats parses the content of /proc/diskstats by device name, e.g.
+//
+//	8       0 sda 1626 463 145238 1072 1041 1234 52112 3016 0 1432 4088 ...
+//
+// The fields following the name are the reads completed, merged, sectors
+// read and milliseconds spent reading, the same for writes, the I/Os in
+// progress and the milliseconds spent doing I/Os
+func parseDiskstats(content []byte) map[string]diskStats {
+	stats := map[string]diskStats{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		values := make([]float64, 10)
+		for i := range values {
+			value, err := strconv.ParseUint(fields[i+3], 10, 64)
+			if err != nil {
+				values = nil
+				break
+			}
+			values[i] = float64(value)
+		}
+		if values == nil {
+			continue
+		}
+		stats[fields[2]] = diskStats{
+			reads:          values[0],
+			readSectors:    values[2],
+			readMillis:     values[3],
+			writes:         values[4],
+			writtenSectors: values[6],
+			writeMillis:    values[7],
+			inFlight:       values[8],
+			ioMillis:       values[9],
+		}
+	}
+	return stats
+}
+
+// ioStats returns the I/O statistics of the device.  The statistics of an
+// NVMe controller are the sum of the statistics of its namespaces.
+func (d *Device) ioStats(stats map[string]diskStats) (diskStats, bool) {
+	target, err := filepath.EvalSymlinks(d.Name)
+	if err != nil {
+		target = d.Name
+	}
+	name := filepath.Base(target)
+	if s, found := stats[name]; found {
+		return s, true
+	}
+	sum, found := diskStats{}, false
+	for device, s := range stats {
+		if match := nvmeNamespaceRegex.FindStringSubmatch(device); match != nil && match[1] == name {
+			sum.add(s)
+			found = true
+		}
+	}
+	return sum, found
+}
+
+// collectDiskStats collects the I/O statistics of the device, so the
+// latency of a disk can be correlated with its health
+func (c *Collector) collectDiskStats(ch chan<- prometheus.Metric, d Device) {
+	content, err := ioutil.ReadFile(procDiskstats)
+	if err != nil {
+		c.collectError(ch, d, "diskstats", err)
+		return
+	}
+	s, found := d.ioStats(parseDiskstats(content))
+	if !found {
+		return
+	}
+	labels := c.labelValues(d)
+	c.constMetric(ch, smartMonIOReadsDesc, prometheus.CounterValue, s.reads, labels...)
+	c.constMetric(ch, smartMonIOWritesDesc, prometheus.CounterValue, s.writes, labels...)
+	c.constMetric(ch, smartMonIOReadBytesDesc, prometheus.CounterValue, s.readSectors*diskSectorSize, labels...)
+	c.constMetric(ch, smartMonIOWrittenBytesDesc, prometheus.CounterValue, s.writtenSectors*diskSectorSize, labels...)
+	c.constMetric(ch, smartMonIOReadTimeDesc, prometheus.CounterValue, s.readMillis/1000, labels...)
+	c.constMetric(ch, smartMonIOWriteTimeDesc, prometheus.CounterValue, s.writeMillis/1000, labels...)
+	c.constMetric(ch, smartMonIOInFlightDesc, prometheus.GaugeValue, s.inFlight, labels...)
+	c.constMetric(ch, smartMonIOTimeDesc, prometheus.CounterValue, s.ioMillis/1000, labels...)
+}
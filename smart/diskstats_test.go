@@ -0,0 +1,42 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import "testing"
+
+func TestDiskStats(t *testing.T) {
+	stats := parseDiskstats([]byte(`   8       0 sda 1626 463 145238 1072 1041 1234 52112 3016 2 1432 4088 0 0 0 0
+   8       1 sda1 1500 400 140000 1000 1000 1200 50000 3000 0 1400 4000 0 0 0 0
+ 259       0 nvme0n1 100 0 800 20 200 0 1600 40 1 50 60
+ 259       1 nvme0n1p1 90 0 700 18 190 0 1500 38 0 48 58
+ 259       2 nvme0n2 10 0 80 2 20 0 160 4 0 5 6
+   7       0 loop0 invalid
+`))
+	sda, found := (&Device{Name: "/dev/sda"}).ioStats(stats)
+	if !found {
+		t.Fatal("expected the stats of sda")
+	}
+	if sda.reads != 1626 || sda.readSectors*diskSectorSize != 145238*512 || sda.writeMillis != 3016 || sda.inFlight != 2 || sda.ioMillis != 1432 {
+		t.Fatal("unexpected stats", sda)
+	}
+
+	// the namespaces of the controller are summed, without their partitions
+	nvme, found := (&Device{Name: "/dev/nvme0"}).ioStats(stats)
+	if !found || nvme.reads != 110 || nvme.writes != 220 || nvme.inFlight != 1 {
+		t.Fatal("unexpected stats", nvme)
+	}
+
+	if _, found := (&Device{Name: "/dev/sdb"}).ioStats(stats); found {
+		t.Fatal("unexpected stats of sdb")
+	}
+}
@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var (
+	smartMonDriveDBDesc           = prometheus.NewDesc("smartmon_drivedb_info", "version of the drive database used by smartctl to name the attributes", []string{"path", "branch", "revision", "date"}, noConstLabels)
+	smartMonDriveDBModifiedDesc   = prometheus.NewDesc("smartmon_drivedb_modified_timestamp_seconds", "unix time the drive database file was last modified", []string{"path"}, noConstLabels)
+	smartMonDriveDBUpdateDesc     = prometheus.NewDesc("smartmon_drivedb_last_update_timestamp_seconds", "unix time of the last update of the drive database run by the exporter", noLabels, noConstLabels)
+	smartMonDriveDBUpdateFailDesc = prometheus.NewDesc("smartmon_drivedb_update_failed", "1 if the last update of the drive database run by the exporter failed", noLabels, noConstLabels)
+)
+
+var (
+	// driveDBPaths are the locations of the drive database, the databases
+	// downloaded by update-smart-drivedb come before the packaged ones
+	driveDBPaths = []string{
+		"/var/lib/smartmontools/drivedb/drivedb.h",
+		"/usr/local/var/lib/smartmontools/drivedb/drivedb.h",
+		"/usr/share/smartmontools/drivedb.h",
+		"/usr/local/share/smartmontools/drivedb.h",
+	}
+	// driveDBUpdateCmd downloads the latest drive database
+	driveDBUpdateCmd = "update-smart-drivedb"
+)
+
+// driveDBHeaderSize is the size of the beginning of the drive database
+// holding its version
+const driveDBHeaderSize = 16 * 1024
+
+// driveDBPath returns the drive database file used by smartctl, or "" if
+// none is found and smartctl uses its built-in database
+func (o *Options) driveDBPath() string {
+	if o != nil && o.DriveDBPath != "" {
+		return o.DriveDBPath
+	}
+	for _, path := range driveDBPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readDriveDB reads the version of the drive database file
+func readDriveDB(path string) (parser.DriveDBVersion, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return parser.DriveDBVersion{}, err
+	}
+	defer f.Close()
+	header := make([]byte, driveDBHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return parser.DriveDBVersion{}, err
+	}
+	return parser.ParseDriveDB(header[:n])
+}
+
+// updateDriveDB runs update-smart-drivedb, through sudo if smartctl runs
+// through sudo
+func updateDriveDB(ctx context.Context, o *Options) error {
+	name, args := driveDBUpdateCmd, []string{}
+	if o != nil && o.Sudo {
+		name, args = "sudo", []string{"-n", driveDBUpdateCmd}
+	}
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return errors.New("Unable to update the drive database: " + err.Error() + ": " + string(output))
+	}
+	return nil
+}
+
+// scheduleDriveDBUpdates updates the drive database on every
+// DriveDBUpdateInterval until the collector is closed
+func (c *Collector) scheduleDriveDBUpdates() {
+	defer c.running.Done()
+	ticker := time.NewTicker(c.collectorOpts.DriveDBUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+		err := updateDriveDB(c.ctx, c.opts)
+		if err != nil {
+			log.Errorln(err)
+		} else {
+			log.Infoln("Updated the drive database")
+		}
+		c.mtx.Lock()
+		c.driveDBUpdated = time.Now()
+		c.driveDBUpdateErr = err
+		c.mtx.Unlock()
+	}
+}
+
+// collectDriveDB reports the version of the drive database and the result
+// of its last update
+func (c *Collector) collectDriveDB(ch chan<- prometheus.Metric) {
+	if path := c.opts.driveDBPath(); path != "" {
+		version, err := readDriveDB(path)
+		if err != nil {
+			c.collectError(ch, Device{}, "drivedb", err)
+		} else {
+			c.constMetric(ch, smartMonDriveDBDesc, prometheus.GaugeValue, 1.0, path, version.Branch, version.Revision, version.Date)
+		}
+		if info, err := os.Stat(path); err == nil {
+			c.constMetric(ch, smartMonDriveDBModifiedDesc, prometheus.GaugeValue, float64(info.ModTime().UnixNano())/1e9, path)
+		}
+	}
+	if c.collectorOpts.DriveDBUpdateInterval <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	updated, err := c.driveDBUpdated, c.driveDBUpdateErr
+	c.mtx.Unlock()
+	if !updated.IsZero() {
+		c.constMetric(ch, smartMonDriveDBUpdateDesc, prometheus.GaugeValue, float64(updated.UnixNano())/1e9)
+		c.constMetric(ch, smartMonDriveDBUpdateFailDesc, prometheus.GaugeValue, boolToMetric(err != nil))
+	}
+}
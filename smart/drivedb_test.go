@@ -0,0 +1,51 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDriveDBPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drivedb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(paths []string) { driveDBPaths = paths }(driveDBPaths)
+	downloaded := filepath.Join(dir, "var", "drivedb.h")
+	packaged := filepath.Join(dir, "share", "drivedb.h")
+	driveDBPaths = []string{downloaded, packaged}
+
+	if path := (&Options{}).driveDBPath(); path != "" {
+		t.Fatal("expected no drive database, found", path)
+	}
+	os.MkdirAll(filepath.Dir(packaged), 0755)
+	ioutil.WriteFile(packaged, []byte(`{ "VERSION: 7.2/5225 2021-06-06 17:22:13 $Id: drivedb.h 5225 2021-06-06 17:22:13Z chrfranke $",`), 0644)
+	if path := (&Options{}).driveDBPath(); path != packaged {
+		t.Fatal("expected the packaged drive database, found", path)
+	}
+	if path := (&Options{DriveDBPath: downloaded}).driveDBPath(); path != downloaded {
+		t.Fatal("expected the configured drive database, found", path)
+	}
+
+	version, err := readDriveDB(packaged)
+	if err != nil {
+		t.Fatal("unable to read drive database", err)
+	}
+	if version.Revision != "5225" {
+		t.Fatal("unexpected version", version)
+	}
+}
@@ -0,0 +1,65 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var smartMonEnclosureDesc = prometheus.NewDesc("smartmon_device_enclosure_info", "enclosure and slot the device is installed in, as reported by the SCSI enclosure services", []string{"disk", "type", "by_id", "wwn", "serial", "enclosure", "slot"}, noConstLabels)
+
+// enclosureSlot is the slot of an enclosure a device is installed in
+type enclosureSlot struct {
+	enclosure string
+	slot      string
+}
+
+// enclosureSlots returns the enclosure slots of the device.  The SCSI
+// device of a disk installed in an enclosure managed by the ses driver
+// links to its slot with an enclosure_device:<slot> link, e.g.
+//
+//	/sys/block/sda/device/enclosure_device:Slot 01 -> ../../0:0:8:0/enclosure/0:0:8:0/Slot 01
+//
+// The slot is the number read from the slot attribute of newer kernels, or
+// the name of the slot.  A disk attached through both ports of a SAS
+// enclosure is reported once per enclosure.
+func (d *Device) enclosureSlots() []enclosureSlot {
+	target, err := filepath.EvalSymlinks(d.Name)
+	if err != nil {
+		return nil
+	}
+	links, _ := filepath.Glob(filepath.Join(sysBlock, filepath.Base(target), "device", "enclosure_device:*"))
+	slots := []enclosureSlot{}
+	for _, link := range links {
+		slotDir, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		slot := strings.TrimPrefix(filepath.Base(link), "enclosure_device:")
+		if number := readSysfs(filepath.Join(slotDir, "slot")); number != "" {
+			slot = number
+		}
+		slots = append(slots, enclosureSlot{enclosure: filepath.Base(filepath.Dir(slotDir)), slot: slot})
+	}
+	return slots
+}
+
+// collectEnclosures reports the enclosure slots the device is installed in
+func (c *Collector) collectEnclosures(ch chan<- prometheus.Metric, d Device) {
+	for _, s := range d.enclosureSlots() {
+		labels := append(c.labelValues(d), s.enclosure, sanitizeValue(s.slot))
+		c.constMetric(ch, smartMonEnclosureDesc, prometheus.GaugeValue, 1.0, labels...)
+	}
+}
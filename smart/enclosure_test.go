@@ -0,0 +1,46 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnclosureSlots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "enclosure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysBlock = path }(sysBlock)
+	sysBlock = filepath.Join(dir, "sys", "block")
+
+	slotDir := filepath.Join(dir, "sys", "devices", "0:0:8:0", "enclosure", "0:0:8:0", "Slot 01")
+	os.MkdirAll(slotDir, 0755)
+	ioutil.WriteFile(filepath.Join(slotDir, "slot"), []byte("1\n"), 0644)
+	deviceDir := filepath.Join(sysBlock, "sda", "device")
+	os.MkdirAll(deviceDir, 0755)
+	if err := os.Symlink(slotDir, filepath.Join(deviceDir, "enclosure_device:Slot 01")); err != nil {
+		t.Fatal(err)
+	}
+	node := filepath.Join(dir, "sda")
+	ioutil.WriteFile(node, nil, 0644)
+
+	d := Device{Name: node}
+	slots := d.enclosureSlots()
+	if len(slots) != 1 || slots[0] != (enclosureSlot{enclosure: "0:0:8:0", slot: "1"}) {
+		t.Fatal("unexpected enclosure slots", slots)
+	}
+}
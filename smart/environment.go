@@ -0,0 +1,142 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var (
+	smartMonEnvironmentDesc = prometheus.NewDesc("smartmon_collector_environment_info", "environment the exporter runs in, the container runtime or none", []string{"container"}, noConstLabels)
+	smartMonScanEmptyDesc   = prometheus.NewDesc("smartmon_scan_empty", "1 if the scan found no devices, with the likely reason", []string{"reason"}, noConstLabels)
+)
+
+var (
+	// dockerEnv and containerEnv are created by docker and podman in the
+	// root of the containers
+	dockerEnv    = "/.dockerenv"
+	containerEnv = "/run/.containerenv"
+	// procCgroup lists the cgroups of the init process, which are named
+	// after the container runtime
+	procCgroup = "/proc/1/cgroup"
+	// devDir contains the device nodes, the host /dev must be mounted into
+	// containers
+	devDir = "/dev"
+)
+
+// The container runtimes reported by smartmon_collector_environment_info
+const (
+	containerNone       = "none"
+	containerDocker     = "docker"
+	containerPodman     = "podman"
+	containerKubernetes = "kubernetes"
+	containerLXC        = "lxc"
+)
+
+// The reasons reported by smartmon_scan_empty
+const (
+	// scanEmptyNoDisks is reported if the kernel knows of no disks either
+	scanEmptyNoDisks = "no_disks"
+	// scanEmptyNodesMissing is reported if the disks are known to the
+	// kernel but their device nodes are missing, e.g. the host /dev is not
+	// mounted into the container
+	scanEmptyNodesMissing = "device_nodes_missing"
+	// scanEmptyNodesUnreadable is reported if the device nodes exist but
+	// cannot be read, e.g. the container is not privileged
+	scanEmptyNodesUnreadable = "device_nodes_unreadable"
+	// scanEmptyUnknown is reported if the device nodes are readable but
+	// smartctl found no devices anyway
+	scanEmptyUnknown = "unknown"
+)
+
+// scanEmptyHints explain the reasons of an empty scan in the logs
+var scanEmptyHints = map[string]string{
+	scanEmptyNoDisks:         "no disks are listed in " + sysBlock,
+	scanEmptyNodesMissing:    "the disks have no device node, mount the host /dev into the container, e.g. with -v /dev:/dev",
+	scanEmptyNodesUnreadable: "the device nodes cannot be read, run the container with --privileged or with the SYS_RAWIO and SYS_ADMIN capabilities and access to the devices",
+	scanEmptyUnknown:         "smartctl found none of the disks, check its output with --log.level=debug",
+}
+
+// detectContainer returns the container runtime the exporter runs in
+func detectContainer() string {
+	if _, err := os.Stat(dockerEnv); err == nil {
+		return containerDocker
+	}
+	if _, err := os.Stat(containerEnv); err == nil {
+		return containerPodman
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return containerKubernetes
+	}
+	cgroup, err := ioutil.ReadFile(procCgroup)
+	if err != nil {
+		return containerNone
+	}
+	switch content := string(cgroup); {
+	case strings.Contains(content, "kubepods"):
+		return containerKubernetes
+	case strings.Contains(content, "docker"):
+		return containerDocker
+	case strings.Contains(content, "libpod"):
+		return containerPodman
+	case strings.Contains(content, "lxc"):
+		return containerLXC
+	}
+	return containerNone
+}
+
+// diagnoseEmptyScan returns the likely reason why the scan found no
+// devices.  The disks are listed in sysfs even in containers, which
+// allows telling missing device nodes from missing disks.
+func diagnoseEmptyScan() string {
+	disks := []string{}
+	for _, pattern := range []string{"sd*", "nvme*", "hd*", "vd*"} {
+		matches, _ := filepath.Glob(filepath.Join(sysBlock, pattern))
+		disks = append(disks, matches...)
+	}
+	if len(disks) == 0 {
+		return scanEmptyNoDisks
+	}
+	found := false
+	for _, disk := range disks {
+		node := filepath.Join(devDir, filepath.Base(disk))
+		if _, err := os.Stat(node); err != nil {
+			continue
+		}
+		found = true
+		if readable(node) {
+			return scanEmptyUnknown
+		}
+	}
+	if !found {
+		return scanEmptyNodesMissing
+	}
+	return scanEmptyNodesUnreadable
+}
+
+// collectEnvironment reports the environment of the exporter and, if the
+// scan found no devices, the likely reason
+func (c *Collector) collectEnvironment(ch chan<- prometheus.Metric, devices []Device) {
+	c.constMetric(ch, smartMonEnvironmentDesc, prometheus.GaugeValue, 1.0, c.container)
+	if len(devices) > 0 {
+		return
+	}
+	reason := diagnoseEmptyScan()
+	log.Warnln("The scan found no devices:", scanEmptyHints[reason])
+	c.constMetric(ch, smartMonScanEmptyDesc, prometheus.GaugeValue, 1.0, reason)
+}
@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectContainer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "environment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(docker, container, cgroup string) {
+		dockerEnv, containerEnv, procCgroup = docker, container, cgroup
+	}(dockerEnv, containerEnv, procCgroup)
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	dockerEnv = filepath.Join(dir, ".dockerenv")
+	containerEnv = filepath.Join(dir, ".containerenv")
+	procCgroup = filepath.Join(dir, "cgroup")
+
+	if container := detectContainer(); container != containerNone {
+		t.Fatal("expected no container, found", container)
+	}
+	ioutil.WriteFile(procCgroup, []byte("0::/kubepods/besteffort/pod1234/abcdef\n"), 0644)
+	if container := detectContainer(); container != containerKubernetes {
+		t.Fatal("expected kubernetes, found", container)
+	}
+	ioutil.WriteFile(dockerEnv, nil, 0644)
+	if container := detectContainer(); container != containerDocker {
+		t.Fatal("expected docker, found", container)
+	}
+}
+
+func TestDiagnoseEmptyScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "environment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(block, dev string) { sysBlock, devDir = block, dev }(sysBlock, devDir)
+	sysBlock = filepath.Join(dir, "sys", "block")
+	devDir = filepath.Join(dir, "dev")
+	os.MkdirAll(filepath.Join(sysBlock, "loop0"), 0755)
+	os.MkdirAll(devDir, 0755)
+
+	if reason := diagnoseEmptyScan(); reason != scanEmptyNoDisks {
+		t.Fatal("expected", scanEmptyNoDisks, "found", reason)
+	}
+	os.MkdirAll(filepath.Join(sysBlock, "sda"), 0755)
+	if reason := diagnoseEmptyScan(); reason != scanEmptyNodesMissing {
+		t.Fatal("expected", scanEmptyNodesMissing, "found", reason)
+	}
+	ioutil.WriteFile(filepath.Join(devDir, "sda"), nil, 0644)
+	if reason := diagnoseEmptyScan(); reason != scanEmptyUnknown {
+		t.Fatal("expected", scanEmptyUnknown, "found", reason)
+	}
+}
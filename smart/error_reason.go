@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import "strings"
+
+// The reasons of smartmon_collector_error, a bounded set of values so the
+// errors do not create a series per error message
+const (
+	// errorReasonTimeout is a command killed or not completed in time
+	errorReasonTimeout = "timeout"
+	// errorReasonPermission is a device or command the exporter is not
+	// permitted to access
+	errorReasonPermission = "permission"
+	// errorReasonNotFound is a missing smartctl, device or file
+	errorReasonNotFound = "not_found"
+	// errorReasonExitStatus is smartctl exiting with a fatal status
+	errorReasonExitStatus = "exit_status"
+	// errorReasonParse is an output which could not be parsed
+	errorReasonParse = "parse"
+	// errorReasonOther is any other error
+	errorReasonOther = "other"
+)
+
+// errorReasons map the messages of the errors to their reason, in order
+var errorReasons = []struct {
+	reason   string
+	messages []string
+}{
+	{errorReasonTimeout, []string{"signal: killed", "deadline exceeded", "context canceled", "timed out", "timeout"}},
+	{errorReasonPermission, []string{"permission denied", "operation not permitted"}},
+	{errorReasonNotFound, []string{"executable file not found", "no such file or directory", "no such device"}},
+	{errorReasonExitStatus, []string{"exit status"}},
+	{errorReasonParse, []string{"unable to parse", "unable to find", "too short", "invalid character", "unexpected end of json", "cannot unmarshal"}},
+}
+
+// errorReason returns the reason of the error reported by
+// smartmon_collector_error
+func errorReason(err error) string {
+	message := strings.ToLower(err.Error())
+	for _, r := range errorReasons {
+		for _, m := range r.messages {
+			if strings.Contains(message, m) {
+				return r.reason
+			}
+		}
+	}
+	return errorReasonOther
+}
@@ -0,0 +1,42 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestErrorReason(t *testing.T) {
+	var syntaxErr error = json.Unmarshal([]byte("{"), &struct{}{})
+	for _, test := range []struct {
+		err    error
+		reason string
+	}{
+		{errors.New("Failed to execute command: signal: killed"), errorReasonTimeout},
+		{context.DeadlineExceeded, errorReasonTimeout},
+		{errors.New("Failed to execute command: open /dev/sda: permission denied"), errorReasonPermission},
+		{errors.New(`Failed to execute command: exec: "smartctl": executable file not found in $PATH`), errorReasonNotFound},
+		{errors.New("Failed to execute command: exit status 2: device_open"), errorReasonExitStatus},
+		{errors.New("unable to find attribute table in smartctl output"), errorReasonParse},
+		{syntaxErr, errorReasonParse},
+		{errors.New("NVMe health log too short: 12 bytes"), errorReasonParse},
+		{errors.New("something else"), errorReasonOther},
+	} {
+		if reason := errorReason(test.err); reason != test.reason {
+			t.Errorf("expected reason %s of %q, got %s", test.reason, test.err, reason)
+		}
+	}
+}
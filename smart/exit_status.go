@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "strings"
+
+// ExitStatus is the exit status of smartctl, a bit mask of the conditions
+// encountered by smartctl
+type ExitStatus int
+
+// The bits of the ExitStatus as documented in the smartctl man page
+const (
+	// ExitCommandLine is set if the command line did not parse
+	ExitCommandLine ExitStatus = 1 << iota
+	// ExitDeviceOpen is set if the device could not be opened, or if it
+	// was skipped because it is in a low-power mode with the -n option
+	ExitDeviceOpen
+	// ExitCommandFailed is set if a command to the device failed or a
+	// SMART data structure had a checksum error, the output may be partial
+	ExitCommandFailed
+	// ExitDiskFailing is set if the SMART status is "DISK FAILING"
+	ExitDiskFailing
+	// ExitPrefailBelowThreshold is set if prefail attributes are at or
+	// below their threshold
+	ExitPrefailBelowThreshold
+	// ExitBelowThresholdInPast is set if attributes were at or below their
+	// threshold in the past
+	ExitBelowThresholdInPast
+	// ExitErrorLog is set if the error log of the device contains errors
+	ExitErrorLog
+	// ExitSelfTestLog is set if the self-test log contains errors
+	ExitSelfTestLog
+)
+
+// exitStatusNames name the bits of the ExitStatus
+var exitStatusNames = []string{
+	"command_line",
+	"device_open",
+	"command_failed",
+	"disk_failing",
+	"prefail_below_threshold",
+	"below_threshold_in_past",
+	"error_log",
+	"self_test_log",
+}
+
+// Fatal returns true if smartctl did not read the device, in which case its
+// output contains no data.  The output is valid for the other conditions.
+func (s ExitStatus) Fatal() bool {
+	return s&(ExitCommandLine|ExitDeviceOpen) != 0
+}
+
+// String lists the names of the bits set, e.g. "disk_failing,error_log"
+func (s ExitStatus) String() string {
+	names := []string{}
+	for i, name := range exitStatusNames {
+		if s&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,75 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// fakeSmartctl writes a script printing the output and exiting with the
+// status, to be executed instead of smartctl
+func fakeSmartctl(t *testing.T, dir string, output string, status int) string {
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + output + "EOF\nexit " + strconv.Itoa(status) + "\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExitStatus(t *testing.T) {
+	status := ExitDiskFailing | ExitErrorLog
+	if status.Fatal() {
+		t.Fatal("a failing disk should not be fatal")
+	}
+	if status.String() != "disk_failing,error_log" {
+		t.Fatal("unexpected status", status.String())
+	}
+	if !(ExitDeviceOpen | ExitErrorLog).Fatal() {
+		t.Fatal("a device which cannot be opened should be fatal")
+	}
+}
+
+func TestSmartCtlExitStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// smartctl exits with the disk failing bit but the info is valid
+	opts := &Options{SmartctlPath: fakeSmartctl(t, dir, `=== START OF INFORMATION SECTION ===
+Device Model:     ST4000DM000-1F2168
+SMART support is: Available - device has SMART capability.
+SMART support is: Enabled
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: FAILED!
+`, int(ExitDiskFailing))}
+	d := Device{Name: "/dev/sda", Type: "sat"}
+	info, err := d.info(context.Background(), opts)
+	if err != nil {
+		t.Fatal("expected the info of the failing disk, found", err)
+	}
+	if info.Healthy || info.Attributes["device_model"] != "ST4000DM000-1F2168" {
+		t.Fatal("unexpected info", info)
+	}
+
+	opts.SmartctlPath = fakeSmartctl(t, dir, "Smartctl open device: /dev/sda failed: No such device\n", int(ExitDeviceOpen))
+	if _, err := d.info(context.Background(), opts); err == nil {
+		t.Fatal("expected an error for a device which cannot be opened")
+	}
+}
@@ -0,0 +1,50 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// failureRiskAttributes are the IDs of the ATA attributes whose nonzero raw
+// value Backblaze found to correlate with drive failures: the reallocated
+// sectors, the reported uncorrectable errors, the command timeouts, the
+// pending sectors and the offline uncorrectable sectors
+var failureRiskAttributes = []int{5, 187, 188, 197, 198}
+
+var smartMonFailureRiskDesc = prometheus.NewDesc("smartmon_device_failure_risk", "heuristic, not a prediction: number of the ATA attributes 5, 187, 188, 197 and 198 with a nonzero raw value, which published drive statistics correlate with failures", deviceLabelNames, noConstLabels)
+
+// failureRisk returns the number of failureRiskAttributes of the device with
+// a nonzero raw value, and false if the device reports none of them
+func failureRisk(attrs []Attribute) (float64, bool) {
+	risk, found := 0.0, false
+	for _, id := range failureRiskAttributes {
+		for _, attr := range attrs {
+			if attr.ID != id {
+				continue
+			}
+			found = true
+			if attr.Raw > 0 {
+				risk++
+			}
+			break
+		}
+	}
+	return risk, found
+}
+
+// collectFailureRisk collects the failure risk of an ATA device, if it
+// reports any of the failureRiskAttributes
+func (c *Collector) collectFailureRisk(ch chan<- prometheus.Metric, d Device, attrs []Attribute) {
+	if risk, found := failureRisk(attrs); found {
+		c.constMetric(ch, smartMonFailureRiskDesc, prometheus.GaugeValue, risk, c.labelValues(d)...)
+	}
+}
@@ -0,0 +1,30 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+func TestFailureRisk(t *testing.T) {
+	for _, test := range []struct {
+		attrs []Attribute
+		risk  float64
+		found bool
+	}{
+		{[]Attribute{{ID: 5, Raw: 0}, {ID: 9, Raw: 1000}, {ID: 197, Raw: 0}}, 0, true},
+		{[]Attribute{{ID: 5, Raw: 8}, {ID: 188, Raw: 1}, {ID: 197, Raw: 0}, {ID: 198, Raw: 2}}, 3, true},
+		{[]Attribute{{ID: 9, Raw: 1000}, {ID: 194, Raw: 35}}, 0, false},
+	} {
+		if risk, found := failureRisk(test.attrs); risk != test.risk || found != test.found {
+			t.Errorf("expected risk %v %v of %+v, got %v %v", test.risk, test.found, test.attrs, risk, found)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// smartctlJSONOutputOption embeds the text output of smartctl in its
+	// JSON output
+	smartctlJSONOutputOption = "--json=o"
+	// smartctlHelpOpts prints the usage of smartctl, listing the log types
+	// it reads
+	smartctlHelpOpts = []string{"-h"}
+)
+
+var smartMonFeaturesDesc = prometheus.NewDesc("smartmon_smartctl_capabilities_info", "features of smartctl found by probing it, \"true\" or \"false\"", []string{"version", "json", "json_output", "json_svn_revision", "devstat"}, noConstLabels)
+
+// Features are the features of the installed smartctl, found by running it
+// rather than inferred from its version, as the distributions ship builds
+// of the same version with different patches
+type Features struct {
+	// Version and Revision are the version of smartctl and its svn
+	// revision, read from the JSON metadata if it reports it
+	Version  string
+	Revision string
+	// JSON is true if smartctl outputs valid JSON with -j, which selects
+	// the JSON parsers unless Options.DisableJSON is set
+	JSON bool
+	// JSONOutput is true if smartctl embeds its text output in the JSON
+	// output with --json=o
+	JSONOutput bool
+	// JSONRevision is true if the JSON metadata reports the svn revision,
+	// missing from the JSON output of some builds
+	JSONRevision bool
+	// DevStat is true if smartctl reads the Device Statistics log of the
+	// ATA devices with -l devstat
+	DevStat bool
+}
+
+// probedFeatures caches the Features of the smartctl builds by command and
+// version output, so smartctl is probed again when it is upgraded
+var probedFeatures = struct {
+	sync.Mutex
+	features map[string]*Features
+}{features: map[string]*Features{}}
+
+// features returns the Features of smartctl, probing them the first time
+// a build of smartctl is run
+func features(ctx context.Context, o *Options) (*Features, error) {
+	output, _, err := smartCtl(ctx, o, smartctlVersionOpts...)
+	if err != nil {
+		return nil, err
+	}
+	name, args := o.command(smartctlVersionOpts)
+	key := name + " " + strings.Join(args, " ") + "\x00" + string(output)
+	probedFeatures.Lock()
+	f, found := probedFeatures.features[key]
+	probedFeatures.Unlock()
+	if found {
+		return f, nil
+	}
+	f, err = probeFeatures(ctx, o, output)
+	if err != nil {
+		return nil, err
+	}
+	probedFeatures.Lock()
+	probedFeatures.features[key] = f
+	probedFeatures.Unlock()
+	return f, nil
+}
+
+// probeFeatures runs smartctl with the options of every feature.  A probe
+// which cannot run at all, e.g. missing from the recordings replayed, falls
+// back to the version of smartctl rather than disabling the feature.
+func probeFeatures(ctx context.Context, o *Options, versionOutput []byte) (*Features, error) {
+	version, err := parser.ParseVersion(versionOutput)
+	if err != nil {
+		return nil, err
+	}
+	f := &Features{Version: version, Revision: parser.ParseRevision(versionOutput)}
+	output, status, err := smartCtl(ctx, o, useJSON(smartctlVersionOpts)...)
+	if meta, parseErr := parser.ParseVersionJSON(output); err == nil && parseErr == nil {
+		f.JSON = true
+		if meta.Smartctl.SvnRevision != "" {
+			f.JSONRevision = true
+			f.Revision = meta.Smartctl.SvnRevision
+		}
+	} else if err != nil && status == 0 {
+		f.JSON = jsonVersion(version)
+	}
+	if f.JSON {
+		output, _, err := smartCtl(ctx, o, append([]string{smartctlJSONOutputOption}, smartctlVersionOpts...)...)
+		if meta, parseErr := parser.ParseVersionJSON(output); err == nil && parseErr == nil {
+			f.JSONOutput = len(meta.Smartctl.Output) > 0
+		}
+	}
+	output, _, err = smartCtl(ctx, o, smartctlHelpOpts...)
+	if err == nil {
+		f.DevStat = bytes.Contains(output, []byte("devstat"))
+	}
+	return f, nil
+}
+
+// collectFeatures collects the version and the features of smartctl
+func (c *Collector) collectFeatures(ch chan<- prometheus.Metric, f *Features) {
+	c.constMetric(ch, smartMonVersionDesc, prometheus.GaugeValue, 1.0, sanitizeValue(f.Version), sanitizeValue(f.Revision))
+	c.constMetric(ch, smartMonFeaturesDesc, prometheus.GaugeValue, 1.0, sanitizeValue(f.Version), strconv.FormatBool(f.JSON), strconv.FormatBool(f.JSONOutput), strconv.FormatBool(f.JSONRevision), strconv.FormatBool(f.DevStat))
+}
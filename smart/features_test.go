@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// featureScript answers the probes like the given smartctl build
+func featureScript(t *testing.T, dir, version, jsonVersion, jsonOutput, help string) string {
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\ncase \"$*\" in\n" +
+		"\"-j -V\") echo '" + jsonVersion + "'; [ -n '" + jsonVersion + "' ] || exit 1 ;;\n" +
+		"\"--json=o -V\") echo '" + jsonOutput + "' ;;\n" +
+		"-h) echo '" + help + "' ;;\n" +
+		"*) echo '" + version + "' ;;\n" +
+		"esac\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFeatures(t *testing.T) {
+	for _, test := range []struct {
+		name                             string
+		version, jsonVersion, jsonOutput string
+		help                             string
+		expected                         Features
+	}{
+		{
+			name:     "6.6",
+			version:  "smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.9.0] (local build)",
+			help:     "  -l TYPE, --log=TYPE  ... xerror[,N][,error], xselftest[,N][,selftest], devstat[,N]",
+			expected: Features{Version: "6.6", Revision: "4324", DevStat: true},
+		},
+		{
+			name:        "7.1",
+			version:     "smartctl 7.1 2019-12-30 r5022 [x86_64-linux-5.4.0] (local build)",
+			jsonVersion: `{"smartctl": {"version": [7, 1], "svn_revision": "5022"}}`,
+			jsonOutput:  `{"smartctl": {"version": [7, 1], "output": ["smartctl 7.1"]}}`,
+			help:        "devstat",
+			expected:    Features{Version: "7.1", Revision: "5022", JSON: true, JSONOutput: true, JSONRevision: true, DevStat: true},
+		},
+		{
+			// a patched build whose JSON lacks the revision and --json=o
+			name:        "7.0 patched",
+			version:     "smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7] (local build)",
+			jsonVersion: `{"smartctl": {"version": [7, 0]}}`,
+			jsonOutput:  `{"smartctl": {"version": [7, 0]}}`,
+			expected:    Features{Version: "7.0", Revision: "4883", JSON: true},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "smartctl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := featureScript(t, dir, test.version, test.jsonVersion, test.jsonOutput, test.help)
+			f, err := features(context.Background(), &Options{SmartctlPath: path})
+			if err != nil {
+				t.Fatal("unable to probe the features", err)
+			}
+			if *f != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, *f)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// procMounts lists the mounted filesystems
+var procMounts = "/proc/mounts"
+
+var smartMonFilesystemDesc = prometheus.NewDesc("smartmon_device_filesystem_info", "filesystem mounted from the device, one of its partitions or a volume stacked on them", []string{"disk", "type", "by_id", "wwn", "serial", "partition", "mountpoint", "fstype"}, noConstLabels)
+
+// mount is a filesystem mounted according to /proc/mounts
+type mount struct {
+	source     string
+	mountpoint string
+	fstype     string
+}
+
+// parseMounts parses the content of /proc/mounts, the spaces and other
+// special characters of the paths are escaped as octal numbers, e.g. \040
+func parseMounts(content []byte) []mount {
+	mounts := []mount{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mounts = append(mounts, mount{
+			source:     unescapeMountPath(fields[0]),
+			mountpoint: unescapeMountPath(fields[1]),
+			fstype:     fields[2],
+		})
+	}
+	return mounts
+}
+
+// unescapeMountPath replaces the octal escapes of a path of /proc/mounts
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// blockDevices maps the block devices of the device to the partition they
+// belong to.  The block devices are the disk itself, or the namespaces of an
+// NVMe controller, their partitions and the volumes stacked on them, e.g.
+// LVM logical volumes or dm-crypt mappings.
+func (d *Device) blockDevices() map[string]string {
+	roots := []string{}
+	if d.nativeNVMe() {
+		controller := filepath.Base(d.Name)
+		namespaces, _ := filepath.Glob(filepath.Join(sysClassNVMe, controller, controller+"n*"))
+		for _, namespace := range namespaces {
+			roots = append(roots, filepath.Base(namespace))
+		}
+	} else if target, err := filepath.EvalSymlinks(d.Name); err == nil {
+		roots = append(roots, filepath.Base(target))
+	}
+
+	devices := map[string]string{}
+	for _, root := range roots {
+		rootDir := filepath.Join(sysBlock, root)
+		addHolders(devices, rootDir, root)
+		partitions, _ := filepath.Glob(filepath.Join(rootDir, root+"*"))
+		for _, partition := range partitions {
+			addHolders(devices, partition, filepath.Base(partition))
+		}
+	}
+	return devices
+}
+
+// addHolders maps the block device of the sysfs directory and the devices
+// holding it, recursively, to the partition
+func addHolders(devices map[string]string, dir string, partition string) {
+	name := filepath.Base(dir)
+	if _, found := devices[name]; found {
+		return
+	}
+	devices[name] = partition
+	holders, _ := filepath.Glob(filepath.Join(dir, "holders", "*"))
+	for _, holder := range holders {
+		addHolders(devices, filepath.Join(sysBlock, filepath.Base(holder)), partition)
+	}
+}
+
+// collectFilesystems reports the filesystems mounted from the device
+func (c *Collector) collectFilesystems(ch chan<- prometheus.Metric, d Device) {
+	content, err := ioutil.ReadFile(procMounts)
+	if err != nil {
+		c.collectError(ch, d, "filesystems", err)
+		return
+	}
+	devices := d.blockDevices()
+	for _, m := range parseMounts(content) {
+		if !strings.HasPrefix(m.source, "/dev/") {
+			continue
+		}
+		source, err := filepath.EvalSymlinks(m.source)
+		if err != nil {
+			continue
+		}
+		if partition, found := devices[filepath.Base(source)]; found {
+			labels := append(c.labelValues(d), partition, sanitizeValue(m.mountpoint), m.fstype)
+			c.constMetric(ch, smartMonFilesystemDesc, prometheus.GaugeValue, 1.0, labels...)
+		}
+	}
+}
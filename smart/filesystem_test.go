@@ -0,0 +1,30 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+func TestParseMounts(t *testing.T) {
+	mounts := parseMounts([]byte(`sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+/dev/mapper/data-backups /srv/backup\040copies xfs rw,relatime 0 0
+`))
+	if len(mounts) != 3 {
+		t.Fatal("expected 3 mounts, found", len(mounts))
+	}
+	if mounts[1].source != "/dev/sda1" || mounts[1].mountpoint != "/" || mounts[1].fstype != "ext4" {
+		t.Fatal("unexpected mount", mounts[1])
+	}
+	if mounts[2].mountpoint != "/srv/backup copies" {
+		t.Fatal("unexpected escaped mountpoint", mounts[2].mountpoint)
+	}
+}
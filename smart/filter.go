@@ -0,0 +1,139 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Names of the collectors of device metrics a Filter selects
+const (
+	CollectorInfo        = "info"
+	CollectorAttributes  = "attributes"
+	CollectorVendorLogs  = "vendor_logs"
+	CollectorFilesystems = "filesystems"
+	CollectorVolumes     = "volumes"
+	CollectorEnclosures  = "enclosures"
+	CollectorDiskStats   = "diskstats"
+	CollectorSelfTest    = "selftest"
+	CollectorErrorLog    = "errorlog"
+	CollectorSecurity    = "security"
+	CollectorCapability  = "capabilities"
+)
+
+// Collectors lists the names of the collectors a Filter selects
+var Collectors = []string{
+	CollectorInfo,
+	CollectorAttributes,
+	CollectorVendorLogs,
+	CollectorFilesystems,
+	CollectorVolumes,
+	CollectorEnclosures,
+	CollectorDiskStats,
+	CollectorSelfTest,
+	CollectorErrorLog,
+	CollectorSecurity,
+	CollectorCapability,
+}
+
+// Filter restricts a collection to some collectors and devices, e.g. to
+// collect the expensive collectors less often from a second scrape job.
+// The global metrics and the power mode of the devices are always
+// collected.
+type Filter struct {
+	// Collectors are the names of the collectors to run, all the collectors
+	// enabled by the CollectorOptions run if empty
+	Collectors []string
+	// Devices are the names of the devices to collect, e.g. /dev/sda, all
+	// the devices are collected if empty
+	Devices []string
+}
+
+// filterKey is the context key of the Filter of a collection
+type filterKey struct{}
+
+// withFilter returns a context carrying the filter of the collection
+func withFilter(ctx context.Context, f *Filter) context.Context {
+	return context.WithValue(ctx, filterKey{}, f)
+}
+
+// filterFrom returns the filter of the collection, nil if it is not filtered
+func filterFrom(ctx context.Context) *Filter {
+	f, _ := ctx.Value(filterKey{}).(*Filter)
+	return f
+}
+
+// collector returns true if the named collector runs
+func (f *Filter) collector(name string) bool {
+	if f == nil || len(f.Collectors) == 0 {
+		return true
+	}
+	for _, collector := range f.Collectors {
+		if collector == name {
+			return true
+		}
+	}
+	return false
+}
+
+// devices returns the devices selected by the filter
+func (f *Filter) devices(devices []Device) []Device {
+	if f == nil || len(f.Devices) == 0 {
+		return devices
+	}
+	selected := []Device{}
+	for _, d := range devices {
+		for _, name := range f.Devices {
+			if name == d.Name || name == d.InfoName {
+				selected = append(selected, d)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// filteredCollector collects the metrics of a Collector selected by a Filter
+type filteredCollector struct {
+	c      *Collector
+	filter *Filter
+}
+
+// Filtered returns a prometheus.Collector collecting the metrics of c
+// selected by the filter.  The collectors cannot be selected when the
+// metrics are collected in the background.
+func (c *Collector) Filtered(f Filter) (prometheus.Collector, error) {
+	for _, name := range f.Collectors {
+		if !(&Filter{Collectors: Collectors}).collector(name) {
+			return nil, errors.New("unknown collector " + name + ", expected one of " + strings.Join(Collectors, ", "))
+		}
+	}
+	if len(f.Collectors) > 0 && c.collectorOpts.CollectionInterval > 0 {
+		return nil, errors.New("collectors cannot be selected when the metrics are collected in the background")
+	}
+	return &filteredCollector{c: c, filter: &f}, nil
+}
+
+// Describe implements the prometheus.Collector interface
+func (f *filteredCollector) Describe(ch chan<- *prometheus.Desc) {
+	f.c.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface
+func (f *filteredCollector) Collect(ch chan<- prometheus.Metric) {
+	f.c.collect(ch, f.filter)
+}
@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFilterDevices(t *testing.T) {
+	devices := []Device{{Name: "/dev/sda", Type: "sat"}, {Name: "/dev/bus/0", InfoName: "/dev/bus/0 [megaraid_disk_00]", Type: "megaraid,0"}, {Name: "/dev/nvme0", Type: "nvme"}}
+	var f *Filter
+	if selected := f.devices(devices); len(selected) != 3 {
+		t.Fatal("expected all the devices, found", selected)
+	}
+	f = &Filter{Devices: []string{"/dev/nvme0", "/dev/bus/0 [megaraid_disk_00]"}}
+	selected := f.devices(devices)
+	if len(selected) != 2 || selected[0].Name != "/dev/bus/0" || selected[1].Name != "/dev/nvme0" {
+		t.Fatal("unexpected devices", selected)
+	}
+}
+
+func TestFiltered(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{CollectionInterval: 1e9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Filtered(Filter{Collectors: []string{"selftests"}}); err == nil {
+		t.Fatal("expected an error selecting an unknown collector")
+	}
+	if _, err := c.Filtered(Filter{Collectors: []string{CollectorInfo}}); err == nil {
+		t.Fatal("expected an error selecting collectors collected in the background")
+	}
+	if _, err := c.Filtered(Filter{Devices: []string{"/dev/sda"}}); err != nil {
+		t.Fatal("unable to select devices collected in the background", err)
+	}
+}
+
+func TestFilterCollectors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// every invocation is logged, the device is always active
+	invocations := filepath.Join(dir, "invocations")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + invocations + "\necho 'Power mode is:    ACTIVE or IDLE'\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := withFilter(context.Background(), &Filter{Collectors: []string{CollectorAttributes}})
+	ch := make(chan prometheus.Metric, 100)
+	c.collectDevice(ctx, ch, Device{Name: "/dev/sda", Type: "sat"})
+	content, err := ioutil.ReadFile(invocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "-i -H") || !strings.Contains(string(content), "-A") {
+		t.Fatal("expected only the attributes to be collected, found", string(content))
+	}
+}
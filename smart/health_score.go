@@ -0,0 +1,173 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The signals of the health score, the keys of the HealthScoreWeights
+const (
+	HealthSignalReallocated = "reallocated_sectors"
+	HealthSignalPending     = "pending_sectors"
+	HealthSignalCRC         = "crc_errors"
+	HealthSignalWear        = "wear"
+	HealthSignalSelfTest    = "self_test"
+)
+
+// DefaultHealthScoreWeights are the points subtracted from the health score
+// of 100 by every signal at its worst
+var DefaultHealthScoreWeights = map[string]float64{
+	HealthSignalReallocated: 25,
+	HealthSignalPending:     25,
+	HealthSignalCRC:         10,
+	HealthSignalWear:        25,
+	HealthSignalSelfTest:    15,
+}
+
+// healthSignalAttributes are the attributes counting the errors of a
+// signal, by signal, and the count at which half of its weight is
+// subtracted.  The NVMe media errors are unrecovered data like the pending
+// sectors of the ATA devices.
+var healthSignalAttributes = map[string]struct {
+	names []string
+	half  float64
+}{
+	HealthSignalReallocated: {[]string{"reallocated_sector_ct"}, 10},
+	HealthSignalPending:     {[]string{"current_pending_sector", "media_errors", "media_and_data_integrity_errors"}, 1},
+	HealthSignalCRC:         {[]string{"udma_crc_error_count"}, 10},
+}
+
+// wearAttributes are the IDs of the ATA attributes whose normalized value
+// is the percentage of the endurance of the device remaining
+var wearAttributes = []int{231, 233, 177}
+
+var smartMonHealthScoreDesc = prometheus.NewDesc("smartmon_device_health_score", "health of the device from 100 down to 0, combining the reallocated and pending sectors, the CRC errors, the wear and the self-test failures with the configured weights", deviceLabelNames, noConstLabels)
+
+// ValidHealthScoreWeights returns an error if a weight is not the weight
+// of a signal or is negative
+func ValidHealthScoreWeights(weights map[string]float64) error {
+	for signal, weight := range weights {
+		if _, found := DefaultHealthScoreWeights[signal]; !found {
+			return errors.New("unknown health score signal: " + signal)
+		}
+		if weight < 0 {
+			return errors.New("health score weight of " + signal + ": " + strconv.FormatFloat(weight, 'g', -1, 64) + " is negative")
+		}
+	}
+	return nil
+}
+
+// healthScoreWeight returns the weight of the signal, the configured weight
+// or the default
+func (o *CollectorOptions) healthScoreWeight(signal string) float64 {
+	if weight, found := o.HealthScoreWeights[signal]; found {
+		return weight
+	}
+	return DefaultHealthScoreWeights[signal]
+}
+
+// recordWear remembers the percentage of the endurance of an ATA device
+// used, from the normalized value of its wear attributes
+func (c *Collector) recordWear(d Device, attrs []Attribute) {
+	if wear, found := ataWear(attrs); found {
+		c.mtx.Lock()
+		c.state(d.Key()).wear = wear
+		c.state(d.Key()).wearKnown = true
+		c.mtx.Unlock()
+	}
+}
+
+// ataWear returns the percentage of the endurance of an ATA device used,
+// from the normalized value of the first of its wear attributes
+func ataWear(attrs []Attribute) (float64, bool) {
+	for _, id := range wearAttributes {
+		for _, attr := range attrs {
+			if attr.ID == id {
+				return math.Max(0, 100-attr.Value), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Wear returns the percentage of the endurance of a device used, reported
+// by the percentage used of an NVMe device or by the wear attributes of an
+// ATA device
+func Wear(attrs []Attribute) (float64, bool) {
+	for _, attr := range attrs {
+		if attr.ID == 0 && parser.NormalizeName(attr.Name) == "percentage_used" {
+			return attr.Raw, true
+		}
+	}
+	return ataWear(attrs)
+}
+
+// recordSelfTest remembers the most recent self-test of the device and
+// notifies the changes of its result
+func (c *Collector) recordSelfTest(d Device, test SelfTest) {
+	c.mtx.Lock()
+	st := c.state(d.Key())
+	old := st.selfTestChange(!test.Passed)
+	st.lastSelfTest = &test
+	c.mtx.Unlock()
+	if old != "" {
+		c.notify(d, Notification{
+			Event:    EventSelfTest,
+			OldState: old,
+			NewState: stateOf(!test.Passed),
+			SelfTest: test.Description + ": " + test.Status,
+		})
+	}
+}
+
+// healthScore computes the health score of the device from the data of its
+// last collection.  Every signal subtracts up to its weight: the error
+// counts half of it at the count of healthSignalAttributes, the wear in
+// proportion to the endurance used and a failed self-test all of it.
+func (c *Collector) healthScore(d Device) float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	penalties := map[string]float64{}
+	for signal, counted := range healthSignalAttributes {
+		for _, name := range counted.names {
+			if count, found := st.attributes[name]; found && count > 0 {
+				penalties[signal] = math.Max(penalties[signal], count/(count+counted.half))
+			}
+		}
+	}
+	if used, found := st.attributes["percentage_used"]; found {
+		penalties[HealthSignalWear] = used / 100
+	} else if st.wearKnown {
+		penalties[HealthSignalWear] = st.wear / 100
+	}
+	if st.selfTestFailed {
+		penalties[HealthSignalSelfTest] = 1
+	}
+	score := 100.0
+	for signal, penalty := range penalties {
+		score -= c.collectorOpts.healthScoreWeight(signal) * math.Min(penalty, 1)
+	}
+	return math.Max(score, 0)
+}
+
+// collectHealthScore collects the health score of the device
+func (c *Collector) collectHealthScore(ch chan<- prometheus.Metric, d Device) {
+	c.constMetric(ch, smartMonHealthScoreDesc, prometheus.GaugeValue, c.healthScore(d), c.labelValues(d)...)
+}
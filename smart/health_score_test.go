@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+func TestHealthScore(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{HealthScoreWeights: map[string]float64{HealthSignalCRC: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	sda := Device{Name: "/dev/sda", Type: "sat"}
+	sdb := Device{Name: "/dev/sdb", Type: "sat"}
+	nvme0 := Device{Name: "/dev/nvme0", Type: "nvme"}
+	c.recordWear(sdb, []Attribute{{ID: 9, Value: 50}, {ID: 177, Value: 60}})
+	c.recordSelfTest(sdb, SelfTest{Passed: false})
+	c.mtx.Lock()
+	c.state("/dev/sda").attributes = map[string]float64{"reallocated_sector_ct": 0, "udma_crc_error_count": 10}
+	c.state("/dev/sdb").attributes = map[string]float64{"current_pending_sector": 1}
+	c.state("/dev/nvme0").attributes = map[string]float64{"percentage_used": 200, "media_errors": 0}
+	c.mtx.Unlock()
+	for _, test := range []struct {
+		d     Device
+		score float64
+	}{
+		// half of the CRC errors weight of 20
+		{sda, 90},
+		// half of pending_sectors, 40% of wear and the self-test failure
+		{sdb, 100 - 12.5 - 10 - 15},
+		// past the rated endurance
+		{nvme0, 75},
+	} {
+		if score := c.healthScore(test.d); score != test.score {
+			t.Errorf("expected %s score %v, got %v", test.d.Name, test.score, score)
+		}
+	}
+}
+
+func TestWear(t *testing.T) {
+	for _, test := range []struct {
+		attrs []Attribute
+		wear  float64
+		found bool
+	}{
+		{[]Attribute{{Name: "Percentage Used", Raw: 7}}, 7, true},
+		{[]Attribute{{ID: 9, Value: 50}, {ID: 177, Value: 60}}, 40, true},
+		{[]Attribute{{ID: 231, Value: 120}}, 0, true},
+		{[]Attribute{{ID: 9, Value: 50}}, 0, false},
+	} {
+		if wear, found := Wear(test.attrs); wear != test.wear || found != test.found {
+			t.Errorf("expected %v %v for %+v, got %v %v", test.wear, test.found, test.attrs, wear, found)
+		}
+	}
+}
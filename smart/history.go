@@ -0,0 +1,119 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"time"
+)
+
+// DefaultHistoryAttributes are the attributes kept in the history of the
+// devices unless CollectorOptions.HistoryAttributes is set: the ATA
+// attributes correlated with drive failures, the NVMe wear and media
+// errors, and the temperature of the device
+var DefaultHistoryAttributes = []string{
+	"reallocated_sector_ct",
+	"reported_uncorrect",
+	"command_timeout",
+	"current_pending_sector",
+	"offline_uncorrectable",
+	"udma_crc_error_count",
+	"percentage_used",
+	"media_errors",
+	"media_and_data_integrity_errors",
+	"temperature",
+}
+
+// Sample is the raw value of an attribute at a collection
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// history is a ring buffer of the last samples of an attribute
+type history struct {
+	samples []Sample
+	// next is the index of the next sample written, which is the oldest
+	// sample once the buffer is full
+	next int
+}
+
+// add records the sample, replacing the oldest one once size samples
+// are recorded
+func (h *history) add(s Sample, size int) {
+	if len(h.samples) < size {
+		h.samples = append(h.samples, s)
+		return
+	}
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+}
+
+// list returns a copy of the samples, oldest first
+func (h *history) list() []Sample {
+	samples := make([]Sample, 0, len(h.samples))
+	samples = append(samples, h.samples[h.next:]...)
+	return append(samples, h.samples[:h.next]...)
+}
+
+// recordHistory adds the values of the history attributes collected from
+// the device to its history.  The temperature is recorded under
+// "temperature" whichever attribute reports it.  The caller must hold
+// c.mtx.
+func (c *Collector) recordHistory(st *deviceState, values map[string]float64, now time.Time) {
+	size := c.collectorOpts.HistorySize
+	if size <= 0 {
+		return
+	}
+	names := c.collectorOpts.HistoryAttributes
+	if len(names) == 0 {
+		names = DefaultHistoryAttributes
+	}
+	if st.history == nil {
+		st.history = map[string]*history{}
+	}
+	for _, name := range names {
+		value, found := values[name]
+		if name == "temperature" {
+			value, found = temperatureOf(values)
+		}
+		if !found {
+			continue
+		}
+		h, found := st.history[name]
+		if !found {
+			h = &history{}
+			st.history[name] = h
+		}
+		h.add(Sample{Time: now, Value: value}, size)
+	}
+}
+
+// AttributeHistory returns the last CollectorOptions.HistorySize samples
+// of the history attributes of the devices, oldest first, by device key,
+// see Device.Key, then attribute name.  The history is only kept in
+// memory, it is empty after a restart of the exporter.
+func (c *Collector) AttributeHistory() map[string]map[string][]Sample {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	devices := make(map[string]map[string][]Sample, len(c.devices))
+	for name, st := range c.devices {
+		if len(st.history) == 0 {
+			continue
+		}
+		attributes := make(map[string][]Sample, len(st.history))
+		for attr, h := range st.history {
+			attributes[attr] = h.list()
+		}
+		devices[name] = attributes
+	}
+	return devices
+}
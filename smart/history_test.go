@@ -0,0 +1,66 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAttributeHistory(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{HistorySize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	c.mtx.Lock()
+	st := c.state("/dev/sda")
+	for i := 0; i < 5; i++ {
+		c.recordHistory(st, map[string]float64{
+			"current_pending_sector": float64(i),
+			"temperature_celsius":    float64(30 + i),
+			"power_on_hours":         1000,
+		}, start.Add(time.Duration(i)*time.Minute))
+	}
+	c.mtx.Unlock()
+
+	// the last 3 samples are kept, the temperature under its common name
+	// and the attributes which are not in the history are ignored
+	sample := func(i int, value float64) Sample {
+		return Sample{Time: start.Add(time.Duration(i) * time.Minute), Value: value}
+	}
+	expected := map[string]map[string][]Sample{
+		"/dev/sda": {
+			"current_pending_sector": {sample(2, 2), sample(3, 3), sample(4, 4)},
+			"temperature":            {sample(2, 32), sample(3, 33), sample(4, 34)},
+		},
+	}
+	if history := c.AttributeHistory(); !reflect.DeepEqual(history, expected) {
+		t.Errorf("expected %v, got %v", expected, history)
+	}
+}
+
+func TestAttributeHistoryDisabled(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.recordAttributes(Device{Name: "/dev/sda"}, map[string]float64{"current_pending_sector": 1})
+	if history := c.AttributeHistory(); len(history) != 0 {
+		t.Errorf("expected no history, got %v", history)
+	}
+}
@@ -0,0 +1,168 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The values of the disk label, see CollectorOptions.DeviceLabel
+const (
+	// DeviceLabelKernel labels the devices with their kernel name, e.g. /dev/sda
+	DeviceLabelKernel = "kernel"
+	// DeviceLabelByID labels the devices with their /dev/disk/by-id link
+	DeviceLabelByID = "by-id"
+	// DeviceLabelWWN labels the devices with their World Wide Name
+	DeviceLabelWWN = "wwn"
+	// DeviceLabelSerial labels the devices with their serial number
+	DeviceLabelSerial = "serial"
+	// DeviceLabelStable labels the devices with the first identifier known
+	// of the WWN, the /dev/disk/by-id link and the serial number
+	DeviceLabelStable = "stable"
+)
+
+// DeviceLabels lists the values of CollectorOptions.DeviceLabel
+var DeviceLabels = []string{DeviceLabelKernel, DeviceLabelByID, DeviceLabelWWN, DeviceLabelSerial, DeviceLabelStable}
+
+var smartMonKernelNameDesc = prometheus.NewDesc("smartmon_device_kernel_name_info", "current kernel name of the device when the disk label is a stable identifier, which may change across reboots", []string{"disk", "type", "by_id", "wwn", "serial", "kernel_name"}, noConstLabels)
+
+// devDiskByID holds the links named after the model and serial number
+// and the WWN of the devices
+var devDiskByID = "/dev/disk/by-id"
+
+// Identity are the identifiers of a device which are stable across reboots,
+// unlike the kernel name.  The identifiers are empty if they are unknown.
+type Identity struct {
+	// ByID is the path of the /dev/disk/by-id link named after the model
+	// and serial number, e.g. /dev/disk/by-id/ata-ST4000DM000-1F2168_Z302SXYZ
+	ByID string
+	// WWN is the World Wide Name of the device, e.g. 0x5000c500a1b2c3d4
+	WWN string
+	// Serial is the serial number of the device
+	Serial string
+}
+
+// ResolveIdentity finds the stable identifiers of the device in
+// /dev/disk/by-id and sysfs, without sending any command to the device
+func (d *Device) ResolveIdentity() Identity {
+	identity := Identity{}
+	target, err := filepath.EvalSymlinks(d.Name)
+	if err != nil {
+		target = d.Name
+	}
+	links, _ := filepath.Glob(filepath.Join(devDiskByID, "*"))
+	sort.Strings(links)
+	for _, link := range links {
+		base := filepath.Base(link)
+		if strings.Contains(base, "-part") {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(link); err != nil || resolved != target {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(base, "wwn-"):
+			if identity.WWN == "" {
+				identity.WWN = strings.TrimPrefix(base, "wwn-")
+			}
+		case strings.HasPrefix(base, "nvme-eui.") || strings.HasPrefix(base, "nvme-nvme."):
+			// the EUI-64 and NGUID links of NVMe namespaces
+		default:
+			if identity.ByID == "" {
+				identity.ByID = link
+			}
+		}
+	}
+	identity.Serial = sysfsSerial(filepath.Base(target))
+	return identity
+}
+
+// sysfsSerial reads the serial number of the block device or NVMe
+// controller reported by sysfs
+func sysfsSerial(name string) string {
+	for _, path := range []string{
+		filepath.Join(sysClassNVMe, name, "serial"),
+		filepath.Join(sysBlock, name, "device", "serial"),
+	} {
+		if serial, err := ioutil.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(serial))
+		}
+	}
+	// the SCSI disks report the serial number in the Unit Serial Number VPD page
+	if page, err := ioutil.ReadFile(filepath.Join(sysBlock, name, "device", "vpd_pg80")); err == nil && len(page) > 4 {
+		return strings.TrimSpace(string(page[4:]))
+	}
+	return ""
+}
+
+// label returns the value of the disk label of the device, the kernel name
+// if the identifier selected by deviceLabel is unknown
+func (i Identity) label(d Device, deviceLabel string) string {
+	switch {
+	case deviceLabel == DeviceLabelByID && i.ByID != "":
+		return i.ByID
+	case deviceLabel == DeviceLabelWWN && i.WWN != "":
+		return i.WWN
+	case deviceLabel == DeviceLabelSerial && i.Serial != "":
+		return sanitizeValue(i.Serial)
+	case deviceLabel == DeviceLabelStable:
+		for _, id := range []string{i.WWN, i.ByID, sanitizeValue(i.Serial)} {
+			if id != "" {
+				return id
+			}
+		}
+	}
+	return d.Name
+}
+
+// collectKernelName reports the kernel name of the device if the disk label
+// is another identifier
+func (c *Collector) collectKernelName(ch chan<- prometheus.Metric, d Device) {
+	labels := c.labelValues(d)
+	if labels[0] == d.Name {
+		return
+	}
+	c.constMetric(ch, smartMonKernelNameDesc, prometheus.GaugeValue, 1.0, append(labels, d.Name)...)
+}
+
+// followRename moves the state kept under another kernel name with the
+// same disk label to the device, e.g. when /dev/sdb came back as /dev/sdc
+// after a reboot, so its counters continue under the stable disk label.
+// The state kept under the name of the device moves to the other name, as
+// the disks often swap names.  Requires c.mtx.
+func (c *Collector) followRename(d Device, labels []string) {
+	if labels[0] == d.Name {
+		return
+	}
+	key := d.Key()
+	current := c.devices[key]
+	if current != nil && current.labels != nil && current.labels[0] == labels[0] {
+		return
+	}
+	for name, st := range c.devices {
+		if name == key || st.labels == nil || st.labels[0] != labels[0] {
+			continue
+		}
+		c.devices[key] = st
+		if current != nil {
+			c.devices[name] = current
+		} else {
+			delete(c.devices, name)
+		}
+		return
+	}
+}
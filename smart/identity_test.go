@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "by-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	device := filepath.Join(dir, "smartmon-test-disk")
+	if err := ioutil.WriteFile(device, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	byID := filepath.Join(dir, "by-id")
+	if err := os.Mkdir(byID, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"ata-ST4000DM000-1F2168_Z302SXYZ", "wwn-0x5000c500a1b2c3d4", "ata-ST4000DM000-1F2168_Z302SXYZ-part1"} {
+		if err := os.Symlink(device, filepath.Join(byID, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(path string) { devDiskByID = path }(devDiskByID)
+	devDiskByID = byID
+
+	d := Device{Name: device, Type: "sat"}
+	identity := d.ResolveIdentity()
+	if identity.ByID != filepath.Join(byID, "ata-ST4000DM000-1F2168_Z302SXYZ") || identity.WWN != "0x5000c500a1b2c3d4" {
+		t.Fatal("unexpected identity", identity)
+	}
+	if identity.label(d, DeviceLabelWWN) != "0x5000c500a1b2c3d4" || identity.label(d, DeviceLabelKernel) != device {
+		t.Fatal("unexpected disk label")
+	}
+	if (Identity{}).label(d, DeviceLabelByID) != device {
+		t.Fatal("expected the kernel name if the by-id link is unknown")
+	}
+	if identity.label(d, DeviceLabelStable) != "0x5000c500a1b2c3d4" || (Identity{Serial: "Z302SXYZ"}).label(d, DeviceLabelStable) != "Z302SXYZ" {
+		t.Fatal("unexpected stable disk label")
+	}
+}
+
+func TestFollowRename(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{DeviceLabel: DeviceLabelStable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.mtx.Lock()
+	c.state("/dev/sdb").labels = []string{"0x5000c500a1b2c3d4", "sat", "", "0x5000c500a1b2c3d4", ""}
+	c.state("/dev/sdb").woken = 2
+	c.state("/dev/sdc").labels = []string{"0x5000c500a1b2c3d5", "sat", "", "0x5000c500a1b2c3d5", ""}
+	c.state("/dev/sdc").woken = 5
+
+	// the disks swapped their names
+	c.followRename(Device{Name: "/dev/sdc"}, []string{"0x5000c500a1b2c3d4", "sat", "", "0x5000c500a1b2c3d4", ""})
+	if c.devices["/dev/sdc"].woken != 2 || c.devices["/dev/sdb"].woken != 5 {
+		t.Fatal("expected the state to follow the disks", c.devices["/dev/sdc"].woken, c.devices["/dev/sdb"].woken)
+	}
+	c.mtx.Unlock()
+}
@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	smartMonInvocationsDesc = prometheus.NewDesc("smartmon_smartctl_invocations_total", "number of smartctl commands executed", noLabels, noConstLabels)
+	smartMonFailuresDesc    = prometheus.NewDesc("smartmon_smartctl_invocation_failures_total", "number of smartctl commands executed which failed, e.g. could not open the device", noLabels, noConstLabels)
+	smartMonCPUDesc         = prometheus.NewDesc("smartmon_smartctl_cpu_seconds_total", "CPU time of the smartctl commands executed, including sudo or the helper and their children", []string{"mode"}, noConstLabels)
+)
+
+// The counters of the smartctl commands executed, the CPU times are in
+// nanoseconds
+var (
+	smartctlInvocations uint64
+	smartctlFailures    uint64
+	smartctlUserTime    uint64
+	smartctlSystemTime  uint64
+)
+
+// recordInvocation counts a smartctl command executed and its CPU time,
+// reported by the operating system when the command was waited for.  The
+// state is nil if the command could not be started.
+func recordInvocation(state *os.ProcessState, err error) {
+	atomic.AddUint64(&smartctlInvocations, 1)
+	if err != nil {
+		atomic.AddUint64(&smartctlFailures, 1)
+	}
+	if state == nil {
+		return
+	}
+	atomic.AddUint64(&smartctlUserTime, uint64(state.UserTime()))
+	atomic.AddUint64(&smartctlSystemTime, uint64(state.SystemTime()))
+}
+
+// collectInvocations exports the number of smartctl commands executed and
+// their CPU time, to quantify the overhead of the monitoring
+func (c *Collector) collectInvocations(ch chan<- prometheus.Metric) {
+	c.constMetric(ch, smartMonInvocationsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&smartctlInvocations)))
+	c.constMetric(ch, smartMonFailuresDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&smartctlFailures)))
+	c.constMetric(ch, smartMonCPUDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&smartctlUserTime))/1e9, "user")
+	c.constMetric(ch, smartMonCPUDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&smartctlSystemTime))/1e9, "system")
+}
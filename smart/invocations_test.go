@@ -0,0 +1,47 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInvocations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	invocations := atomic.LoadUint64(&smartctlInvocations)
+	failures := atomic.LoadUint64(&smartctlFailures)
+	ok := &Options{SmartctlPath: fakeSmartctl(t, dir, "Device is in ACTIVE or IDLE mode\n", 0)}
+	if _, _, err := smartCtl(context.Background(), ok, "-n", "standby", "/dev/sda"); err != nil {
+		t.Fatal(err)
+	}
+	failing := &Options{SmartctlPath: fakeSmartctl(t, dir, "Smartctl open device: /dev/sda failed: No such device\n", 2)}
+	if _, _, err := smartCtl(context.Background(), failing, "-n", "standby", "/dev/sda"); err == nil {
+		t.Fatal("expected the device open to fail")
+	}
+	missing := &Options{SmartctlPath: dir + "/missing"}
+	smartCtl(context.Background(), missing, "-V")
+	if count := atomic.LoadUint64(&smartctlInvocations) - invocations; count != 3 {
+		t.Error("expected 3 invocations, found", count)
+	}
+	if count := atomic.LoadUint64(&smartctlFailures) - failures; count != 2 {
+		t.Error("expected 2 failures, found", count)
+	}
+}
@@ -0,0 +1,56 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var smartMonJSONFallbacksDesc = prometheus.NewDesc("smartmon_smartctl_json_fallbacks_total", "number of smartctl commands whose JSON output failed and which were run again with the text output", []string{"command"}, noConstLabels)
+
+// jsonFallbacks counts the commands run again with the text output after
+// their JSON output failed, by command
+var jsonFallbacks = map[string]*uint64{
+	"scan":       new(uint64),
+	"info":       new(uint64),
+	"attributes": new(uint64),
+	"selftest":   new(uint64),
+}
+
+// fallback returns true if a command whose JSON output failed should be run
+// again with the text output, e.g. as some 7.0 builds output broken JSON
+// for SAS devices, and counts the fallback
+func fallback(ctx context.Context, command string, d *Device, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	name := ""
+	if d != nil {
+		name = d.Name
+	}
+	log.Infoln("Falling back to the text output of smartctl for", command, name+":", err)
+	atomic.AddUint64(jsonFallbacks[command], 1)
+	return true
+}
+
+// collectJSONFallbacks exports the number of commands run again with the
+// text output
+func (c *Collector) collectJSONFallbacks(ch chan<- prometheus.Metric) {
+	for command, count := range jsonFallbacks {
+		c.constMetric(ch, smartMonJSONFallbacksDesc, prometheus.CounterValue, float64(atomic.LoadUint64(count)), command)
+	}
+}
@@ -0,0 +1,57 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJSONFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// smartctl outputs JSON but breaks it for the device
+	script := "#!/bin/sh\ncase \"$*\" in\n" +
+		"\"-j -V\") echo '{\"smartctl\": {\"version\": [7, 0]}}' ;;\n" +
+		"-j*) echo '{\"smartctl\": {\"version\": [7, 0]}, \"model_name\": ' ;;\n" +
+		"-V) echo 'smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7] (local build)' ;;\n" +
+		"*) echo 'Device Model:     ST4000DM000-1F2168' ;;\n" +
+		"esac\n"
+	path := filepath.Join(dir, "smartctl")
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	before := atomic.LoadUint64(jsonFallbacks["info"])
+	b, err := NewBackend(&Options{SmartctlPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := b.Info(context.Background(), &Device{Name: "/dev/sda", Type: "sat"})
+	if err != nil {
+		t.Fatal("expected the text output to be parsed", err)
+	}
+	if info.Attributes["device_model"] != "ST4000DM000-1F2168" {
+		t.Fatal("unexpected info", info.Attributes)
+	}
+	if fallbacks := atomic.LoadUint64(jsonFallbacks["info"]) - before; fallbacks != 1 {
+		t.Fatal("expected 1 fallback, found", fallbacks)
+	}
+}
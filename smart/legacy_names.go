@@ -0,0 +1,54 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// The metrics renamed after their base unit, collected along the new names
+// with CollectorOptions.LegacyMetricNames to migrate dashboards and alerts
+var (
+	legacySelfTestProgressDesc = prometheus.NewDesc("smartmon_self_test_progress_percent", "deprecated, see smartmon_self_test_progress_ratio: percentage completed of the self-test in progress, 100 if none is in progress", deviceLabelNames, noConstLabels)
+	legacySelfTestPollingDesc  = prometheus.NewDesc("smartmon_self_test_polling_minutes", "deprecated, see smartmon_self_test_polling_seconds: recommended polling time of the self-test", []string{"disk", "type", "by_id", "wwn", "serial", "test"}, noConstLabels)
+	legacySelfTestHoursDesc    = prometheus.NewDesc("smartmon_device_self_test_lifetime_hours", "deprecated, see smartmon_device_self_test_lifetime_seconds: power on hours of the device when its most recent completed self-test ran", deviceLabelNames, noConstLabels)
+)
+
+// nvmeCounterUnits are the NVMe counters converted to a base unit, by
+// normalized name: the name of the metric, smartmon_nvme_<name>_total, and
+// the factor converting the reported value.  The data units are thousands
+// of 512 bytes.
+var nvmeCounterUnits = map[string]struct {
+	name  string
+	scale float64
+}{
+	"data_units_read":      {"data_read_bytes", 512000},
+	"data_units_written":   {"data_written_bytes", 512000},
+	"controller_busy_time": {"controller_busy_time_seconds", 60},
+	"power_on_hours":       {"power_on_seconds", 3600},
+}
+
+// nvmeCounter returns the name and the value of the metric of an NVMe
+// counter, converted to its base unit
+func nvmeCounter(name string, value float64) (string, float64) {
+	if unit, found := nvmeCounterUnits[name]; found {
+		return "smartmon_nvme_" + unit.name + "_total", value * unit.scale
+	}
+	return "smartmon_nvme_" + name + "_total", value
+}
+
+// legacyMetric collects a metric under its name before the conversion to
+// base units, if CollectorOptions.LegacyMetricNames is set
+func (c *Collector) legacyMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	if c.collectorOpts.LegacyMetricNames {
+		c.constMetric(ch, desc, valueType, value, labelValues...)
+	}
+}
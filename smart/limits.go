@@ -0,0 +1,74 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// The cardinality limits counted by smartmon_series_limited_total
+const (
+	limitLabelsPerMetric = "labels_per_metric"
+	limitSeriesPerDevice = "series_per_device"
+)
+
+var smartMonSeriesLimitedDesc = prometheus.NewDesc("smartmon_series_limited_total", "number of series truncated or dropped by the cardinality limits, by limit", []string{"limit"}, noConstLabels)
+
+// limitLabels returns the labels identifying the device and the other
+// labels in alphabetical order, up to max labels
+func limitLabels(labels prometheus.Labels, max int) prometheus.Labels {
+	limited := prometheus.Labels{}
+	for _, name := range deviceLabelNames {
+		if value, found := labels[name]; found {
+			limited[name] = value
+		}
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if _, found := limited[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(limited) >= max {
+			break
+		}
+		limited[name] = labels[name]
+	}
+	return limited
+}
+
+// limitSeries sends the metrics collected from the device up to the limit
+// of series per device and counts the dropped series
+func (c *Collector) limitSeries(ch chan<- prometheus.Metric, d Device, metrics []prometheus.Metric) {
+	max := c.collectorOpts.MaxSeriesPerDevice
+	if len(metrics) > max {
+		log.Warnln("Dropping", len(metrics)-max, "series of", d.Name, "beyond the limit of", max, "series per device")
+		atomic.AddUint64(&c.seriesLimited, uint64(len(metrics)-max))
+		metrics = metrics[:max]
+	}
+	for _, m := range metrics {
+		ch <- m
+	}
+}
+
+// collectLimited reports the number of series limited by every limit
+func (c *Collector) collectLimited(ch chan<- prometheus.Metric) {
+	c.constMetric(ch, smartMonSeriesLimitedDesc, prometheus.CounterValue, float64(c.descs.limitedCount()), limitLabelsPerMetric)
+	c.constMetric(ch, smartMonSeriesLimitedDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.seriesLimited)), limitSeriesPerDevice)
+}
@@ -0,0 +1,57 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLimitLabels(t *testing.T) {
+	labels := prometheus.Labels{"disk": "/dev/nvme0", "type": "nvme", "critical_warning": "0x00", "available_spare": "100%", "temperature": "36 Celsius"}
+	limited := limitLabels(labels, 3)
+	if len(limited) != 3 || limited["disk"] != "/dev/nvme0" || limited["type"] != "nvme" || limited["available_spare"] != "100%" {
+		t.Fatal("unexpected labels", limited)
+	}
+
+	c := descCache{maxLabels: 3}
+	c.get("smartmon_attributes", labels)
+	c.get("smartmon_attributes", labels)
+	c.get("smartmon_device_smart_healthy", prometheus.Labels{"disk": "/dev/nvme0", "type": "nvme"})
+	if c.limitedCount() != 2 {
+		t.Fatal("expected 2 limited descriptors, found", c.limitedCount())
+	}
+}
+
+func TestLimitSeries(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{MaxSeriesPerDevice: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+	metrics := record(func(ch chan<- prometheus.Metric) {
+		for i := 0; i < 3; i++ {
+			c.constMetric(ch, smartMonActiveDesc, prometheus.GaugeValue, 1.0, c.labelValues(d)...)
+		}
+	})
+	ch := make(chan prometheus.Metric, 3)
+	c.limitSeries(ch, d, metrics)
+	if len(ch) != 2 {
+		t.Fatal("expected 2 series, found", len(ch))
+	}
+	if c.seriesLimited != 1 {
+		t.Fatal("expected 1 series to be limited, found", c.seriesLimited)
+	}
+}
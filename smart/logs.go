@@ -0,0 +1,73 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// smartctlErrorLogOpts reads the error log of the device
+var smartctlErrorLogOpts = []string{"-l", "error"}
+
+// The metrics of the self-test and error logs of the device, collected
+// only if enabled as reading the logs takes longer than the attributes
+var (
+	smartMonSelfTestPassedDesc = prometheus.NewDesc("smartmon_device_self_test_passed", "1 if the most recent completed self-test of the device passed, reported by smartctl -l selftest", []string{"disk", "type", "by_id", "wwn", "serial", "test"}, noConstLabels)
+	smartMonSelfTestHoursDesc  = newDesc("smartmon_device_self_test_lifetime_seconds", deviceLabelNames)
+	smartMonErrorLogDesc       = prometheus.NewDesc("smartmon_device_error_log_count", "number of errors logged by the device, reported by smartctl -l error", deviceLabelNames, noConstLabels)
+)
+
+// errorLogCount gets the number of errors in the error log reported by
+// 'smartctl -l error'
+func (d *Device) errorLogCount(ctx context.Context, o *Options) (int, error) {
+	opts := append(smartctlErrorLogOpts, "-d", d.Type, d.Name)
+	output, _, err := smartCtl(ctx, o, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return parser.ParseErrorLogCount(output)
+}
+
+// collectSelfTests collects the result of the most recent self-test of the
+// device which is not in progress, nothing if the log is empty
+func (c *Collector) collectSelfTests(ctx context.Context, ch chan<- prometheus.Metric, d Device) error {
+	tests, err := d.SelfTests(ctx, c.deviceOpts(d))
+	if err != nil {
+		return err
+	}
+	for _, test := range tests {
+		if strings.Contains(test.Status, "in progress") {
+			continue
+		}
+		c.constMetric(ch, smartMonSelfTestPassedDesc, prometheus.GaugeValue, boolToMetric(test.Passed), append(c.labelValues(d), test.Description)...)
+		c.constMetric(ch, smartMonSelfTestHoursDesc, prometheus.GaugeValue, float64(test.LifetimeHours)*3600, c.labelValues(d)...)
+		c.legacyMetric(ch, legacySelfTestHoursDesc, prometheus.GaugeValue, float64(test.LifetimeHours), c.labelValues(d)...)
+		c.recordSelfTest(d, test)
+		break
+	}
+	return nil
+}
+
+// collectErrorLog collects the number of errors logged by the device
+func (c *Collector) collectErrorLog(ctx context.Context, ch chan<- prometheus.Metric, d Device) error {
+	count, err := d.errorLogCount(ctx, c.deviceOpts(d))
+	if err != nil {
+		return err
+	}
+	c.constMetric(ch, smartMonErrorLogDesc, prometheus.GaugeValue, float64(count), c.labelValues(d)...)
+	return nil
+}
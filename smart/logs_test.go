@@ -0,0 +1,39 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestErrorLogCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// smartctl sets the error log bit of the exit status if errors are logged
+	path := fakeSmartctl(t, dir, "SMART Error Log Version: 1\nATA Error Count: 3\n", int(ExitErrorLog))
+	d := &Device{Name: "/dev/sda", Type: "sat"}
+	count, err := d.errorLogCount(context.Background(), &Options{SmartctlPath: path})
+	if err != nil {
+		t.Fatal("unable to read the error log", err)
+	}
+	if count != 3 {
+		t.Fatal("expected 3 errors, found", count)
+	}
+}
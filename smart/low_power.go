@@ -0,0 +1,103 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sysClassPowerSupply lists the power supplies of the system, e.g. the AC
+// adapter and the battery of a laptop or the UPS of a NAS
+var sysClassPowerSupply = "/sys/class/power_supply"
+
+// defaultStandbyCheckInterval is the default interval of the power mode
+// checks of the devices in standby in low power mode
+const defaultStandbyCheckInterval = time.Hour
+
+var smartMonOnBatteryDesc = prometheus.NewDesc("smartmon_exporter_on_battery", "1 if the system runs on battery and the collection of the devices is paused", noLabels, noConstLabels)
+
+// standbyCheckInterval returns the interval of the power mode checks of
+// the devices in standby in low power mode
+func (o *CollectorOptions) standbyCheckInterval() time.Duration {
+	if o.StandbyCheckInterval > 0 {
+		return o.StandbyCheckInterval
+	}
+	return defaultStandbyCheckInterval
+}
+
+// onBattery returns true if the system runs on battery: a battery is
+// discharging and no mains power supply is online.  Systems without power
+// supplies in sysfs never run on battery.
+func onBattery() bool {
+	supplies, err := ioutil.ReadDir(sysClassPowerSupply)
+	if err != nil {
+		return false
+	}
+	discharging := false
+	for _, supply := range supplies {
+		dir := filepath.Join(sysClassPowerSupply, supply.Name())
+		switch readSysfs(filepath.Join(dir, "type")) {
+		case "Mains", "USB":
+			if readSysfs(filepath.Join(dir, "online")) == "1" {
+				return false
+			}
+		case "Battery", "UPS":
+			if readSysfs(filepath.Join(dir, "status")) == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
+
+// paused returns true if the collection of the devices is paused because
+// the system runs on battery
+func (c *Collector) paused() bool {
+	return c.collectorOpts.PauseOnBattery && onBattery()
+}
+
+// collectOnBattery reports if the collection of the devices is paused
+func (c *Collector) collectOnBattery(ch chan<- prometheus.Metric) {
+	if c.collectorOpts.PauseOnBattery {
+		c.constMetric(ch, smartMonOnBatteryDesc, prometheus.GaugeValue, boolToMetric(onBattery()))
+	}
+}
+
+// powerMode gets the power mode of the device.  In low power mode a device
+// last found in standby or sleep is not checked again before the standby
+// check interval elapsed, its last mode is returned instead.
+func (c *Collector) powerMode(ctx context.Context, d Device) (PowerMode, error) {
+	now := time.Now()
+	if c.collectorOpts.LowPower {
+		c.mtx.Lock()
+		st := c.state(d.Key())
+		mode := st.mode
+		recent := now.Sub(st.modeChecked) < c.collectorOpts.standbyCheckInterval()
+		c.mtx.Unlock()
+		if (mode == parser.PowerModeStandby || mode == parser.PowerModeSleep) && recent {
+			return mode, nil
+		}
+	}
+	mode, err := d.PowerMode(ctx, c.deviceOpts(d))
+	c.recordStandbyCommand(d, mode)
+	c.mtx.Lock()
+	c.state(d.Key()).modeChecked = now
+	c.mtx.Unlock()
+	return mode, err
+}
@@ -0,0 +1,115 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOnBattery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "power_supply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysClassPowerSupply = path }(sysClassPowerSupply)
+	sysClassPowerSupply = dir
+
+	supply := func(name string, attrs map[string]string) {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for attr, value := range attrs {
+			if err := ioutil.WriteFile(filepath.Join(dir, name, attr), []byte(value+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if onBattery() {
+		t.Fatal("a system without power supplies should not run on battery")
+	}
+	supply("BAT0", map[string]string{"type": "Battery", "status": "Discharging"})
+	if !onBattery() {
+		t.Fatal("expected to run on a discharging battery")
+	}
+	supply("AC", map[string]string{"type": "Mains", "online": "0"})
+	if !onBattery() {
+		t.Fatal("expected to run on battery while the AC adapter is offline")
+	}
+	supply("AC", map[string]string{"online": "1"})
+	if onBattery() {
+		t.Fatal("expected to run on AC power")
+	}
+}
+
+func TestLowPower(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// every invocation is logged, the device is always active
+	invocations := filepath.Join(dir, "invocations")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + invocations + "\necho 'Power mode is:    ACTIVE or IDLE'\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, &CollectorOptions{LowPower: true, CollectStandby: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+	ch := make(chan prometheus.Metric, 100)
+	c.collectDevice(context.Background(), ch, d)
+	content, err := ioutil.ReadFile(invocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the info and the attributes are read by a single command
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "-i -H -A") {
+		t.Fatal("expected the power mode and a batched command, found", lines)
+	}
+
+	// a device found in standby is not checked again before the interval
+	// elapsed, nor woken up whatever CollectStandby
+	c.mtx.Lock()
+	c.state(d.Key()).mode = parser.PowerModeStandby
+	c.mtx.Unlock()
+	c.collectDevice(context.Background(), ch, d)
+	content, err = ioutil.ReadFile(invocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strings.Split(strings.TrimSpace(string(content)), "\n")) != 2 {
+		t.Fatal("expected no command for the device in standby, found", string(content))
+	}
+	c.mtx.Lock()
+	c.state(d.Key()).modeChecked = time.Now().Add(-2 * time.Hour)
+	c.mtx.Unlock()
+	if mode, err := c.powerMode(context.Background(), d); err != nil || mode != parser.PowerModeActive {
+		t.Fatal("expected the power mode to be checked again", mode, err)
+	}
+}
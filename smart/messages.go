@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"sync"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// Message is a message printed by smartctl, included with its JSON output
+type Message = parser.Message
+
+// The severities of the messages printed by smartctl
+const (
+	MessageInformation = "information"
+	MessageWarning     = "warning"
+	MessageError       = "error"
+)
+
+var smartMonMessagesDesc = prometheus.NewDesc("smartmon_smartctl_messages_total", "number of messages printed by smartctl in its JSON output, e.g. a failure to read the SMART data", []string{"severity"}, noConstLabels)
+
+var (
+	// messagesMtx protects messages
+	messagesMtx sync.Mutex
+	// messages counts the messages printed by smartctl by severity
+	messages = map[string]uint64{}
+)
+
+// recordMessages counts and logs the messages included with the JSON output
+// of smartctl for the device, which is nil for the scan.  smartctl reports
+// partial failures, e.g. a log which could not be read, only through these
+// messages.
+func recordMessages(d *Device, output []byte) {
+	parsed := parser.ParseMessagesJSON(output)
+	if len(parsed) == 0 {
+		return
+	}
+	name := "scan"
+	if d != nil {
+		name = d.Name
+	}
+	messagesMtx.Lock()
+	defer messagesMtx.Unlock()
+	for _, m := range parsed {
+		severity := m.Severity
+		if severity == "" {
+			severity = MessageInformation
+		}
+		messages[severity]++
+		switch severity {
+		case MessageError:
+			log.Errorln("smartctl", name+":", m.String)
+		case MessageWarning:
+			log.Warnln("smartctl", name+":", m.String)
+		default:
+			log.Debugln("smartctl", name+":", m.String)
+		}
+	}
+}
+
+// collectMessages exports the number of messages printed by smartctl.  The
+// known severities are always exported so that increases from zero are seen.
+func (c *Collector) collectMessages(ch chan<- prometheus.Metric) {
+	messagesMtx.Lock()
+	counts := map[string]uint64{MessageInformation: 0, MessageWarning: 0, MessageError: 0}
+	for severity, count := range messages {
+		counts[severity] = count
+	}
+	messagesMtx.Unlock()
+	for severity, count := range counts {
+		c.constMetric(ch, smartMonMessagesDesc, prometheus.CounterValue, float64(count), sanitizeValue(severity))
+	}
+}
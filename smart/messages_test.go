@@ -0,0 +1,39 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import "testing"
+
+func TestRecordMessages(t *testing.T) {
+	messagesMtx.Lock()
+	before := messages[MessageError]
+	messagesMtx.Unlock()
+
+	recordMessages(&Device{Name: "/dev/sda"}, []byte(`{
+  "smartctl": {
+    "version": [7, 1],
+    "messages": [
+      {"string": "Read SMART Data failed", "severity": "error"},
+      {"string": "Read SMART Log Directory failed", "severity": "error"}
+    ],
+    "exit_status": 4
+  }
+}`))
+	recordMessages(&Device{Name: "/dev/sda"}, []byte("not json"))
+
+	messagesMtx.Lock()
+	defer messagesMtx.Unlock()
+	if messages[MessageError]-before != 2 {
+		t.Fatal("unexpected number of error messages", messages[MessageError]-before)
+	}
+}
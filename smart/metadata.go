@@ -0,0 +1,129 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// baseUnits are the units of the metric values, which the metric names end
+// with, before _total for the counters, and how the help states them.  The
+// values are converted to these units, e.g. to seconds rather than hours.
+var baseUnits = map[string]string{
+	"celsius": "in degrees Celsius",
+	"bytes":   "in bytes",
+	"seconds": "in seconds",
+	"ratio":   "from 0 to 1",
+}
+
+// metadata is the help of a metric family and the base unit of its values,
+// empty if the values have no unit
+type metadata struct {
+	help string
+	unit string
+}
+
+// metricMetadata is the metadata of the metric families whose descriptors
+// are created while collecting, or shared by the collectors, by name
+var metricMetadata = map[string]metadata{
+	"smartmon_device_info":            {"information about the device reported by smartctl -i as labels, always 1", ""},
+	"smartmon_device_smart_available": {"1 if the device supports SMART", ""},
+	"smartmon_device_smart_enabled":   {"1 if SMART is enabled on the device", ""},
+	"smartmon_device_smart_healthy":   {"1 if the device passes the SMART overall-health self-assessment", ""},
+	"smartmon_attributes":             {"NVMe health information reported by smartctl -A as labels, always 1", ""},
+
+	"smartmon_self_test_progress_ratio":          {"part completed of the self-test in progress, 1 if none is in progress", "ratio"},
+	"smartmon_self_test_polling_seconds":         {"recommended polling time of the self-test", "seconds"},
+	"smartmon_device_self_test_lifetime_seconds": {"power on time of the device when its most recent completed self-test ran", "seconds"},
+
+	"smartmon_nvme_data_read_bytes_total":              {"data read from the NVMe device, counted by smartctl in units of 512000 bytes", "bytes"},
+	"smartmon_nvme_data_written_bytes_total":           {"data written to the NVMe device, counted by smartctl in units of 512000 bytes", "bytes"},
+	"smartmon_nvme_controller_busy_time_seconds_total": {"time the NVMe controller was busy with I/O commands, counted in minutes", "seconds"},
+	"smartmon_nvme_power_on_seconds_total":             {"power on time of the NVMe device, counted in hours", "seconds"},
+
+	"smartmon_smartd_attribute_value":                 {"normalized value of the attribute last logged by smartd", ""},
+	"smartmon_smartd_attribute_raw_value":             {"raw value of the attribute last logged by smartd", ""},
+	"smartmon_smartd_attribute_log_timestamp_seconds": {"unix time smartd last logged the attributes of the device", "seconds"},
+	"smartmon_smartd_attribute_log_error":             {"error encountered while reading a smartd attribute log", ""},
+	"smartmon_smartd_warnings_total":                  {"number of warnings issued by smartd", ""},
+	"smartmon_smartd_warnings_error":                  {"error encountered while reading the smartd warnings file", ""},
+	"smartmon_smartd_warnings_build_errors_total":     {"number of smartd warnings metrics which could not be built", ""},
+}
+
+// attributeMetadata is the metadata of the families of the ATA attribute
+// metrics, named smartmon_<attribute><suffix>, by suffix.  The help is a
+// format of the attribute name.
+var attributeMetadata = []struct {
+	suffix string
+	metadata
+}{
+	{"_raw_value", metadata{"raw value of the ATA attribute %s", ""}},
+	{"_interpreted_value", metadata{"value packed in the raw value of the ATA attribute %s by the raw_value_rules", ""}},
+	{"_operations", metadata{"total number of operations packed in the raw value of the ATA attribute %s by the raw_value_rules", ""}},
+	{"_error_ratio", metadata{"ratio of the errors to the operations packed in the raw value of the ATA attribute %s", "ratio"}},
+	{"_value", metadata{"normalized value of the ATA attribute %s, decreasing as it worsens", ""}},
+	{"_worst", metadata{"worst normalized value of the ATA attribute %s", ""}},
+	{"_threshold", metadata{"normalized value of the ATA attribute %s at or below which it fails", ""}},
+}
+
+// vendorLogPages are the NVMe vendor log pages whose attributes are metrics
+// named after the attribute, by metric prefix
+var vendorLogPages = map[string]string{
+	"smartmon_nvme_ocp_":   "OCP SMART / Health Information Extended log page",
+	"smartmon_nvme_intel_": "Intel vendor specific SMART log page",
+}
+
+// lookupMetadata returns the metadata of the metric family, and false if it
+// is not registered
+func lookupMetadata(name string) (metadata, bool) {
+	if m, found := metricMetadata[name]; found {
+		return m, true
+	}
+	for prefix, page := range vendorLogPages {
+		if strings.HasPrefix(name, prefix) {
+			return metadata{"attribute " + strings.TrimPrefix(name, prefix) + " of the " + page, ""}, true
+		}
+	}
+	if strings.HasPrefix(name, "smartmon_nvme_") && strings.HasSuffix(name, "_total") {
+		field := strings.TrimSuffix(strings.TrimPrefix(name, "smartmon_nvme_"), "_total")
+		return metadata{field + " reported in the NVMe SMART/Health Information log", ""}, true
+	}
+	for _, attribute := range attributeMetadata {
+		if strings.HasSuffix(name, attribute.suffix) {
+			attr := strings.TrimSuffix(strings.TrimPrefix(name, "smartmon_"), attribute.suffix)
+			return metadata{fmt.Sprintf(attribute.help, attr), attribute.unit}, true
+		}
+	}
+	return metadata{}, false
+}
+
+// metricHelp returns the help of the metric family, stating the unit of its
+// values, or the name of the family if it is not registered
+func metricHelp(name string) string {
+	m, found := lookupMetadata(name)
+	if !found {
+		return name
+	}
+	if m.unit != "" {
+		return m.help + ", " + baseUnits[m.unit]
+	}
+	return m.help
+}
+
+// newDesc creates the descriptor of a registered metric family
+func newDesc(name string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(name, metricHelp(name), labels, noConstLabels)
+}
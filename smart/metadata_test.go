@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricHelp(t *testing.T) {
+	for name, help := range map[string]string{
+		"smartmon_device_smart_healthy":                   "1 if the device passes the SMART overall-health self-assessment",
+		"smartmon_reallocated_sector_ct_raw_value":        "raw value of the ATA attribute reallocated_sector_ct",
+		"smartmon_reallocated_sector_ct_value":            "normalized value of the ATA attribute reallocated_sector_ct, decreasing as it worsens",
+		"smartmon_raw_read_error_rate_error_ratio":        "ratio of the errors to the operations packed in the raw value of the ATA attribute raw_read_error_rate, from 0 to 1",
+		"smartmon_nvme_data_units_read_total":             "data_units_read reported in the NVMe SMART/Health Information log",
+		"smartmon_nvme_intel_wear_leveling_count":         "attribute wear_leveling_count of the Intel vendor specific SMART log page",
+		"smartmon_smartd_attribute_log_timestamp_seconds": "unix time smartd last logged the attributes of the device, in seconds",
+		"smartmon_nvme_ocp_bad_user_nand_blocks":          "attribute bad_user_nand_blocks of the OCP SMART / Health Information Extended log page",
+		"smartmon_unknown":                                "smartmon_unknown",
+	} {
+		if actual := metricHelp(name); actual != help {
+			t.Errorf("expected help of %s %q, got %q", name, help, actual)
+		}
+	}
+}
+
+// TestMetricUnits checks the names of the registered families end with the
+// unit of their values, and only then
+func TestMetricUnits(t *testing.T) {
+	for name, m := range metricMetadata {
+		base := strings.TrimSuffix(name, "_total")
+		for unit := range baseUnits {
+			if strings.HasSuffix(base, "_"+unit) != (m.unit == unit) {
+				t.Errorf("expected %s to end with its unit %q", name, m.unit)
+			}
+		}
+	}
+	for _, attribute := range attributeMetadata {
+		if attribute.unit != "" && !strings.HasSuffix(attribute.suffix, "_"+attribute.unit) {
+			t.Errorf("expected %s to end with its unit %q", attribute.suffix, attribute.unit)
+		}
+	}
+}
+
+func TestNVMeCounter(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		raw      float64
+		expected string
+		value    float64
+	}{
+		{"data_units_read", 2, "smartmon_nvme_data_read_bytes_total", 1024000},
+		{"power_on_hours", 10, "smartmon_nvme_power_on_seconds_total", 36000},
+		{"unsafe_shutdowns", 3, "smartmon_nvme_unsafe_shutdowns_total", 3},
+	} {
+		if name, value := nvmeCounter(test.name, test.raw); name != test.expected || value != test.value {
+			t.Errorf("expected %s %v, got %s %v", test.expected, test.value, name, value)
+		}
+	}
+}
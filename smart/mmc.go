@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The types of the MMC devices
+const (
+	mmcTypeEMMC = "emmc"
+	mmcTypeSD   = "sd"
+)
+
+// sysClassMMCHost lists the MMC host controllers and their cards
+var sysClassMMCHost = "/sys/class/mmc_host"
+
+// The metrics of the eMMC and SD cards read from sysfs, smartctl does not
+// support these devices
+var (
+	smartMonMMCInfoDesc     = prometheus.NewDesc("smartmon_mmc_info", "identity of the eMMC or SD card reported by sysfs", []string{"disk", "type", "by_id", "wwn", "serial", "name", "manfid", "oemid", "date", "fwrev"}, noConstLabels)
+	smartMonMMCLifeTimeDesc = prometheus.NewDesc("smartmon_mmc_life_time_estimate", "device life time estimate of the eMMC by memory type, 1 for 0-10% used up to 10 for 90-100% used and 11 once exceeded", []string{"disk", "type", "by_id", "wwn", "serial", "estimate"}, noConstLabels)
+	smartMonMMCPreEOLDesc   = prometheus.NewDesc("smartmon_mmc_pre_eol_info", "pre end of life information of the eMMC based on the reserved blocks consumed, 1 normal, 2 warning and 3 urgent", deviceLabelNames, noConstLabels)
+)
+
+// mmcCard is an eMMC or SD card found in sysfs
+type mmcCard struct {
+	Device
+	// dir is the sysfs directory of the card, e.g.
+	// /sys/class/mmc_host/mmc0/mmc0:0001
+	dir string
+}
+
+// mmcCards returns the eMMC and SD cards with a block device.  The cards
+// are listed below their host controller, e.g.
+//
+//	/sys/class/mmc_host/mmc0/mmc0:0001/block/mmcblk0
+func mmcCards() []mmcCard {
+	dirs, _ := filepath.Glob(filepath.Join(sysClassMMCHost, "*", "*:*"))
+	cards := []mmcCard{}
+	for _, dir := range dirs {
+		var typ string
+		switch readSysfs(filepath.Join(dir, "type")) {
+		case "MMC":
+			typ = mmcTypeEMMC
+		case "SD":
+			typ = mmcTypeSD
+		default: // e.g. SDIO wifi cards
+			continue
+		}
+		blocks, _ := filepath.Glob(filepath.Join(dir, "block", "*"))
+		if len(blocks) == 0 {
+			continue
+		}
+		cards = append(cards, mmcCard{
+			Device: Device{Name: filepath.Join(devDir, filepath.Base(blocks[0])), Type: typ},
+			dir:    dir,
+		})
+	}
+	return cards
+}
+
+// parseMMCLevels parses the levels reported by the life_time and
+// pre_eol_info attributes, e.g. "0x01 0x02"
+func parseMMCLevels(value string) []float64 {
+	levels := []float64{}
+	for _, field := range strings.Fields(value) {
+		level, err := strconv.ParseUint(field, 0, 8)
+		if err != nil {
+			return nil
+		}
+		levels = append(levels, float64(level))
+	}
+	return levels
+}
+
+// collectMMC collects the health of the eMMC and SD cards reported by sysfs.
+// The life time estimates are reported by eMMC 5.0 and later for type A
+// and type B memory, e.g. SLC and MLC, and are missing for SD cards.
+func (c *Collector) collectMMC(ch chan<- prometheus.Metric) {
+	for _, card := range mmcCards() {
+		identity := card.ResolveIdentity()
+		if identity.Serial == "" {
+			identity.Serial = readSysfs(filepath.Join(card.dir, "serial"))
+		}
+		c.identitiesMtx.Lock()
+		c.identities[card.Name] = identity
+		c.identitiesMtx.Unlock()
+
+		labels := c.labelValues(card.Device)
+		info := []string{}
+		for _, attr := range []string{"name", "manfid", "oemid", "date", "fwrev"} {
+			info = append(info, sanitizeValue(readSysfs(filepath.Join(card.dir, attr))))
+		}
+		c.constMetric(ch, smartMonMMCInfoDesc, prometheus.GaugeValue, 1.0, append(labels, info...)...)
+
+		lifeTime := parseMMCLevels(readSysfs(filepath.Join(card.dir, "life_time")))
+		for i, estimate := range []string{"a", "b"} {
+			if i < len(lifeTime) {
+				c.constMetric(ch, smartMonMMCLifeTimeDesc, prometheus.GaugeValue, lifeTime[i], append(c.labelValues(card.Device), estimate)...)
+			}
+		}
+		if preEOL := parseMMCLevels(readSysfs(filepath.Join(card.dir, "pre_eol_info"))); len(preEOL) == 1 {
+			c.constMetric(ch, smartMonMMCPreEOLDesc, prometheus.GaugeValue, preEOL[0], labels...)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectMMC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysClassMMCHost = path }(sysClassMMCHost)
+	sysClassMMCHost = dir
+
+	for path, value := range map[string]string{
+		"mmc0/mmc0:0001/type":              "MMC",
+		"mmc0/mmc0:0001/name":              "DG4016",
+		"mmc0/mmc0:0001/serial":            "0x5d1e2f3a",
+		"mmc0/mmc0:0001/life_time":         "0x01 0x02",
+		"mmc0/mmc0:0001/pre_eol_info":      "0x01",
+		"mmc0/mmc0:0001/block/mmcblk0/dev": "179:0",
+		"mmc1/mmc1:0001/type":              "SDIO",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cards := mmcCards()
+	if len(cards) != 1 || cards[0].Name != "/dev/mmcblk0" || cards[0].Type != mmcTypeEMMC {
+		t.Fatal("unexpected cards", cards)
+	}
+
+	c, err := NewCollector(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ch := make(chan prometheus.Metric, 10)
+	c.collectMMC(ch)
+	// the info, the 2 life time estimates and the pre EOL info
+	if len(ch) != 4 {
+		t.Fatal("expected 4 metrics, found", len(ch))
+	}
+	if c.identity(cards[0].Device).Serial != "0x5d1e2f3a" {
+		t.Fatal("unexpected identity", c.identity(cards[0].Device))
+	}
+}
+
+func TestParseMMCLevels(t *testing.T) {
+	levels := parseMMCLevels("0x01 0x0b")
+	if len(levels) != 2 || levels[0] != 1 || levels[1] != 11 {
+		t.Fatal("unexpected levels", levels)
+	}
+	if levels := parseMMCLevels("invalid"); levels != nil {
+		t.Fatal("unexpected levels", levels)
+	}
+}
@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var smartMonPathsDesc = prometheus.NewDesc("smartmon_device_paths_info", "paths of a device reachable through several paths, e.g. multipath SAN or dual-port SAS, the device is collected once through the first path", []string{"disk", "type", "by_id", "wwn", "serial", "paths"}, noConstLabels)
+
+// wwid returns the World Wide Identifier sysfs reports for the SCSI device
+// of the disk, e.g. naa.5000c500a1b2c3d4, which is the same for every path
+// to the disk.  Empty if unknown.
+func (d *Device) wwid() string {
+	target, err := filepath.EvalSymlinks(d.Name)
+	if err != nil {
+		target = d.Name
+	}
+	return readSysfs(filepath.Join(sysBlock, filepath.Base(target), "device", "wwid"))
+}
+
+// dedupPaths keeps the first of the devices reporting the same WWID, i.e.
+// the paths to the same disk, so each disk is collected and woken up once.
+// The devices behind a RAID controller share the node of the controller and
+// its WWID, see Device.Key, so they are always kept.  Returns the paths of
+// the devices kept which have several, by key.
+func dedupPaths(devices []Device) ([]Device, map[string][]string) {
+	kept := make([]Device, 0, len(devices))
+	first := map[string]string{}
+	paths := map[string][]string{}
+	for _, d := range devices {
+		if d.Key() != d.Name {
+			kept = append(kept, d)
+			continue
+		}
+		wwid := d.wwid()
+		if wwid == "" {
+			kept = append(kept, d)
+			continue
+		}
+		key, found := first[wwid]
+		if !found {
+			first[wwid] = d.Key()
+			kept = append(kept, d)
+			continue
+		}
+		if len(paths[key]) == 0 {
+			paths[key] = []string{key}
+		}
+		paths[key] = append(paths[key], d.Name)
+	}
+	return kept, paths
+}
+
+// collectPaths reports the paths of the device if it has several
+func (c *Collector) collectPaths(ch chan<- prometheus.Metric, d Device) {
+	c.scans.mtx.Lock()
+	paths := c.scans.paths[d.Key()]
+	c.scans.mtx.Unlock()
+	if len(paths) == 0 {
+		return
+	}
+	labels := append(c.labelValues(d), strings.Join(paths, ","))
+	c.constMetric(ch, smartMonPathsDesc, prometheus.GaugeValue, 1.0, labels...)
+}
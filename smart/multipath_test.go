@@ -0,0 +1,55 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDedupPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multipath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysBlock = path }(sysBlock)
+	sysBlock = dir
+	for name, wwid := range map[string]string{"sda": "naa.5000c500a1b2c3d4", "sdb": "naa.5000c500a1b2c3d5", "sdc": "naa.5000c500a1b2c3d4"} {
+		os.MkdirAll(filepath.Join(dir, name, "device"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name, "device", "wwid"), []byte(wwid+"\n"), 0644)
+	}
+
+	devices, paths := dedupPaths([]Device{
+		{Name: "/dev/sda", Type: "scsi"},
+		{Name: "/dev/sdb", Type: "scsi"},
+		{Name: "/dev/sdc", Type: "scsi"},
+		{Name: "/dev/bus/0", Type: "megaraid,0"},
+		{Name: "/dev/bus/0", Type: "megaraid,1"},
+		// the disks behind a controller share its node and its WWID
+		{Name: "/dev/sda", Type: "megaraid,0"},
+		{Name: "/dev/sda", Type: "megaraid,1"},
+	})
+	keys := []string{}
+	for _, d := range devices {
+		keys = append(keys, d.Key())
+	}
+	if strings.Join(keys, " ") != "/dev/sda /dev/sdb /dev/bus/0:megaraid,0 /dev/bus/0:megaraid,1 /dev/sda:megaraid,0 /dev/sda:megaraid,1" {
+		t.Fatal("unexpected devices", keys)
+	}
+	if len(paths) != 1 || strings.Join(paths["/dev/sda"], ",") != "/dev/sda,/dev/sdc" {
+		t.Fatal("unexpected paths", paths)
+	}
+}
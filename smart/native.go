@@ -0,0 +1,171 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+)
+
+// BackendNative reads the NVMe and ATA devices with ioctls instead of
+// running smartctl, the other devices are read with smartctl
+const BackendNative = "native"
+
+var (
+	// sysClassNVMe lists the NVMe controllers
+	sysClassNVMe = "/sys/class/nvme"
+	// sysBlock lists the block devices, the ATA disks attached through
+	// libata report the "ATA" vendor
+	sysBlock = "/sys/block"
+)
+
+// nativeBackend reads the NVMe and ATA devices with ioctls, the other
+// devices and the self-test logs with the fallback backend
+type nativeBackend struct {
+	fallback Backend
+}
+
+// nativeNVMe returns true if the device is read by the native NVMe backend,
+// the NVMe drives behind a MegaRAID controller are not visible to the ioctl
+func (d *Device) nativeNVMe() bool {
+	return strings.HasPrefix(d.Type, "nvme") && !d.megaraid()
+}
+
+// nativeATA returns true if the device is read by the native ATA backend
+func (d *Device) nativeATA() bool {
+	return d.Type == "sat" || d.Type == "ata"
+}
+
+// Scan finds the NVMe controllers and the ATA disks in sysfs and the
+// other devices with smartctl.  The devices found in sysfs are returned
+// even if smartctl fails, so the exporter works without smartctl on hosts
+// with only NVMe and ATA devices.
+func (b *nativeBackend) Scan(ctx context.Context) ([]Device, error) {
+	devices := []Device{}
+	found := map[string]bool{}
+	controllers, _ := filepath.Glob(filepath.Join(sysClassNVMe, "nvme*"))
+	for _, controller := range controllers {
+		name := "/dev/" + filepath.Base(controller)
+		devices = append(devices, Device{Name: name, InfoName: name, Type: "nvme", Protocol: "NVMe"})
+		found[name] = true
+	}
+	disks, _ := filepath.Glob(filepath.Join(sysBlock, "sd*"))
+	for _, disk := range disks {
+		vendor, err := ioutil.ReadFile(filepath.Join(disk, "device", "vendor"))
+		if err != nil || strings.TrimSpace(string(vendor)) != "ATA" {
+			continue
+		}
+		name := "/dev/" + filepath.Base(disk)
+		devices = append(devices, Device{Name: name, InfoName: name, Type: "sat", Protocol: "ATA"})
+		found[name] = true
+	}
+	scanned, err := b.fallback.Scan(ctx)
+	if err != nil {
+		if len(devices) > 0 {
+			return devices, nil
+		}
+		return nil, err
+	}
+	for _, d := range scanned {
+		if !found[d.Name] && !d.nativeNVMe() {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+
+// PowerMode reads the power mode of ATA devices.  NVMe devices are always
+// reported as active, like smartctl does.
+func (b *nativeBackend) PowerMode(ctx context.Context, d *Device) (PowerMode, error) {
+	switch {
+	case d.nativeNVMe():
+		return PowerModeActive, nil
+	case d.nativeATA():
+		return readATAPowerMode(d.Name)
+	}
+	return b.fallback.PowerMode(ctx, d)
+}
+
+// Info reads the identity and health of the device
+func (b *nativeBackend) Info(ctx context.Context, d *Device) (*DeviceInfo, error) {
+	switch {
+	case d.nativeNVMe():
+		return d.infoNVMe()
+	case d.nativeATA():
+		return d.infoATA()
+	}
+	return b.fallback.Info(ctx, d)
+}
+
+// Attributes reads the SMART attributes of the device
+func (b *nativeBackend) Attributes(ctx context.Context, d *Device) ([]Attribute, error) {
+	switch {
+	case d.nativeNVMe():
+		return d.attributesNVMe()
+	case d.nativeATA():
+		return d.attributesATA()
+	}
+	return b.fallback.Attributes(ctx, d)
+}
+
+// SelfTests reads the self-test log with the fallback backend
+func (b *nativeBackend) SelfTests(ctx context.Context, d *Device) ([]SelfTest, error) {
+	return b.fallback.SelfTests(ctx, d)
+}
+
+// infoNVMe reads the identity and health of an NVMe controller
+func (d *Device) infoNVMe() (*DeviceInfo, error) {
+	identify, err := readNVMeIdentify(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	healthLog, err := readNVMeHealthLog(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseNVMeIdentify(identify, healthLog)
+}
+
+// attributesNVMe reads the health information of an NVMe controller
+func (d *Device) attributesNVMe() ([]Attribute, error) {
+	healthLog, err := readNVMeHealthLog(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseNVMeHealthLog(healthLog)
+}
+
+// infoATA reads the identity and health of an ATA device
+func (d *Device) infoATA() (*DeviceInfo, error) {
+	identify, err := readATAIdentify(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	healthy, err := readATAHealthy(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseATAIdentify(identify, healthy)
+}
+
+// attributesATA reads the SMART attributes of an ATA device
+func (d *Device) attributesATA() ([]Attribute, error) {
+	data, thresholds, err := readATASmartData(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseATASmartData(data, thresholds)
+}
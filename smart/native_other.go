@@ -0,0 +1,50 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build !linux
+// +build !linux
+
+package smart
+
+import "errors"
+
+// errNativeUnsupported is returned by the native backend on other platforms
+var errNativeUnsupported = errors.New("the native backend is only supported on Linux")
+
+// readNVMeHealthLog is only implemented on Linux
+func readNVMeHealthLog(name string) ([]byte, error) {
+	return nil, errNativeUnsupported
+}
+
+// readNVMeIdentify is only implemented on Linux
+func readNVMeIdentify(name string) ([]byte, error) {
+	return nil, errNativeUnsupported
+}
+
+// readATASmartData is only implemented on Linux
+func readATASmartData(name string) ([]byte, []byte, error) {
+	return nil, nil, errNativeUnsupported
+}
+
+// readATAIdentify is only implemented on Linux
+func readATAIdentify(name string) ([]byte, error) {
+	return nil, errNativeUnsupported
+}
+
+// readATAHealthy is only implemented on Linux
+func readATAHealthy(name string) (bool, error) {
+	return false, errNativeUnsupported
+}
+
+// readATAPowerMode is only implemented on Linux
+func readATAPowerMode(name string) (PowerMode, error) {
+	return PowerModeUnknown, errNativeUnsupported
+}
@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"time"
+)
+
+// The states of a Notification, those reported by smartctl for the health
+// self-assessment.  The previous state is unknown when the failure is found
+// by the first collection of the device.
+const (
+	StatePassed  = "PASSED"
+	StateFailed  = "FAILED"
+	StateUnknown = "UNKNOWN"
+)
+
+// The events of a Notification
+const (
+	// EventHealth is a change of the health self-assessment
+	EventHealth = "health"
+	// EventAttribute is an attribute crossing its threshold
+	EventAttribute = "attribute"
+	// EventSelfTest is a change of the result of the most recent self-test
+	EventSelfTest = "self_test"
+)
+
+// Notification is a change of the health of a device found by a
+// collection, passing or failing
+type Notification struct {
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"`
+	Type   string    `json:"type"`
+	Serial string    `json:"serial,omitempty"`
+	WWN    string    `json:"wwn,omitempty"`
+	// Event is EventHealth, EventAttribute or EventSelfTest
+	Event string `json:"event"`
+	// OldState and NewState are StatePassed, StateFailed or StateUnknown
+	OldState string `json:"old_state"`
+	NewState string `json:"new_state"`
+	// Attribute is the attribute which crossed its threshold for an
+	// EventAttribute
+	Attribute *Attribute `json:"attribute,omitempty"`
+	// SelfTest is the type and status of the self-test for an
+	// EventSelfTest, e.g. "Extended offline: Completed: read failure"
+	SelfTest string `json:"self_test,omitempty"`
+}
+
+// Notifier is notified of the changes of the health of the devices.
+// Notify is called by the collection and must not block it.
+type Notifier interface {
+	Notify(n Notification)
+}
+
+// healthChange records the result of the health self-assessment of the
+// device and returns the previous state if it changed, or "" otherwise
+func (st *deviceState) healthChange(failed bool) string {
+	old := ""
+	switch {
+	case !st.healthKnown && failed:
+		old = StateUnknown
+	case st.healthKnown && st.unhealthy != failed:
+		old = stateOf(st.unhealthy)
+	}
+	st.unhealthy, st.healthKnown = failed, true
+	return old
+}
+
+// selfTestChange records the result of the most recent self-test of the
+// device and returns the previous state if it changed, or "" otherwise
+func (st *deviceState) selfTestChange(failed bool) string {
+	old := ""
+	switch {
+	case !st.selfTestKnown && failed:
+		old = StateUnknown
+	case st.selfTestKnown && st.selfTestFailed != failed:
+		old = stateOf(st.selfTestFailed)
+	}
+	st.selfTestFailed, st.selfTestKnown = failed, true
+	return old
+}
+
+// attributeChanges records the attributes of the device at or below their
+// threshold and returns those which crossed it since the last collection,
+// with their previous state
+func (st *deviceState) attributeChanges(attrs []Attribute) map[int]string {
+	changes := map[int]string{}
+	failing := make(map[string]bool, len(attrs))
+	for i, attr := range attrs {
+		failing[attr.Name] = attributeFailing(attr)
+		switch {
+		case st.failingAttributes == nil && failing[attr.Name]:
+			changes[i] = StateUnknown
+		case st.failingAttributes != nil && st.failingAttributes[attr.Name] != failing[attr.Name]:
+			changes[i] = stateOf(st.failingAttributes[attr.Name])
+		}
+	}
+	st.failingAttributes = failing
+	return changes
+}
+
+// stateOf returns the state of a Notification
+func stateOf(failed bool) string {
+	if failed {
+		return StateFailed
+	}
+	return StatePassed
+}
+
+// notify notifies the notifier of the options of a change of the health
+// of the device, the notification is completed with the device identity
+func (c *Collector) notify(d Device, n Notification) {
+	if c.collectorOpts.Notifier == nil {
+		return
+	}
+	identity := c.identity(d)
+	n.Time = time.Now()
+	n.Device, n.Type = d.Name, d.Type
+	n.Serial, n.WWN = identity.Serial, identity.WWN
+	c.collectorOpts.Notifier.Notify(n)
+}
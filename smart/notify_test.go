@@ -0,0 +1,108 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+// recordingNotifier records the notifications
+type recordingNotifier []Notification
+
+func (r *recordingNotifier) Notify(n Notification) {
+	*r = append(*r, n)
+}
+
+func TestNotifyHealth(t *testing.T) {
+	notifier := &recordingNotifier{}
+	c, err := NewCollector(nil, &CollectorOptions{Notifier: notifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+
+	// a healthy device is not notified until its health changes
+	c.recordHealth(d, &DeviceInfo{Healthy: true})
+	c.recordHealth(d, &DeviceInfo{Healthy: true})
+	c.recordHealth(d, &DeviceInfo{Failed: true})
+	c.recordHealth(d, &DeviceInfo{Failed: true})
+	c.recordHealth(d, &DeviceInfo{Healthy: true})
+	if len(*notifier) != 2 {
+		t.Fatalf("expected 2 notifications, got %+v", *notifier)
+	}
+	if n := (*notifier)[0]; n.Device != "/dev/sda" || n.Event != EventHealth || n.OldState != StatePassed || n.NewState != StateFailed || n.Attribute != nil {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	if n := (*notifier)[1]; n.OldState != StateFailed || n.NewState != StatePassed {
+		t.Errorf("unexpected notification %+v", n)
+	}
+
+	// a device failing on its first collection is notified
+	*notifier = nil
+	c.recordHealth(Device{Name: "/dev/sdb"}, &DeviceInfo{Failed: true})
+	if len(*notifier) != 1 || (*notifier)[0].OldState != StateUnknown {
+		t.Errorf("unexpected notifications %+v", *notifier)
+	}
+}
+
+func TestNotifyAttributes(t *testing.T) {
+	notifier := &recordingNotifier{}
+	c, err := NewCollector(nil, &CollectorOptions{Notifier: notifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+
+	c.recordPrefail(d, []Attribute{
+		{ID: 5, Name: "reallocated_sector_ct", Flags: "PO--CK", Value: 100, Threshold: 10},
+		{ID: 190, Name: "airflow_temperature_cel", Flags: "-O---K", Value: 60, Threshold: 40},
+	})
+	if len(*notifier) != 0 {
+		t.Fatalf("unexpected notifications %+v", *notifier)
+	}
+	c.recordPrefail(d, []Attribute{
+		{ID: 5, Name: "reallocated_sector_ct", Flags: "PO--CK", Value: 5, Threshold: 10},
+		{ID: 190, Name: "airflow_temperature_cel", Flags: "-O---K", Value: 60, Threshold: 40, WhenFailed: "In_the_past"},
+	})
+	if len(*notifier) != 1 {
+		t.Fatalf("expected 1 notification, got %+v", *notifier)
+	}
+	n := (*notifier)[0]
+	if n.Event != EventAttribute || n.OldState != StatePassed || n.NewState != StateFailed || n.Attribute == nil || n.Attribute.ID != 5 {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if !c.state(d.Key()).failingPrefail {
+		t.Error("expected a failing pre-failure attribute")
+	}
+}
+
+func TestNotifySelfTest(t *testing.T) {
+	notifier := &recordingNotifier{}
+	c, err := NewCollector(nil, &CollectorOptions{Notifier: notifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+
+	c.recordSelfTest(d, SelfTest{Description: "Short offline", Status: "Completed without error", Passed: true})
+	c.recordSelfTest(d, SelfTest{Description: "Extended offline", Status: "Completed: read failure"})
+	if len(*notifier) != 1 {
+		t.Fatalf("expected 1 notification, got %+v", *notifier)
+	}
+	n := (*notifier)[0]
+	if n.Event != EventSelfTest || n.NewState != StateFailed || n.SelfTest != "Extended offline: Completed: read failure" {
+		t.Errorf("unexpected notification %+v", n)
+	}
+}
@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build linux
+// +build linux
+
+package smart
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// nvmeIoctlAdminCmd is NVME_IOCTL_ADMIN_CMD, _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeIoctlAdminCmd = 0xC0484E41
+	// nvmeAdminGetLogPage and nvmeAdminIdentify are the admin command opcodes
+	nvmeAdminGetLogPage = 0x02
+	nvmeAdminIdentify   = 0x06
+	// nvmeLogHealth is the identifier of the SMART / Health Information log
+	nvmeLogHealth = 0x02
+	// nvmeIdentifyController is the CNS value identifying the controller
+	nvmeIdentifyController = 0x01
+	// nvmeNSIDAll addresses the controller rather than a namespace
+	nvmeNSIDAll = 0xffffffff
+)
+
+// nvmePassthruCmd is struct nvme_passthru_cmd of linux/nvme_ioctl.h
+type nvmePassthruCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// nvmeAdminCommand sends the admin command to the NVMe controller, data
+// receives the data transferred by the controller
+func nvmeAdminCommand(name string, cmd *nvmePassthruCmd, data []byte) error {
+	f, err := os.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cmd.addr = uint64(uintptr(unsafe.Pointer(&data[0])))
+	cmd.dataLen = uint32(len(data))
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(cmd)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return errors.New("NVMe admin command failed on " + name + ": " + errno.Error())
+	}
+	return nil
+}
+
+// readNVMeHealthLog reads the SMART / Health Information log of the controller
+func readNVMeHealthLog(name string) ([]byte, error) {
+	data := make([]byte, parser.NVMeHealthLogSize)
+	numd := uint32(len(data)/4 - 1)
+	cmd := &nvmePassthruCmd{
+		opcode: nvmeAdminGetLogPage,
+		nsid:   nvmeNSIDAll,
+		cdw10:  nvmeLogHealth | numd<<16,
+	}
+	return data, nvmeAdminCommand(name, cmd, data)
+}
+
+// readNVMeIdentify reads the Identify Controller data structure
+func readNVMeIdentify(name string) ([]byte, error) {
+	data := make([]byte, parser.NVMeIdentifySize)
+	cmd := &nvmePassthruCmd{
+		opcode: nvmeAdminIdentify,
+		cdw10:  nvmeIdentifyController,
+	}
+	return data, nvmeAdminCommand(name, cmd, data)
+}
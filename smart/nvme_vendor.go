@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// smartctlNVMeLogOption reads an NVMe log page, e.g. -l nvmelog,0xc0,512
+const smartctlNVMeLogOption = "-l"
+
+// intelModelPrefixes match the models of the NVMe devices with the Intel
+// SMART log page, the page has a different meaning on other devices
+var intelModelPrefixes = []string{"INTEL", "SOLIDIGM"}
+
+// nvmeLog reads an NVMe log page of the device with 'smartctl -l nvmelog',
+// the log page is empty if the device does not support it
+func (d *Device) nvmeLog(ctx context.Context, o *Options, page int, size int) ([]byte, error) {
+	opts := []string{smartctlNVMeLogOption, fmt.Sprintf("nvmelog,0x%02x,%d", page, size), "-d", d.Type, d.Name}
+	output, _, err := smartCtl(ctx, o, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseNVMeLog(output), nil
+}
+
+// collectVendorLogs collects the vendor specific NVMe log pages supported by
+// the device, the OCP SMART log page of hyperscale SSDs and the SMART log
+// page of Intel SSDs
+func (c *Collector) collectVendorLogs(ctx context.Context, ch chan<- prometheus.Metric, d Device) error {
+	opts := c.deviceOpts(d)
+	data, err := d.nvmeLog(ctx, opts, parser.OCPSMARTLogPage, parser.OCPSMARTLogSize)
+	if err != nil {
+		return err
+	}
+	if attrs, err := parser.ParseOCPSMARTLog(data); err == nil {
+		c.collectVendorAttributes(ch, d, "smartmon_nvme_ocp_", attrs)
+	}
+
+	c.mtx.Lock()
+	model := strings.ToUpper(c.state(d.Key()).model)
+	c.mtx.Unlock()
+	for _, prefix := range intelModelPrefixes {
+		if !strings.HasPrefix(model, prefix) {
+			continue
+		}
+		data, err := d.nvmeLog(ctx, opts, parser.IntelSMARTLogPage, parser.IntelSMARTLogSize)
+		if err != nil {
+			return err
+		}
+		c.collectVendorAttributes(ch, d, "smartmon_nvme_intel_", parser.ParseIntelSMARTLog(data))
+		break
+	}
+	return nil
+}
+
+// collectVendorAttributes exports the attributes of a vendor log page as
+// gauges named after the attribute
+func (c *Collector) collectVendorAttributes(ch chan<- prometheus.Metric, d Device, metricPrefix string, attrs []Attribute) {
+	for _, attr := range attrs {
+		metricName := metricPrefix + attr.Name
+		desc := c.descs.get(metricName, c.labels(d))
+		c.constMetric(ch, desc, prometheus.GaugeValue, attr.Raw)
+	}
+}
@@ -0,0 +1,48 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNVMeLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := &Options{SmartctlPath: fakeSmartctl(t, dir, `NVMe Log 0xc0 (0x0020 bytes)
+0000: 00 10 00 00 00 00 00 00 00 00 00 00 00 00 00 00  ................
+0010: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 ff  ................
+`, 0)}
+	d := Device{Name: "/dev/nvme0", Type: "nvme"}
+	data, err := d.nvmeLog(context.Background(), opts, 0xc0, 32)
+	if err != nil {
+		t.Fatal("unable to read log page", err)
+	}
+	if len(data) != 32 || data[1] != 0x10 || data[31] != 0xff {
+		t.Fatal("unexpected log page", data)
+	}
+
+	// the device rejects the log page, smartctl exits with the command failed bit
+	opts.SmartctlPath = fakeSmartctl(t, dir, "Read NVMe Log 0xc0 failed: Invalid Field in Command\n", int(ExitCommandFailed))
+	data, err = d.nvmeLog(context.Background(), opts, 0xc0, 32)
+	if err != nil || len(data) != 0 {
+		t.Fatal("expected an empty log page for an unsupported page, found", data, err)
+	}
+}
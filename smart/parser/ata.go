@@ -0,0 +1,161 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ATASectorSize is the size of the SMART data, the SMART thresholds
+	// and the IDENTIFY DEVICE data
+	ATASectorSize = 512
+	// ataAttributeCount is the number of attribute entries of the SMART data
+	ataAttributeCount = 30
+	// ataAttributeSize is the size of an attribute entry
+	ataAttributeSize = 12
+)
+
+// ataAttributeNames are the default names smartctl gives to the attributes
+// when the drive database has no entry for the drive
+var ataAttributeNames = map[int]string{
+	1:   "Raw_Read_Error_Rate",
+	2:   "Throughput_Performance",
+	3:   "Spin_Up_Time",
+	4:   "Start_Stop_Count",
+	5:   "Reallocated_Sector_Ct",
+	7:   "Seek_Error_Rate",
+	8:   "Seek_Time_Performance",
+	9:   "Power_On_Hours",
+	10:  "Spin_Retry_Count",
+	11:  "Calibration_Retry_Count",
+	12:  "Power_Cycle_Count",
+	183: "Runtime_Bad_Block",
+	184: "End-to-End_Error",
+	187: "Reported_Uncorrect",
+	188: "Command_Timeout",
+	189: "High_Fly_Writes",
+	190: "Airflow_Temperature_Cel",
+	191: "G-Sense_Error_Rate",
+	192: "Power-Off_Retract_Count",
+	193: "Load_Cycle_Count",
+	194: "Temperature_Celsius",
+	195: "Hardware_ECC_Recovered",
+	196: "Reallocated_Event_Count",
+	197: "Current_Pending_Sector",
+	198: "Offline_Uncorrectable",
+	199: "UDMA_CRC_Error_Count",
+	200: "Multi_Zone_Error_Rate",
+	240: "Head_Flying_Hours",
+	241: "Total_LBAs_Written",
+	242: "Total_LBAs_Read",
+}
+
+// ATAAttributeName returns the name smartctl gives to the attribute when the
+// drive database has no entry for the drive, or "" if it has no default name
+func ATAAttributeName(id int) string {
+	return ataAttributeNames[id]
+}
+
+// ataTemperatureAttributes report the current temperature in the lowest
+// byte of the raw value
+var ataTemperatureAttributes = map[int]bool{190: true, 194: true}
+
+// ParseATASmartData parses the attributes of the data returned by the ATA
+// SMART READ DATA command along with their thresholds returned by SMART
+// READ THRESHOLDS.  The attributes are named like smartctl does for drives
+// which are not in its drive database.
+func ParseATASmartData(data []byte, thresholds []byte) ([]Attribute, error) {
+	if len(data) < ATASectorSize || len(thresholds) < ATASectorSize {
+		return nil, errors.New("ATA SMART data too short")
+	}
+	limits := map[int]float64{}
+	for i := 0; i < ataAttributeCount; i++ {
+		entry := thresholds[2+i*ataAttributeSize:]
+		if entry[0] != 0 {
+			limits[int(entry[0])] = float64(entry[1])
+		}
+	}
+	attrs := []Attribute{}
+	for i := 0; i < ataAttributeCount; i++ {
+		entry := data[2+i*ataAttributeSize:]
+		id := int(entry[0])
+		if id == 0 {
+			continue
+		}
+		raw := uint64(entry[5]) | uint64(entry[6])<<8 | uint64(entry[7])<<16 |
+			uint64(entry[8])<<24 | uint64(entry[9])<<32 | uint64(entry[10])<<40
+		if ataTemperatureAttributes[id] {
+			raw &= 0xff
+		}
+		attr := Attribute{
+			ID:        id,
+			Name:      ataAttributeNames[id],
+			Flags:     "0x" + leftPad(strconv.FormatUint(uint64(binary.LittleEndian.Uint16(entry[1:3])), 16), 4),
+			Value:     float64(entry[3]),
+			Worst:     float64(entry[4]),
+			Threshold: limits[id],
+			Raw:       float64(raw),
+			RawString: strconv.FormatUint(raw, 10),
+		}
+		if attr.Name == "" {
+			attr.Name = "Unknown_Attribute"
+		}
+		if attr.Threshold > 0 && attr.Value <= attr.Threshold {
+			attr.WhenFailed = "FAILING_NOW"
+		} else if attr.Threshold > 0 && attr.Worst <= attr.Threshold {
+			attr.WhenFailed = "In_the_past"
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// ParseATAIdentify parses the model, serial number and firmware of the
+// IDENTIFY DEVICE data along with the health returned by SMART RETURN STATUS
+func ParseATAIdentify(identify []byte, healthy bool) (*DeviceInfo, error) {
+	if len(identify) < ATASectorSize {
+		return nil, errors.New("ATA IDENTIFY DEVICE data too short")
+	}
+	// word 82 bit 0 and word 85 bit 0 report SMART support and enablement
+	return &DeviceInfo{
+		Available: identify[164]&1 != 0,
+		Enabled:   identify[170]&1 != 0,
+		Healthy:   healthy,
+		Attributes: map[string]string{
+			"serial_number":    ataString(identify[20:40]),
+			"firmware_version": ataString(identify[46:54]),
+			"device_model":     ataString(identify[54:94]),
+		},
+	}, nil
+}
+
+// ataString reads a string of the IDENTIFY DEVICE data, which has the two
+// bytes of every word swapped
+func ataString(b []byte) string {
+	swapped := make([]byte, len(b))
+	for i := 0; i+1 < len(b); i += 2 {
+		swapped[i], swapped[i+1] = b[i+1], b[i]
+	}
+	return strings.TrimSpace(string(swapped))
+}
+
+// leftPad pads s with zeros to the given length
+func leftPad(s string, length int) string {
+	if len(s) >= length {
+		return s
+	}
+	return strings.Repeat("0", length-len(s)) + s
+}
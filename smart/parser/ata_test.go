@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import "testing"
+
+func TestParseATASmartData(t *testing.T) {
+	data := make([]byte, ATASectorSize)
+	thresholds := make([]byte, ATASectorSize)
+	// 5 Reallocated_Sector_Ct 0x0033 100 100 010 raw 8
+	copy(data[2:], []byte{5, 0x33, 0x00, 100, 100, 8, 0, 0, 0, 0, 0, 0})
+	copy(thresholds[2:], []byte{5, 10})
+	// 194 Temperature_Celsius 0x0022 036 045 000 raw 36 (Min/Max 20/45)
+	copy(data[14:], []byte{194, 0x22, 0x00, 36, 45, 36, 0, 20, 0, 45, 0, 0})
+	// an unknown attribute failing now
+	copy(data[26:], []byte{254, 0x01, 0x00, 5, 5, 1, 0, 0, 0, 0, 0, 0})
+	copy(thresholds[14:], []byte{254, 10})
+
+	attrs, err := ParseATASmartData(data, thresholds)
+	if err != nil {
+		t.Fatal("unable to parse SMART data", err)
+	}
+	if len(attrs) != 3 {
+		t.Fatal("expected 3 attributes, found", len(attrs))
+	}
+	if attrs[0].Name != "Reallocated_Sector_Ct" || attrs[0].Flags != "0x0033" || attrs[0].Threshold != 10 || attrs[0].Raw != 8 {
+		t.Fatal("unexpected attribute", attrs[0])
+	}
+	if attrs[1].Raw != 36 || attrs[1].Worst != 45 {
+		t.Fatal("unexpected temperature", attrs[1])
+	}
+	if attrs[2].Name != "Unknown_Attribute" || attrs[2].WhenFailed != "FAILING_NOW" {
+		t.Fatal("unexpected attribute", attrs[2])
+	}
+}
+
+func TestParseATAIdentify(t *testing.T) {
+	identify := make([]byte, ATASectorSize)
+	copy(identify[20:], swapWords("Z3YX2S30            "))
+	copy(identify[46:], swapWords("CC43    "))
+	copy(identify[54:], swapWords("ST4000DM000-1F2168                      "))
+	identify[164] = 1
+	identify[170] = 1
+	info, err := ParseATAIdentify(identify, true)
+	if err != nil {
+		t.Fatal("unable to parse identify data", err)
+	}
+	if info.Attributes["serial_number"] != "Z3YX2S30" || info.Attributes["device_model"] != "ST4000DM000-1F2168" || info.Attributes["firmware_version"] != "CC43" {
+		t.Fatal("unexpected info", info.Attributes)
+	}
+	if !info.Available || !info.Enabled || !info.Healthy {
+		t.Fatal("unexpected SMART support", info)
+	}
+}
+
+// swapWords swaps the bytes of every word like the IDENTIFY DEVICE data
+func swapWords(s string) []byte {
+	b := []byte(s)
+	for i := 0; i+1 < len(b); i += 2 {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+	return b
+}
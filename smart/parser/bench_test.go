@@ -0,0 +1,147 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchmarkDevices is the number of devices of the benchmarks, e.g. a
+// 60 disk JBOD
+const benchmarkDevices = 60
+
+var benchmarkScan = func() []byte {
+	var b strings.Builder
+	for i := 0; i < benchmarkDevices; i++ {
+		name := "/dev/sd" + string(rune('a'+i%26)) + strconv.Itoa(i/26)
+		b.WriteString(name + " -d sat # " + name + " [SAT], ATA device\n")
+	}
+	return []byte(b.String())
+}()
+
+var benchmarkInfo = []byte(`smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7-200.fc30.x86_64] (local build)
+Copyright (C) 2002-18, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF INFORMATION SECTION ===
+Model Family:     Seagate Desktop HDD.15
+Device Model:     ST4000DM000-1F2168
+Serial Number:    Z302SXYZ
+LU WWN Device Id: 5 000c50 0a1b2c3d4
+Firmware Version: CC54
+User Capacity:    4,000,787,030,016 bytes [4.00 TB]
+Sector Sizes:     512 bytes logical, 4096 bytes physical
+Rotation Rate:    5900 rpm
+Form Factor:      3.5 inches
+Device is:        In smartctl database [for details use: -P show]
+ATA Version is:   ACS-2, ACS-3 T13/2161-D revision 3b
+SATA Version is:  SATA 3.1, 6.0 Gb/s (current: 6.0 Gb/s)
+Local Time is:    Mon Jan 27 12:00:00 2020 CET
+SMART support is: Available - device has SMART capability.
+SMART support is: Enabled
+
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+`)
+
+var benchmarkATAAttributes = []byte(`smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7-200.fc30.x86_64] (local build)
+
+=== START OF READ SMART DATA SECTION ===
+SMART Attributes Data Structure revision number: 10
+Vendor Specific SMART Attributes with Thresholds:
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  1 Raw_Read_Error_Rate     0x000f   117   099   006    Pre-fail  Always       -       153427856
+  3 Spin_Up_Time            0x0003   092   091   000    Pre-fail  Always       -       0
+  4 Start_Stop_Count        0x0032   100   100   020    Old_age   Always       -       302
+  5 Reallocated_Sector_Ct   0x0033   100   100   010    Pre-fail  Always       -       0
+  7 Seek_Error_Rate         0x000f   086   060   030    Pre-fail  Always       -       434124867
+  9 Power_On_Hours          0x0032   076   076   000    Old_age   Always       -       21089
+ 10 Spin_Retry_Count        0x0013   100   100   097    Pre-fail  Always       -       0
+ 12 Power_Cycle_Count       0x0032   100   100   020    Old_age   Always       -       302
+183 Runtime_Bad_Block       0x0032   100   100   000    Old_age   Always       -       0
+184 End-to-End_Error        0x0032   100   100   099    Old_age   Always       -       0
+187 Reported_Uncorrect      0x0032   100   100   000    Old_age   Always       -       0
+188 Command_Timeout         0x0032   100   100   000    Old_age   Always       -       0 0 0
+189 High_Fly_Writes         0x003a   100   100   000    Old_age   Always       -       0
+190 Airflow_Temperature_Cel 0x0022   064   055   045    Old_age   Always       -       36 (Min/Max 20/45)
+191 G-Sense_Error_Rate      0x0032   100   100   000    Old_age   Always       -       0
+192 Power-Off_Retract_Count 0x0032   100   100   000    Old_age   Always       -       269
+193 Load_Cycle_Count        0x0032   061   061   000    Old_age   Always       -       78694
+194 Temperature_Celsius     0x0022   036   045   000    Old_age   Always       -       36 (0 16 0 0 0)
+197 Current_Pending_Sector  0x0012   100   100   000    Old_age   Always       -       0
+198 Offline_Uncorrectable   0x0010   100   100   000    Old_age   Offline      -       0
+199 UDMA_CRC_Error_Count    0x003e   200   200   000    Old_age   Always       -       0
+240 Head_Flying_Hours       0x0000   100   253   000    Old_age   Offline      -       20846h+45m+09.317s
+241 Total_LBAs_Written      0x0000   100   253   000    Old_age   Offline      -       48412470917
+242 Total_LBAs_Read         0x0000   100   253   000    Old_age   Offline      -       285606298795
+`)
+
+var benchmarkNVMeAttributes = []byte(`smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7-200.fc30.x86_64] (local build)
+
+=== START OF SMART DATA SECTION ===
+SMART/Health Information (NVMe Log 0x02)
+Critical Warning:                   0x00
+Temperature:                        35 Celsius
+Available Spare:                    100%
+Available Spare Threshold:          10%
+Percentage Used:                    0%
+Data Units Read:                    1,234,567 [632 GB]
+Data Units Written:                 2,345,678 [1.20 TB]
+Host Read Commands:                 12,345,678
+Host Write Commands:                23,456,789
+Controller Busy Time:               123
+Power Cycles:                       456
+Power On Hours:                     7,890
+Unsafe Shutdowns:                   12
+Media and Data Integrity Errors:    0
+Error Information Log Entries:      0
+Warning  Comp. Temperature Time:    0
+Critical Comp. Temperature Time:    0
+Temperature Sensor 1:               35 Celsius
+`)
+
+func BenchmarkParseScan(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseScan(benchmarkScan); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseInfo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseInfo(benchmarkInfo); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseATAAttributes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseATAAttributes(benchmarkATAAttributes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseNVMeAttributes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseNVMeAttributes(benchmarkNVMeAttributes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// DriveDBVersion identifies a drive database file of smartctl
+type DriveDBVersion struct {
+	// Branch is the smartmontools release branch of the database, e.g. 7.2,
+	// only known if the database has a VERSION entry
+	Branch string
+	// Revision is the SVN revision of the database
+	Revision string
+	// Date is the date of the revision, e.g. 2021-06-06
+	Date string
+}
+
+// ParseDriveDB parses the version of a drivedb.h file.  The version is read
+// from the VERSION entry of the database, e.g.
+//
+//	{ "VERSION: 7.2/5225 2021-06-06 17:22:13 $Id: drivedb.h 5225 ... $",
+//
+// or from the $Id$ keyword of older databases, e.g.
+//
+//	$Id: drivedb.h 4842 2018-12-02 16:07:26Z chrfranke $
+func ParseDriveDB(content []byte) (DriveDBVersion, error) {
+	if i := bytes.Index(content, []byte("VERSION: ")); i >= 0 {
+		fields := strings.Fields(string(content[i+len("VERSION: ") : i+lineEnd(content[i:])]))
+		if len(fields) >= 2 {
+			if parts := strings.SplitN(fields[0], "/", 2); len(parts) == 2 {
+				return DriveDBVersion{Branch: parts[0], Revision: parts[1], Date: fields[1]}, nil
+			}
+		}
+	}
+	if i := bytes.Index(content, []byte("$Id: drivedb.h ")); i >= 0 {
+		fields := strings.Fields(string(content[i : i+lineEnd(content[i:])]))
+		if len(fields) >= 4 {
+			return DriveDBVersion{Revision: fields[2], Date: fields[3]}, nil
+		}
+	}
+	return DriveDBVersion{}, errors.New("unable to find the version of the drive database")
+}
+
+// lineEnd returns the length of the first line of the content
+func lineEnd(content []byte) int {
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		return i
+	}
+	return len(content)
+}
@@ -0,0 +1,53 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import "testing"
+
+func TestParseDriveDB(t *testing.T) {
+	version, err := ParseDriveDB([]byte(`/*
+ * drivedb.h - smartmontools drive database file
+ *
+ * Home page of code is: https://www.smartmontools.org
+ */
+
+/*
+const drive_settings builtin_knowndrives[] = {
+ */
+{ "VERSION: 7.2/5225 2021-06-06 17:22:13 $Id: drivedb.h 5225 2021-06-06 17:22:13Z chrfranke $",
+  "-", "-",
+  "Version information",
+  ""
+},
+`))
+	if err != nil {
+		t.Fatal("unable to parse drive database", err)
+	}
+	if version != (DriveDBVersion{Branch: "7.2", Revision: "5225", Date: "2021-06-06"}) {
+		t.Fatal("unexpected version", version)
+	}
+
+	version, err = ParseDriveDB([]byte(`/*
+ * $Id: drivedb.h 4842 2018-12-02 16:07:26Z chrfranke $
+ */
+`))
+	if err != nil {
+		t.Fatal("unable to parse drive database", err)
+	}
+	if version != (DriveDBVersion{Revision: "4842", Date: "2018-12-02"}) {
+		t.Fatal("unexpected version", version)
+	}
+
+	if _, err := ParseDriveDB([]byte("not a drive database")); err == nil {
+		t.Fatal("expected an error parsing an invalid drive database")
+	}
+}
@@ -0,0 +1,53 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import "testing"
+
+// The fuzz targets only verify that the parsers never panic, whatever
+// smartctl (or a misbehaving device) writes to its output.
+
+func FuzzParseScan(f *testing.F) {
+	f.Add([]byte("/dev/sda -d sat # /dev/sda [SAT], ATA device\n"))
+	f.Add([]byte("/dev/nvme0 -d nvme # /dev/nvme0, NVMe device\n"))
+	f.Add([]byte(`{"devices":[{"name":"/dev/sda","info_name":"/dev/sda [SAT]","type":"sat","protocol":"ATA"}]}`))
+	f.Add([]byte(`{"devices":null}`))
+	f.Fuzz(func(t *testing.T, output []byte) {
+		ParseScan(output)
+		ParseScanJSON(output)
+	})
+}
+
+func FuzzParseInfo(f *testing.F) {
+	f.Add([]byte("SMART support is: Available - device has SMART capability.\nSMART support is: Enabled\n"))
+	f.Add([]byte("SMART overall-health self-assessment test result: PASSED\n"))
+	f.Add([]byte(`{"model_name":"SAMSUNG MZVLB512HAJQ-000L7","smart_status":{"passed":true}}`))
+	f.Add([]byte(`{"smart_status":null}`))
+	f.Fuzz(func(t *testing.T, output []byte) {
+		ParseInfo(output)
+		ParseInfoJSON(output)
+	})
+}
+
+func FuzzParseAttributes(f *testing.F) {
+	f.Add([]byte("ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE\n" +
+		"194 Temperature_Celsius     0x0022   036   045   000    Old_age   Always       -       36 (Min/Max 20/45)\n"))
+	f.Add([]byte("Temperature:                        35 Celsius\nData Units Read:                    1,234,567 [632 GB]\n"))
+	f.Add([]byte(`{"ata_smart_attributes":{"table":[{"id":194,"name":"Temperature_Celsius","value":36,"raw":{"value":36,"string":"36"}}]}}`))
+	f.Add([]byte(`{"nvme_smart_health_information_log":{"temperature":35,"critical_warning":0}}`))
+	f.Fuzz(func(t *testing.T, output []byte) {
+		ParseATAAttributes(output)
+		ParseNVMeAttributes(output)
+		ParseAttributesJSON(output)
+	})
+}
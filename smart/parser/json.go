@@ -0,0 +1,401 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// parsedJSON maps the keys of a JSON object to their unparsed values.  The
+// values are not pointers so that a null value cannot be dereferenced.
+type parsedJSON map[string]json.RawMessage
+
+func parseJSON(data []byte) (parsedJSON, error) {
+	parsed := parsedJSON{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// ParseVersionJSON parses the metadata of smartctl included with its JSON
+// output, e.g. of 'smartctl -j -V'
+func ParseVersionJSON(output []byte) (*SmartctlJSONMeta, error) {
+	meta := &SmartctlJSONMeta{}
+	if err := json.Unmarshal(output, meta); err != nil {
+		return nil, err
+	}
+	if len(meta.Smartctl.Version) == 0 {
+		return nil, errors.New("unable to find 'smartctl' version in JSON output")
+	}
+	return meta, nil
+}
+
+// ParseMessagesJSON returns the messages printed by smartctl included with
+// its JSON output, or nil if the output cannot be parsed
+func ParseMessagesJSON(output []byte) []Message {
+	meta := &SmartctlJSONMeta{}
+	if err := json.Unmarshal(output, meta); err != nil {
+		return nil
+	}
+	return meta.Smartctl.Messages
+}
+
+// ParseScanJSON is similar to ParseScan but parses the JSON output of
+// 'smartctl -j --scan'
+func ParseScanJSON(output []byte) ([]Device, error) {
+	mappedJSON, err := parseJSON(output)
+	if err != nil {
+		return nil, err
+	}
+	devices := []Device{}
+
+	unparsedDevices, exists := mappedJSON["devices"]
+	if !exists {
+		return nil, errors.New("unable to find 'devices' entry in JSON output")
+	}
+	err = json.Unmarshal(unparsedDevices, &devices)
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+var (
+	parsableFields = map[string]struct{}{
+		"json_format_version": {},
+		"smartctl":            {},
+		"device":              {},
+		"smart_status":        {},
+		"nvme_namespaces":     {},
+		"interface_speed":     {},
+		"form_factor":         {},
+		"rotation_rate":       {},
+		// the attributes reported by -A, which are not part of the info
+		// when read with the info by a single command
+		"ata_smart_attributes":              {},
+		"nvme_smart_health_information_log": {},
+		"power_on_time":                     {},
+		"power_cycle_count":                 {},
+		"temperature":                       {},
+	}
+)
+
+// interfaceSpeedJSON models the "interface_speed" entry of the JSON output
+// of 'smartctl -i', the speed is units_per_second * bits_per_unit
+type interfaceSpeedJSON struct {
+	Max     linkSpeedJSON `json:"max"`
+	Current linkSpeedJSON `json:"current"`
+}
+
+// linkSpeedJSON is a link speed of the "interface_speed" entry
+type linkSpeedJSON struct {
+	UnitsPerSecond float64 `json:"units_per_second"`
+	BitsPerUnit    float64 `json:"bits_per_unit"`
+	String         string  `json:"string"`
+}
+
+// nvmeNamespaceJSON models an entry of the "nvme_namespaces" entry of the
+// JSON output of 'smartctl -i'
+type nvmeNamespaceJSON struct {
+	ID   int `json:"id"`
+	Size struct {
+		Bytes float64 `json:"bytes"`
+	} `json:"size"`
+	Capacity struct {
+		Bytes float64 `json:"bytes"`
+	} `json:"capacity"`
+	Utilization struct {
+		Bytes float64 `json:"bytes"`
+	} `json:"utilization"`
+	FormattedLBASize float64 `json:"formatted_lba_size"`
+}
+
+// attributes gets just the key, value  pairs that cannot be parsed into
+// a known struct
+func attributes(mappedJSON parsedJSON) map[string]string {
+	cleanedAttributes := map[string]string{}
+	for key, val := range mappedJSON {
+		if _, found := parsableFields[key]; !found {
+			cleanedAttributes[key] = sanitizeValue(string(val))
+		}
+	}
+	return cleanedAttributes
+}
+
+// ParseInfoJSON is similar to ParseInfo but parses the JSON output of
+// 'smartctl -j -i -H'
+func ParseInfoJSON(output []byte) (*DeviceInfo, error) {
+	mappedJSON, err := parseJSON(output)
+	if err != nil {
+		return nil, err
+	}
+	info := DeviceInfo{
+		Attributes: attributes(mappedJSON),
+	}
+	if statusData, ok := mappedJSON["smart_status"]; ok {
+		statusDetail, err := parseJSON(statusData)
+		if err != nil {
+			return nil, err
+		}
+		if passed, ok := statusDetail["passed"]; ok {
+			if string(passed) == "true" {
+				info.passed()
+			} else {
+				info.Failed = true
+			}
+		}
+	}
+	if supportData, ok := mappedJSON["smart_support"]; ok {
+		support := struct {
+			Available bool `json:"available"`
+			Enabled   bool `json:"enabled"`
+		}{}
+		if err := json.Unmarshal(supportData, &support); err != nil {
+			return nil, err
+		}
+		info.Available = info.Available || support.Available
+		info.Enabled = info.Enabled || support.Enabled
+		info.Unsupported = !support.Available
+	}
+	if err := info.parseDeviceTypeJSON(mappedJSON); err != nil {
+		return nil, err
+	}
+	if err := info.parseCapacityJSON(output); err != nil {
+		return nil, err
+	}
+	if namespacesData, ok := mappedJSON["nvme_namespaces"]; ok {
+		namespaces := []nvmeNamespaceJSON{}
+		if err := json.Unmarshal(namespacesData, &namespaces); err != nil {
+			return nil, err
+		}
+		for _, ns := range namespaces {
+			info.Namespaces = append(info.Namespaces, Namespace{
+				ID:               ns.ID,
+				SizeBytes:        ns.Size.Bytes,
+				CapacityBytes:    ns.Capacity.Bytes,
+				UtilizationBytes: ns.Utilization.Bytes,
+				FormattedLBASize: ns.FormattedLBASize,
+			})
+		}
+	}
+	info.namespaceCapacity()
+	return &info, nil
+}
+
+// parseCapacityJSON parses the capacity and the block sizes of
+// 'smartctl -j -i', which are also kept as attributes
+func (info *DeviceInfo) parseCapacityJSON(output []byte) error {
+	capacity := struct {
+		UserCapacity struct {
+			Bytes float64 `json:"bytes"`
+		} `json:"user_capacity"`
+		NVMeTotalCapacity float64 `json:"nvme_total_capacity"`
+		LogicalBlockSize  float64 `json:"logical_block_size"`
+		PhysicalBlockSize float64 `json:"physical_block_size"`
+	}{}
+	if err := json.Unmarshal(output, &capacity); err != nil {
+		return err
+	}
+	info.CapacityBytes = capacity.UserCapacity.Bytes
+	if info.CapacityBytes == 0 {
+		info.CapacityBytes = capacity.NVMeTotalCapacity
+	}
+	info.LogicalBlockSize = capacity.LogicalBlockSize
+	info.PhysicalBlockSize = capacity.PhysicalBlockSize
+	return nil
+}
+
+// parseDeviceTypeJSON parses the rotation rate, form factor and interface
+// speed of 'smartctl -j -i'.  They are added to the attributes with the
+// values of the text output, e.g. "7200 rpm" or "Solid State Device", so the
+// labels do not depend on the output parsed.
+func (info *DeviceInfo) parseDeviceTypeJSON(mappedJSON parsedJSON) error {
+	if data, ok := mappedJSON["rotation_rate"]; ok {
+		var rate int
+		if err := json.Unmarshal(data, &rate); err != nil {
+			return err
+		}
+		if rate == 0 {
+			info.Attributes["rotation_rate"] = "Solid State Device"
+		} else {
+			info.Attributes["rotation_rate"] = strconv.Itoa(rate) + " rpm"
+		}
+	}
+	if data, ok := mappedJSON["form_factor"]; ok {
+		formFactor := struct {
+			Name string `json:"name"`
+		}{}
+		if err := json.Unmarshal(data, &formFactor); err != nil {
+			return err
+		}
+		info.Attributes["form_factor"] = formFactor.Name
+	}
+	if data, ok := mappedJSON["interface_speed"]; ok {
+		speed := interfaceSpeedJSON{}
+		if err := json.Unmarshal(data, &speed); err != nil {
+			return err
+		}
+		info.MaxInterfaceSpeed = speed.Max.UnitsPerSecond * speed.Max.BitsPerUnit
+		info.InterfaceSpeed = speed.Current.UnitsPerSecond * speed.Current.BitsPerUnit
+		if speed.Current.String != "" {
+			info.Attributes["interface_speed"] = speed.Current.String
+		}
+	}
+	return nil
+}
+
+// ataSmartAttributesJSON models the "ata_smart_attributes" entry of the
+// JSON output of 'smartctl -A'
+type ataSmartAttributesJSON struct {
+	Table []struct {
+		ID         int    `json:"id"`
+		Name       string `json:"name"`
+		Value      int    `json:"value"`
+		Worst      int    `json:"worst"`
+		Thresh     int    `json:"thresh"`
+		WhenFailed string `json:"when_failed"`
+		Flags      struct {
+			String string `json:"string"`
+		} `json:"flags"`
+		Raw struct {
+			Value  float64 `json:"value"`
+			String string  `json:"string"`
+		} `json:"raw"`
+	} `json:"table"`
+}
+
+// ParseAttributesJSON is similar to ParseATAAttributes and ParseNVMeAttributes
+// but parses the JSON output of 'smartctl -j -A'
+func ParseAttributesJSON(output []byte) ([]Attribute, error) {
+	mappedJSON, err := parseJSON(output)
+	if err != nil {
+		return nil, err
+	}
+	attrs := []Attribute{}
+	if ataData, ok := mappedJSON["ata_smart_attributes"]; ok {
+		ataAttrs := ataSmartAttributesJSON{}
+		if err := json.Unmarshal(ataData, &ataAttrs); err != nil {
+			return nil, err
+		}
+		for _, a := range ataAttrs.Table {
+			attrs = append(attrs, Attribute{
+				ID:         a.ID,
+				Name:       a.Name,
+				Flags:      a.Flags.String,
+				Value:      float64(a.Value),
+				Worst:      float64(a.Worst),
+				Threshold:  float64(a.Thresh),
+				WhenFailed: a.WhenFailed,
+				Raw:        a.Raw.Value,
+				RawString:  a.Raw.String,
+			})
+		}
+	}
+	if nvmeData, ok := mappedJSON["nvme_smart_health_information_log"]; ok {
+		nvmeLog, err := parseJSON(nvmeData)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range nvmeLog {
+			attr := Attribute{
+				Name:      key,
+				RawString: sanitizeValue(string(val)),
+			}
+			attr.Raw = parseRawValue(attr.RawString)
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs, nil
+}
+
+// selfTestLogJSON models a self-test log entry in the JSON output of
+// 'smartctl -l selftest' for ATA ("ata_smart_self_test_log") and
+// NVMe ("nvme_self_test_log") devices
+type selfTestLogJSON struct {
+	Type struct {
+		String string `json:"string"`
+	} `json:"type"`
+	Status struct {
+		String           string `json:"string"`
+		Passed           *bool  `json:"passed"`
+		RemainingPercent int    `json:"remaining_percent"`
+	} `json:"status"`
+	LifetimeHours int    `json:"lifetime_hours"`
+	LBA           *int64 `json:"lba"`
+
+	SelfTestCode struct {
+		String string `json:"string"`
+	} `json:"self_test_code"`
+	SelfTestResult struct {
+		Value  int    `json:"value"`
+		String string `json:"string"`
+	} `json:"self_test_result"`
+	PowerOnHours int `json:"power_on_hours"`
+}
+
+// ParseSelfTestsJSON is similar to ParseSelfTests but parses the JSON
+// output of 'smartctl -j -l selftest'
+func ParseSelfTestsJSON(output []byte) ([]SelfTest, error) {
+	log := struct {
+		ATA struct {
+			Standard struct {
+				Table []selfTestLogJSON `json:"table"`
+			} `json:"standard"`
+		} `json:"ata_smart_self_test_log"`
+		NVMe struct {
+			Table []selfTestLogJSON `json:"table"`
+		} `json:"nvme_self_test_log"`
+	}{}
+	if err := json.Unmarshal(output, &log); err != nil {
+		return nil, err
+	}
+	tests := []SelfTest{}
+	for i, entry := range log.ATA.Standard.Table {
+		test := SelfTest{
+			Num:           i + 1,
+			Description:   entry.Type.String,
+			Status:        entry.Status.String,
+			Passed:        entry.Status.Passed != nil && *entry.Status.Passed,
+			Remaining:     entry.Status.RemainingPercent,
+			LifetimeHours: entry.LifetimeHours,
+			FirstErrorLBA: "-",
+		}
+		if entry.LBA != nil {
+			test.FirstErrorLBA = strconv.FormatInt(*entry.LBA, 10)
+		}
+		tests = append(tests, test)
+	}
+	for i, entry := range log.NVMe.Table {
+		tests = append(tests, SelfTest{
+			Num:           i + 1,
+			Description:   entry.SelfTestCode.String,
+			Status:        entry.SelfTestResult.String,
+			Passed:        entry.SelfTestResult.Value == 0,
+			LifetimeHours: entry.PowerOnHours,
+			FirstErrorLBA: "-",
+		})
+	}
+	return tests, nil
+}
+
+// sanitizeValue removes unnecessary characters from JSON values
+func sanitizeValue(value string) string {
+	value = strings.ReplaceAll(value, "\"", "")
+	value = strings.ReplaceAll(value, "\n", "")
+	return value
+}
@@ -0,0 +1,201 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import "testing"
+
+func TestParseScanJSON(t *testing.T) {
+	output := []byte(`{
+  "json_format_version": [1, 0],
+  "devices": [
+    {
+      "name": "/dev/nvme0",
+      "info_name": "/dev/nvme0",
+      "type": "nvme",
+      "protocol": "NVMe"
+    }
+  ]
+}`)
+	devices, err := ParseScanJSON(output)
+	if err != nil {
+		t.Fatal("unable to parse devices", err)
+	}
+	if len(devices) != 1 || devices[0].Name != "/dev/nvme0" || devices[0].InfoName != "/dev/nvme0" || devices[0].Type != "nvme" {
+		t.Fatal("unexpected devices", devices)
+	}
+}
+
+func TestParseVersionJSON(t *testing.T) {
+	output := []byte(`{
+  "json_format_version": [1, 0],
+  "smartctl": {
+    "version": [7, 0],
+    "svn_revision": "4903",
+    "platform_info": "x86_64-linux-5.2.7-200.fc30.x86_64",
+    "build_info": "(local build)",
+    "argv": ["smartctl", "-j", "-V"],
+    "exit_status": 0
+  }
+}`)
+	meta, err := ParseVersionJSON(output)
+	if err != nil {
+		t.Fatal("unable to parse version", err)
+	}
+	if meta.Version() != "7.0" || meta.Smartctl.SvnRevision != "4903" || meta.Smartctl.PlatformInfo != "x86_64-linux-5.2.7-200.fc30.x86_64" {
+		t.Fatal("unexpected version", meta)
+	}
+	if _, err := ParseVersionJSON([]byte(`{"devices": []}`)); err == nil {
+		t.Fatal("expected an error parsing output without version")
+	}
+}
+
+func TestParseMessagesJSON(t *testing.T) {
+	output := []byte(`{
+  "smartctl": {
+    "version": [7, 1],
+    "messages": [
+      {
+        "string": "Read SMART Data failed: scsi error badly formed scsi parameters",
+        "severity": "error"
+      }
+    ],
+    "exit_status": 4
+  }
+}`)
+	messages := ParseMessagesJSON(output)
+	if len(messages) != 1 || messages[0].Severity != "error" || messages[0].String != "Read SMART Data failed: scsi error badly formed scsi parameters" {
+		t.Fatal("unexpected messages", messages)
+	}
+	if messages := ParseMessagesJSON([]byte("not json")); messages != nil {
+		t.Fatal("unexpected messages", messages)
+	}
+}
+
+func TestParseInfoJSON(t *testing.T) {
+	output := []byte(`{
+  "model_name": "SAMSUNG MZVLB512HAJQ-000L7",
+  "serial_number": "S3TNNX1K710265",
+  "smart_status": {
+    "passed": true
+  }
+}`)
+	info, err := ParseInfoJSON(output)
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if !info.Available || !info.Enabled || !info.Healthy {
+		t.Fatal("device should be healthy", info)
+	}
+	if info.Attributes["model_name"] != "SAMSUNG MZVLB512HAJQ-000L7" {
+		t.Fatal("unexpected model name", info.Attributes["model_name"])
+	}
+	if _, found := info.Attributes["smart_status"]; found {
+		t.Fatal("smart_status should not be an attribute")
+	}
+}
+
+func TestParseInfoJSONNamespaces(t *testing.T) {
+	output := []byte(`{
+  "model_name": "SAMSUNG MZVLB512HAJQ-000L7",
+  "nvme_namespaces": [
+    {
+      "id": 1,
+      "size": {"blocks": 1000215216, "bytes": 512110190592},
+      "capacity": {"blocks": 1000215216, "bytes": 512110190592},
+      "utilization": {"blocks": 251500984, "bytes": 128768503808},
+      "formatted_lba_size": 512
+    },
+    {
+      "id": 2,
+      "size": {"blocks": 2048, "bytes": 1048576},
+      "capacity": {"blocks": 2048, "bytes": 1048576},
+      "utilization": {"blocks": 0, "bytes": 0},
+      "formatted_lba_size": 512
+    }
+  ]
+}`)
+	info, err := ParseInfoJSON(output)
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if len(info.Namespaces) != 2 {
+		t.Fatal("expected 2 namespaces, found", info.Namespaces)
+	}
+	expected := Namespace{ID: 1, SizeBytes: 512110190592, CapacityBytes: 512110190592, UtilizationBytes: 128768503808, FormattedLBASize: 512}
+	if info.Namespaces[0] != expected {
+		t.Fatal("unexpected namespace", info.Namespaces[0])
+	}
+	if _, found := info.Attributes["nvme_namespaces"]; found {
+		t.Fatal("nvme_namespaces should not be an attribute")
+	}
+}
+
+func TestParseInfoJSONInterfaceSpeed(t *testing.T) {
+	info, err := ParseInfoJSON([]byte(`{
+  "rotation_rate": 0,
+  "form_factor": {"ata_value": 3, "name": "2.5 inches"},
+  "interface_speed": {
+    "max": {"sata_value": 14, "string": "6.0 Gb/s", "units_per_second": 60, "bits_per_unit": 100000000},
+    "current": {"sata_value": 3, "string": "3.0 Gb/s", "units_per_second": 30, "bits_per_unit": 100000000}
+  }
+}`))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if info.MaxInterfaceSpeed != 6e9 || info.InterfaceSpeed != 3e9 {
+		t.Fatal("unexpected interface speeds", info.MaxInterfaceSpeed, info.InterfaceSpeed)
+	}
+	if info.Attributes["interface_speed"] != "3.0 Gb/s" || info.Attributes["rotation_rate"] != "Solid State Device" || info.Attributes["form_factor"] != "2.5 inches" {
+		t.Fatal("unexpected attributes", info.Attributes)
+	}
+}
+
+func TestParseInfoJSONUnsupported(t *testing.T) {
+	output := []byte(`{
+  "model_name": "QEMU HARDDISK",
+  "smart_support": {
+    "available": false
+  }
+}`)
+	info, err := ParseInfoJSON(output)
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if !info.Unsupported || info.Available || info.Enabled {
+		t.Fatal("the device should lack SMART capability", info)
+	}
+}
+
+func TestParseInfoJSONCapacity(t *testing.T) {
+	info, err := ParseInfoJSON([]byte(`{
+  "user_capacity": {
+    "blocks": 7814037168,
+    "bytes": 4000787030016
+  },
+  "logical_block_size": 512,
+  "physical_block_size": 4096
+}`))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if info.CapacityBytes != 4000787030016 || info.LogicalBlockSize != 512 || info.PhysicalBlockSize != 4096 {
+		t.Fatal("unexpected capacity", info)
+	}
+	info, err = ParseInfoJSON([]byte(`{"nvme_total_capacity": 512110190592}`))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if info.CapacityBytes != 512110190592 {
+		t.Fatal("unexpected NVMe capacity", info.CapacityBytes)
+	}
+}
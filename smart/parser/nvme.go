@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NVMeHealthLogSize is the size of the SMART / Health Information log page
+	NVMeHealthLogSize = 512
+	// NVMeIdentifySize is the size of the Identify Controller data structure
+	NVMeIdentifySize = 4096
+	// kelvin is 0 Celsius in Kelvin, NVMe reports the temperatures in Kelvin
+	kelvin = 273
+)
+
+// nvmeCounters are the 128 bit counters of the health log by offset, named
+// like in the text output of smartctl
+var nvmeCounters = []struct {
+	offset int
+	name   string
+}{
+	{32, "Data Units Read"},
+	{48, "Data Units Written"},
+	{64, "Host Read Commands"},
+	{80, "Host Write Commands"},
+	{96, "Controller Busy Time"},
+	{112, "Power Cycles"},
+	{128, "Power On Hours"},
+	{144, "Unsafe Shutdowns"},
+	{160, "Media and Data Integrity Errors"},
+	{176, "Error Information Log Entries"},
+}
+
+// ParseNVMeHealthLog parses the SMART / Health Information log page (log
+// identifier 02h) read with the NVMe Get Log Page admin command.  The
+// attributes are named like in the text output of smartctl so they are
+// collected the same way.
+func ParseNVMeHealthLog(log []byte) ([]Attribute, error) {
+	if len(log) < NVMeHealthLogSize {
+		return nil, errors.New("NVMe health log too short: " + strconv.Itoa(len(log)) + " bytes")
+	}
+	attrs := []Attribute{
+		nvmeAttribute("Critical Warning", float64(log[0]), "0x"+strconv.FormatUint(uint64(log[0]), 16)),
+		nvmeTemperature("Temperature", binary.LittleEndian.Uint16(log[1:3])),
+		nvmeAttribute("Available Spare", float64(log[3]), strconv.Itoa(int(log[3]))+"%"),
+		nvmeAttribute("Available Spare Threshold", float64(log[4]), strconv.Itoa(int(log[4]))+"%"),
+		nvmeAttribute("Percentage Used", float64(log[5]), strconv.Itoa(int(log[5]))+"%"),
+	}
+	for _, counter := range nvmeCounters {
+		value := uint128(log[counter.offset : counter.offset+16])
+		attrs = append(attrs, nvmeAttribute(counter.name, value, strconv.FormatFloat(value, 'f', -1, 64)))
+	}
+	warningTime := binary.LittleEndian.Uint32(log[192:196])
+	criticalTime := binary.LittleEndian.Uint32(log[196:200])
+	attrs = append(attrs,
+		nvmeAttribute("Warning  Comp. Temperature Time", float64(warningTime), strconv.FormatUint(uint64(warningTime), 10)),
+		nvmeAttribute("Critical Comp. Temperature Time", float64(criticalTime), strconv.FormatUint(uint64(criticalTime), 10)),
+	)
+	for i := 0; i < 8; i++ {
+		// sensors which are not implemented report 0
+		if sensor := binary.LittleEndian.Uint16(log[200+2*i:]); sensor != 0 {
+			attrs = append(attrs, nvmeTemperature("Temperature Sensor "+strconv.Itoa(i+1), sensor))
+		}
+	}
+	return attrs, nil
+}
+
+// ParseNVMeIdentify parses the model, serial number and firmware of the
+// Identify Controller data structure, along with the health reported by
+// the critical warning of the health log
+func ParseNVMeIdentify(identify []byte, healthLog []byte) (*DeviceInfo, error) {
+	if len(identify) < NVMeIdentifySize {
+		return nil, errors.New("NVMe identify data too short: " + strconv.Itoa(len(identify)) + " bytes")
+	}
+	if len(healthLog) < NVMeHealthLogSize {
+		return nil, errors.New("NVMe health log too short: " + strconv.Itoa(len(healthLog)) + " bytes")
+	}
+	info := &DeviceInfo{
+		Available: true,
+		Enabled:   true,
+		Healthy:   healthLog[0] == 0,
+		Attributes: map[string]string{
+			"serial_number":    strings.TrimSpace(string(identify[4:24])),
+			"model_number":     strings.TrimSpace(string(identify[24:64])),
+			"firmware_version": strings.TrimSpace(string(identify[64:72])),
+		},
+	}
+	return info, nil
+}
+
+// nvmeAttribute creates an attribute of the health log
+func nvmeAttribute(name string, raw float64, rawString string) Attribute {
+	return Attribute{Name: name, Raw: raw, RawString: rawString}
+}
+
+// nvmeTemperature creates an attribute of a temperature in Kelvin
+func nvmeTemperature(name string, temperature uint16) Attribute {
+	celsius := int(temperature) - kelvin
+	return nvmeAttribute(name, float64(celsius), strconv.Itoa(celsius)+" Celsius")
+}
+
+// uint128 reads a little endian 128 bit counter as a float
+func uint128(b []byte) float64 {
+	low := binary.LittleEndian.Uint64(b[:8])
+	high := binary.LittleEndian.Uint64(b[8:16])
+	return float64(high)*math.Pow(2, 64) + float64(low)
+}
@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseNVMeHealthLog(t *testing.T) {
+	log := make([]byte, NVMeHealthLogSize)
+	binary.LittleEndian.PutUint16(log[1:], 308) // 35 Celsius
+	log[3] = 100
+	log[4] = 10
+	log[5] = 3
+	binary.LittleEndian.PutUint64(log[32:], 1234) // data units read
+	binary.LittleEndian.PutUint64(log[120:], 1)   // power cycles, upper 64 bits
+	binary.LittleEndian.PutUint16(log[200:], 310) // temperature sensor 1
+	attrs, err := ParseNVMeHealthLog(log)
+	if err != nil {
+		t.Fatal("unable to parse health log", err)
+	}
+	found := map[string]Attribute{}
+	for _, attr := range attrs {
+		found[NormalizeName(attr.Name)] = attr
+	}
+	if found["temperature"].Raw != 35 || found["temperature"].RawString != "35 Celsius" {
+		t.Fatal("unexpected temperature", found["temperature"])
+	}
+	if found["available_spare"].RawString != "100%" || found["percentage_used"].Raw != 3 {
+		t.Fatal("unexpected spare", found["available_spare"], found["percentage_used"])
+	}
+	if found["data_units_read"].Raw != 1234 {
+		t.Fatal("unexpected data units read", found["data_units_read"])
+	}
+	if found["power_cycles"].Raw != 18446744073709551616 {
+		t.Fatal("unexpected power cycles", found["power_cycles"])
+	}
+	if found["temperature_sensor_1"].Raw != 37 {
+		t.Fatal("unexpected temperature sensor", found["temperature_sensor_1"])
+	}
+	if _, ok := found["temperature_sensor_2"]; ok {
+		t.Fatal("unimplemented temperature sensor should be skipped")
+	}
+
+	if _, err := ParseNVMeHealthLog(log[:100]); err == nil {
+		t.Fatal("expected an error parsing a short health log")
+	}
+}
+
+func TestParseNVMeIdentify(t *testing.T) {
+	identify := make([]byte, NVMeIdentifySize)
+	copy(identify[4:], "S3TNNX1K710265      ")
+	copy(identify[24:], "SAMSUNG MZVLB512HAJQ-000L7              ")
+	copy(identify[64:], "4L2QEXA7")
+	healthLog := make([]byte, NVMeHealthLogSize)
+	info, err := ParseNVMeIdentify(identify, healthLog)
+	if err != nil {
+		t.Fatal("unable to parse identify data", err)
+	}
+	if !info.Healthy || info.Attributes["model_number"] != "SAMSUNG MZVLB512HAJQ-000L7" || info.Attributes["serial_number"] != "S3TNNX1K710265" {
+		t.Fatal("unexpected info", info)
+	}
+	healthLog[0] = 0x04
+	if info, _ := ParseNVMeIdentify(identify, healthLog); info.Healthy {
+		t.Fatal("a critical warning should be reported as unhealthy")
+	}
+}
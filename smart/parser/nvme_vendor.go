@@ -0,0 +1,198 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The vendor specific NVMe log pages
+const (
+	// OCPSMARTLogPage is the SMART / Health Information Extended log page
+	// of the OCP Datacenter NVMe SSD specification, also implemented by
+	// the hyperscale SSDs of WDC
+	OCPSMARTLogPage = 0xc0
+	// OCPSMARTLogSize is the size of the OCP SMART log page
+	OCPSMARTLogSize = 512
+	// IntelSMARTLogPage is the additional SMART attributes log page of
+	// Intel SSDs
+	IntelSMARTLogPage = 0xca
+	// IntelSMARTLogSize is the size read of the Intel SMART log page
+	IntelSMARTLogSize = 512
+)
+
+var (
+	// nvmeLogLineRegex matches a line of the hex dump of an NVMe log page
+	// printed by 'smartctl -l nvmelog,<page>,<size>', e.g.
+	// 0000: 00 00 10 00 00 00 00 00 00 00 00 00 00 00 00 00  ................
+	nvmeLogLineRegex = regexp.MustCompile(`^\s*([0-9a-fA-F]+):((?:\s+[0-9a-fA-F]{2}){1,16})`)
+	// ocpSMARTLogGUID identifies the OCP SMART log page, it is stored at
+	// the end of the page in little endian
+	ocpSMARTLogGUID = []byte{0xc5, 0xaf, 0x10, 0x28, 0xea, 0xbf, 0xf2, 0xa4, 0x9c, 0x4f, 0x6f, 0x7c, 0xc9, 0x14, 0xd5, 0xaf}
+)
+
+// ParseNVMeLog reads the bytes of an NVMe log page from the hex dump printed
+// by 'smartctl -l nvmelog,<page>,<size>', or returns nil if the output does
+// not contain the log page, e.g. because the device does not support it
+func ParseNVMeLog(output []byte) []byte {
+	var data []byte
+	eachLine(output, func(line string) {
+		match := nvmeLogLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			return
+		}
+		offset, err := strconv.ParseUint(match[1], 16, 32)
+		if err != nil || int(offset) != len(data) {
+			return
+		}
+		for _, field := range strings.Fields(match[2]) {
+			b, _ := strconv.ParseUint(field, 16, 8)
+			data = append(data, byte(b))
+		}
+	})
+	return data
+}
+
+// ocpSMARTLogFields are the little endian fields of the OCP SMART log page,
+// the 128-bit fields are read as floats
+var ocpSMARTLogFields = []struct {
+	name   string
+	offset int
+	size   int
+}{
+	{"physical_media_units_written", 0, 16},
+	{"physical_media_units_read", 16, 16},
+	{"bad_user_nand_blocks_normalized", 32, 2},
+	{"bad_user_nand_blocks", 34, 6},
+	{"bad_system_nand_blocks_normalized", 40, 2},
+	{"bad_system_nand_blocks", 42, 6},
+	{"xor_recovery_count", 48, 8},
+	{"uncorrectable_read_error_count", 56, 8},
+	{"soft_ecc_error_count", 64, 8},
+	{"end_to_end_detected_errors", 72, 4},
+	{"end_to_end_corrected_errors", 76, 4},
+	{"system_data_percent_used", 80, 1},
+	{"refresh_count", 81, 7},
+	{"max_user_data_erase_count", 88, 4},
+	{"min_user_data_erase_count", 92, 4},
+	{"thermal_throttling_count", 96, 1},
+	{"thermal_throttling_status", 97, 1},
+	{"pcie_correctable_error_count", 104, 8},
+	{"incomplete_shutdowns", 112, 4},
+	{"percent_free_blocks", 120, 1},
+	{"capacitor_health", 128, 2},
+}
+
+// ParseOCPSMARTLog parses the OCP SMART / Health Information Extended log
+// page, with the media wear and thermal throttling counters missing from
+// the standard health log.  Returns an error if the page is not an OCP
+// SMART log page.
+func ParseOCPSMARTLog(data []byte) ([]Attribute, error) {
+	if len(data) < OCPSMARTLogSize || !bytes.Equal(data[496:512], ocpSMARTLogGUID) {
+		return nil, errors.New("unable to find the OCP SMART log page GUID")
+	}
+	attrs := make([]Attribute, 0, len(ocpSMARTLogFields))
+	for _, field := range ocpSMARTLogFields {
+		value := littleEndian(data[field.offset : field.offset+field.size])
+		attrs = append(attrs, Attribute{
+			Name:      field.name,
+			Raw:       value,
+			RawString: strconv.FormatFloat(value, 'f', -1, 64),
+		})
+	}
+	return attrs, nil
+}
+
+// intelSMARTLogNames name the attributes of the Intel SMART log page by key
+var intelSMARTLogNames = map[byte]string{
+	0xab: "program_fail_count",
+	0xac: "erase_fail_count",
+	0xad: "wear_leveling",
+	0xb8: "end_to_end_error_detection_count",
+	0xc7: "crc_error_count",
+	0xe2: "timed_workload_media_wear",
+	0xe3: "timed_workload_host_reads",
+	0xe4: "timed_workload_timer",
+	0xea: "thermal_throttle",
+	0xf0: "retry_buffer_overflow_count",
+	0xf3: "pll_lock_loss_count",
+	0xf4: "nand_bytes_written",
+	0xf5: "host_bytes_written",
+}
+
+// intelSMARTLogEntrySize is the size of an attribute of the Intel SMART log
+// page, the key, 2 reserved bytes, the normalized value, a reserved byte,
+// the 6 byte raw value and a reserved byte
+const intelSMARTLogEntrySize = 12
+
+// ParseIntelSMARTLog parses the additional SMART attributes log page of
+// Intel SSDs.  The raw value of the wear leveling attribute holds the min,
+// max and average erase counts and the one of the thermal throttle
+// attribute holds the throttling percentage and count, which are reported
+// as separate attributes.
+func ParseIntelSMARTLog(data []byte) []Attribute {
+	attrs := []Attribute{}
+	for offset := 0; offset+intelSMARTLogEntrySize <= len(data); offset += intelSMARTLogEntrySize {
+		entry := data[offset : offset+intelSMARTLogEntrySize]
+		if entry[0] == 0 {
+			break
+		}
+		name, ok := intelSMARTLogNames[entry[0]]
+		if !ok {
+			continue
+		}
+		normalized := float64(entry[3])
+		raw := entry[5:11]
+		switch entry[0] {
+		case 0xad:
+			attrs = append(attrs,
+				intelAttribute(entry[0], name+"_min", normalized, littleEndian(raw[0:2])),
+				intelAttribute(entry[0], name+"_max", normalized, littleEndian(raw[2:4])),
+				intelAttribute(entry[0], name+"_avg", normalized, littleEndian(raw[4:6])))
+		case 0xea:
+			attrs = append(attrs,
+				intelAttribute(entry[0], name+"_percent", normalized, littleEndian(raw[0:1])),
+				intelAttribute(entry[0], name+"_count", normalized, littleEndian(raw[1:5])))
+		default:
+			attrs = append(attrs, intelAttribute(entry[0], name, normalized, littleEndian(raw)))
+		}
+	}
+	return attrs
+}
+
+func intelAttribute(key byte, name string, normalized float64, raw float64) Attribute {
+	return Attribute{
+		ID:        int(key),
+		Name:      name,
+		Value:     normalized,
+		Raw:       raw,
+		RawString: strconv.FormatFloat(raw, 'f', -1, 64),
+	}
+}
+
+// littleEndian reads an unsigned little endian integer of up to 16 bytes,
+// values above 2^53 lose precision
+func littleEndian(b []byte) float64 {
+	if len(b) <= 8 {
+		padded := make([]byte, 8)
+		copy(padded, b)
+		return float64(binary.LittleEndian.Uint64(padded))
+	}
+	return littleEndian(b[8:])*math.Pow(2, 64) + littleEndian(b[:8])
+}
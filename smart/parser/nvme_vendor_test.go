@@ -0,0 +1,98 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hexDump formats the data like 'smartctl -l nvmelog' prints it
+func hexDump(page int, data []byte) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "NVMe Log 0x%02x (0x%04x bytes)\n", page, len(data))
+	for offset := 0; offset < len(data); offset += 16 {
+		fmt.Fprintf(&b, "%04x:", offset)
+		for _, c := range data[offset : offset+16] {
+			fmt.Fprintf(&b, " %02x", c)
+		}
+		b.WriteString("  ................\n")
+	}
+	return []byte(b.String())
+}
+
+func TestParseNVMeLog(t *testing.T) {
+	data := make([]byte, 32)
+	data[0], data[31] = 0x1b, 0xff
+	parsed := ParseNVMeLog(hexDump(0xc0, data))
+	if len(parsed) != 32 || parsed[0] != 0x1b || parsed[31] != 0xff {
+		t.Fatal("unexpected log page", parsed)
+	}
+	if parsed := ParseNVMeLog([]byte("Read NVMe Log 0xc0 failed: Invalid Field in Command\n")); parsed != nil {
+		t.Fatal("unexpected log page", parsed)
+	}
+}
+
+func TestParseOCPSMARTLog(t *testing.T) {
+	data := make([]byte, OCPSMARTLogSize)
+	data[0], data[1] = 0x00, 0x10 // 4096 physical media units written
+	data[34] = 3                  // bad user NAND blocks
+	data[96], data[97] = 7, 1     // thermal throttling count and status
+	copy(data[496:], ocpSMARTLogGUID)
+
+	attrs, err := ParseOCPSMARTLog(ParseNVMeLog(hexDump(OCPSMARTLogPage, data)))
+	if err != nil {
+		t.Fatal("unable to parse OCP SMART log", err)
+	}
+	values := map[string]float64{}
+	for _, attr := range attrs {
+		values[attr.Name] = attr.Raw
+	}
+	if values["physical_media_units_written"] != 4096 || values["bad_user_nand_blocks"] != 3 ||
+		values["thermal_throttling_count"] != 7 || values["thermal_throttling_status"] != 1 {
+		t.Fatal("unexpected attributes", values)
+	}
+
+	if _, err := ParseOCPSMARTLog(make([]byte, OCPSMARTLogSize)); err == nil {
+		t.Fatal("expected an error parsing a page without the OCP GUID")
+	}
+}
+
+func TestParseIntelSMARTLog(t *testing.T) {
+	data := make([]byte, IntelSMARTLogSize)
+	copy(data[0:], []byte{0xab, 0, 0, 100, 0, 2, 0, 0, 0, 0, 0, 0})
+	copy(data[12:], []byte{0xad, 0, 0, 98, 0, 10, 0, 30, 0, 20, 0, 0})
+	copy(data[24:], []byte{0xea, 0, 0, 100, 0, 5, 9, 0, 0, 0, 0, 0})
+
+	values := map[string]float64{}
+	for _, attr := range ParseIntelSMARTLog(data) {
+		values[attr.Name] = attr.Raw
+	}
+	expected := map[string]float64{
+		"program_fail_count":       2,
+		"wear_leveling_min":        10,
+		"wear_leveling_max":        30,
+		"wear_leveling_avg":        20,
+		"thermal_throttle_percent": 5,
+		"thermal_throttle_count":   9,
+	}
+	if len(values) != len(expected) {
+		t.Fatal("unexpected attributes", values)
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Fatal("unexpected value of", name, values[name])
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parser converts the output of the smartctl command into structs.
+// The functions in this package are pure, they never run smartctl
+// themselves, so they can be tested and fuzzed against recorded output.
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Device represents a SMART capable device as reported by 'smartctl --scan'
+type Device struct {
+	Name     string `json:"name"`
+	InfoName string `json:"info_name"`
+	Type     string `json:"type"`
+	Protocol string `json:"protocol"`
+}
+
+// DeviceStatus contains the status reported by the -H option
+type DeviceStatus struct {
+	Passed  bool
+	Details map[string]string
+}
+
+// DeviceInfo contains info reported by the -i option
+// "model_name": "SAMSUNG MZVLB512HAJQ-000L7",
+// "serial_number": "S3TNNX1K710265",
+// "firmware_version": "4L2QEXA7",
+// "nvme_pci_vendor": {
+//   "id": 5197,
+//   "subsystem_id": 5197
+// },
+// "nvme_ieee_oui_identifier": 9528,
+// "nvme_total_capacity": 512110190592,
+// "nvme_unallocated_capacity": 0,
+// "nvme_controller_id": 4,
+// "nvme_number_of_namespaces": 1,
+// "nvme_namespaces": [
+//   {
+// 	"id": 1,
+// 	"size": {
+// 	  "blocks": 1000215216,
+// 	  "bytes": 512110190592
+// 	},
+// 	"capacity": {
+// 	  "blocks": 1000215216,
+// 	  "bytes": 512110190592
+// 	},
+// 	"utilization": {
+// 	  "blocks": 251500984,
+// 	  "bytes": 128768503808
+// 	},
+// 	"formatted_lba_size": 512,
+// 	"eui64": {
+// 	  "oui": 9528,
+// 	  "ext_id": 581996836738
+// 	}
+//   }
+// ],
+// "user_capacity": {
+//   "blocks": 1000215216,
+//   "bytes": 512110190592
+// },
+// "logical_block_size": 512,
+// "local_time": {
+//   "time_t": 1566314980,
+//   "asctime": "Tue Aug 20 10:29:40 2019 CDT"
+// }
+type DeviceInfo struct {
+	Available  bool              `json:"available"`
+	Enabled    bool              `json:"enabled"`
+	Healthy    bool              `json:"healthy"`
+	Attributes map[string]string `json:"attributes"`
+	// Failed is true if the device reported failing its health
+	// self-assessment, Healthy is also false if it reported none
+	Failed bool `json:"failed,omitempty"`
+	// Unsupported is true if the device reports that it lacks SMART
+	// capability, e.g. a virtual disk or a card reader
+	Unsupported bool `json:"unsupported,omitempty"`
+	// Namespaces are the namespaces of an NVMe controller
+	Namespaces []Namespace `json:"namespaces,omitempty"`
+	// InterfaceSpeed is the speed negotiated by the link of the device in
+	// bits per second, MaxInterfaceSpeed the maximum speed supported by the
+	// device, 0 if unknown
+	InterfaceSpeed    float64 `json:"interface_speed,omitempty"`
+	MaxInterfaceSpeed float64 `json:"max_interface_speed,omitempty"`
+	// CapacityBytes is the user capacity of the device, LogicalBlockSize
+	// and PhysicalBlockSize the sizes of its blocks in bytes, 0 if unknown
+	CapacityBytes     float64 `json:"capacity_bytes,omitempty"`
+	LogicalBlockSize  float64 `json:"logical_block_size,omitempty"`
+	PhysicalBlockSize float64 `json:"physical_block_size,omitempty"`
+}
+
+// Namespace is an NVMe namespace as reported by the -i option.  The sizes
+// which are not reported, e.g. the capacity in the text output, are 0.
+type Namespace struct {
+	ID               int     `json:"id"`
+	SizeBytes        float64 `json:"size_bytes"`
+	CapacityBytes    float64 `json:"capacity_bytes"`
+	UtilizationBytes float64 `json:"utilization_bytes"`
+	FormattedLBASize float64 `json:"formatted_lba_size"`
+}
+
+// Attribute is a single SMART attribute as reported by the -A option.
+// ATA devices report the normalized Value, Worst and Threshold along with
+// the vendor specific raw value, other devices (e.g. NVMe) only report
+// a Name and the raw value.
+type Attribute struct {
+	ID         int     `json:"id,omitempty"`
+	Name       string  `json:"name"`
+	Flags      string  `json:"flags,omitempty"`
+	Value      float64 `json:"value,omitempty"`
+	Worst      float64 `json:"worst,omitempty"`
+	Threshold  float64 `json:"threshold,omitempty"`
+	WhenFailed string  `json:"when_failed,omitempty"`
+	// Raw is the numeric interpretation of RawString, or 0 if the raw
+	// value is not numeric
+	Raw       float64 `json:"raw"`
+	RawString string  `json:"raw_string"`
+}
+
+// SelfTest is an entry of the self-test log as reported by -l selftest
+type SelfTest struct {
+	Num           int
+	Description   string
+	Status        string
+	Passed        bool
+	Remaining     int
+	LifetimeHours int
+	// FirstErrorLBA is the LBA of the first error, or "-" if there was none
+	FirstErrorLBA string
+}
+
+// Capabilities are the General SMART Values of an ATA device as reported by
+// 'smartctl -c'
+type Capabilities struct {
+	// OfflineStatus is the offline data collection status byte, the status
+	// in bits 0-6 and bit 7 set if the automatic offline collection is
+	// enabled
+	OfflineStatus int
+	// OfflineSeconds is the time to complete the offline data collection
+	OfflineSeconds int
+	// SelfTestStatus is the self-test execution status byte, the status in
+	// the high nibble and the remaining tenths of a test in progress in the
+	// low nibble
+	SelfTestStatus int
+	// PollingMinutes are the recommended polling times of the self-tests by
+	// test, e.g. short, extended and conveyance
+	PollingMinutes map[string]int
+}
+
+// selfTestInProgress is the self-test execution status of a test in progress
+const selfTestInProgress = 15
+
+// SelfTestInProgress returns true if a self-test is in progress
+func (c *Capabilities) SelfTestInProgress() bool {
+	return c.SelfTestStatus>>4 == selfTestInProgress
+}
+
+// SelfTestRemainingPercent returns the percentage remaining of the
+// self-test in progress, 0 if none is in progress
+func (c *Capabilities) SelfTestRemainingPercent() int {
+	if !c.SelfTestInProgress() {
+		return 0
+	}
+	return (c.SelfTestStatus & 0x0f) * 10
+}
+
+// Security is the ATA Security status of a device as reported by
+// 'smartctl -g security', and the support of the security commands of the
+// self-encrypting drives as reported by 'smartctl -i'
+type Security struct {
+	// Status is the ATA Security status, e.g. "Disabled, frozen [SEC2]",
+	// empty if not reported
+	Status  string
+	Enabled bool
+	Locked  bool
+	Frozen  bool
+	// SecurityCommands is true if an NVMe device supports the Security
+	// Send and Receive commands of the TCG Opal self-encrypting drives
+	SecurityCommands bool
+}
+
+// PowerMode is the power state of a device as reported by 'smartctl -n'
+type PowerMode string
+
+// The power modes distinguished by smartctl.  Devices which cannot tell
+// ACTIVE from IDLE are reported as active.
+const (
+	PowerModeActive  PowerMode = "active"
+	PowerModeIdle    PowerMode = "idle"
+	PowerModeStandby PowerMode = "standby"
+	PowerModeSleep   PowerMode = "sleep"
+	PowerModeUnknown PowerMode = "unknown"
+)
+
+// PowerModes lists all the power modes
+var PowerModes = []PowerMode{PowerModeActive, PowerModeIdle, PowerModeStandby, PowerModeSleep, PowerModeUnknown}
+
+// SmartctlJSONMeta contains metadata included with the JSON output
+// of the smartctl command
+//   "smartctl": {
+//     "version": [
+//       7,
+//       0
+//     ],
+//     "svn_revision": "4903",
+//     "platform_info": "x86_64-linux-5.2.7-200.fc30.x86_64",
+//     "build_info": "(local build)",
+//     "argv": [
+//       "smartctl",
+//       "--scan",
+//       "-j"
+//     ],
+//     "messages": [
+//       {
+//         "string": "Read SMART Data failed: scsi error badly formed scsi parameters",
+//         "severity": "error"
+//       }
+//     ],
+//     "exit_status": 0
+//   },
+type SmartctlJSONMeta struct {
+	Smartctl struct {
+		Version      []int     `json:"version"`
+		SvnRevision  string    `json:"svn_revision"`
+		PlatformInfo string    `json:"platform_info"`
+		BuildInfo    string    `json:"build_info"`
+		Argv         []string  `json:"argv"`
+		ExitStatus   int       `json:"exit_status"`
+		Messages     []Message `json:"messages"`
+		// Output is the text output embedded with --json=o
+		Output []string `json:"output"`
+	} `json:"smartctl"`
+}
+
+// Message is a message printed by smartctl, included with its JSON output
+// e.g. {"string": "Read SMART Data failed", "severity": "error"}
+type Message struct {
+	String string `json:"string"`
+	// Severity is "information", "warning" or "error"
+	Severity string `json:"severity"`
+}
+
+// Version formats the version of smartctl, e.g. "7.0"
+func (m *SmartctlJSONMeta) Version() string {
+	parts := make([]string, 0, len(m.Smartctl.Version))
+	for _, v := range m.Smartctl.Version {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ".")
+}
+
+// NormalizeName formats an attribute name reported by smartctl, e.g.
+// "Model Family" or "Data Units Read", as a lower case identifier
+func NormalizeName(name string) string {
+	// a single pass over the name, the names are normalized for every
+	// attribute of every device on each scrape
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '/', '-', '.':
+			return '_'
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// namespaceCapacity derives the capacity and the block size of an NVMe
+// device which does not report them from its namespaces
+func (info *DeviceInfo) namespaceCapacity() {
+	if info.CapacityBytes == 0 {
+		for _, ns := range info.Namespaces {
+			info.CapacityBytes += ns.SizeBytes
+		}
+	}
+	if info.LogicalBlockSize == 0 && len(info.Namespaces) > 0 {
+		info.LogicalBlockSize = info.Namespaces[0].FormattedLBASize
+	}
+}
+
+// passed marks the device as healthy.  A passing health check implies
+// SMART is both available and enabled on the device.
+func (info *DeviceInfo) passed() {
+	info.Available = true
+	info.Enabled = true
+	info.Healthy = true
+}
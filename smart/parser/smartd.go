@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smartdTimeFormat is the format of the timestamp starting each line of
+// the smartd attribute log
+const smartdTimeFormat = "2006-01-02 15:04:05"
+
+// ParseSmartdAttrLog parses a line of an attribute log written by
+// 'smartd -A', e.g. for an ATA device
+//
+//	2020-01-28 20:09:45;	1;117;153427856;	194;36;36;
+//
+// or for a SCSI device
+//
+//	2020-01-28 20:09:45;	read-total-err-corrected;0;	temperature;35;
+//
+// ATA attributes have an ID, a normalized and a raw value, the attributes
+// of other devices only have a name and a raw value.  The timestamp is in
+// the local time of the host running smartd.
+func ParseSmartdAttrLog(line string) (time.Time, []Attribute, error) {
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	logged, err := time.ParseInLocation(smartdTimeFormat, strings.TrimSuffix(strings.TrimSpace(fields[0]), ";"), time.Local)
+	if err != nil {
+		return time.Time{}, nil, errors.New("unable to parse smartd log timestamp: " + err.Error())
+	}
+	attrs := []Attribute{}
+	for _, field := range fields[1:] {
+		values := strings.Split(strings.TrimSuffix(strings.TrimSpace(field), ";"), ";")
+		if id, err := strconv.Atoi(values[0]); err == nil && len(values) == 3 {
+			attr := Attribute{ID: id, RawString: values[2], Raw: parseRawValue(values[2])}
+			if attr.Value, err = strconv.ParseFloat(values[1], 64); err != nil {
+				return time.Time{}, nil, errors.New("unable to parse smartd log attribute: " + field)
+			}
+			attrs = append(attrs, attr)
+		} else if len(values) == 2 {
+			attrs = append(attrs, Attribute{Name: values[0], RawString: values[1], Raw: parseRawValue(values[1])})
+		} else {
+			return time.Time{}, nil, errors.New("unable to parse smartd log attribute: " + field)
+		}
+	}
+	return logged, attrs, nil
+}
@@ -0,0 +1,39 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import "testing"
+
+func TestParseSmartdAttrLog(t *testing.T) {
+	logged, attrs, err := ParseSmartdAttrLog("2020-01-28 20:09:45;\t1;117;153427856;\t194;36;36;\n")
+	if err != nil {
+		t.Fatal("unable to parse smartd log", err)
+	}
+	if logged.Year() != 2020 || logged.Hour() != 20 {
+		t.Fatal("unexpected timestamp", logged)
+	}
+	if len(attrs) != 2 || attrs[0].ID != 1 || attrs[0].Value != 117 || attrs[0].Raw != 153427856 {
+		t.Fatal("unexpected attributes", attrs)
+	}
+
+	_, attrs, err = ParseSmartdAttrLog("2020-01-28 20:09:45;\tread-total-err-corrected;0;\ttemperature;35;")
+	if err != nil {
+		t.Fatal("unable to parse smartd log", err)
+	}
+	if len(attrs) != 2 || attrs[1].Name != "temperature" || attrs[1].Raw != 35 {
+		t.Fatal("unexpected attributes", attrs)
+	}
+
+	if _, _, err := ParseSmartdAttrLog("not a log line"); err == nil {
+		t.Fatal("expected an error parsing an invalid line")
+	}
+}
@@ -0,0 +1,615 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// selfTestRegex matches an entry of the ATA self-test log, e.g.
+	// # 1  Short offline       Completed without error       00%     21089         -
+	selfTestRegex = regexp.MustCompile(`^#\s*(\d+)\s+(\S.*?)\s{2,}(\S.*?)\s+(\d+)%\s+(\d+)\s+(\S+)\s*$`)
+	// powerModeRegex matches the power mode reported with the -n option, e.g.
+	// "Device is in STANDBY mode, exit(2)" or "Power mode is:    ACTIVE or IDLE"
+	powerModeRegex = regexp.MustCompile(`(?:Device is in|Power mode (?:is|was):)\s+(\S+)`)
+	// selfTestWaitRegex matches the duration of a self-test started with the
+	// -t option, e.g. "Please wait 2 minutes for test to complete."
+	selfTestWaitRegex = regexp.MustCompile(`Please wait (\d+) (minutes|seconds) for test to complete`)
+	// ataErrorCountRegex matches the number of errors of the ATA error log,
+	// e.g. "ATA Error Count: 5 (device log contains only the most recent five errors)"
+	ataErrorCountRegex = regexp.MustCompile(`ATA Error Count:\s+(\d+)`)
+	// nvmeErrorRegex matches an entry of the NVMe error information log, e.g.
+	//   0       1294     0  0x0008  0x4004      -            0     0     -
+	nvmeErrorRegex = regexp.MustCompile(`^\s*\d+\s+(\d+)\s+\d+\s+0x`)
+)
+
+// ParseVersion reads the smartctl version from the output of 'smartctl -V', e.g.
+// smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7-200.fc30.x86_64] (local build)
+func ParseVersion(output []byte) (string, error) {
+	line, err := firstLine(output)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "smartctl" {
+		return "", errors.New("unable to parse smartctl version: " + line)
+	}
+	return fields[1], nil
+}
+
+// ParseRevision reads the svn revision of smartctl from the output of
+// 'smartctl -V', e.g. "4883" for r4883, or returns "" if it is missing
+func ParseRevision(output []byte) string {
+	line, err := firstLine(output)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "smartctl" || !strings.HasPrefix(fields[3], "r") {
+		return ""
+	}
+	return strings.TrimPrefix(fields[3], "r")
+}
+
+// ParseScan parses the list of devices reported by 'smartctl --scan', e.g.
+// /dev/sda -d sat # /dev/sda [SAT], ATA device
+// /dev/bus/0 -d megaraid,8 # /dev/bus/0 [megaraid_disk_08], NVMe device
+func ParseScan(output []byte) ([]Device, error) {
+	devices := make([]Device, 0, bytes.Count(output, []byte("\n"))+1)
+	var err error
+	eachLine(output, func(line string) {
+		if err != nil || strings.TrimSpace(line) == "" {
+			return
+		}
+		device, ok := parseScanLine(line)
+		if !ok {
+			err = errors.New("Unable to parse device line: " + line)
+			return
+		}
+		devices = append(devices, device)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// parseScanLine parses a device line of 'smartctl --scan' without the cost
+// of matching a regular expression for every device of large enclosures
+func parseScanLine(line string) (Device, bool) {
+	hash := strings.Index(line, " # ")
+	if hash < 0 {
+		return Device{}, false
+	}
+	head, comment := line[:hash], line[hash+3:]
+	option := strings.LastIndex(head, " -d ")
+	if option < 2 || head[0] != '/' {
+		return Device{}, false
+	}
+	name, devType := head[:option], head[option+4:]
+	if devType == "" || strings.IndexFunc(devType, func(r rune) bool {
+		return !(r == '_' || r == ',' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) >= 0 {
+		return Device{}, false
+	}
+	comma := strings.LastIndex(comment, ", ")
+	if comma < 1 || comma+2 == len(comment) {
+		return Device{}, false
+	}
+	return Device{
+		Name:     name,
+		Type:     devType,
+		InfoName: comment[:comma],
+		Protocol: comment[comma+2:],
+	}, true
+}
+
+// eachLine calls fn for every line of the output, the output is only
+// converted to a string once
+func eachLine(output []byte, fn func(line string)) {
+	text := string(output)
+	for text != "" {
+		end := strings.IndexByte(text, '\n')
+		if end < 0 {
+			fn(text)
+			return
+		}
+		fn(text[:end])
+		text = text[end+1:]
+	}
+}
+
+// firstLine reads the first line from a string
+func firstLine(text []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	if !scanner.Scan() {
+		return "", errors.New("unable to read first line")
+	}
+	return scanner.Text(), nil
+}
+
+// attributesHeaders start the section of the attributes reported by -A,
+// of the ATA and of the NVMe devices
+var attributesHeaders = [][]byte{
+	[]byte("SMART Attributes Data Structure"),
+	[]byte("SMART/Health Information"),
+}
+
+// splitAttributes splits the output of smartctl at the section of the
+// attributes, so the output of a single 'smartctl -i -H -A' command can be
+// parsed as the output of -i -H and of -A.  The attributes are nil if the
+// output does not contain them.
+func splitAttributes(output []byte) ([]byte, []byte) {
+	for _, header := range attributesHeaders {
+		index := bytes.Index(output, header)
+		if index >= 0 && (index == 0 || output[index-1] == '\n') {
+			return output[:index], output[index:]
+		}
+	}
+	return output, nil
+}
+
+// ParseInfo parses the info and health reported by 'smartctl -i -H'.
+// Returns an error if the output does not contain any information.
+func ParseInfo(output []byte) (*DeviceInfo, error) {
+	info := DeviceInfo{
+		Attributes: make(map[string]string, 32),
+	}
+	output, _ = splitAttributes(output)
+	eachLine(output, func(line string) {
+		// the name ends at the first colon, which is followed by the value
+		colon := strings.IndexByte(line, ':')
+		if colon < 1 || len(line) < colon+3 || line[colon+1] != ' ' {
+			return
+		}
+		name, val := line[:colon], line[colon+2:]
+		info.Attributes[NormalizeName(name)] = strings.TrimSpace(val)
+		if strings.HasPrefix(name, "SMART support is") {
+			// the SCSI output aligns the values with spaces
+			val = strings.TrimSpace(val)
+			switch {
+			case strings.HasPrefix(val, "Available"):
+				info.Available = true
+			case strings.HasPrefix(val, "Unavailable"):
+				info.Unsupported = true
+			case strings.HasPrefix(val, "Enabled"):
+				info.Enabled = true
+			}
+		} else if strings.HasPrefix(name, "SMART Health Status") {
+			if strings.HasPrefix(val, "OK") {
+				info.passed()
+			} else {
+				info.Failed = true
+			}
+		} else if strings.HasPrefix(name, "SMART overall-health self-assessment test result") {
+			if strings.HasPrefix(val, "PASSED") {
+				info.passed()
+			} else {
+				info.Failed = true
+			}
+		} else if strings.HasPrefix(name, "Namespace ") {
+			info.parseNamespace(name, val)
+		} else if name == "SATA Version is" {
+			info.parseSATAVersion(val)
+		} else if name == "User Capacity" || name == "Total NVM Capacity" {
+			info.CapacityBytes = parseRawValue(val)
+		} else if name == "Sector Size" || name == "Sector Sizes" {
+			info.parseSectorSizes(val)
+		} else if name == "Logical block size" {
+			info.LogicalBlockSize = parseRawValue(val)
+		} else if name == "Physical block size" {
+			info.PhysicalBlockSize = parseRawValue(val)
+		}
+	})
+	if len(info.Attributes) == 0 {
+		return nil, errors.New("unable to find device info in smartctl output")
+	}
+	info.namespaceCapacity()
+	return &info, nil
+}
+
+// parseNamespace parses an NVMe namespace line of 'smartctl -i', e.g.
+//
+//	Namespace 1 Size/Capacity:          512,110,190,592 [512 GB]
+//	Namespace 1 Utilization:            128,768,503,808 [128 GB]
+//	Namespace 1 Formatted LBA Size:     512
+func (info *DeviceInfo) parseNamespace(name, val string) {
+	fields := strings.SplitN(name, " ", 3)
+	if len(fields) != 3 {
+		return
+	}
+	id, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return
+	}
+	var ns *Namespace
+	for i := range info.Namespaces {
+		if info.Namespaces[i].ID == id {
+			ns = &info.Namespaces[i]
+		}
+	}
+	if ns == nil {
+		info.Namespaces = append(info.Namespaces, Namespace{ID: id})
+		ns = &info.Namespaces[len(info.Namespaces)-1]
+	}
+	value := parseRawValue(val)
+	switch fields[2] {
+	case "Size/Capacity":
+		ns.SizeBytes = value
+		ns.CapacityBytes = value
+	case "Size":
+		ns.SizeBytes = value
+	case "Capacity":
+		ns.CapacityBytes = value
+	case "Utilization":
+		ns.UtilizationBytes = value
+	case "Formatted LBA Size":
+		ns.FormattedLBASize = value
+	}
+}
+
+// parseSectorSizes parses the sector sizes of an ATA device reported by
+// 'smartctl -i', e.g.
+//
+//	Sector Size:      512 bytes logical/physical
+//	Sector Sizes:     512 bytes logical, 4096 bytes physical
+func (info *DeviceInfo) parseSectorSizes(val string) {
+	for _, size := range strings.Split(val, ",") {
+		value := parseRawValue(size)
+		if strings.Contains(size, "logical") {
+			info.LogicalBlockSize = value
+		}
+		if strings.Contains(size, "physical") {
+			info.PhysicalBlockSize = value
+		}
+	}
+}
+
+// parseSATAVersion parses the link speeds of the SATA version line of
+// 'smartctl -i', e.g.
+//
+//	SATA Version is:  SATA 3.1, 6.0 Gb/s (current: 1.5 Gb/s)
+//
+// The current speed is only reported if it differs from the maximum speed
+// on older versions of smartctl.
+func (info *DeviceInfo) parseSATAVersion(val string) {
+	comma := strings.IndexByte(val, ',')
+	if comma < 0 {
+		return
+	}
+	maxSpeed, speed := val[comma+1:], ""
+	if i := strings.Index(maxSpeed, "(current:"); i >= 0 {
+		maxSpeed, speed = maxSpeed[:i], strings.TrimSuffix(maxSpeed[i+len("(current:"):], ")")
+	}
+	maxSpeed, speed = strings.TrimSpace(maxSpeed), strings.TrimSpace(speed)
+	if speed == "" {
+		speed = maxSpeed
+	}
+	info.MaxInterfaceSpeed = parseLinkSpeed(maxSpeed)
+	info.InterfaceSpeed = parseLinkSpeed(speed)
+	info.Attributes["interface_speed"] = speed
+}
+
+// parseLinkSpeed parses a link speed, e.g. "6.0 Gb/s", in bits per second
+func parseLinkSpeed(speed string) float64 {
+	fields := strings.Fields(speed)
+	if len(fields) != 2 {
+		return 0
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	switch fields[1] {
+	case "Gb/s":
+		return value * 1e9
+	case "Mb/s":
+		return value * 1e6
+	}
+	return 0
+}
+
+// ParseATAAttributes parses the attribute table of an ATA device, e.g.
+//
+//	ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+//	  1 Raw_Read_Error_Rate     0x000f   117   099   006    Pre-fail  Always       -       153427856
+//
+// Returns an error if the output does not contain the attribute table.
+func ParseATAAttributes(output []byte) ([]Attribute, error) {
+	if !bytes.Contains(output, []byte("ID# ATTRIBUTE_NAME")) {
+		return nil, errors.New("unable to find attribute table in smartctl output")
+	}
+	attrs := make([]Attribute, 0, 32)
+	eachLine(output, func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			return
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return // not an attribute row, e.g. the table header
+		}
+		attr := Attribute{
+			ID:        id,
+			Name:      fields[1],
+			Flags:     fields[2],
+			RawString: fields[9],
+		}
+		if len(fields) > 10 {
+			attr.RawString = strings.Join(fields[9:], " ")
+		}
+		if attr.Value, err = strconv.ParseFloat(fields[3], 64); err != nil {
+			return
+		}
+		if attr.Worst, err = strconv.ParseFloat(fields[4], 64); err != nil {
+			return
+		}
+		if attr.Threshold, err = strconv.ParseFloat(fields[5], 64); err != nil {
+			return
+		}
+		if fields[8] != "-" {
+			attr.WhenFailed = fields[8]
+		}
+		attr.Raw = parseRawValue(attr.RawString)
+		attrs = append(attrs, attr)
+	})
+	return attrs, nil
+}
+
+// ParseNVMeAttributes parses the health information of an NVMe device, e.g.
+// Temperature:                        35 Celsius
+// Available Spare:                    100%
+// Returns an error if the output does not contain any health information.
+func ParseNVMeAttributes(output []byte) ([]Attribute, error) {
+	attrs := make([]Attribute, 0, 32)
+	if _, section := splitAttributes(output); section != nil {
+		output = section
+	}
+	eachLine(output, func(line string) {
+		// only lines with a single colon hold a value
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 || strings.IndexByte(line[colon+1:], ':') >= 0 {
+			return
+		}
+		value := strings.TrimSpace(line[colon+1:])
+		if value == "" {
+			return
+		}
+		attrs = append(attrs, Attribute{
+			Name:      strings.TrimSpace(line[:colon]),
+			Raw:       parseRawValue(value),
+			RawString: value,
+		})
+	})
+	if len(attrs) == 0 {
+		return nil, errors.New("unable to find health information in smartctl output")
+	}
+	return attrs, nil
+}
+
+// parseRawValue reads the leading number of a raw attribute value, ignoring
+// thousands separators and units, e.g. "1,234 [632 GB]" or "100%".
+// Returns 0 if the value does not start with a number.
+func parseRawValue(raw string) float64 {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return 0
+	}
+	number := strings.TrimRight(strings.ReplaceAll(fields[0], ",", ""), "%")
+	if strings.HasPrefix(number, "0x") {
+		if value, err := strconv.ParseInt(number, 0, 64); err == nil {
+			return float64(value)
+		}
+		return 0
+	}
+	if i := strings.IndexFunc(number, func(r rune) bool { return (r < '0' || r > '9') && r != '.' }); i >= 0 {
+		number = number[:i]
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// ParsePowerMode parses the power mode reported by 'smartctl -n standby'.
+// smartctl only reports the mode of a device which was skipped or when
+// the device info is requested, an active device is reported as
+// PowerModeUnknown otherwise.
+func ParsePowerMode(output []byte) PowerMode {
+	matches := powerModeRegex.FindSubmatch(output)
+	if matches == nil {
+		return PowerModeUnknown
+	}
+	mode := string(matches[1])
+	switch {
+	case strings.HasPrefix(mode, "ACTIVE"):
+		return PowerModeActive
+	case strings.HasPrefix(mode, "IDLE"):
+		return PowerModeIdle
+	case strings.HasPrefix(mode, "STANDBY"):
+		return PowerModeStandby
+	case strings.HasPrefix(mode, "SLEEP"):
+		return PowerModeSleep
+	}
+	return PowerModeUnknown
+}
+
+// ParseSelfTests parses the entries of the ATA self-test log reported
+// by 'smartctl -l selftest'
+func ParseSelfTests(output []byte) []SelfTest {
+	tests := []SelfTest{}
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := selfTestRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		num, _ := strconv.Atoi(matches[1])
+		remaining, _ := strconv.Atoi(matches[4])
+		hours, _ := strconv.Atoi(matches[5])
+		tests = append(tests, SelfTest{
+			Num:           num,
+			Description:   matches[2],
+			Status:        matches[3],
+			Passed:        strings.HasPrefix(matches[3], "Completed without error"),
+			Remaining:     remaining,
+			LifetimeHours: hours,
+			FirstErrorLBA: matches[6],
+		})
+	}
+	return tests
+}
+
+// ParseSelfTestStart parses the estimated duration of a self-test started
+// with 'smartctl -t'.  Returns an error if smartctl did not start the test.
+func ParseSelfTestStart(output []byte) (time.Duration, error) {
+	matches := selfTestWaitRegex.FindSubmatch(output)
+	if matches == nil {
+		return 0, errors.New("unable to find self-test duration in smartctl output")
+	}
+	wait, _ := strconv.Atoi(string(matches[1]))
+	if string(matches[2]) == "seconds" {
+		return time.Duration(wait) * time.Second, nil
+	}
+	return time.Duration(wait) * time.Minute, nil
+}
+
+// ParseCapabilities parses the General SMART Values of an ATA device from the
+// output of 'smartctl -c', e.g.
+//
+//	Offline data collection status:  (0x82)	Offline data collection activity
+//						was completed without error.
+//	Self-test execution status:      ( 249)	Self-test routine in progress...
+//						90% of test remaining.
+//	Total time to complete Offline
+//	data collection: 		(  600) seconds.
+//	Short self-test routine
+//	recommended polling time: 	 (   2) minutes.
+//
+// Returns nil if the output does not contain them, e.g. of NVMe devices.
+func ParseCapabilities(output []byte) *Capabilities {
+	var caps *Capabilities
+	capabilities := func() *Capabilities {
+		if caps == nil {
+			caps = &Capabilities{PollingMinutes: map[string]int{}}
+		}
+		return caps
+	}
+	previous := ""
+	eachLine(output, func(line string) {
+		defer func() { previous = line }()
+		colon := strings.IndexByte(line, ':')
+		if colon < 1 {
+			return
+		}
+		value, ok := parenthesizedValue(line[colon+1:])
+		if !ok {
+			return
+		}
+		switch name := strings.TrimSpace(line[:colon]); {
+		case name == "Offline data collection status":
+			capabilities().OfflineStatus = value
+		case name == "Self-test execution status":
+			capabilities().SelfTestStatus = value
+		case name == "data collection" && strings.HasPrefix(previous, "Total time to complete Offline"):
+			capabilities().OfflineSeconds = value
+		case name == "recommended polling time":
+			if test := strings.Fields(previous); len(test) > 0 {
+				capabilities().PollingMinutes[strings.ToLower(test[0])] = value
+			}
+		}
+	})
+	return caps
+}
+
+// parenthesizedValue parses the value in parentheses of a line of
+// 'smartctl -c', in hexadecimal, e.g. "(0x82)", or decimal, e.g. "( 249)"
+func parenthesizedValue(val string) (int, bool) {
+	start, end := strings.IndexByte(val, '('), strings.IndexByte(val, ')')
+	if start < 0 || end < start {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(val[start+1:end]), 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(value), true
+}
+
+// ParseSecurity parses the security status of the device from the output
+// of 'smartctl -i -g security', e.g.
+//
+//	ATA Security is:  ENABLED, PW level HIGH, not locked, not frozen [SEC5]
+//	Optional Admin Commands (0x0017):   Security Format Frmw_DL Self_Test
+//
+// Returns nil if the device reports neither.
+func ParseSecurity(output []byte) *Security {
+	var security *Security
+	eachLine(output, func(line string) {
+		colon := strings.IndexByte(line, ':')
+		if colon < 1 {
+			return
+		}
+		name, val := line[:colon], strings.TrimSpace(line[colon+1:])
+		switch {
+		case name == "ATA Security is" && val != "Unavailable":
+			status := strings.ToLower(val)
+			security = &Security{
+				Status:  val,
+				Enabled: strings.HasPrefix(status, "enabled"),
+				Locked:  strings.Contains(status, "locked") && !strings.Contains(status, "not locked"),
+				Frozen:  strings.Contains(status, "frozen") && !strings.Contains(status, "not frozen"),
+			}
+		case strings.HasPrefix(name, "Optional Admin Commands"):
+			for _, command := range strings.Fields(val) {
+				if command == "Security" {
+					security = &Security{SecurityCommands: true}
+				}
+			}
+		}
+	})
+	return security
+}
+
+// ParseErrorLogCount parses the number of errors the device logged from the
+// output of 'smartctl -l error', the ATA error count or the highest error
+// count of the entries of the NVMe error information log
+func ParseErrorLogCount(output []byte) (int, error) {
+	if bytes.Contains(output, []byte("No Errors Logged")) {
+		return 0, nil
+	}
+	if matches := ataErrorCountRegex.FindSubmatch(output); matches != nil {
+		return strconv.Atoi(string(matches[1]))
+	}
+	if !bytes.Contains(output, []byte("Error Information (NVMe Log")) {
+		return 0, errors.New("unable to find the error log in smartctl output")
+	}
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := nvmeErrorRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		if n, _ := strconv.Atoi(matches[1]); n > count {
+			count = n
+		}
+	}
+	return count, nil
+}
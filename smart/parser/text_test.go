@@ -0,0 +1,370 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseATAAttributes(t *testing.T) {
+	output := []byte(`smartctl 6.6 2017-11-05 r4594 [x86_64-linux-4.19.0] (local build)
+
+=== START OF READ SMART DATA SECTION ===
+SMART Attributes Data Structure revision number: 16
+Vendor Specific SMART Attributes with Thresholds:
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  1 Raw_Read_Error_Rate     0x000f   117   099   006    Pre-fail  Always       -       153427856
+194 Temperature_Celsius     0x0022   036   045   000    Old_age   Always       -       36 (Min/Max 20/45)
+`)
+	attrs, err := ParseATAAttributes(output)
+	if err != nil {
+		t.Fatal("unable to parse attributes", err)
+	}
+	if len(attrs) != 2 {
+		t.Fatal("expected 2 attributes, found", len(attrs))
+	}
+	if attrs[0].ID != 1 || attrs[0].Name != "Raw_Read_Error_Rate" || attrs[0].Value != 117 || attrs[0].Raw != 153427856 {
+		t.Fatal("unexpected attribute", attrs[0])
+	}
+	if attrs[1].Raw != 36 || attrs[1].RawString != "36 (Min/Max 20/45)" {
+		t.Fatal("unexpected raw value", attrs[1].RawString)
+	}
+}
+
+func TestParseScan(t *testing.T) {
+	output := []byte(`/dev/sda -d sat # /dev/sda [SAT], ATA device
+/dev/bus/0 -d megaraid,8 # /dev/bus/0 [megaraid_disk_08], NVMe device
+`)
+	devices, err := ParseScan(output)
+	if err != nil {
+		t.Fatal("unable to parse scan", err)
+	}
+	if len(devices) != 2 || devices[0].Type != "sat" || devices[0].Protocol != "ATA device" {
+		t.Fatal("unexpected devices", devices)
+	}
+	if devices[1].Name != "/dev/bus/0" || devices[1].Type != "megaraid,8" || devices[1].InfoName != "/dev/bus/0 [megaraid_disk_08]" {
+		t.Fatal("unexpected megaraid device", devices[1])
+	}
+}
+
+func TestParseSelfTests(t *testing.T) {
+	output := []byte(`SMART Self-test log structure revision number 1
+Num  Test_Description    Status                  Remaining  LifeTime(hours)  LBA_of_first_error
+# 1  Short offline       Completed without error       00%     21089         -
+# 2  Extended offline    Completed: read failure       90%     21000         12345678
+`)
+	tests := ParseSelfTests(output)
+	if len(tests) != 2 {
+		t.Fatal("expected 2 self-tests, found", len(tests))
+	}
+	if !tests[0].Passed || tests[0].Description != "Short offline" || tests[0].LifetimeHours != 21089 {
+		t.Fatal("unexpected self-test", tests[0])
+	}
+	if tests[1].Passed || tests[1].Remaining != 90 || tests[1].FirstErrorLBA != "12345678" {
+		t.Fatal("unexpected self-test", tests[1])
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	output := []byte(`smartctl 6.6 2017-11-05 r4594 [x86_64-linux-4.19.0] (local build)
+
+=== START OF INFORMATION SECTION ===
+Device Model:     ST4000DM000-1F2168
+Serial Number:    Z300XXXX
+SMART support is: Available - device has SMART capability.
+SMART support is: Enabled
+
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+`)
+	info, err := ParseInfo(output)
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if !info.Available || !info.Enabled || !info.Healthy {
+		t.Fatal("device should be healthy", info)
+	}
+	if info.Attributes["device_model"] != "ST4000DM000-1F2168" {
+		t.Fatal("unexpected device model", info.Attributes["device_model"])
+	}
+}
+
+func TestParseEmptyOutput(t *testing.T) {
+	if _, err := ParseInfo([]byte{}); err == nil {
+		t.Fatal("expected an error parsing empty info")
+	}
+	if _, err := ParseATAAttributes([]byte{}); err == nil {
+		t.Fatal("expected an error parsing empty attributes")
+	}
+	if _, err := ParseNVMeAttributes([]byte{}); err == nil {
+		t.Fatal("expected an error parsing empty health information")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	version, err := ParseVersion([]byte("smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7-200.fc30.x86_64] (local build)\n"))
+	if err != nil {
+		t.Fatal("unable to parse version", err)
+	}
+	if version != "7.0" {
+		t.Fatal("unexpected version", version)
+	}
+	if _, err := ParseVersion([]byte{}); err == nil {
+		t.Fatal("expected an error parsing empty version")
+	}
+}
+
+func TestParseRevision(t *testing.T) {
+	revision := ParseRevision([]byte("smartctl 7.0 2018-12-30 r4883 [x86_64-linux-5.2.7-200.fc30.x86_64] (local build)\n"))
+	if revision != "4883" {
+		t.Fatal("unexpected revision", revision)
+	}
+	if revision := ParseRevision([]byte("smartctl 7.0\n")); revision != "" {
+		t.Fatal("unexpected revision", revision)
+	}
+}
+
+func TestParsePowerMode(t *testing.T) {
+	tests := map[string]PowerMode{
+		"Device is in STANDBY mode, exit(2)\n":   PowerModeStandby,
+		"Device is in SLEEP mode, exit(2)\n":     PowerModeSleep,
+		"Power mode is:    ACTIVE or IDLE\n":     PowerModeActive,
+		"Power mode was:   IDLE_A\n":             PowerModeIdle,
+		"=== START OF READ SMART DATA SECTION\n": PowerModeUnknown,
+	}
+	for output, expected := range tests {
+		if mode := ParsePowerMode([]byte(output)); mode != expected {
+			t.Fatal("unexpected power mode", mode, "for", output)
+		}
+	}
+}
+
+func TestParseSelfTestStart(t *testing.T) {
+	output := []byte(`=== START OF OFFLINE IMMEDIATE AND SELF-TEST SECTION ===
+Sending command: "Execute SMART Short self-test routine immediately in off-line mode".
+Drive command "Execute SMART Short self-test routine immediately in off-line mode" successful.
+Testing has begun.
+Please wait 2 minutes for test to complete.
+Test will complete after Mon Jan 27 12:02:00 2020
+`)
+	wait, err := ParseSelfTestStart(output)
+	if err != nil {
+		t.Fatal("unable to parse self-test start", err)
+	}
+	if wait != 2*time.Minute {
+		t.Fatal("unexpected self-test duration", wait)
+	}
+	if _, err := ParseSelfTestStart([]byte("Can't start self-test without aborting current test")); err == nil {
+		t.Fatal("expected an error parsing a self-test which was not started")
+	}
+}
+
+func TestParseInfoNamespaces(t *testing.T) {
+	info, err := ParseInfo([]byte(`=== START OF INFORMATION SECTION ===
+Model Number:                       SAMSUNG MZVLB512HAJQ-000L7
+Namespace 1 Size/Capacity:          512,110,190,592 [512 GB]
+Namespace 1 Utilization:            128,768,503,808 [128 GB]
+Namespace 1 Formatted LBA Size:     512
+`))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	expected := Namespace{ID: 1, SizeBytes: 512110190592, CapacityBytes: 512110190592, UtilizationBytes: 128768503808, FormattedLBASize: 512}
+	if len(info.Namespaces) != 1 || info.Namespaces[0] != expected {
+		t.Fatal("unexpected namespaces", info.Namespaces)
+	}
+}
+
+func TestParseInfoInterfaceSpeed(t *testing.T) {
+	info, err := ParseInfo([]byte(`=== START OF INFORMATION SECTION ===
+Device Model:     ST4000DM000-1F2168
+Rotation Rate:    5900 rpm
+Form Factor:      3.5 inches
+SATA Version is:  SATA 3.1, 6.0 Gb/s (current: 1.5 Gb/s)
+`))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if info.MaxInterfaceSpeed != 6e9 || info.InterfaceSpeed != 1.5e9 {
+		t.Fatal("unexpected interface speeds", info.MaxInterfaceSpeed, info.InterfaceSpeed)
+	}
+	if info.Attributes["interface_speed"] != "1.5 Gb/s" || info.Attributes["rotation_rate"] != "5900 rpm" || info.Attributes["form_factor"] != "3.5 inches" {
+		t.Fatal("unexpected attributes", info.Attributes)
+	}
+}
+
+func TestParseErrorLogCount(t *testing.T) {
+	tests := map[string]int{
+		"SMART Error Log Version: 1\nNo Errors Logged\n":                                                          0,
+		"SMART Error Log Version: 1\nATA Error Count: 5 (device log contains only the most recent five errors)\n": 5,
+		`Error Information (NVMe Log 0x01, 16 of 64 entries)
+Num   ErrCount  SQId   CmdId  Status  PELoc          LBA  NSID    VS
+  0       1294     0  0x0008  0x4004      -            0     0     -
+  1       1293     0  0x0010  0x4004      -            0     0     -
+`: 1294,
+	}
+	for output, expected := range tests {
+		count, err := ParseErrorLogCount([]byte(output))
+		if err != nil {
+			t.Fatal("unable to parse error log", err)
+		}
+		if count != expected {
+			t.Fatal("unexpected error count", count, "for", output)
+		}
+	}
+	if _, err := ParseErrorLogCount([]byte("SMART Error Log not supported\n")); err == nil {
+		t.Fatal("expected an error parsing an unsupported error log")
+	}
+}
+
+func TestParseInfoAttributes(t *testing.T) {
+	// the output of 'smartctl -i -H -A' parses as the info and as the attributes
+	output := []byte(`=== START OF INFORMATION SECTION ===
+Model Number:                       SAMSUNG MZVLB512HAJQ-000L7
+Serial Number:                      S3TNNX1K710265
+
+=== START OF SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+
+SMART/Health Information (NVMe Log 0x02)
+Critical Warning:                   0x00
+Temperature:                        36 Celsius
+`)
+	info, err := ParseInfo(output)
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if !info.Healthy || info.Attributes["model_number"] != "SAMSUNG MZVLB512HAJQ-000L7" {
+		t.Fatal("unexpected info", info)
+	}
+	if _, found := info.Attributes["temperature"]; found {
+		t.Fatal("the attributes should not be part of the info", info.Attributes)
+	}
+	attrs, err := ParseNVMeAttributes(output)
+	if err != nil {
+		t.Fatal("unable to parse attributes", err)
+	}
+	if len(attrs) != 2 || attrs[0].Name != "Critical Warning" || attrs[1].Raw != 36 {
+		t.Fatal("unexpected attributes", attrs)
+	}
+}
+
+func TestParseInfoUnsupported(t *testing.T) {
+	info, err := ParseInfo([]byte(`=== START OF INFORMATION SECTION ===
+Vendor:               QEMU
+Product:              QEMU HARDDISK
+Device type:          disk
+SMART support is:     Unavailable - device lacks SMART capability.
+`))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if !info.Unsupported || info.Available {
+		t.Fatal("the device should lack SMART capability", info)
+	}
+}
+
+func TestParseInfoCapacity(t *testing.T) {
+	for output, expected := range map[string][3]float64{
+		"User Capacity:    4,000,787,030,016 bytes [4.00 TB]\nSector Sizes:     512 bytes logical, 4096 bytes physical\n":           {4000787030016, 512, 4096},
+		"User Capacity:    500,107,862,016 bytes [500 GB]\nSector Size:      512 bytes logical/physical\n":                          {500107862016, 512, 512},
+		"User Capacity:        600,127,266,816 bytes [600 GB]\nLogical block size:   512 bytes\nPhysical block size:  4096 bytes\n": {600127266816, 512, 4096},
+		"Total NVM Capacity:                 512,110,190,592 [512 GB]\nNamespace 1 Formatted LBA Size:     512\n":                   {512110190592, 512, 0},
+		"Namespace 1 Size/Capacity:          512,110,190,592 [512 GB]\nNamespace 1 Formatted LBA Size:     4096\n":                  {512110190592, 4096, 0},
+	} {
+		info, err := ParseInfo([]byte(output))
+		if err != nil {
+			t.Fatal("unable to parse info", err)
+		}
+		if info.CapacityBytes != expected[0] || info.LogicalBlockSize != expected[1] || info.PhysicalBlockSize != expected[2] {
+			t.Error("unexpected capacity of", output, info.CapacityBytes, info.LogicalBlockSize, info.PhysicalBlockSize)
+		}
+	}
+}
+
+func TestParseSecurity(t *testing.T) {
+	for output, expected := range map[string]*Security{
+		"ATA Security is:  Disabled, frozen [SEC2]\n":                               {Status: "Disabled, frozen [SEC2]", Frozen: true},
+		"ATA Security is:  Disabled, NOT FROZEN [SEC1]\n":                           {Status: "Disabled, NOT FROZEN [SEC1]"},
+		"ATA Security is:  ENABLED, PW level HIGH, not locked, not frozen [SEC5]\n": {Status: "ENABLED, PW level HIGH, not locked, not frozen [SEC5]", Enabled: true},
+		"ATA Security is:  ENABLED, PW level HIGH, **LOCKED** [SEC4]\n":             {Status: "ENABLED, PW level HIGH, **LOCKED** [SEC4]", Enabled: true, Locked: true},
+		"Optional Admin Commands (0x0017):   Security Format Frmw_DL Self_Test\n":   {SecurityCommands: true},
+	} {
+		security := ParseSecurity([]byte(output))
+		if security == nil || *security != *expected {
+			t.Errorf("unexpected security of %q: %+v", output, security)
+		}
+	}
+	for _, output := range []string{
+		"ATA Security is:  Unavailable\n",
+		"Optional Admin Commands (0x0006):   Format Frmw_DL\n",
+	} {
+		if security := ParseSecurity([]byte(output)); security != nil {
+			t.Errorf("unexpected security of %q: %+v", output, security)
+		}
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps := ParseCapabilities([]byte(`=== START OF READ SMART DATA SECTION ===
+General SMART Values:
+Offline data collection status:  (0x82)	Offline data collection activity
+					was completed without error.
+					Auto Offline Data Collection: Enabled.
+Self-test execution status:      ( 249)	Self-test routine in progress...
+					90% of test remaining.
+Total time to complete Offline 
+data collection: 		(  600) seconds.
+Offline data collection
+capabilities: 			 (0x7b) SMART execute Offline immediate.
+Short self-test routine 
+recommended polling time: 	 (   2) minutes.
+Extended self-test routine
+recommended polling time: 	 ( 255) minutes.
+Conveyance self-test routine
+recommended polling time: 	 (   5) minutes.
+`))
+	if caps == nil {
+		t.Fatal("expected the capabilities")
+	}
+	if caps.OfflineStatus != 0x82 || caps.OfflineSeconds != 600 || caps.SelfTestStatus != 249 {
+		t.Fatal("unexpected capabilities", caps)
+	}
+	if !caps.SelfTestInProgress() || caps.SelfTestRemainingPercent() != 90 {
+		t.Fatal("expected a self-test in progress with 90% remaining", caps.SelfTestRemainingPercent())
+	}
+	if caps.PollingMinutes["short"] != 2 || caps.PollingMinutes["extended"] != 255 || caps.PollingMinutes["conveyance"] != 5 {
+		t.Fatal("unexpected polling times", caps.PollingMinutes)
+	}
+	if caps := ParseCapabilities([]byte("Supported Power States\n")); caps != nil {
+		t.Fatal("unexpected capabilities", caps)
+	}
+}
+
+func TestParseInfoFailed(t *testing.T) {
+	info, err := ParseInfo([]byte("SMART overall-health self-assessment test result: FAILED!\n"))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if !info.Failed || info.Healthy {
+		t.Fatal("the device should have failed", info)
+	}
+	info, err = ParseInfo([]byte("Model Number:   SAMSUNG MZVLB512HAJQ-000L7\n"))
+	if err != nil {
+		t.Fatal("unable to parse info", err)
+	}
+	if info.Failed {
+		t.Fatal("the device reported no health", info)
+	}
+}
@@ -0,0 +1,65 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Privileged returns true if smartctl is expected to be able to access the
+// given devices: either smartctl runs through sudo or a helper, or the
+// exporter runs as root, or CAP_SYS_RAWIO / CAP_SYS_ADMIN are held by the
+// exporter or granted as file capabilities of the smartctl binary and the
+// device nodes are readable.
+func (o *Options) Privileged(devices []Device) bool {
+	if o != nil && (o.Sudo || o.HelperPath != "") {
+		return true
+	}
+	if os.Geteuid() == 0 {
+		return true
+	}
+	smartctlPath, err := exec.LookPath(o.smartctl())
+	if err != nil {
+		return false
+	}
+	if !hasCapabilities(smartctlPath) {
+		return false
+	}
+	for _, d := range devices {
+		if !readable(d.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDeviceNode returns an error if smartctl is expected to be unable to
+// open the device node, either because the node is missing, e.g. /dev is
+// not mounted from the host in a container, or because the exporter lacks
+// the permission to read it.  smartctl run through sudo or a helper does
+// not depend on the permissions of the exporter, and the replayed outputs
+// of another host do not depend on the local device nodes.
+func (o *Options) checkDeviceNode(name string) error {
+	if o != nil && (o.Sudo || o.HelperPath != "" || o.ReplayDir != "") {
+		return nil
+	}
+	if _, err := os.Stat(name); err != nil {
+		return errors.New("device node missing: " + err.Error())
+	}
+	if !readable(name) {
+		return errors.New("permission denied reading device node " + name)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package smart
+
+import (
+	"github.com/syndtr/gocapability/capability"
+	"golang.org/x/sys/unix"
+)
+
+// rawIOCapabilities are the capabilities smartctl needs to send commands
+// to the devices, either of them is sufficient
+var rawIOCapabilities = []capability.Cap{capability.CAP_SYS_RAWIO, capability.CAP_SYS_ADMIN}
+
+// hasCapabilities returns true if the exporter process holds the capabilities
+// required by smartctl, or if they are granted as file capabilities of the
+// smartctl binary
+func hasCapabilities(smartctlPath string) bool {
+	if caps, err := capability.NewPid2(0); err == nil && caps.Load() == nil {
+		for _, c := range rawIOCapabilities {
+			if caps.Get(capability.EFFECTIVE, c) {
+				return true
+			}
+		}
+	}
+	if caps, err := capability.NewFile2(smartctlPath); err == nil && caps.Load() == nil {
+		for _, c := range rawIOCapabilities {
+			if caps.Get(capability.PERMITTED, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readable returns true if the device node can be opened for reading using
+// the effective user and group ids of the exporter
+func readable(name string) bool {
+	return unix.Faccessat(unix.AT_FDCWD, name, unix.R_OK, unix.AT_EACCESS) == nil
+}
@@ -0,0 +1,29 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+func TestCheckDeviceNode(t *testing.T) {
+	opts := &Options{}
+	if err := opts.checkDeviceNode("/dev/nonexistent-smartmon-device"); err == nil {
+		t.Fatal("expected an error for a missing device node")
+	}
+	opts.Sudo = true
+	if err := opts.checkDeviceNode("/dev/nonexistent-smartmon-device"); err != nil {
+		t.Fatal("expected no error through sudo, found", err)
+	}
+	opts = &Options{ReplayDir: "replay"}
+	if err := opts.checkDeviceNode("/dev/nonexistent-smartmon-device"); err != nil {
+		t.Fatal("expected no error replaying, found", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// defaultQuarantineDuration is the time a failing device is not queried if
+// CollectorOptions.QuarantineDuration is not set
+const defaultQuarantineDuration = 30 * time.Minute
+
+var smartMonQuarantinedDesc = prometheus.NewDesc("smartmon_device_quarantined", "1 if the device is not queried because it failed too many consecutive collections", deviceLabelNames, noConstLabels)
+
+// quarantineDuration returns the time a failing device is not queried
+func (o *CollectorOptions) quarantineDuration() time.Duration {
+	if o.QuarantineDuration > 0 {
+		return o.QuarantineDuration
+	}
+	return defaultQuarantineDuration
+}
+
+// quarantined returns true if the device is not to be queried, so that a
+// dying device, e.g. a USB enclosure timing out, does not slow down every
+// collection
+func (c *Collector) quarantined(d Device) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return time.Now().Before(c.state(d.Key()).quarantinedUntil)
+}
+
+// collectQuarantine records the result of the collection of the device and
+// exports whether it is quarantined as a result
+func (c *Collector) collectQuarantine(ch chan<- prometheus.Metric, d Device, ok bool) {
+	duration := c.collectorOpts.quarantineDuration()
+	c.mtx.Lock()
+	st := c.state(d.Key())
+	quarantined := st.recordCollection(ok, c.collectorOpts.QuarantineFailures, duration, time.Now())
+	failures := st.failures
+	c.mtx.Unlock()
+	if quarantined {
+		log.Warnln("Device", d.Name, "failed", failures, "consecutive collections, not querying it for", duration)
+	}
+	c.constMetric(ch, smartMonQuarantinedDesc, prometheus.GaugeValue, boolToMetric(quarantined), c.labelValues(d)...)
+}
@@ -0,0 +1,61 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestQuarantine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the device cannot be opened, every invocation is logged
+	invocations := filepath.Join(dir, "invocations")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + invocations + "\necho 'Smartctl open device: /dev/sdz failed: No such device'\nexit 2\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, &CollectorOptions{QuarantineFailures: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sdz", Type: "sat"}
+	for i := 0; i < 3; i++ {
+		ch := make(chan prometheus.Metric, 100)
+		c.collectDevice(context.Background(), ch, d)
+	}
+	content, err := ioutil.ReadFile(invocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "\n"); n != 2 {
+		t.Fatal("expected smartctl to be invoked twice before the quarantine, found", n)
+	}
+	if !c.quarantined(d) {
+		t.Fatal("expected the device to be quarantined")
+	}
+}
@@ -0,0 +1,112 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"path"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RawValueRule interprets the raw value of an ATA attribute whose raw value
+// packs several values, e.g. the Seek_Error_Rate of Seagate drives holds
+// the number of seek errors in its upper 16 bits and the number of seeks in
+// its lower 32 bits.  The bits selected by Shift and Bits are exported as
+// smartmon_<attribute>_interpreted_value.  If TotalBits is set, the bits
+// selected by TotalShift and TotalBits are the number of operations the
+// value counts the errors of, which are exported as
+// smartmon_<attribute>_operations along with
+// smartmon_<attribute>_error_ratio.
+type RawValueRule struct {
+	// Model is a shell pattern matched against the model of the device,
+	// e.g. ST*, any model matches if empty
+	Model string `yaml:"model,omitempty"`
+	// ID is the ID of the attribute
+	ID int `yaml:"id"`
+	// Shift and Bits select the bits of the raw value holding the value,
+	// the whole raw value is selected if Bits is 0
+	Shift uint `yaml:"shift,omitempty"`
+	Bits  uint `yaml:"bits,omitempty"`
+	// TotalShift and TotalBits select the bits of the raw value holding
+	// the number of operations, if any
+	TotalShift uint `yaml:"total_shift,omitempty"`
+	TotalBits  uint `yaml:"total_bits,omitempty"`
+}
+
+// defaultRawValueRules are applied after the configured rules
+var defaultRawValueRules = []RawValueRule{
+	// Raw_Read_Error_Rate and Seek_Error_Rate of Seagate drives
+	{Model: "ST*", ID: 1, Shift: 32, Bits: 16, TotalBits: 32},
+	{Model: "ST*", ID: 7, Shift: 32, Bits: 16, TotalBits: 32},
+}
+
+// matches returns true if the rule applies to the attribute of the model
+func (r *RawValueRule) matches(model string, id int) bool {
+	if r.ID != id {
+		return false
+	}
+	if r.Model == "" {
+		return true
+	}
+	matched, _ := path.Match(r.Model, model)
+	return matched
+}
+
+// interpret returns the value and number of operations packed in the raw
+// value
+func (r *RawValueRule) interpret(raw uint64) (value, total uint64) {
+	return bitField(raw, r.Shift, r.Bits), bitField(raw, r.TotalShift, r.TotalBits)
+}
+
+// bitField returns the bits of the value selected by shift and bits, the
+// whole shifted value if bits is 0
+func bitField(value uint64, shift, bits uint) uint64 {
+	value >>= shift
+	if bits > 0 && bits < 64 {
+		value &= 1<<bits - 1
+	}
+	return value
+}
+
+// rawValueRule returns the first configured or default rule applying to
+// the attribute of the model, or nil
+func (o *CollectorOptions) rawValueRule(model string, id int) *RawValueRule {
+	for _, rules := range [][]RawValueRule{o.RawValueRules, defaultRawValueRules} {
+		for i := range rules {
+			if rules[i].matches(model, id) {
+				return &rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// collectInterpretedValue exports the values packed in the raw value of the
+// attribute if a rule applies to it
+func (c *Collector) collectInterpretedValue(ch chan<- prometheus.Metric, model string, attr Attribute, metricPrefix string, labels prometheus.Labels) {
+	rule := c.collectorOpts.rawValueRule(model, attr.ID)
+	if rule == nil {
+		return
+	}
+	value, total := rule.interpret(uint64(attr.Raw))
+	valueDesc := c.descs.get(metricPrefix+"_interpreted_value", labels)
+	c.constMetric(ch, valueDesc, prometheus.GaugeValue, float64(value))
+	if rule.TotalBits == 0 {
+		return
+	}
+	totalDesc := c.descs.get(metricPrefix+"_operations", labels)
+	c.constMetric(ch, totalDesc, prometheus.GaugeValue, float64(total))
+	if total > 0 {
+		ratioDesc := c.descs.get(metricPrefix+"_error_ratio", labels)
+		c.constMetric(ch, ratioDesc, prometheus.GaugeValue, float64(value)/float64(total))
+	}
+}
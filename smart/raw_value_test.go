@@ -0,0 +1,34 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+func TestRawValueRule(t *testing.T) {
+	opts := &CollectorOptions{RawValueRules: []RawValueRule{{Model: "ST4000DM000*", ID: 7}}}
+	// the configured rule overrides the default one
+	if rule := opts.rawValueRule("ST4000DM000-1F2168", 7); rule == nil || rule.Bits != 0 {
+		t.Fatal("expected the configured rule, found", rule)
+	}
+	if rule := opts.rawValueRule("WDC WD40EFRX-68N32N0", 7); rule != nil {
+		t.Fatal("expected no rule, found", rule)
+	}
+	rule := opts.rawValueRule("ST8000NM0055-1RM112", 7)
+	if rule == nil {
+		t.Fatal("expected the default Seagate rule")
+	}
+	// 3 seek errors in 1000000 seeks
+	value, total := rule.interpret(3<<32 | 1000000)
+	if value != 3 || total != 1000000 {
+		t.Fatal("unexpected interpreted value", value, total)
+	}
+}
@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// recordingTimeFormat timestamps the recordings, the timestamps sort in the
+// order they were recorded
+const recordingTimeFormat = "20060102T150405.000000000Z"
+
+// recordingKey identifies the smartctl arguments in the recording file
+// names, e.g. -i%20-H%20-d%20sat%20%2Fdev%2Fsda
+func recordingKey(opts []string) string {
+	return url.PathEscape(strings.Join(opts, " "))
+}
+
+// recordingName names the recording of the output of the smartctl
+// arguments, <timestamp>_<exit status>_<arguments>
+func recordingName(opts []string, status ExitStatus, now time.Time) string {
+	return now.UTC().Format(recordingTimeFormat) + "_" + strconv.Itoa(int(status)) + "_" + recordingKey(opts)
+}
+
+// recordOutput saves the output of smartctl to the record directory.  Only
+// the last recording of the arguments is kept, the previous ones are
+// removed, so the directory does not grow with every collection.
+func recordOutput(dir string, opts []string, output []byte, status ExitStatus, now time.Time) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorln("Unable to create record directory:", err)
+		return
+	}
+	name := recordingName(opts, status, now)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), output, 0644); err != nil {
+		log.Errorln("Unable to record smartctl output:", err)
+		return
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Errorln("Unable to read record directory:", err)
+		return
+	}
+	key := recordingKey(opts)
+	for _, f := range files {
+		parts := strings.SplitN(f.Name(), "_", 3)
+		if len(parts) != 3 || parts[2] != key || f.Name() == name {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+			log.Errorln("Unable to remove previous recording:", err)
+		}
+	}
+}
+
+// replayOutput returns the last recorded output of smartctl for the arguments
+// instead of executing smartctl, with the error the recorded exit status
+// results in
+func replayOutput(dir string, opts []string) ([]byte, ExitStatus, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, 0, errors.New("Unable to read replay directory: " + err.Error())
+	}
+	suffix := "_" + recordingKey(opts)
+	var last string
+	var status ExitStatus
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		parts := strings.SplitN(name, "_", 3)
+		if len(parts) != 3 || parts[2] != suffix[1:] {
+			continue
+		}
+		code, err := strconv.Atoi(parts[1])
+		if err != nil || name < last {
+			continue
+		}
+		last, status = name, ExitStatus(code)
+	}
+	if last == "" {
+		return nil, 0, errors.New("Failed to execute command: no recording of smartctl " + strings.Join(opts, " "))
+	}
+	output, err := ioutil.ReadFile(filepath.Join(dir, last))
+	if err != nil {
+		return nil, 0, errors.New("Unable to read recording: " + err.Error())
+	}
+	if status.Fatal() {
+		return output, status, errors.New("Failed to execute command: exit status " + strconv.Itoa(int(status)) + ": " + status.String())
+	}
+	return output, status, nil
+}
@@ -0,0 +1,87 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	recordDir := filepath.Join(dir, "recording")
+
+	opts := &Options{SmartctlPath: fakeSmartctl(t, dir, `=== START OF INFORMATION SECTION ===
+Device Model:     ST4000DM000-1F2168
+SMART overall-health self-assessment test result: FAILED!
+`, int(ExitDiskFailing)), RecordDir: recordDir}
+	d := Device{Name: "/dev/sda", Type: "sat"}
+	if _, err := d.info(context.Background(), opts); err != nil {
+		t.Fatal("unable to get info", err)
+	}
+	files, err := ioutil.ReadDir(recordDir)
+	if err != nil || len(files) != 1 {
+		t.Fatal("expected a recording, found", files, err)
+	}
+
+	// the recording is served without executing smartctl
+	replayOpts := &Options{SmartctlPath: filepath.Join(dir, "missing"), ReplayDir: recordDir}
+	info, err := d.info(context.Background(), replayOpts)
+	if err != nil {
+		t.Fatal("unable to replay info", err)
+	}
+	if info.Healthy || info.Attributes["device_model"] != "ST4000DM000-1F2168" {
+		t.Fatal("unexpected info", info)
+	}
+	output, status, err := smartCtl(context.Background(), replayOpts, "-i", "-H", "-d", "sat", "/dev/sda")
+	if err != nil || status != ExitDiskFailing || len(output) == 0 {
+		t.Fatal("unexpected replay", status, err)
+	}
+
+	if _, err := (&Device{Name: "/dev/sdb", Type: "sat"}).info(context.Background(), replayOpts); err == nil {
+		t.Fatal("expected an error for a device without recording")
+	}
+}
+
+func TestRecordOutputKeepsLast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := []string{"-i", "-d", "sat", "/dev/sda"}
+	recordOutput(dir, opts, []byte("first"), 0, now)
+	recordOutput(dir, []string{"-i", "-d", "sat", "/dev/sdb"}, []byte("other"), 0, now)
+	recordOutput(dir, opts, []byte("last"), ExitDiskFailing, now.Add(time.Minute))
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatal("expected a recording by arguments, found", files, err)
+	}
+	output, status, err := replayOutput(dir, opts)
+	if err != nil || string(output) != "last" || status != ExitDiskFailing {
+		t.Fatal("unexpected replay", string(output), status, err)
+	}
+	if output, _, err := replayOutput(dir, []string{"-i", "-d", "sat", "/dev/sdb"}); err != nil || string(output) != "other" {
+		t.Fatal("unexpected replay", string(output), err)
+	}
+}
@@ -0,0 +1,81 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultRetryBackoff is the wait before the first retry if
+// Options.RetryBackoff is not set
+const defaultRetryBackoff = time.Second
+
+// transientErrorRegex matches the errors printed by smartctl which are
+// likely to go away when retried, e.g. a device busy with another command or
+// a USB bridge returning EAGAIN
+var transientErrorRegex = regexp.MustCompile(`(?i)device or resource busy|resource temporarily unavailable|try again`)
+
+var smartMonRetriesDesc = prometheus.NewDesc("smartmon_smartctl_retries_total", "number of smartctl commands retried after a transient failure, e.g. a busy device", noLabels, noConstLabels)
+
+// smartctlRetries counts the retried smartctl commands
+var smartctlRetries uint64
+
+// retries returns the number of times a failing smartctl command is retried
+func (o *Options) retries() int {
+	if o == nil {
+		return 0
+	}
+	return o.Retries
+}
+
+// backoff returns the wait before the retry, doubled on every attempt
+func (o *Options) backoff(attempt int) time.Duration {
+	backoff := defaultRetryBackoff
+	if o != nil && o.RetryBackoff > 0 {
+		backoff = o.RetryBackoff
+	}
+	return backoff << uint(attempt)
+}
+
+// transient returns true if smartctl failed with an error which is likely
+// to go away when retried
+func transient(status ExitStatus, output []byte) bool {
+	return status != 0 && transientErrorRegex.Match(output)
+}
+
+// retrySmartCtl runs smartctl, retrying with an exponential backoff for as
+// long as it fails with a transient error, up to Options.Retries times.
+// The output of the last attempt is returned.
+func retrySmartCtl(ctx context.Context, o *Options, opts []string) ([]byte, ExitStatus, error) {
+	output, status, err := runSmartCtl(ctx, o, opts)
+	for attempt := 0; attempt < o.retries() && transient(status, output); attempt++ {
+		select {
+		case <-time.After(o.backoff(attempt)):
+		case <-ctx.Done():
+			return output, status, err
+		}
+		atomic.AddUint64(&smartctlRetries, 1)
+		output, status, err = runSmartCtl(ctx, o, opts)
+	}
+	return output, status, err
+}
+
+// collectRetries exports the number of retried smartctl commands
+func (c *Collector) collectRetries(ch chan<- prometheus.Metric) {
+	c.constMetric(ch, smartMonRetriesDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&smartctlRetries)))
+}
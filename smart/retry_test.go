@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetrySmartCtl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the device is busy on the first 2 attempts
+	attempts := filepath.Join(dir, "attempts")
+	script := "#!/bin/sh\necho x >> " + attempts + "\n" +
+		"if [ $(wc -l < " + attempts + ") -le 2 ]; then\n" +
+		"  echo 'Smartctl open device: /dev/sda failed: Device or resource busy'\n  exit 2\nfi\n" +
+		"echo 'Device Model:     ST4000DM000-1F2168'\n"
+	path := filepath.Join(dir, "smartctl")
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	before := atomic.LoadUint64(&smartctlRetries)
+	opts := &Options{SmartctlPath: path, Retries: 3, RetryBackoff: time.Millisecond}
+	output, _, err := smartCtl(context.Background(), opts, "-i", "/dev/sda")
+	if err != nil {
+		t.Fatal("expected the command to succeed once the device is not busy", err, string(output))
+	}
+	if retried := atomic.LoadUint64(&smartctlRetries) - before; retried != 2 {
+		t.Fatal("expected 2 retries, found", retried)
+	}
+
+	// the error is not retried without retries configured
+	os.Remove(attempts)
+	opts.Retries = 0
+	if _, _, err := smartCtl(context.Background(), opts, "-i", "/dev/sda"); err == nil {
+		t.Fatal("expected the busy device to fail")
+	}
+}
+
+func TestTransient(t *testing.T) {
+	if !transient(ExitCommandFailed, []byte("Read SMART Data failed: Resource temporarily unavailable\n")) {
+		t.Fatal("expected EAGAIN to be transient")
+	}
+	if transient(ExitDiskFailing, []byte("SMART overall-health self-assessment test result: FAILED!\n")) {
+		t.Fatal("a failing disk should not be transient")
+	}
+	if transient(0, []byte("Device or resource busy\n")) {
+		t.Fatal("a successful command should not be transient")
+	}
+}
@@ -0,0 +1,110 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sanitizeName converts a metric or label name, e.g. derived from an
+// attribute name reported by a device, to a name matching the Prometheus
+// label name regex [a-zA-Z_][a-zA-Z0-9_]*, which metric names match too.
+// The invalid characters are replaced by underscores, names starting with a
+// digit are prefixed by an underscore and the double underscore prefix
+// reserved for internal use is reduced to a single one.
+func sanitizeName(name string) string {
+	if validName(name) {
+		return name
+	}
+	sanitized := strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.HasPrefix(sanitized, "__") {
+		sanitized = "_" + strings.TrimLeft(sanitized, "_")
+	}
+	if sanitized == "" || (sanitized[0] >= '0' && sanitized[0] <= '9') {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
+
+// validName returns true if the name matches the Prometheus label name
+// regex and is not reserved
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9' && i > 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeValue replaces the invalid UTF-8 sequences of a label value, e.g.
+// a serial number read from a misbehaving device
+func sanitizeValue(value string) string {
+	if utf8.ValidString(value) {
+		return value
+	}
+	return strings.ToValidUTF8(value, string(utf8.RuneError))
+}
+
+// sanitizeLabels returns the labels with valid names and values.  Labels
+// whose sanitized names collide keep their value under the sanitized name
+// suffixed with _2, _3, etc.  The labels whose names were already valid are
+// never renamed, and the others are renamed in sorted order, so the names
+// are stable across collections.
+func sanitizeLabels(labels prometheus.Labels) prometheus.Labels {
+	renamed := []string{}
+	valid := true
+	for name, value := range labels {
+		if !validName(name) {
+			renamed = append(renamed, name)
+			valid = false
+		} else if !utf8.ValidString(value) {
+			valid = false
+		}
+	}
+	if valid {
+		return labels
+	}
+	sort.Strings(renamed)
+
+	sanitized := prometheus.Labels{}
+	for name, value := range labels {
+		if validName(name) {
+			sanitized[name] = sanitizeValue(value)
+		}
+	}
+	for _, name := range renamed {
+		base := sanitizeName(name)
+		unique := base
+		for i := 2; ; i++ {
+			if _, found := sanitized[unique]; !found {
+				break
+			}
+			unique = base + "_" + strconv.Itoa(i)
+		}
+		sanitized[unique] = sanitizeValue(labels[name])
+	}
+	return sanitized
+}
@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSanitizeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"smartmon_reallocated_sector_ct_value": "smartmon_reallocated_sector_ct_value",
+		"smartmon_power-off_retract_count":     "smartmon_power_off_retract_count",
+		"1st_error_lba":                        "_1st_error_lba",
+		"temperature_(celsius)":                "temperature__celsius_",
+		"température":                          "temp_rature",
+		"__internal":                           "_internal",
+		"":                                     "_",
+	} {
+		if sanitized := sanitizeName(name); sanitized != expected {
+			t.Errorf("expected %q to be sanitized to %q, found %q", name, expected, sanitized)
+		}
+	}
+}
+
+func TestSanitizeLabels(t *testing.T) {
+	labels := sanitizeLabels(prometheus.Labels{
+		"disk":          "/dev/sda",
+		"rotation_rate": "7200 rpm",
+		"rotation rate": "collides with rotation_rate",
+		"rotation-rate": "collides with rotation_rate too",
+		"form factor":   "3.5 inches",
+		"serial":        "Z3YX\xff",
+	})
+	expected := prometheus.Labels{
+		"disk":            "/dev/sda",
+		"rotation_rate":   "7200 rpm",
+		"rotation_rate_2": "collides with rotation_rate",
+		"rotation_rate_3": "collides with rotation_rate too",
+		"form_factor":     "3.5 inches",
+		"serial":          "Z3YX\ufffd",
+	}
+	if len(labels) != len(expected) {
+		t.Fatal("unexpected labels", labels)
+	}
+	for name, value := range expected {
+		if labels[name] != value {
+			t.Errorf("expected label %s=%q, found %q", name, value, labels[name])
+		}
+	}
+
+	// the sanitized labels must be accepted by the client library
+	desc := prometheus.NewDesc("smartmon_test", "help", nil, labels)
+	if _, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1.0); err != nil {
+		t.Fatal(err)
+	}
+}
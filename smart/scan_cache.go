@@ -0,0 +1,94 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	smartMonScanDurationDesc = prometheus.NewDesc("smartmon_device_scan_duration_seconds", "time the last scan for devices took", noLabels, noConstLabels)
+	smartMonScanAgeDesc      = prometheus.NewDesc("smartmon_device_scan_age_seconds", "time since the devices were last scanned, the devices found are cached for the rescan interval", noLabels, noConstLabels)
+)
+
+// scanCache is the result of the last scan for devices, reused until
+// CollectorOptions.RescanInterval elapses or a block device is added or
+// removed
+type scanCache struct {
+	mtx      sync.Mutex
+	devices  []Device
+	scanned  time.Time
+	duration time.Duration
+	// blockDevices lists the block devices when the devices were scanned
+	blockDevices string
+	// paths lists the paths of the devices found through several paths
+	paths map[string][]string
+}
+
+// blockDevices lists the names of the block devices of sysBlock, which
+// change when a disk is plugged in or removed.  Empty if sysBlock cannot be
+// read, e.g. on other systems than Linux.
+func blockDevices() string {
+	files, err := ioutil.ReadDir(sysBlock)
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	return strings.Join(names, " ")
+}
+
+// scan returns the devices found by the last scan if it is recent enough
+// and no block device was added or removed since, scans for the devices
+// otherwise.  The paths to the same disk are reduced to the first one.
+// The scans are serialized, so concurrent scrapes share a slow
+// scan of a large SAS topology.
+func (c *Collector) scan(ctx context.Context) ([]Device, error) {
+	c.scans.mtx.Lock()
+	defer c.scans.mtx.Unlock()
+	block := blockDevices()
+	if interval := c.collectorOpts.RescanInterval; interval > 0 && !c.scans.scanned.IsZero() &&
+		time.Since(c.scans.scanned) < interval && block == c.scans.blockDevices {
+		return c.scans.devices, nil
+	}
+	start := time.Now()
+	devices, err := Scan(ctx, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	devices, c.scans.paths = dedupPaths(devices)
+	c.scans.devices = devices
+	c.scans.scanned = start
+	c.scans.duration = time.Since(start)
+	c.scans.blockDevices = block
+	return devices, nil
+}
+
+// collectScan collects the duration of the last scan and its age
+func (c *Collector) collectScan(ch chan<- prometheus.Metric) {
+	c.scans.mtx.Lock()
+	defer c.scans.mtx.Unlock()
+	if c.scans.scanned.IsZero() {
+		return
+	}
+	c.constMetric(ch, smartMonScanDurationDesc, prometheus.GaugeValue, c.scans.duration.Seconds())
+	c.constMetric(ch, smartMonScanAgeDesc, prometheus.GaugeValue, time.Since(c.scans.scanned).Seconds())
+}
@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysBlock = path }(sysBlock)
+	sysBlock = filepath.Join(dir, "block")
+	os.MkdirAll(filepath.Join(sysBlock, "sda"), 0755)
+
+	log := filepath.Join(dir, "log")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\necho \"$*\" >>" + log + "\necho '/dev/sda -d sat # /dev/sda, ATA device'\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	scans := func() int {
+		out, _ := ioutil.ReadFile(log)
+		return strings.Count(string(out), "--scan")
+	}
+
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, &CollectorOptions{RescanInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		devices, err := c.scan(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(devices) != 1 || devices[0].Name != "/dev/sda" {
+			t.Fatal("unexpected devices", devices)
+		}
+	}
+	if n := scans(); n != 1 {
+		t.Fatal("expected the devices to be scanned once, scanned", n)
+	}
+
+	os.MkdirAll(filepath.Join(sysBlock, "sdb"), 0755)
+	if _, err := c.scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := scans(); n != 2 {
+		t.Fatal("expected a hotplugged device to rescan, scanned", n)
+	}
+
+	c.scans.scanned = time.Now().Add(-2 * time.Hour)
+	if _, err := c.scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := scans(); n != 3 {
+		t.Fatal("expected the rescan interval to rescan, scanned", n)
+	}
+}
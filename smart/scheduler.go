@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// schedule collects the metrics in the background until the collector is
+// closed.  The devices are checked on the shortest configured interval and
+// collected when their own interval elapsed.
+func (c *Collector) schedule() {
+	defer c.running.Done()
+	tick := c.collectorOpts.tick()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		c.collectBackground(tick)
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// collectBackground refreshes the snapshot of the global metrics and of
+// the devices which are due to be collected
+func (c *Collector) collectBackground(tick time.Duration) {
+	c.descs.sweep()
+	var devices []Device
+	global := record(func(ch chan<- prometheus.Metric) {
+		devices, _ = c.collectGlobal(c.ctx, ch)
+	})
+	c.mtx.Lock()
+	c.snapshot = global
+	c.snapshotDevices = devices
+	c.mtx.Unlock()
+	// keep serving the metrics last collected from the devices while paused
+	if !c.paused() {
+		c.collectDevicesDue(devices, tick)
+	}
+	summary := record(func(ch chan<- prometheus.Metric) {
+		c.collectSummary(ch, devices)
+	})
+	c.mtx.Lock()
+	c.snapshot = append(global, summary...)
+	c.mtx.Unlock()
+	c.saveState()
+}
+
+// collectDevicesDue collects the devices which are due to be collected in
+// the background
+func (c *Collector) collectDevicesDue(devices []Device, tick time.Duration) {
+	c.eachDevice(devices, func(d Device) {
+		now := time.Now()
+		c.mtx.Lock()
+		st := c.state(d.Key())
+		// allow for the jitter of the ticker, a device collected on every
+		// tick would otherwise skip every other tick
+		due := now.Sub(st.scheduled) >= c.collectorOpts.interval(d, st.mode)-tick/2
+		c.mtx.Unlock()
+		if !due {
+			return
+		}
+		metrics := record(func(ch chan<- prometheus.Metric) {
+			c.collectDevice(c.ctx, ch, d)
+		})
+		c.mtx.Lock()
+		st.scheduled = now
+		st.snapshot = metrics
+		c.mtx.Unlock()
+	})
+}
+
+// collectSnapshot serves the metrics of the last background collection of
+// the devices selected by the filter
+func (c *Collector) collectSnapshot(ch chan<- prometheus.Metric, f *Filter) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, m := range c.snapshot {
+		ch <- m
+	}
+	for _, d := range f.devices(c.snapshotDevices) {
+		st := c.state(d.Key())
+		for _, m := range st.snapshot {
+			ch <- m
+		}
+		interval := c.collectorOpts.interval(d, st.mode)
+		c.constMetric(ch, smartMonIntervalDesc, prometheus.GaugeValue, interval.Seconds(), c.labelValues(d)...)
+	}
+}
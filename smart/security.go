@@ -0,0 +1,57 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"context"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// smartctlSecurityOpts reads the ATA Security status and the optional
+// commands of the device
+var smartctlSecurityOpts = []string{"-i", "-g", "security"}
+
+var smartMonSecurityDesc = prometheus.NewDesc("smartmon_device_security_info", "security features of the device, 1 if the feature is set: the ATA Security is enabled, locked or frozen, or an NVMe device supports the security commands of self-encrypting drives", []string{"disk", "type", "by_id", "wwn", "serial", "feature"}, noConstLabels)
+
+// security gets the security status reported by 'smartctl -i -g security'
+func (d *Device) security(ctx context.Context, o *Options) (*parser.Security, error) {
+	opts := append(smartctlSecurityOpts, "-d", d.Type, d.Name)
+	output, _, err := smartCtl(ctx, o, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseSecurity(output), nil
+}
+
+// collectSecurity collects the security status of the device, nothing if
+// the device reports none
+func (c *Collector) collectSecurity(ctx context.Context, ch chan<- prometheus.Metric, d Device) error {
+	security, err := d.security(ctx, c.deviceOpts(d))
+	if err != nil || security == nil {
+		return err
+	}
+	features := map[string]bool{
+		"security_commands": security.SecurityCommands,
+	}
+	if security.Status != "" {
+		features["enabled"] = security.Enabled
+		features["locked"] = security.Locked
+		features["frozen"] = security.Frozen
+	}
+	for feature, set := range features {
+		c.constMetric(ch, smartMonSecurityDesc, prometheus.GaugeValue, boolToMetric(set), append(c.labelValues(d), feature)...)
+	}
+	return nil
+}
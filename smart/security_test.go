@@ -0,0 +1,44 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectSecurity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := fakeSmartctl(t, dir, "=== START OF INFORMATION SECTION ===\nATA Security is:  Disabled, frozen [SEC2]\n", 0)
+	c, err := NewCollector(&Options{SmartctlPath: path}, &CollectorOptions{Security: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ch := make(chan prometheus.Metric, 10)
+	if err := c.collectSecurity(context.Background(), ch, Device{Name: "/dev/sda", Type: "sat"}); err != nil {
+		t.Fatal("unable to collect the security status", err)
+	}
+	// enabled, locked, frozen and security_commands
+	if len(ch) != 4 {
+		t.Fatal("expected 4 metrics, found", len(ch))
+	}
+}
@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smart reads S.M.A.R.T. data of storage devices using the
+// smartctl command of smartmontools.  The data is returned as typed
+// structs so it can be used independently of the prometheus Collector.
+package smart
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Scan gets the list of available smart devices with the backend selected
+// by the options.  The smartctl backend parses the JSON output of smartctl
+// if the installed version supports it.
+func Scan(ctx context.Context, opts *Options) ([]Device, error) {
+	b, err := NewBackend(opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.Scan(ctx)
+}
+
+// Active returns true if the device is in an active state, i.e. not in
+// sleep or standby.  Checking the state does not wake up the device.
+func (d *Device) Active(ctx context.Context, opts *Options) (bool, error) {
+	mode, err := d.PowerMode(ctx, opts)
+	if err != nil {
+		return false, err
+	}
+	return mode == PowerModeActive || mode == PowerModeIdle, nil
+}
+
+// PowerMode gets the power mode of the device without waking it up
+func (d *Device) PowerMode(ctx context.Context, opts *Options) (PowerMode, error) {
+	b, err := NewBackend(opts)
+	if err != nil {
+		return PowerModeUnknown, err
+	}
+	return b.PowerMode(ctx, d)
+}
+
+// Info gets the identity and health of the device
+func (d *Device) Info(ctx context.Context, opts *Options) (*DeviceInfo, error) {
+	b, err := NewBackend(opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.Info(ctx, d)
+}
+
+// Attributes gets the SMART attributes of the device
+func (d *Device) Attributes(ctx context.Context, opts *Options) ([]Attribute, error) {
+	b, err := NewBackend(opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.Attributes(ctx, d)
+}
+
+// SelfTests gets the self-test log of the device
+func (d *Device) SelfTests(ctx context.Context, opts *Options) ([]SelfTest, error) {
+	b, err := NewBackend(opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.SelfTests(ctx, d)
+}
+
+// StartSelfTest starts a self-test of the device in the background and
+// returns the estimated time until it completes.  The result is reported
+// in the self-test log once the test completed.
+func (d *Device) StartSelfTest(ctx context.Context, opts *Options, test SelfTestType) (time.Duration, error) {
+	if test != SelfTestShort && test != SelfTestLong {
+		return 0, errors.New("unsupported self-test type: " + string(test))
+	}
+	return d.startSelfTest(ctx, opts, test)
+}
@@ -13,14 +13,14 @@
 package smart
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"errors"
 	"os/exec"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/blang/semver"
+	"github.com/pgier/smartmon-exporter/smart/parser"
 )
 
 const (
@@ -42,128 +42,355 @@ var (
 	smartctlDeviceInfoOpts = []string{"-i", "-H"}
 	// smartctlDeviceMetricOpts
 	smartctlDeviceMetricOpts = []string{"-A"}
-	smartctlJSONOption       = "-j"
+	// smartctlSelfTestLogOpts reads the self-test log of the device
+	smartctlSelfTestLogOpts = []string{"-l", "selftest"}
+	// smartctlSelfTestOption starts a self-test of the device
+	smartctlSelfTestOption = "-t"
+	smartctlJSONOption     = "-j"
+	// smartctlToleranceOption sets the tolerance to failed ATA commands
+	smartctlToleranceOption = "-T"
+)
 
-	smartctlDeviceRegex = regexp.MustCompile("^(/.+) -d ([\\w]+) # (.+), (.+)")
-	smartctlInfoRegex   = regexp.MustCompile("^([^:]+): (.+)$")
+// The tolerance levels of smartctl -T
+const (
+	ToleranceNormal         = "normal"
+	ToleranceConservative   = "conservative"
+	TolerancePermissive     = "permissive"
+	ToleranceVeryPermissive = "verypermissive"
 )
 
+// Tolerances lists the tolerance levels of smartctl -T
+var Tolerances = []string{ToleranceNormal, ToleranceConservative, TolerancePermissive, ToleranceVeryPermissive}
+
+// Options configures how the smartctl command is invoked.  A nil *Options
+// is valid and uses the defaults.
+type Options struct {
+	// SmartctlPath is the smartctl binary to execute, defaults to
+	// looking up "smartctl" in the PATH
+	SmartctlPath string
+	// ExtraArgs are passed to smartctl before the arguments of every
+	// command querying a device, e.g. site specific options such as
+	// "-T permissive"
+	ExtraArgs []string
+	// Tolerance is the smartctl -T tolerance of the commands querying a
+	// device, e.g. permissive for old drives which do not answer otherwise,
+	// the smartctl default if empty
+	Tolerance string
+	// DisableJSON forces parsing of the plain text output even when the
+	// installed smartctl is capable of JSON output
+	DisableJSON bool
+	// Sudo runs smartctl through 'sudo -n' so that the caller itself
+	// does not need to be privileged
+	Sudo bool
+	// HelperPath is a privileged helper, e.g. a setuid wrapper, which is
+	// executed with the smartctl arguments instead of smartctl
+	HelperPath string
+	// Backend is the name of the registered Backend reading the SMART
+	// data, BackendSmartctl if empty
+	Backend string
+	// DriveDBPath is the drive database file used by smartctl, defaults
+	// to the first database found in the usual locations
+	DriveDBPath string
+	// Retries is the number of times a smartctl command failing with a
+	// transient error, e.g. a busy device, is retried
+	Retries int
+	// RetryBackoff is the wait before the first retry, doubled on every
+	// retry, defaults to 1s
+	RetryBackoff time.Duration
+	// RecordDir saves the output of every smartctl command executed to a
+	// file of this directory
+	RecordDir string
+	// ReplayDir serves the output of the smartctl commands recorded to
+	// this directory with RecordDir instead of executing smartctl
+	ReplayDir string
+	// Trace is called with every smartctl command executed, e.g. to debug
+	// the parsing of the output of a device
+	Trace func(Invocation)
+}
+
+// Invocation is a smartctl command executed, passed to Options.Trace
+type Invocation struct {
+	// Command and Args are the command executed, which is sudo or the
+	// helper instead of smartctl if configured
+	Command string
+	Args    []string
+	// Output is the combined output of the command
+	Output []byte
+	// Status is the exit status of smartctl, and Err the error returned
+	// for the command
+	Status ExitStatus
+	Err    error
+	// Duration is the time the command took to complete
+	Duration time.Duration
+}
+
+// smartctl returns the smartctl binary to execute
+func (o *Options) smartctl() string {
+	if o == nil || o.SmartctlPath == "" {
+		return smartctlCmd
+	}
+	return o.SmartctlPath
+}
+
+// tolerance returns the tolerance of the commands querying a device if it
+// is not the smartctl default
+func (o *Options) tolerance() string {
+	if o == nil || o.Tolerance == ToleranceNormal {
+		return ""
+	}
+	return o.Tolerance
+}
+
+// ValidTolerance returns an error if the tolerance is not a smartctl -T
+// tolerance level
+func ValidTolerance(tolerance string) error {
+	for _, t := range Tolerances {
+		if tolerance == t {
+			return nil
+		}
+	}
+	return errors.New("unknown tolerance: " + tolerance + ", expected one of " + strings.Join(Tolerances, ", "))
+}
+
+// command returns the command to execute to run smartctl with the given
+// options, preceded by the extra arguments if the command queries a device
+func (o *Options) command(opts []string) (string, []string) {
+	cmd := o.smartctl()
+	if tolerance := o.tolerance(); tolerance != "" && deviceCommand(opts) {
+		opts = append([]string{smartctlToleranceOption, tolerance}, opts...)
+	}
+	if o != nil && len(o.ExtraArgs) > 0 && deviceCommand(opts) {
+		opts = withExtraArgs(o.ExtraArgs, opts)
+	}
+	switch {
+	case o != nil && o.HelperPath != "":
+		return o.HelperPath, opts
+	case o != nil && o.Sudo:
+		return "sudo", append([]string{"-n", cmd}, opts...)
+	}
+	return cmd, opts
+}
+
+// withExtraArgs returns the options preceded by the extra arguments.  As
+// smartctl uses the last -d option, a -d type of the extra arguments
+// replaces the type of the options instead, e.g. sat,12 for a USB dock.
+func withExtraArgs(extra, opts []string) []string {
+	args := make([]string, 0, len(extra)+len(opts))
+	deviceType := ""
+	for i := 0; i < len(extra); i++ {
+		if extra[i] == "-d" && i+1 < len(extra) {
+			deviceType = extra[i+1]
+			i++
+			continue
+		}
+		args = append(args, extra[i])
+	}
+	start := len(args)
+	args = append(args, opts...)
+	if deviceType != "" {
+		for i := start; i+1 < len(args); i++ {
+			if args[i] == "-d" {
+				args[i+1] = deviceType
+			}
+		}
+	}
+	return args
+}
+
+// deviceCommand returns true if the smartctl options query a device, i.e.
+// select its type, unlike e.g. the version and the scan
+func deviceCommand(opts []string) bool {
+	for _, opt := range opts {
+		if opt == "-d" {
+			return true
+		}
+	}
+	return false
+}
+
+// json returns true if the JSON output of smartctl should be parsed
+func (o *Options) json(ctx context.Context) bool {
+	if o != nil && o.DisableJSON {
+		return false
+	}
+	return jsonCapable(ctx, o)
+}
+
 // Device represents a SMART capable device
 type Device struct {
-	Name     string
-	InfoName string
-	Type     string
-	Protocol string
+	Name     string `json:"name"`
+	InfoName string `json:"info_name"`
+	Type     string `json:"type"`
+	Protocol string `json:"protocol"`
 }
 
-// DeviceStatus contains the status reported by the -H option
-type DeviceStatus struct {
-	Passed  bool
-	Details map[string]string
+// Key identifies the device among the devices found by a scan.  The devices
+// behind a RAID controller share the name of the controller and differ by
+// type, e.g. megaraid,0 and megaraid,1 of /dev/bus/0, so their key is the
+// name followed by the type.  The key of the other devices is their name.
+func (d *Device) Key() string {
+	if strings.Contains(strings.TrimPrefix(d.Type, "nvme,"), ",") {
+		return d.Name + ":" + d.Type
+	}
+	return d.Name
 }
 
+// DeviceStatus contains the status reported by the -H option
+type DeviceStatus = parser.DeviceStatus
+
 // DeviceInfo contains info reported by the -i option
-// "model_name": "SAMSUNG MZVLB512HAJQ-000L7",
-// "serial_number": "S3TNNX1K710265",
-// "firmware_version": "4L2QEXA7",
-// "nvme_pci_vendor": {
-//   "id": 5197,
-//   "subsystem_id": 5197
-// },
-// "nvme_ieee_oui_identifier": 9528,
-// "nvme_total_capacity": 512110190592,
-// "nvme_unallocated_capacity": 0,
-// "nvme_controller_id": 4,
-// "nvme_number_of_namespaces": 1,
-// "nvme_namespaces": [
-//   {
-// 	"id": 1,
-// 	"size": {
-// 	  "blocks": 1000215216,
-// 	  "bytes": 512110190592
-// 	},
-// 	"capacity": {
-// 	  "blocks": 1000215216,
-// 	  "bytes": 512110190592
-// 	},
-// 	"utilization": {
-// 	  "blocks": 251500984,
-// 	  "bytes": 128768503808
-// 	},
-// 	"formatted_lba_size": 512,
-// 	"eui64": {
-// 	  "oui": 9528,
-// 	  "ext_id": 581996836738
-// 	}
-//   }
-// ],
-// "user_capacity": {
-//   "blocks": 1000215216,
-//   "bytes": 512110190592
-// },
-// "logical_block_size": 512,
-// "local_time": {
-//   "time_t": 1566314980,
-//   "asctime": "Tue Aug 20 10:29:40 2019 CDT"
-// }
-type DeviceInfo struct {
-	Available  bool
-	Enabled    bool
-	Healthy    bool
-	Attributes map[string]string
-}
+type DeviceInfo = parser.DeviceInfo
+
+// Namespace is an NVMe namespace as reported by the -i option
+type Namespace = parser.Namespace
+
+// Attribute is a single SMART attribute as reported by the -A option
+type Attribute = parser.Attribute
+
+// SelfTest is an entry of the self-test log as reported by -l selftest
+type SelfTest = parser.SelfTest
+
+// PowerMode is the power state of a device as reported by the -n option
+type PowerMode = parser.PowerMode
+
+// The power modes distinguished by smartctl
+const (
+	PowerModeActive  = parser.PowerModeActive
+	PowerModeIdle    = parser.PowerModeIdle
+	PowerModeStandby = parser.PowerModeStandby
+	PowerModeSleep   = parser.PowerModeSleep
+	PowerModeUnknown = parser.PowerModeUnknown
+)
+
+// SelfTestType is the type of self-test started with the -t option
+type SelfTestType string
+
+const (
+	// SelfTestShort is a test of the electrical and mechanical performance
+	// and of a small part of the surface, taking a few minutes
+	SelfTestShort SelfTestType = "short"
+	// SelfTestLong is a test of the whole surface, taking up to several hours
+	SelfTestLong SelfTestType = "long"
+)
 
 func smartCtrlAvailable() bool {
 	_, err := exec.LookPath("smartctl")
 	return err != nil
 }
 
-// smartCtl runs the smartctl command with the given options and returns the combined output
-func smartCtl(opts ...string) ([]byte, error) {
-	smartctlCmd := exec.Command(smartctlCmd, opts...)
+// smartCtl runs the smartctl command with the given options and returns the
+// combined output along with the exit status of smartctl.  smartctl exits
+// with a non-zero status for many conditions where its output is still
+// valid, e.g. a failing disk, so an error is only returned if smartctl could
+// not be executed or the status is fatal, the output is returned in any
+// case.  The command is retried if it fails with a transient error and is
+// killed if the context is done before it completes.
+func smartCtl(ctx context.Context, o *Options, opts ...string) ([]byte, ExitStatus, error) {
+	return retrySmartCtl(ctx, o, opts)
+}
+
+// runSmartCtl runs the smartctl command once
+func runSmartCtl(ctx context.Context, o *Options, opts []string) ([]byte, ExitStatus, error) {
+	name, args := o.command(opts)
+	start := time.Now()
+	var output []byte
+	var status ExitStatus
+	var err error
+	if o != nil && o.ReplayDir != "" {
+		output, status, err = replayOutput(o.ReplayDir, opts)
+	} else {
+		output, status, err = execSmartCtl(ctx, name, args)
+	}
+	// only the commands which ran are recorded, not e.g. a missing smartctl
+	if o != nil && o.RecordDir != "" && o.ReplayDir == "" && (err == nil || status != 0) {
+		recordOutput(o.RecordDir, opts, output, status, start)
+	}
+	if o != nil && o.Trace != nil {
+		o.Trace(Invocation{
+			Command:  name,
+			Args:     args,
+			Output:   output,
+			Status:   status,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+	return output, status, err
+}
+
+// execSmartCtl executes the command and interprets its exit status
+func execSmartCtl(ctx context.Context, name string, args []string) ([]byte, ExitStatus, error) {
+	smartctlCmd := exec.CommandContext(ctx, name, args...)
+	output, status, err := waitSmartCtl(smartctlCmd)
+	recordInvocation(smartctlCmd.ProcessState, err)
+	return output, status, err
+}
+
+// waitSmartCtl runs the command to completion and interprets its exit status
+func waitSmartCtl(smartctlCmd *exec.Cmd) ([]byte, ExitStatus, error) {
 	output, err := smartctlCmd.CombinedOutput()
-	if err != nil {
-		return nil, errors.New("Failed to execute command: " + err.Error())
+	if err == nil {
+		return output, 0, nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() < 0 {
+		return output, 0, errors.New("Failed to execute command: " + err.Error())
 	}
-	return output, nil
+	status := ExitStatus(exitErr.ExitCode())
+	if status.Fatal() {
+		return output, status, errors.New("Failed to execute command: " + err.Error() + ": " + status.String())
+	}
+	return output, status, nil
 }
 
 // Version gets the current version of the smartmon tools, returns an error
 // if smartmon tools cannot be found.
 func Version() (string, error) {
-	output, err := smartCtl(smartctlVersionOpts...)
+	return version(context.Background(), nil)
+}
+
+func version(ctx context.Context, o *Options) (string, error) {
+	output, _, err := smartCtl(ctx, o, smartctlVersionOpts...)
 	if err != nil {
 		return "", err
 	}
-	return strings.Fields(firstLine(output))[1], nil
+	return parser.ParseVersion(output)
 }
 
 // scanDevices gets the list of available smart devices as
 // reported by 'smartctl --scan'
-func scanDevices() ([]Device, error) {
-	output, err := smartCtl(smartctlScanOpts...)
+func scanDevices(ctx context.Context, o *Options) ([]Device, error) {
+	output, _, err := smartCtl(ctx, o, smartctlScanOpts...)
 	if err != nil {
 		return nil, err
 	}
-	lines := strings.Split(string(output), "\n")
-	devices := []Device{}
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		matches := smartctlDeviceRegex.FindSubmatch([]byte(line))
-		if len(matches) < 4 {
-			return nil, errors.New("Unable to parse device line: " + line)
-		}
-		device := Device{
-			Name:     string(matches[1]),
-			Type:     string(matches[2]),
-			InfoName: string(matches[3]),
-			Protocol: string(matches[4]),
+	parsed, err := parser.ParseScan(output)
+	if err != nil {
+		return nil, err
+	}
+	return toDevices(parsed), nil
+}
+
+// toDevices converts the devices found by the parser.  The NVMe drives
+// behind a tri-mode MegaRAID controller are scanned as megaraid,N with the
+// NVMe protocol and addressed as nvme,megaraid,N, so they are read like the
+// other NVMe devices.
+func toDevices(parsed []parser.Device) []Device {
+	devices := make([]Device, 0, len(parsed))
+	for _, d := range parsed {
+		device := Device(d)
+		if strings.HasPrefix(device.Type, "megaraid,") && strings.HasPrefix(device.Protocol, "NVMe") {
+			device.Type = "nvme," + device.Type
 		}
 		devices = append(devices, device)
 	}
-	return devices, nil
+	return devices
+}
+
+// megaraid returns true if the device is addressed through a MegaRAID
+// controller, e.g. megaraid,0 or nvme,megaraid,0
+func (d *Device) megaraid() bool {
+	return strings.HasPrefix(d.Type, "megaraid,") || strings.HasPrefix(d.Type, "nvme,megaraid,")
 }
 
 // CheckSupportedVersion verifies that the smartctl command is available and
@@ -171,12 +398,17 @@ func scanDevices() ([]Device, error) {
 // the minimum version supported by the library.  Returns an error if the smartctl
 // command cannot be found, or if the version is lower than the minimum
 func CheckSupportedVersion() error {
+	return checkSupportedVersion(context.Background(), nil)
+}
+
+func checkSupportedVersion(ctx context.Context, o *Options) error {
 	minVer := semver.MustParse(smartMonMinVersion)
-	foundVer, err := Version()
+	foundVer, err := version(ctx, o)
 	if err != nil {
 		return errors.New("Unable to determine installed smartctl version:" + err.Error())
 	}
-	installedVer, err := semver.Parse(foundVer)
+	// smartctl versions only have a major and minor number, e.g. 7.0
+	installedVer, err := semver.ParseTolerant(foundVer)
 	if err != nil {
 		return errors.New("Unable to parse installed smartctl version:" + err.Error())
 	}
@@ -186,72 +418,60 @@ func CheckSupportedVersion() error {
 	return nil
 }
 
-// firstLine reads the first line from a string
-func firstLine(text []byte) string {
-	scanner := bufio.NewScanner(bytes.NewReader(text))
-	if !scanner.Scan() {
-		panic("Unable to read first line")
+// powerMode gets the power mode of the device.  smartctl skips a device in
+// standby or sleep without waking it up and exits with an error, the mode
+// reported in the output is returned in this case.
+func (d *Device) powerMode(ctx context.Context, o *Options) (PowerMode, error) {
+	opts := append(smartctlDeviceActiveOpts, "-d", d.Type, d.Name)
+	output, _, err := smartCtl(ctx, o, opts...)
+	mode := parser.ParsePowerMode(output)
+	switch {
+	case mode == parser.PowerModeStandby || mode == parser.PowerModeSleep:
+		return mode, nil
+	case err != nil:
+		return parser.PowerModeUnknown, err
+	case mode == parser.PowerModeUnknown:
+		return parser.PowerModeActive, nil
 	}
-	return scanner.Text()
+	return mode, nil
 }
 
-// active returns true if the device is in an active state
-// i.e. not in sleep or standby
-func (d *Device) active() (bool, error) {
-	opts := append(smartctlDeviceActiveOpts, "-d", d.Type, d.Name)
-	_, err := smartCtl(opts...)
+func (d *Device) info(ctx context.Context, o *Options) (*DeviceInfo, error) {
+	output, err := d.deviceSmartCtl(ctx, o, smartctlDeviceInfoOpts, false)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return true, nil
+	return parser.ParseInfo(output)
 }
 
-func (d *Device) info() (*DeviceInfo, error) {
-	opts := append(smartctlDeviceInfoOpts, "-d", d.Type, d.Name)
-	output, err := smartCtl(opts...)
+// attributes gets the SMART attributes reported by 'smartctl -A'
+func (d *Device) attributes(ctx context.Context, o *Options) ([]Attribute, error) {
+	output, err := d.deviceSmartCtl(ctx, o, smartctlDeviceMetricOpts, false)
 	if err != nil {
 		return nil, err
 	}
-
-	//smartAvailable, smartEnabled, smartHealthy := 0.0, 0.0, 0.0
-	info := DeviceInfo{
-		Attributes: map[string]string{},
+	if strings.HasPrefix(d.Type, "nvme") {
+		return parser.ParseNVMeAttributes(output)
 	}
-	for _, line := range strings.Split(string(output), "\n") {
-		matches := smartctlInfoRegex.FindStringSubmatch(line)
-		if matches != nil && len(matches) > 2 {
-			name, val := matches[1], matches[2]
-			info.Attributes[sanitizeLabelName(name)] = strings.TrimSpace(val)
-			if strings.HasPrefix(name, "SMART support is") {
-				switch {
-				case strings.HasPrefix(val, "Available"):
-					info.Available = true
-				case strings.HasPrefix(val, "Enabled"):
-					info.Enabled = true
-				}
-			} else if strings.HasPrefix(name, "SMART Health Status") {
-				if strings.HasPrefix(val, "OK") {
-					info.Healthy = true
-					info.Available = true
-					info.Enabled = true
-				}
-			} else if strings.HasPrefix(name, "SMART overall-health self-assessment test result") {
-				if strings.HasPrefix(val, "PASSED") {
-					info.Healthy = true
-					info.Available = true
-					info.Enabled = true
-				}
-			}
-		}
+	return parser.ParseATAAttributes(output)
+}
+
+// selfTests gets the self-test log reported by 'smartctl -l selftest'
+func (d *Device) selfTests(ctx context.Context, o *Options) ([]SelfTest, error) {
+	opts := append(smartctlSelfTestLogOpts, "-d", d.Type, d.Name)
+	output, _, err := smartCtl(ctx, o, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return &info, nil
+	return parser.ParseSelfTests(output), nil
 }
 
-// sanitizedLabelName formats a string to be an acceptable label name
-func sanitizeLabelName(name string) string {
-	name = strings.ReplaceAll(name, " ", "_")
-	name = strings.ReplaceAll(name, "/", "_")
-	name = strings.ReplaceAll(name, "-", "_")
-	name = strings.ReplaceAll(name, ".", "_")
-	return strings.ToLower(name)
+// startSelfTest starts a self-test and returns its estimated duration
+func (d *Device) startSelfTest(ctx context.Context, o *Options, test SelfTestType) (time.Duration, error) {
+	opts := []string{smartctlSelfTestOption, string(test), "-d", d.Type, d.Name}
+	output, _, err := smartCtl(ctx, o, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return parser.ParseSelfTestStart(output)
 }
@@ -13,20 +13,26 @@
 package smart
 
 import (
-	"encoding/json"
-	"errors"
-	"strings"
+	"context"
 
 	"github.com/blang/semver"
+	"github.com/pgier/smartmon-exporter/smart/parser"
 )
 
 // JSONCapable returns true if the current installed version of smartmon tools is capable of outputting JSON
 func JSONCapable() bool {
+	return jsonCapable(context.Background(), nil)
+}
+
+func jsonCapable(ctx context.Context, o *Options) bool {
+	f, err := features(ctx, o)
+	return err == nil && f.JSON
+}
+
+// jsonVersion returns true if the smartctl version is capable of outputting
+// JSON, assumed when the JSON output cannot be probed
+func jsonVersion(foundVer string) bool {
 	minVer := semver.MustParse(smartMonMinVersionJSON)
-	foundVer, err := Version()
-	if err != nil {
-		return false
-	}
 	installedVer, err := semver.ParseTolerant(foundVer)
 	if err != nil {
 		return false
@@ -39,128 +45,53 @@ func JSONCapable() bool {
 
 // SmartctlJSONMeta contains metadata included with the JSON output
 // of the smartctl command
-//   "smartctl": {
-//     "version": [
-//       7,
-//       0
-//     ],
-//     "svn_revision": "4903",
-//     "platform_info": "x86_64-linux-5.2.7-200.fc30.x86_64",
-//     "build_info": "(local build)",
-//     "argv": [
-//       "smartctl",
-//       "--scan",
-//       "-j"
-//     ],
-//     "exit_status": 0
-//   },
-type SmartctlJSONMeta struct {
-	Smartctl struct {
-		Version []int
-	}
-	SvnRevision  string
-	PlatformInfo string
-	BuildInfo    string
-	Argv         []string
-	ExitStatus   int
-}
+type SmartctlJSONMeta = parser.SmartctlJSONMeta
 
 func useJSON(opts []string) []string {
 	return append([]string{smartctlJSONOption}, opts...)
 }
 
-type parsedJSON map[string]*json.RawMessage
-
-func parseJSON(data []byte) (parsedJSON, error) {
-	parsed := parsedJSON{}
-	err := json.Unmarshal(data, &parsed)
-	if err != nil {
-		return nil, err
-	}
-	return parsed, nil
-}
-
 // scanDevicesJSON is similar to deviceList but uses JSON
 // output of the smartctl command
-func scanDevicesJSON() ([]Device, error) {
-	output, err := smartCtl(useJSON(smartctlScanOpts)...)
+func scanDevicesJSON(ctx context.Context, o *Options) ([]Device, error) {
+	output, _, err := smartCtl(ctx, o, useJSON(smartctlScanOpts)...)
+	recordMessages(nil, output)
 	if err != nil {
 		return nil, err
 	}
-	mappedJSON, err := parseJSON(output)
+	parsed, err := parser.ParseScanJSON(output)
 	if err != nil {
 		return nil, err
 	}
-	devices := []Device{}
+	return toDevices(parsed), nil
+}
 
-	unparsedDevices, exists := mappedJSON["devices"]
-	if !exists {
-		return nil, errors.New("unable to find 'devices' entry in JSON output")
-	}
-	err = json.Unmarshal(*unparsedDevices, &devices)
+func (d *Device) infoJSON(ctx context.Context, o *Options) (*DeviceInfo, error) {
+	output, err := d.deviceSmartCtl(ctx, o, smartctlDeviceInfoOpts, true)
 	if err != nil {
 		return nil, err
 	}
-	return devices, nil
+	return parser.ParseInfoJSON(output)
 }
 
-var (
-	parsableFields = map[string]struct{}{
-		"json_format_version": {},
-		"smartctl":            {},
-		"device":              {},
-		"smart_status":        {},
-	}
-)
-
-// attributes gets just the key, value  pairs that cannot be parsed into
-// a known struct
-func attributes(mappedJSON map[string]*json.RawMessage) map[string]string {
-	cleanedAttributes := map[string]string{}
-	for key, val := range mappedJSON {
-		if _, found := parsableFields[key]; !found {
-			cleanedAttributes[key] = sanitizeLabelValue(string(*val))
-		}
-	}
-	return cleanedAttributes
-}
-
-func (d *Device) infoJSON() (*DeviceInfo, error) {
-	opts := append(smartctlDeviceInfoOpts, "-d", d.Type, d.Name)
-	output, err := smartCtl(useJSON(opts)...)
+// attributesJSON is similar to attributes but uses the JSON output
+// of the smartctl command
+func (d *Device) attributesJSON(ctx context.Context, o *Options) ([]Attribute, error) {
+	output, err := d.deviceSmartCtl(ctx, o, smartctlDeviceMetricOpts, true)
 	if err != nil {
 		return nil, err
 	}
+	return parser.ParseAttributesJSON(output)
+}
 
+// selfTestsJSON is similar to selfTests but uses the JSON output
+// of the smartctl command
+func (d *Device) selfTestsJSON(ctx context.Context, o *Options) ([]SelfTest, error) {
+	opts := append(smartctlSelfTestLogOpts, "-d", d.Type, d.Name)
+	output, _, err := smartCtl(ctx, o, useJSON(opts)...)
+	recordMessages(d, output)
 	if err != nil {
 		return nil, err
 	}
-	mappedJSON, err := parseJSON(output)
-	if err != nil {
-		return nil, err
-	}
-	info := DeviceInfo{
-		Attributes: attributes(mappedJSON),
-	}
-	if statusData, ok := mappedJSON["smart_status"]; ok {
-		statusDetail, err := parseJSON([]byte(*statusData))
-		if err != nil {
-			return nil, err
-		}
-		if passed, ok := statusDetail["passed"]; ok {
-			if string(*passed) == "true" {
-				info.Available = true
-				info.Enabled = true
-				info.Healthy = true
-			}
-		}
-	}
-	return &info, nil
-}
-
-// sanitizeLabelValue removes unnecessary characters from label values
-func sanitizeLabelValue(value string) string {
-	value = strings.ReplaceAll(value, "\"", "")
-	value = strings.ReplaceAll(value, "\n", "")
-	return value
+	return parser.ParseSelfTestsJSON(output)
 }
@@ -12,14 +12,17 @@
 
 package smart
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestScanJSON(t *testing.T) {
 	if !JSONCapable() {
 		t.Fatal("not json capable")
 		return
 	}
-	devices, err := scanDevicesJSON()
+	devices, err := scanDevicesJSON(context.Background(), nil)
 	if err != nil {
 		t.Fatal("unable to scan devices", err)
 	}
@@ -12,7 +12,13 @@
 
 package smart
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+)
 
 func TestVersion(t *testing.T) {
 	_, err := Version()
@@ -22,7 +28,7 @@ func TestVersion(t *testing.T) {
 }
 
 func TestScan(t *testing.T) {
-	devices, err := scanDevices()
+	devices, err := scanDevices(context.Background(), nil)
 	if err != nil {
 		t.Fatal("unable to scan devices", err)
 	}
@@ -31,12 +37,75 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestToDevicesMegaRAIDNVMe(t *testing.T) {
+	devices := toDevices([]parser.Device{
+		{Name: "/dev/bus/0", Type: "megaraid,8", Protocol: "NVMe device"},
+		{Name: "/dev/bus/0", Type: "megaraid,9", Protocol: "SCSI device"},
+	})
+	if devices[0].Type != "nvme,megaraid,8" || devices[0].nativeNVMe() {
+		t.Fatal("expected an NVMe drive behind the controller", devices[0])
+	}
+	if devices[1].Type != "megaraid,9" {
+		t.Fatal("unexpected SAS drive behind the controller", devices[1])
+	}
+}
+
+func TestDeviceKey(t *testing.T) {
+	for _, test := range []struct {
+		d   Device
+		key string
+	}{
+		{Device{Name: "/dev/sda", Type: "sat"}, "/dev/sda"},
+		{Device{Name: "/dev/nvme0", Type: "nvme"}, "/dev/nvme0"},
+		{Device{Name: "/dev/bus/0", Type: "megaraid,1"}, "/dev/bus/0:megaraid,1"},
+		{Device{Name: "/dev/bus/0", Type: "nvme,megaraid,8"}, "/dev/bus/0:nvme,megaraid,8"},
+		{Device{Name: "/dev/sg1", Type: "cciss,0"}, "/dev/sg1:cciss,0"},
+	} {
+		if key := test.d.Key(); key != test.key {
+			t.Errorf("expected key %q of %+v, got %q", test.key, test.d, key)
+		}
+	}
+}
+
 func TestActive(t *testing.T) {
 	device := Device{
 		Name: "/foo", // non-existing device name should not be active
 		Type: "nvme",
 	}
-	if active, _ := device.active(); active {
+	if active, _ := device.Active(context.Background(), nil); active {
 		t.Fatal("device should not be active")
 	}
 }
+
+func TestCommandExtraArgs(t *testing.T) {
+	opts := &Options{SmartctlPath: "/opt/smartmontools/sbin/smartctl", ExtraArgs: []string{"-T", "permissive"}, Sudo: true}
+	name, args := opts.command([]string{"-i", "-H", "-d", "sat", "/dev/sda"})
+	if name != "sudo" || strings.Join(args, " ") != "-n /opt/smartmontools/sbin/smartctl -T permissive -i -H -d sat /dev/sda" {
+		t.Fatal("unexpected command", name, args)
+	}
+	// the extra arguments only apply to the devices
+	if _, args := opts.command(smartctlScanOpts); strings.Join(args, " ") != "-n /opt/smartmontools/sbin/smartctl --scan" {
+		t.Fatal("unexpected scan command", args)
+	}
+}
+
+func TestCommandDeviceType(t *testing.T) {
+	opts := &Options{ExtraArgs: []string{"-d", "sat,12", "-T", "permissive"}}
+	if _, args := opts.command([]string{"-A", "-d", "sat", "/dev/sdc"}); strings.Join(args, " ") != "-T permissive -A -d sat,12 /dev/sdc" {
+		t.Fatal("expected the extra -d to replace the type", args)
+	}
+}
+
+func TestCommandTolerance(t *testing.T) {
+	opts := &Options{Tolerance: TolerancePermissive}
+	if _, args := opts.command([]string{"-A", "-d", "sat", "/dev/sda"}); strings.Join(args, " ") != "-T permissive -A -d sat /dev/sda" {
+		t.Fatal("unexpected command", args)
+	}
+	opts.Tolerance = ToleranceNormal
+	if _, args := opts.command([]string{"-A", "-d", "sat", "/dev/sda"}); strings.Join(args, " ") != "-A -d sat /dev/sda" {
+		t.Fatal("the default tolerance should not be passed", args)
+	}
+	if err := ValidTolerance("lenient"); err == nil {
+		t.Fatal("expected an error validating an unknown tolerance")
+	}
+}
@@ -0,0 +1,167 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	// smartdAttrLogPattern matches the attribute logs written by 'smartd -A',
+	// e.g. attrlog.ST4000DM000-1F2168-Z302SXYZ.ata.csv
+	smartdAttrLogPattern = "attrlog.*.csv"
+	// smartdTailSize is the size of the end of an attribute log read to
+	// find its last line
+	smartdTailSize = 64 * 1024
+)
+
+var (
+	smartdValueDesc     = newDesc("smartmon_smartd_attribute_value", []string{"disk", "type", "smart_id", "name"})
+	smartdRawValueDesc  = newDesc("smartmon_smartd_attribute_raw_value", []string{"disk", "type", "smart_id", "name"})
+	smartdTimestampDesc = newDesc("smartmon_smartd_attribute_log_timestamp_seconds", []string{"disk", "type"})
+	smartdErrorDesc     = newDesc("smartmon_smartd_attribute_log_error", []string{"file", "reason"})
+)
+
+// SmartdCollector collects the attributes logged by smartd instead of
+// invoking smartctl, which avoids polling the devices twice on hosts
+// already running smartd
+type SmartdCollector struct {
+	dir string
+	// buildErrors counts the metrics which could not be built, e.g.
+	// because of an attribute logged twice
+	buildErrors uint64
+}
+
+// NewSmartdCollector creates a collector reading the attribute logs written
+// by 'smartd -A <dir>/attrlog.'
+func NewSmartdCollector(dir string) *SmartdCollector {
+	return &SmartdCollector{dir: dir}
+}
+
+// Describe implements the prometheus.Collector interface
+func (c *SmartdCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+// Ready returns an error if the attribute log directory cannot be read
+func (c *SmartdCollector) Ready(ctx context.Context) error {
+	_, err := ioutil.ReadDir(c.dir)
+	return err
+}
+
+// Close does nothing, the collector does not run any commands
+func (c *SmartdCollector) Close() {}
+
+// Collect implements the prometheus.Collector interface and reads the
+// last line of every attribute log
+func (c *SmartdCollector) Collect(ch chan<- prometheus.Metric) {
+	defer func() {
+		sendConstMetric(ch, &c.buildErrors, smartMonBuildErrorsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.buildErrors)))
+	}()
+	files, err := filepath.Glob(filepath.Join(c.dir, smartdAttrLogPattern))
+	if err != nil {
+		c.collectError(ch, c.dir, err)
+		return
+	}
+	for _, file := range files {
+		if err := c.collectAttrLog(ch, file); err != nil {
+			c.collectError(ch, file, err)
+		}
+	}
+}
+
+// collectError logs the error reading the attribute log and reports its
+// reason
+func (c *SmartdCollector) collectError(ch chan<- prometheus.Metric, file string, err error) {
+	reason := errorReason(err)
+	log.With("reason", reason).Infoln("error reading the smartd attribute log "+file+":", err)
+	sendConstMetric(ch, &c.buildErrors, smartdErrorDesc, prometheus.GaugeValue, 1.0, sanitizeValue(file), reason)
+}
+
+// collectAttrLog collects the attributes of the last line of the log.  The
+// device and attribute names are read from the log, so they are sanitized.
+func (c *SmartdCollector) collectAttrLog(ch chan<- prometheus.Metric, file string) error {
+	line, err := lastLine(file)
+	if err != nil {
+		return err
+	}
+	logged, attrs, err := parser.ParseSmartdAttrLog(line)
+	if err != nil {
+		return err
+	}
+	disk, devType := smartdDevice(file)
+	disk, devType = sanitizeValue(disk), sanitizeValue(devType)
+	sendConstMetric(ch, &c.buildErrors, smartdTimestampDesc, prometheus.GaugeValue, float64(logged.UnixNano())/1e9, disk, devType)
+	for _, attr := range attrs {
+		id := ""
+		name := sanitizeValue(attr.Name)
+		if attr.ID != 0 {
+			id = strconv.Itoa(attr.ID)
+			sendConstMetric(ch, &c.buildErrors, smartdValueDesc, prometheus.GaugeValue, attr.Value, disk, devType, id, name)
+		}
+		sendConstMetric(ch, &c.buildErrors, smartdRawValueDesc, prometheus.GaugeValue, attr.Raw, disk, devType, id, name)
+	}
+	return nil
+}
+
+// smartdDevice returns the device identity and type encoded in the name
+// of an attribute log, e.g. "ST4000DM000-1F2168-Z302SXYZ" and "ata"
+func smartdDevice(file string) (string, string) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "attrlog."), ".csv")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// lastLine reads the last non empty line of the file without reading the
+// whole log, which smartd keeps appending to
+func lastLine(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := stat.Size() - smartdTailSize
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	tail, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	tail = bytes.TrimRight(tail, "\r\n")
+	if len(tail) == 0 {
+		return "", errors.New("empty smartd attribute log")
+	}
+	return string(tail[bytes.LastIndexByte(tail, '\n')+1:]), nil
+}
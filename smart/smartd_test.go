@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestSmartdCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartd-attrlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the device and attribute names are not valid UTF-8
+	logs := map[string]string{
+		"attrlog.ST4000DM000-\xff-Z302SXYZ.scsi.csv": "2020-01-28 20:09:45;\ttemp\xffrature;35;\n",
+		"attrlog.WDC-WD40EFRX-WD-WCC4.ata.csv":       "not a log line\n",
+	}
+	for name, line := range logs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(line), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewSmartdCollector(dir)
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+	names := map[string]bool{}
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatal(err)
+		}
+		for _, label := range metric.Label {
+			if !utf8.ValidString(label.GetValue()) {
+				t.Errorf("expected a valid label value of %s, got %q", m.Desc(), label.GetValue())
+			}
+			if label.GetName() == "reason" && label.GetValue() != errorReasonParse {
+				t.Errorf("expected the reason %s, got %s", errorReasonParse, label.GetValue())
+			}
+		}
+		names[m.Desc().String()] = true
+	}
+	for _, desc := range []*prometheus.Desc{smartdTimestampDesc, smartdRawValueDesc, smartdErrorDesc, smartMonBuildErrorsDesc} {
+		if !names[desc.String()] {
+			t.Errorf("expected %s to be collected", desc)
+		}
+	}
+	if c.buildErrors != 0 {
+		t.Errorf("expected no build error, got %d", c.buildErrors)
+	}
+}
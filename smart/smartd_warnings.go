@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var (
+	smartdWarningsDesc            = newDesc("smartmon_smartd_warnings_total", []string{"disk", "reason"})
+	smartdWarningsErrorDesc       = newDesc("smartmon_smartd_warnings_error", []string{"file", "reason"})
+	smartdWarningsBuildErrorsDesc = newDesc("smartmon_smartd_warnings_build_errors_total", noLabels)
+)
+
+// smartdWarning identifies the counter of a warning
+type smartdWarning struct {
+	disk   string
+	reason string
+}
+
+// SmartdWarningsCollector counts the warnings issued by smartd.  smartd
+// executes a script for every warning with the -M exec directive, the
+// script appends a line to the warnings file with the SMARTD_DEVICE and
+// SMARTD_FAILTYPE environment variables separated by a tab, e.g.
+//
+//	/dev/sda	Temperature
+type SmartdWarningsCollector struct {
+	file string
+	// buildErrors counts the metrics which could not be built
+	buildErrors uint64
+
+	// mtx protects the offset read so far and the counts
+	mtx    sync.Mutex
+	offset int64
+	counts map[smartdWarning]float64
+}
+
+// NewSmartdWarningsCollector creates a collector counting the warnings
+// appended to the file
+func NewSmartdWarningsCollector(file string) *SmartdWarningsCollector {
+	return &SmartdWarningsCollector{
+		file:   file,
+		counts: map[smartdWarning]float64{},
+	}
+}
+
+// Describe implements the prometheus.Collector interface
+func (c *SmartdWarningsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+// Collect implements the prometheus.Collector interface and counts the
+// warnings appended to the file since the last collection
+func (c *SmartdWarningsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if err := c.read(); err != nil && !os.IsNotExist(err) {
+		reason := errorReason(err)
+		log.With("reason", reason).Infoln("error reading the smartd warnings file "+c.file+":", err)
+		sendConstMetric(ch, &c.buildErrors, smartdWarningsErrorDesc, prometheus.GaugeValue, 1.0, sanitizeValue(c.file), reason)
+	}
+	for warning, count := range c.counts {
+		sendConstMetric(ch, &c.buildErrors, smartdWarningsDesc, prometheus.CounterValue, count, warning.disk, warning.reason)
+	}
+	sendConstMetric(ch, &c.buildErrors, smartdWarningsBuildErrorsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.buildErrors)))
+}
+
+// read counts the complete lines appended to the file since the last
+// read.  The device and reason are written by a script, so they are
+// sanitized.  The file is read from the start again if it was truncated, e.g.
+// by log rotation, the counts keep increasing.  The caller must hold c.mtx.
+func (c *SmartdWarningsCollector) read() error {
+	f, err := os.Open(c.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() < c.offset {
+		c.offset = 0
+	}
+	if _, err := f.Seek(c.offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// an incomplete line is read again once the script finished writing it
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c.offset += int64(len(line))
+		fields := strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		c.counts[smartdWarning{disk: sanitizeValue(fields[0]), reason: sanitizeValue(fields[1])}]++
+	}
+}
@@ -0,0 +1,76 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSmartdWarningsCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartd-warnings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "warnings")
+	c := NewSmartdWarningsCollector(file)
+	if err := c.read(); !os.IsNotExist(err) {
+		t.Fatal("expected the missing file to be reported", err)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("/dev/sda\tTemperature\n/dev/sda\tTemperature\n/dev/sdb\tFailedHealthCheck\n/dev/sdb"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.read(); err != nil {
+		t.Fatal("unable to read warnings", err)
+	}
+	if c.counts[smartdWarning{"/dev/sda", "Temperature"}] != 2 || len(c.counts) != 2 {
+		t.Fatal("unexpected counts", c.counts)
+	}
+
+	// a truncated file is read from the start, the counts keep increasing
+	if err := ioutil.WriteFile(file, []byte("/dev/sda\tTemperature\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.read(); err != nil {
+		t.Fatal("unable to read warnings", err)
+	}
+	if c.counts[smartdWarning{"/dev/sda", "Temperature"}] != 3 {
+		t.Fatal("unexpected counts", c.counts)
+	}
+}
+
+func TestSmartdWarningsCollectorInvalidUTF8(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartd-warnings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "warnings")
+	if err := ioutil.WriteFile(file, []byte("/dev/sd\xff\tTemp\xfeerature\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewSmartdWarningsCollector(file)
+	ch := make(chan prometheus.Metric, 2)
+	c.Collect(ch)
+	if len(ch) != 2 || c.buildErrors != 0 {
+		t.Fatal("expected the warning and build errors metrics, found", len(ch), "metrics and", c.buildErrors, "build errors")
+	}
+	if c.counts[smartdWarning{"/dev/sd\ufffd", "Temp\ufffderature"}] != 1 {
+		t.Fatal("expected the sanitized warning to be counted", c.counts)
+	}
+}
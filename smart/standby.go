@@ -0,0 +1,98 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart/parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	smartMonStandbyCommandsDesc = prometheus.NewDesc("smartmon_device_standby_commands_total", "number of smartctl commands issued to the device while it was in standby or sleep, e.g. the power mode checks", deviceLabelNames, noConstLabels)
+	smartMonSkippedStandbyDesc  = prometheus.NewDesc("smartmon_device_skipped_to_preserve_standby_total", "number of collections which skipped all smartctl commands to the device as it was idle for longer than the idle threshold", deviceLabelNames, noConstLabels)
+)
+
+// skipIdle returns the idle time after which the device is not contacted
+func (o *CollectorOptions) skipIdle(d Device) time.Duration {
+	if opts := o.device(d); opts.SkipIdle > 0 {
+		return opts.SkipIdle
+	}
+	return o.SkipIdle
+}
+
+// idle returns true if the device completed no I/O for longer than the idle
+// threshold, it is then likely spun down and not contacted at all.  The
+// commands of smartctl are passed through to the device without being
+// counted in /proc/diskstats, so the collection does not reset the idle
+// time.
+func (c *Collector) idle(d Device) bool {
+	threshold := c.collectorOpts.skipIdle(d)
+	if threshold <= 0 {
+		return false
+	}
+	content, err := ioutil.ReadFile(procDiskstats)
+	if err != nil {
+		return false
+	}
+	s, found := d.ioStats(parseDiskstats(content))
+	if !found {
+		return false
+	}
+	total := s.reads + s.writes
+	now := time.Now()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	if st.ioChanged.IsZero() || total != st.ioTotal {
+		st.ioTotal, st.ioChanged = total, now
+		return false
+	}
+	return now.Sub(st.ioChanged) >= threshold
+}
+
+// recordStandbyCommand counts a command issued to a device found in
+// standby or sleep
+func (c *Collector) recordStandbyCommand(d Device, mode PowerMode) {
+	if mode != parser.PowerModeStandby && mode != parser.PowerModeSleep {
+		return
+	}
+	c.mtx.Lock()
+	c.state(d.Key()).standbyCommands++
+	c.mtx.Unlock()
+}
+
+// collectSkipped counts the collection skipping an idle device and serves
+// its cached metrics
+func (c *Collector) collectSkipped(ch chan<- prometheus.Metric, d Device) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	st.skippedStandby++
+	c.collectCached(ch, st)
+}
+
+// collectStandbyAudit reports the commands issued to the device while it
+// was spun down, and the collections which skipped it if idle devices are
+// skipped
+func (c *Collector) collectStandbyAudit(ch chan<- prometheus.Metric, d Device) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	c.constMetric(ch, smartMonStandbyCommandsDesc, prometheus.CounterValue, float64(st.standbyCommands), c.labelValues(d)...)
+	if c.collectorOpts.skipIdle(d) > 0 {
+		c.constMetric(ch, smartMonSkippedStandbyDesc, prometheus.CounterValue, float64(st.skippedStandby), c.labelValues(d)...)
+	}
+}
@@ -0,0 +1,88 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSkipIdle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { procDiskstats = path }(procDiskstats)
+	procDiskstats = filepath.Join(dir, "diskstats")
+	diskstats := func(reads string) {
+		content := "   8       0 sda " + reads + " 0 0 0 0 0 0 0 0 0 0\n"
+		if err := ioutil.WriteFile(procDiskstats, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every invocation is logged, the device is always in standby
+	invocations := filepath.Join(dir, "invocations")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\necho x >> " + invocations + "\necho 'Device is in STANDBY mode, exit(2)'\nexit 2\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, &CollectorOptions{SkipIdle: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "sat"}
+	commands := func() int {
+		content, _ := ioutil.ReadFile(invocations)
+		return len(content) / 2
+	}
+	ch := make(chan prometheus.Metric, 100)
+
+	// the device is checked until it was idle for the threshold
+	diskstats("100")
+	c.collectSMART(context.Background(), ch, d)
+	if commands() != 1 {
+		t.Fatal("expected the power mode to be checked, found", commands(), "commands")
+	}
+	c.mtx.Lock()
+	st := c.state(d.Key())
+	st.ioChanged = st.ioChanged.Add(-2 * time.Hour)
+	if st.standbyCommands != 1 {
+		t.Fatal("expected 1 command while in standby, found", st.standbyCommands)
+	}
+	c.mtx.Unlock()
+	c.collectSMART(context.Background(), ch, d)
+	if commands() != 1 {
+		t.Fatal("expected the idle device to be skipped, found", commands(), "commands")
+	}
+
+	// any I/O means the device may be spun up
+	diskstats("101")
+	c.collectSMART(context.Background(), ch, d)
+	if commands() != 2 {
+		t.Fatal("expected the power mode to be checked after I/O, found", commands(), "commands")
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if st.skippedStandby != 1 || st.standbyCommands != 2 {
+		t.Fatal("unexpected counters", st.skippedStandby, st.standbyCommands)
+	}
+}
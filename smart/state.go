@@ -0,0 +1,268 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var (
+	smartMonPresentDesc   = prometheus.NewDesc("smartmon_device_present", "1 if the device was found by the last scan, 0 if a device seen before is missing", deviceLabelNames, noConstLabels)
+	smartMonFirstSeenDesc = prometheus.NewDesc("smartmon_device_first_seen_timestamp_seconds", "unix time the device was first found by a scan", deviceLabelNames, noConstLabels)
+	smartMonLastSeenDesc  = prometheus.NewDesc("smartmon_device_last_seen_timestamp_seconds", "unix time the device was last found by a scan", deviceLabelNames, noConstLabels)
+)
+
+// The events logged when the devices found by the scans change
+const (
+	// deviceEventAdded is a device found for the first time
+	deviceEventAdded = "added"
+	// deviceEventRemoved is a device seen before which is missing
+	deviceEventRemoved = "removed"
+	// deviceEventReturned is a missing device found again
+	deviceEventReturned = "returned"
+	// deviceEventReplaced is another disk found under the name of a device,
+	// i.e. with another serial number
+	deviceEventReplaced = "replaced"
+)
+
+// absentRetention is how long a missing device is reported as absent
+// before it is forgotten
+var absentRetention = 30 * 24 * time.Hour
+
+// persistedState is the state of the collector saved to
+// CollectorOptions.StateFile, so the counters and the devices seen survive
+// restarts of the exporter
+type persistedState struct {
+	Devices map[string]persistedDevice `json:"devices"`
+}
+
+// persistedDevice is the saved deviceState of a device
+type persistedDevice struct {
+	Labels          []string           `json:"labels"`
+	FirstSeen       time.Time          `json:"first_seen,omitempty"`
+	LastSeen        time.Time          `json:"last_seen"`
+	Absent          bool               `json:"absent,omitempty"`
+	LastCollected   time.Time          `json:"last_collected"`
+	WakeupsAvoided  uint64             `json:"wakeups_avoided"`
+	Woken           uint64             `json:"woken"`
+	StandbyCommands uint64             `json:"standby_commands,omitempty"`
+	SkippedStandby  uint64             `json:"skipped_standby,omitempty"`
+	Unsupported     string             `json:"unsupported,omitempty"`
+	Firmware        string             `json:"firmware,omitempty"`
+	Serial          string             `json:"serial,omitempty"`
+	FirmwareChanged time.Time          `json:"firmware_changed,omitempty"`
+	Attributes      map[string]float64 `json:"attributes,omitempty"`
+	// Increases are the raw values and the sums of the increases of the
+	// attributes counted by smartmon_attribute_increase_total by ID
+	Increases map[int]attributeIncrease `json:"increases,omitempty"`
+}
+
+// loadState restores the state of the devices saved to the state file.  A
+// missing state file is not an error, the exporter starts without state.
+func (c *Collector) loadState() error {
+	content, err := ioutil.ReadFile(c.collectorOpts.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New("Unable to read state file: " + err.Error())
+	}
+	state := persistedState{}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return errors.New("Unable to parse state file: " + err.Error())
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for name, saved := range state.Devices {
+		// the labels of a truncated or edited state file cannot be used
+		if len(saved.Labels) != len(deviceLabelNames) {
+			log.Warnln("Ignoring the state of device", name, "with", len(saved.Labels), "labels instead of", len(deviceLabelNames), "in", c.collectorOpts.StateFile)
+			continue
+		}
+		c.devices[name] = &deviceState{
+			labels:          saved.Labels,
+			firstSeen:       saved.FirstSeen,
+			lastSeen:        saved.LastSeen,
+			absent:          saved.Absent,
+			lastCollected:   saved.LastCollected,
+			wakeupsAvoided:  saved.WakeupsAvoided,
+			woken:           saved.Woken,
+			standbyCommands: saved.StandbyCommands,
+			skippedStandby:  saved.SkippedStandby,
+			unsupported:     saved.Unsupported,
+			firmware:        saved.Firmware,
+			serial:          saved.Serial,
+			firmwareChanged: saved.FirmwareChanged,
+			attributes:      saved.Attributes,
+			increases:       saved.Increases,
+		}
+	}
+	return nil
+}
+
+// saveState writes the state of the devices to the state file, the file is
+// replaced atomically so a crash cannot leave a truncated state behind
+func (c *Collector) saveState() {
+	if c.collectorOpts.StateFile == "" {
+		return
+	}
+	state := persistedState{Devices: map[string]persistedDevice{}}
+	c.mtx.Lock()
+	for name, st := range c.devices {
+		if st.labels == nil {
+			continue
+		}
+		state.Devices[name] = persistedDevice{
+			Labels:          st.labels,
+			FirstSeen:       st.firstSeen,
+			LastSeen:        st.lastSeen,
+			Absent:          st.absent,
+			LastCollected:   st.lastCollected,
+			WakeupsAvoided:  st.wakeupsAvoided,
+			Woken:           st.woken,
+			StandbyCommands: st.standbyCommands,
+			SkippedStandby:  st.skippedStandby,
+			Unsupported:     st.unsupported,
+			Firmware:        st.firmware,
+			Serial:          st.serial,
+			FirmwareChanged: st.firmwareChanged,
+			Attributes:      st.attributes,
+			Increases:       st.increases,
+		}
+	}
+	content, err := json.Marshal(state)
+	c.mtx.Unlock()
+	if err == nil {
+		err = writeFileAtomic(c.collectorOpts.StateFile, content)
+	}
+	if err != nil {
+		log.Errorln("Unable to save state file", c.collectorOpts.StateFile+":", err)
+	}
+}
+
+// writeFileAtomic writes the file through a temporary file renamed over it
+func writeFileAtomic(filename string, content []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// collectAbsent reports the devices seen before which the scan did not
+// find, and forgets the devices missing for longer than absentRetention
+func (c *Collector) collectAbsent(ch chan<- prometheus.Metric, devices []Device) {
+	found := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		// the devices behind a controller were kept under the name of the
+		// controller by the previous versions
+		found[d.Key()], found[d.Name] = true, true
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for name, st := range c.devices {
+		if found[name] || st.labels == nil {
+			continue
+		}
+		if time.Since(st.lastSeen) > absentRetention {
+			delete(c.devices, name)
+			continue
+		}
+		if !st.absent {
+			st.absent = true
+			logDeviceEvent(deviceEventRemoved, name, st.labels)
+		}
+		c.constMetric(ch, smartMonPresentDesc, prometheus.GaugeValue, 0.0, st.labels...)
+		c.collectSeen(ch, st.labels, st.firstSeen, st.lastSeen)
+	}
+}
+
+// collectPresent reports the device as present and remembers its labels to
+// report it once it goes missing.  The changes of the devices found are
+// logged as events.
+func (c *Collector) collectPresent(ch chan<- prometheus.Metric, d Device) {
+	labels := c.labelValues(d)
+	now := time.Now()
+	c.mtx.Lock()
+	c.followRename(d, labels)
+	st := c.state(d.Key())
+	switch {
+	case st.labels == nil:
+		st.firstSeen = now
+		logDeviceEvent(deviceEventAdded, d.Name, labels)
+	case st.labels[4] != labels[4] && st.labels[4] != "" && labels[4] != "":
+		st.firstSeen = now
+		// the increases of the previous disk do not carry over
+		st.increases = nil
+		logDeviceEvent(deviceEventReplaced, d.Name, labels)
+	case st.absent:
+		logDeviceEvent(deviceEventReturned, d.Name, labels)
+	}
+	if st.firstSeen.IsZero() {
+		// saved by a version which did not record it
+		st.firstSeen = now
+	}
+	st.labels = labels
+	st.device = d
+	st.lastSeen = now
+	st.absent = false
+	firstSeen := st.firstSeen
+	c.mtx.Unlock()
+	c.constMetric(ch, smartMonPresentDesc, prometheus.GaugeValue, 1.0, labels...)
+	c.collectSeen(ch, labels, firstSeen, now)
+}
+
+// collectSeen reports when the device was first and last found by a scan
+func (c *Collector) collectSeen(ch chan<- prometheus.Metric, labels []string, firstSeen, lastSeen time.Time) {
+	if !firstSeen.IsZero() {
+		c.constMetric(ch, smartMonFirstSeenDesc, prometheus.GaugeValue, float64(firstSeen.Unix()), labels...)
+	}
+	c.constMetric(ch, smartMonLastSeenDesc, prometheus.GaugeValue, float64(lastSeen.Unix()), labels...)
+}
+
+// logDeviceEvent logs a change of the devices found with the labels of
+// the device as fields, e.g. for tools tracking the drive swaps
+func logDeviceEvent(event, name string, labels []string) {
+	logger := log.With("event", "device_"+event).With("device", name)
+	for i, value := range labels {
+		if value != "" {
+			logger = logger.With(deviceLabelNames[i], value)
+		}
+	}
+	logger.Infoln("Device", name, event)
+}
+
+// recordAttributes remembers the last raw values of the attributes of the
+// device and adds them to its history
+func (c *Collector) recordAttributes(d Device, values map[string]float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	st.attributes = values
+	c.recordHistory(st, values, time.Now())
+}
@@ -0,0 +1,142 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &CollectorOptions{StateFile: filepath.Join(dir, "state.json")}
+
+	c, err := NewCollector(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mtx.Lock()
+	st := c.state("/dev/sda")
+	st.labels = []string{"/dev/sda", "sat", "", "", "Z3YX2S30"}
+	st.lastSeen = time.Now()
+	st.wakeupsAvoided = 3
+	st.attributes = map[string]float64{"power_on_hours": 1234}
+	c.mtx.Unlock()
+	c.saveState()
+	c.Close()
+
+	c, err = NewCollector(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	st = c.devices["/dev/sda"]
+	if st == nil || st.wakeupsAvoided != 3 || st.attributes["power_on_hours"] != 1234 {
+		t.Fatal("unexpected restored state", st)
+	}
+
+	// the device is missing from the scan
+	ch := make(chan prometheus.Metric, 2)
+	c.collectAbsent(ch, nil)
+	if len(ch) != 2 || !st.absent {
+		t.Fatal("expected the missing device to be reported as absent with its last seen time")
+	}
+}
+
+func TestStateInvalidLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &CollectorOptions{StateFile: filepath.Join(dir, "state.json")}
+	state := `{"devices": {
+		"/dev/sda": {"labels": []},
+		"/dev/sdb": {"labels": ["/dev/sdb", "sat"]},
+		"/dev/sdc": {"labels": ["/dev/sdc", "sat", "", "", "Z3YX2S31"], "wakeups_avoided": 2}
+	}}`
+	if err := ioutil.WriteFile(opts.StateFile, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCollector(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if len(c.devices) != 1 || c.devices["/dev/sdc"] == nil {
+		t.Fatal("expected the state of /dev/sdc only, got", c.devices)
+	}
+
+	// the devices whose state was ignored are collected from scratch
+	ch := make(chan prometheus.Metric, 10)
+	c.collectPresent(ch, Device{Name: "/dev/sda", Type: "sat"})
+	c.collectAbsent(ch, []Device{{Name: "/dev/sda", Type: "sat"}})
+}
+
+func TestDeviceEvents(t *testing.T) {
+	c, err := NewCollector(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/smartmon-test-disk", Type: "sat"}
+	ch := make(chan prometheus.Metric, 3)
+	c.collectPresent(ch, d)
+	if len(ch) != 3 {
+		t.Fatal("expected the present, first seen and last seen metrics, got", len(ch))
+	}
+	st := c.devices[d.Name]
+	firstSeen := st.firstSeen
+	if firstSeen.IsZero() || st.lastSeen != firstSeen {
+		t.Fatal("unexpected first seen time", st.firstSeen, st.lastSeen)
+	}
+
+	// another disk is installed in place of the device
+	st.labels = []string{d.Name, "sat", "", "", "Z302SXYZ"}
+	st.firstSeen = firstSeen.Add(-time.Hour)
+	c.collectAbsent(make(chan prometheus.Metric, 3), nil)
+	if !st.absent {
+		t.Fatal("expected the device to be absent")
+	}
+	c.identities[d.Name] = Identity{Serial: "Z302SABC"}
+	c.collectPresent(make(chan prometheus.Metric, 3), d)
+	if st.absent || st.firstSeen.Before(firstSeen) {
+		t.Fatal("expected the replaced device to be first seen again", st.absent, st.firstSeen)
+	}
+}
+
+func TestStateBehindController(t *testing.T) {
+	c, err := NewCollector(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the disks behind a controller share its name but not their state
+	c.recordHealth(Device{Name: "/dev/bus/0", Type: "megaraid,0"}, &DeviceInfo{Healthy: true})
+	c.recordHealth(Device{Name: "/dev/bus/0", Type: "megaraid,1"}, &DeviceInfo{Failed: true})
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.state("/dev/bus/0:megaraid,0").unhealthy || !c.state("/dev/bus/0:megaraid,1").unhealthy {
+		t.Error("expected the state of each disk behind the controller")
+	}
+}
@@ -0,0 +1,166 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultTemperatureThreshold is the default temperature in Celsius above
+// which a device is counted as over temperature
+const defaultTemperatureThreshold = 60
+
+// temperatureAttributes are the names of the attributes reporting the
+// temperature of the device in Celsius, in order of preference
+var temperatureAttributes = []string{"temperature_celsius", "airflow_temperature_cel", "temperature"}
+
+// The metrics summarizing the devices of the node, so a single series can be
+// alerted on.  The devices which were not collected, e.g. in standby, are
+// counted with the data of their last collection.
+var (
+	smartMonDevicesDesc               = prometheus.NewDesc("smartmon_devices_total", "number of devices found by the last scan", noLabels, noConstLabels)
+	smartMonDevicesUnhealthyDesc      = prometheus.NewDesc("smartmon_devices_unhealthy", "number of devices failing the SMART overall-health self-assessment", noLabels, noConstLabels)
+	smartMonDevicesFailingPrefailDesc = prometheus.NewDesc("smartmon_devices_failing_prefail_attributes", "number of devices with a pre-failure attribute at or below its threshold", noLabels, noConstLabels)
+	smartMonDevicesOverTempDesc       = prometheus.NewDesc("smartmon_devices_over_temperature", "number of devices whose temperature exceeds the temperature threshold", noLabels, noConstLabels)
+)
+
+// temperatureThreshold returns the temperature in Celsius above which the
+// device is over temperature
+func (o *CollectorOptions) temperatureThreshold(d Device) float64 {
+	if opts := o.device(d); opts.TemperatureThreshold > 0 {
+		return opts.TemperatureThreshold
+	}
+	if o.TemperatureThreshold > 0 {
+		return o.TemperatureThreshold
+	}
+	return defaultTemperatureThreshold
+}
+
+// temperature returns the temperature in Celsius last reported by the
+// attributes of the device
+func (st *deviceState) temperature() (float64, bool) {
+	return temperatureOf(st.attributes)
+}
+
+// Temperature returns the temperature in Celsius reported by the ATA or
+// NVMe attributes of a device
+func Temperature(attrs []Attribute) (float64, bool) {
+	values := make(map[string]float64, len(attrs))
+	for _, attr := range attrs {
+		values[strings.ToLower(attr.Name)] = attr.Raw
+	}
+	return temperatureOf(values)
+}
+
+// temperatureOf returns the temperature in Celsius of the raw values of
+// the attributes by name.  The raw value of the ATA attributes may hold the
+// minimum and maximum temperatures in its upper bytes.
+func temperatureOf(values map[string]float64) (float64, bool) {
+	for _, name := range temperatureAttributes {
+		if t, found := values[name]; found {
+			if t > 255 {
+				t = float64(int64(t) & 0xff)
+			}
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// prefailFailing returns true if the attribute is a pre-failure attribute
+// which fails.  The pre-failure attributes are flagged P, or with bit 0 of
+// the hexadecimal flags of the old format.
+func prefailFailing(attr Attribute) bool {
+	prefail := strings.HasPrefix(attr.Flags, "P")
+	if flags, err := strconv.ParseUint(attr.Flags, 0, 16); err == nil {
+		prefail = flags&1 != 0
+	}
+	return prefail && attributeFailing(attr)
+}
+
+// attributeFailing returns true if smartctl reports the attribute as
+// failing now or its normalized value reached its threshold
+func attributeFailing(attr Attribute) bool {
+	return attr.WhenFailed == "FAILING_NOW" || belowThreshold(attr)
+}
+
+// belowThreshold returns true if the normalized value of the attribute
+// reached its threshold, which smartctl reports as failing.  A threshold of
+// 0 is never reached.
+func belowThreshold(attr Attribute) bool {
+	return attr.Threshold > 0 && attr.Value <= attr.Threshold
+}
+
+// recordHealth remembers if the device failed its health self-assessment
+// and notifies the changes
+func (c *Collector) recordHealth(d Device, info *DeviceInfo) {
+	c.mtx.Lock()
+	old := c.state(d.Key()).healthChange(info.Failed)
+	c.mtx.Unlock()
+	if old != "" {
+		c.notify(d, Notification{Event: EventHealth, OldState: old, NewState: stateOf(info.Failed)})
+	}
+}
+
+// recordPrefail remembers if a pre-failure attribute of the device fails
+// and notifies the attributes crossing their threshold
+func (c *Collector) recordPrefail(d Device, attrs []Attribute) {
+	failing := false
+	for _, attr := range attrs {
+		failing = failing || prefailFailing(attr)
+	}
+	c.mtx.Lock()
+	st := c.state(d.Key())
+	st.failingPrefail = failing
+	changes := st.attributeChanges(attrs)
+	c.mtx.Unlock()
+	for i, attr := range attrs {
+		if old, found := changes[i]; found {
+			attr := attr
+			c.notify(d, Notification{Event: EventAttribute, OldState: old, NewState: stateOf(attributeFailing(attr)), Attribute: &attr})
+		}
+	}
+}
+
+// summary counts the devices which are unhealthy, failing a pre-failure
+// attribute and over temperature
+func (c *Collector) summary(devices []Device) (unhealthy, failing, hot int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, d := range devices {
+		st := c.state(d.Key())
+		if st.unhealthy {
+			unhealthy++
+		}
+		if st.failingPrefail {
+			failing++
+		}
+		if t, found := st.temperature(); found && t > c.collectorOpts.temperatureThreshold(d) {
+			hot++
+		}
+	}
+	return unhealthy, failing, hot
+}
+
+// collectSummary collects the number of devices found by the scan and of
+// the devices which are unhealthy, failing or too hot
+func (c *Collector) collectSummary(ch chan<- prometheus.Metric, devices []Device) {
+	unhealthy, failing, hot := c.summary(devices)
+	c.constMetric(ch, smartMonDevicesDesc, prometheus.GaugeValue, float64(len(devices)))
+	c.constMetric(ch, smartMonDevicesUnhealthyDesc, prometheus.GaugeValue, float64(unhealthy))
+	c.constMetric(ch, smartMonDevicesFailingPrefailDesc, prometheus.GaugeValue, float64(failing))
+	c.constMetric(ch, smartMonDevicesOverTempDesc, prometheus.GaugeValue, float64(hot))
+}
@@ -0,0 +1,85 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import "testing"
+
+func TestPrefailFailing(t *testing.T) {
+	for _, test := range []struct {
+		attr    Attribute
+		failing bool
+	}{
+		{Attribute{ID: 5, Flags: "PO--CK", Value: 5, Threshold: 10}, true},
+		{Attribute{ID: 5, Flags: "PO--CK", Value: 100, Threshold: 10, WhenFailed: "FAILING_NOW"}, true},
+		{Attribute{ID: 5, Flags: "PO--CK", Value: 100, Threshold: 10}, false},
+		{Attribute{ID: 5, Flags: "0x0033", Value: 10, Threshold: 10}, true},
+		{Attribute{ID: 194, Flags: "-O---K", Value: 10, Threshold: 10}, false},
+		{Attribute{ID: 194, Flags: "0x0022", Value: 10, Threshold: 10}, false},
+		{Attribute{ID: 9, Flags: "PO--CK", Value: 0, Threshold: 0}, false},
+	} {
+		if failing := prefailFailing(test.attr); failing != test.failing {
+			t.Errorf("expected %+v failing %v", test.attr, test.failing)
+		}
+	}
+}
+
+func TestCollectSummary(t *testing.T) {
+	c, err := NewCollector(nil, &CollectorOptions{Devices: []DeviceOptions{{Type: "nvme", TemperatureThreshold: 70}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	devices := []Device{{Name: "/dev/sda", Type: "sat"}, {Name: "/dev/sdb", Type: "sat"}, {Name: "/dev/nvme0", Type: "nvme"}}
+	c.mtx.Lock()
+	c.state("/dev/sda").unhealthy = true
+	c.state("/dev/sda").attributes = map[string]float64{"temperature_celsius": 0x2d001e003d}
+	c.state("/dev/sdb").failingPrefail = true
+	c.state("/dev/nvme0").attributes = map[string]float64{"temperature": 65}
+	c.mtx.Unlock()
+	// sda is unhealthy and at 61°C, sdb failing and nvme0 below its threshold
+	unhealthy, failing, hot := c.summary(devices)
+	if unhealthy != 1 || failing != 1 || hot != 1 {
+		t.Fatal("unexpected summary", unhealthy, failing, hot)
+	}
+}
+
+func TestBelowThreshold(t *testing.T) {
+	for _, test := range []struct {
+		attr  Attribute
+		below bool
+	}{
+		{Attribute{ID: 5, Value: 10, Threshold: 10}, true},
+		{Attribute{ID: 5, Value: 11, Threshold: 10}, false},
+		{Attribute{ID: 194, Value: 0, Threshold: 0}, false},
+	} {
+		if below := belowThreshold(test.attr); below != test.below {
+			t.Errorf("expected %+v below its threshold %v", test.attr, test.below)
+		}
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	for _, test := range []struct {
+		attrs       []Attribute
+		temperature float64
+		found       bool
+	}{
+		{[]Attribute{{ID: 194, Name: "Temperature_Celsius", Raw: 0x2d001e003d}}, 61, true},
+		{[]Attribute{{ID: 190, Name: "Airflow_Temperature_Cel", Raw: 40}, {ID: 194, Name: "Temperature_Celsius", Raw: 42}}, 42, true},
+		{[]Attribute{{Name: "Temperature", Raw: 38}}, 38, true},
+		{[]Attribute{{ID: 5, Name: "Reallocated_Sector_Ct"}}, 0, false},
+	} {
+		if temperature, found := Temperature(test.attrs); temperature != test.temperature || found != test.found {
+			t.Errorf("expected %v %v for %+v, got %v %v", test.temperature, test.found, test.attrs, temperature, found)
+		}
+	}
+}
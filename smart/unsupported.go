@@ -0,0 +1,55 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var smartMonSupportedDesc = prometheus.NewDesc("smartmon_device_supported", "0 if the device reported that it lacks SMART capability, e.g. a virtual disk or a card reader, and is no longer queried", deviceLabelNames, noConstLabels)
+
+// markUnsupported remembers that the device lacks SMART capability, so it
+// is no longer queried.  The mark is kept in the state file, bound to the
+// identity of the device so another device found under the same name is
+// queried again.
+func (c *Collector) markUnsupported(d Device) {
+	id := strings.Join(c.labelValues(d), " ")
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	if st.unsupported == id {
+		return
+	}
+	log.Infoln("Device", d.Name, "lacks SMART capability, it is no longer queried")
+	st.unsupported = id
+}
+
+// unsupported returns true if the device was found to lack SMART capability
+func (c *Collector) unsupported(d Device) bool {
+	id := strings.Join(c.labelValues(d), " ")
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	st := c.state(d.Key())
+	if st.unsupported != "" && st.unsupported != id {
+		st.unsupported = ""
+	}
+	return st.unsupported != ""
+}
+
+// collectSupported reports if the device is queried for its SMART data
+func (c *Collector) collectSupported(ch chan<- prometheus.Metric, d Device, supported bool) {
+	c.constMetric(ch, smartMonSupportedDesc, prometheus.GaugeValue, boolToMetric(supported), c.labelValues(d)...)
+}
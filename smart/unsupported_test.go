@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestUnsupported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// every invocation is logged, the device lacks SMART capability
+	invocations := filepath.Join(dir, "invocations")
+	path := filepath.Join(dir, "smartctl")
+	script := "#!/bin/sh\necho x >> " + invocations + "\ncat <<'EOF'\n=== START OF INFORMATION SECTION ===\nVendor:               QEMU\nProduct:              QEMU HARDDISK\nSMART support is:     Unavailable - device lacks SMART capability.\nEOF\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCollector(&Options{SmartctlPath: path, DisableJSON: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := Device{Name: "/dev/sda", Type: "scsi"}
+	commands := func() int {
+		content, _ := ioutil.ReadFile(invocations)
+		return len(content) / 2
+	}
+
+	// the power mode and the info are read, but not the attributes
+	c.collectDevice(context.Background(), make(chan prometheus.Metric, 100), d)
+	if commands() != 2 {
+		t.Fatal("expected 2 commands, found", commands())
+	}
+	if !c.unsupported(d) {
+		t.Fatal("expected the device to be unsupported")
+	}
+	c.collectDevice(context.Background(), make(chan prometheus.Metric, 100), d)
+	if commands() != 2 {
+		t.Fatal("expected the unsupported device not to be queried, found", commands(), "commands")
+	}
+
+	// another device under the same name is queried again
+	c.identitiesMtx.Lock()
+	c.identities[d.Name] = Identity{Serial: "1234"}
+	c.identitiesMtx.Unlock()
+	if c.unsupported(d) {
+		t.Fatal("expected another device to be queried")
+	}
+}
@@ -0,0 +1,103 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	smartMonMDDesc  = prometheus.NewDesc("smartmon_device_md_info", "md RAID array the device, or one of its partitions, is a member of", []string{"disk", "type", "by_id", "wwn", "serial", "partition", "md_device", "raid_level", "slot_state"}, noConstLabels)
+	smartMonLVMDesc = prometheus.NewDesc("smartmon_device_lvm_info", "LVM logical volume stored on the device or one of its partitions", []string{"disk", "type", "by_id", "wwn", "serial", "partition", "volume"}, noConstLabels)
+)
+
+// mdMember is the membership of a block device in an md RAID array
+type mdMember struct {
+	member    string
+	mdDevice  string
+	raidLevel string
+	slotState string
+}
+
+// lvmVolume is an LVM logical volume stored on a block device
+type lvmVolume struct {
+	member string
+	volume string
+}
+
+// mdMembers lists the members of the md RAID arrays found in sysfs, the
+// members of an array are listed in /sys/block/mdX/md/dev-<member>
+func mdMembers() []mdMember {
+	members := []mdMember{}
+	slots, _ := filepath.Glob(filepath.Join(sysBlock, "md*", "md", "dev-*"))
+	for _, slot := range slots {
+		mdDir := filepath.Dir(slot)
+		members = append(members, mdMember{
+			member:    strings.TrimPrefix(filepath.Base(slot), "dev-"),
+			mdDevice:  filepath.Base(filepath.Dir(mdDir)),
+			raidLevel: readSysfs(filepath.Join(mdDir, "level")),
+			slotState: readSysfs(filepath.Join(slot, "state")),
+		})
+	}
+	return members
+}
+
+// lvmVolumes lists the LVM logical volumes held by the block devices, the
+// device mapper uuid of a logical volume starts with "LVM-"
+func lvmVolumes(devices map[string]string) []lvmVolume {
+	names := make([]string, 0, len(devices))
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	volumes := []lvmVolume{}
+	for _, name := range names {
+		dmDir := filepath.Join(sysBlock, name, "dm")
+		if !strings.HasPrefix(readSysfs(filepath.Join(dmDir, "uuid")), "LVM-") {
+			continue
+		}
+		volumes = append(volumes, lvmVolume{member: name, volume: readSysfs(filepath.Join(dmDir, "name"))})
+	}
+	return volumes
+}
+
+// readSysfs reads a sysfs attribute, or returns an empty string if the
+// attribute cannot be read
+func readSysfs(path string) string {
+	value, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// collectVolumes reports the md RAID arrays and LVM logical volumes the
+// device is a member of
+func (c *Collector) collectVolumes(ch chan<- prometheus.Metric, d Device) {
+	devices := d.blockDevices()
+	for _, m := range mdMembers() {
+		if partition, found := devices[m.member]; found {
+			labels := append(c.labelValues(d), partition, m.mdDevice, m.raidLevel, m.slotState)
+			c.constMetric(ch, smartMonMDDesc, prometheus.GaugeValue, 1.0, labels...)
+		}
+	}
+	for _, v := range lvmVolumes(devices) {
+		labels := append(c.labelValues(d), devices[v.member], sanitizeValue(v.volume))
+		c.constMetric(ch, smartMonLVMDesc, prometheus.GaugeValue, 1.0, labels...)
+	}
+}
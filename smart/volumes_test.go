@@ -0,0 +1,68 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package smart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSysfs creates the sysfs attributes under the fake /sys/block
+func writeSysfs(t *testing.T, attributes map[string]string) {
+	for path, value := range attributes {
+		path = filepath.Join(sysBlock, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysBlock = path }(sysBlock)
+	sysBlock = dir
+
+	writeSysfs(t, map[string]string{
+		"md0/md/level":          "raid1",
+		"md0/md/dev-sda1/state": "in_sync",
+		"md0/md/dev-sdb1/state": "faulty",
+		"dm-0/dm/uuid":          "LVM-abcdef",
+		"dm-0/dm/name":          "data-backups",
+		"dm-1/dm/uuid":          "CRYPT-LUKS2-abcdef",
+		"dm-1/dm/name":          "luks-data",
+	})
+
+	members := mdMembers()
+	if len(members) != 2 {
+		t.Fatal("expected 2 md members, found", members)
+	}
+	if members[0] != (mdMember{member: "sda1", mdDevice: "md0", raidLevel: "raid1", slotState: "in_sync"}) {
+		t.Fatal("unexpected md member", members[0])
+	}
+	if members[1].member != "sdb1" || members[1].slotState != "faulty" {
+		t.Fatal("unexpected md member", members[1])
+	}
+
+	volumes := lvmVolumes(map[string]string{"sda": "sda", "sda1": "sda1", "md0": "sda1", "dm-0": "sda1", "dm-1": "sda1"})
+	if len(volumes) != 1 || volumes[0] != (lvmVolume{member: "dm-0", volume: "data-backups"}) {
+		t.Fatal("unexpected LVM volumes", volumes)
+	}
+}
@@ -13,10 +13,16 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
+	"github.com/pgier/smartmon-exporter/config"
+	"github.com/pgier/smartmon-exporter/notify"
 	"github.com/pgier/smartmon-exporter/smart"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -25,46 +31,385 @@ import (
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
-const (
-	rootuid = 0
-)
-
 var (
-	listenAddress = kingpin.Flag("web.listen-address", "Address on which to expose metrics and web interface.").Default(":9151").String()
-	outputFile    = kingpin.Flag("output-file", "Filename which to write metrics.").Default("").String()
+	listenAddresses    = kingpin.Flag("web.listen-address", "Address on which to expose metrics and web interface, host:port or unix:///path/to/socket.  Repeat to listen on several addresses.").Default(":9151").Strings()
+	outputFile         = kingpin.Flag("output-file", "Filename which to write metrics, in the --output-format.").Default("").String()
+	maxRequests        = kingpin.Flag("web.max-requests", "Maximum number of parallel scrape requests, 0 is unlimited.").Default("40").Int()
+	scrapeTimeout      = kingpin.Flag("web.scrape-timeout", "Time after which a scrape is answered with 503 Service Unavailable, 0 never times out.").Default("0s").Duration()
+	disableCompression = kingpin.Flag("web.disable-compression", "Never gzip the metrics, even when the scraper accepts it.").Default("false").Bool()
+	enablePprof        = kingpin.Flag("web.enable-pprof", "Serve the Go runtime profiles on /debug/pprof/ to profile the exporter.").Default("false").Bool()
+	shutdownTimeout    = kingpin.Flag("web.shutdown-timeout", "Time to wait for in-flight scrapes to complete on shutdown.").Default("30s").Duration()
+	ctlPath            = kingpin.Flag("smart.ctl-path", "smartctl binary to execute, defaults to looking up smartctl in the PATH.").Default("").String()
+	ctlExtraArgs       = kingpin.Flag("smart.ctl-extra-args", "Arguments passed to every smartctl command querying a device, e.g. \"-T permissive\".").Default("").String()
+	tolerance          = kingpin.Flag("smart.tolerance", "Tolerance of smartctl to failed ATA commands, passed as -T to the commands querying a device, e.g. permissive for old drives which return no data otherwise.").Default(smart.ToleranceNormal).Enum(smart.Tolerances...)
+	useSudo            = kingpin.Flag("smart.use-sudo", "Run smartctl through 'sudo -n' so the exporter can run as an unprivileged user.").Default("false").Bool()
+	helperPath         = kingpin.Flag("smart.helper-path", "Privileged helper (e.g. a setuid wrapper) to execute with the smartctl arguments instead of smartctl.").Default("").String()
+	recordDir          = kingpin.Flag("smart.record-dir", "Save the last output of every smartctl command to a timestamped file of this directory, e.g. to attach to a bug report.").Default("").String()
+	replayDir          = kingpin.Flag("smart.replay-dir", "Serve the metrics from the smartctl output recorded with --smart.record-dir in this directory instead of executing smartctl.").Default("").String()
+	retries            = kingpin.Flag("smart.retries", "Number of times a smartctl command failing with a transient error, e.g. a busy device, is retried.").Default("0").Int()
+	retryBackoff       = kingpin.Flag("smart.retry-backoff", "Wait before the first retry of a smartctl command, doubled on every retry.").Default("1s").Duration()
+	backend            = kingpin.Flag("smart.backend", "Backend reading the SMART data, native reads the NVMe and ATA devices with ioctls instead of running smartctl.").Default(smart.BackendSmartctl).Enum(smart.BackendSmartctl, smart.BackendSmartctlText, smart.BackendSmartctlJSON, smart.BackendNative)
+	deviceLabel        = kingpin.Flag("smart.device-label", "Identifier of the devices used as the disk label, the kernel name, the /dev/disk/by-id link, the WWN, the serial number or the first of them known in the stable order wwn, by-id, serial.").Default(smart.DeviceLabelKernel).Enum(smart.DeviceLabels...)
+	filesystems        = kingpin.Flag("smart.filesystems", "Report the filesystems mounted from the devices as smartmon_device_filesystem_info.").Default("false").Bool()
+	volumes            = kingpin.Flag("smart.volumes", "Report the md RAID arrays and LVM volumes the devices are members of as smartmon_device_md_info and smartmon_device_lvm_info.").Default("false").Bool()
+	enclosures         = kingpin.Flag("smart.enclosures", "Report the SCSI enclosure slots the devices are installed in as smartmon_device_enclosure_info.").Default("false").Bool()
+	diskStats          = kingpin.Flag("smart.diskstats", "Report the I/O statistics of the devices from /proc/diskstats as smartmon_device_io_*.").Default("false").Bool()
+	nvmeVendorLogs     = kingpin.Flag("smart.nvme-vendor-logs", "Collect the vendor specific log pages of the NVMe devices supporting them, the OCP SMART log page as smartmon_nvme_ocp_* and the Intel SMART log page as smartmon_nvme_intel_*.").Default("false").Bool()
+	collectInfo        = kingpin.Flag("collector.info", "Collect the identity and health of the devices reported by smartctl -i -H.").Default("true").Bool()
+	collectAttributes  = kingpin.Flag("collector.attributes", "Collect the SMART attributes of the devices reported by smartctl -A.").Default("true").Bool()
+	collectSelfTest    = kingpin.Flag("collector.selftest", "Collect the result of the most recent self-test of the devices reported by smartctl -l selftest.").Default("false").Bool()
+	collectErrorLog    = kingpin.Flag("collector.errorlog", "Collect the number of errors logged by the devices reported by smartctl -l error.").Default("false").Bool()
+	collectSecurity    = kingpin.Flag("collector.security", "Collect the ATA Security status of the devices reported by smartctl -g security, and the support of the security commands of self-encrypting NVMe devices.").Default("false").Bool()
+	collectCaps        = kingpin.Flag("collector.capabilities", "Collect the offline data collection and self-test execution status of the ATA devices reported by smartctl -c, e.g. the progress of a self-test.").Default("false").Bool()
+	canonicalNames     = kingpin.Flag("smart.canonical-attribute-names", "Name the ATA attribute metrics after the attribute ID instead of the names reported for the drive, which depend on the drive database.").Default("false").Bool()
+	driveDBPath        = kingpin.Flag("smart.drivedb-path", "Drive database used by smartctl, defaults to the first database found in the usual locations.").Default("").String()
+	driveDBUpdate      = kingpin.Flag("smart.drivedb-update-interval", "Run update-smart-drivedb on this interval, 0 never updates the drive database.").Default("0s").Duration()
+	stateFile          = kingpin.Flag("smart.state-file", "File saving the state of the devices, e.g. the wakeup counters and the devices seen, across restarts.").Default("").String()
+	collectionInterval = kingpin.Flag("smart.collection-interval", "Collect the metrics in the background on this interval and serve the last collected metrics on scrape, 0 collects on every scrape.").Default("0s").Duration()
+	rescanInterval     = kingpin.Flag("smart.rescan-interval", "Reuse the devices found by a scan for this interval unless a block device is added or removed, 0 scans on every collection.").Default("0s").Duration()
+	concurrency        = kingpin.Flag("smart.concurrency", "Number of devices collected in parallel, the devices attached through the same RAID controller or SAS expander are collected one at a time.").Default("0").Int()
+	maxLabels          = kingpin.Flag("smart.max-labels", "Maximum number of labels of a metric, the labels beyond the limit are dropped except the labels identifying the device, 0 is unlimited.").Default("0").Int()
+	maxSeriesPerDevice = kingpin.Flag("smart.max-series-per-device", "Maximum number of series collected from a device, the series beyond the limit are dropped, 0 is unlimited.").Default("0").Int()
+	quarantineFailures = kingpin.Flag("smart.quarantine-failures", "Stop querying a device for --smart.quarantine-duration once it failed this number of consecutive collections, 0 never stops querying the devices.").Default("0").Int()
+	quarantineDuration = kingpin.Flag("smart.quarantine-duration", "Time a failing device is not queried.").Default("30m").Duration()
+	configFile         = kingpin.Flag("config.file", "YAML configuration file.").Default("").String()
+	collectStandby     = kingpin.Flag("smart.collect-standby", "Collect the metrics of devices in standby or sleep, which wakes them up.").Default("false").Bool()
+	smartdAttrLogDir   = kingpin.Flag("smartd.attrlog-dir", "Collect the attributes logged by 'smartd -A' in this directory instead of invoking smartctl.").Default("").String()
+	smartdWarningsFile = kingpin.Flag("smartd.warnings-file", "Count the smartd warnings appended to this file by contrib/smartd-warning.sh.").Default("").String()
+	lowPower           = kingpin.Flag("smart.low-power", "Never wake up the devices in standby, check the power mode of the devices in standby on --smart.standby-check-interval only and read the info and the attributes of a device with a single smartctl command.").Default("false").Bool()
+	standbyCheck       = kingpin.Flag("smart.standby-check-interval", "Interval of the power mode checks of the devices in standby or sleep with --smart.low-power, 0 uses the default of 1h.").Default("0s").Duration()
+	pauseOnBattery     = kingpin.Flag("smart.pause-on-battery", "Pause the collection of the devices while the system runs on battery according to /sys/class/power_supply.").Default("false").Bool()
+	skipIdle           = kingpin.Flag("smart.skip-idle", "Skip all the smartctl commands to the devices which completed no I/O for this duration according to /proc/diskstats, as they are likely spun down, 0 always contacts the devices.").Default("0s").Duration()
+	temperatureLimit   = kingpin.Flag("smart.temperature-threshold", "Temperature in Celsius above which a device is counted by smartmon_devices_over_temperature, 0 uses the default of 60.").Default("0").Float64()
+	healthScore        = kingpin.Flag("smart.health-score", "Collect smartmon_device_health_score, the health of the devices from 100 down to 0, weighted by the health_score weights of the configuration file.").Default("false").Bool()
+	failureRisk        = kingpin.Flag("smart.failure-risk", "Collect smartmon_device_failure_risk, the number of the ATA attributes 5, 187, 188, 197 and 198 with a nonzero raw value, a heuristic based on published drive failure statistics.").Default("false").Bool()
+	attrIncreases      = kingpin.Flag("smart.attribute-increases", "Collect smartmon_attribute_increase_total, the sum of the increases of the raw values of the ATA attributes 5, 187, 197 and 198 between collections, for alerting with increase().").Default("false").Bool()
+	legacyNames        = kingpin.Flag("smart.legacy-metric-names", "Also collect the metrics renamed after their base unit under their previous names, e.g. smartmon_self_test_polling_minutes along smartmon_self_test_polling_seconds, while migrating dashboards and alerts.").Default("false").Bool()
+	historySize        = kingpin.Flag("smart.attribute-history-size", "Number of samples of the key attributes of every device kept in memory and served on /api/v1/attributes/history, 0 keeps no history.").Default("0").Int()
+	cacheStandby       = kingpin.Flag("smart.cache-standby", "Serve the metrics last collected from devices in standby, timestamped with their collection time.").Default("false").Bool()
 )
 
+// collector is implemented by the smartctl and smartd collectors
+type collector interface {
+	prometheus.Collector
+	// Ready returns an error until the collector is able to collect
+	Ready(ctx context.Context) error
+	// Close stops the collector and waits for the collections in progress
+	Close()
+}
+
 func main() {
 	log.AddFlags(kingpin.CommandLine)
 	kingpin.Version(version.Print("smartmon_exporter"))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
-	if os.Geteuid() != rootuid {
-		log.Infoln("Not running as root, some metrics will not be available")
+	command := kingpin.Parse()
+	if *useSudo && *helperPath != "" {
+		log.Fatal("--smart.use-sudo and --smart.helper-path are mutually exclusive")
+	}
+	if *recordDir != "" && *replayDir != "" {
+		log.Fatal("--smart.record-dir and --smart.replay-dir are mutually exclusive")
+	}
+	opts := &smart.Options{
+		SmartctlPath: *ctlPath,
+		ExtraArgs:    strings.Fields(*ctlExtraArgs),
+		Tolerance:    *tolerance,
+		Sudo:         *useSudo,
+		HelperPath:   *helperPath,
+		Backend:      *backend,
+		DriveDBPath:  *driveDBPath,
+		Retries:      *retries,
+		RetryBackoff: *retryBackoff,
+		RecordDir:    *recordDir,
+		ReplayDir:    *replayDir,
+	}
+
+	if command == checkConfigCmd.FullCommand() {
+		checkConfig(opts)
+	}
+
+	cfg := &config.Config{}
+	if *configFile != "" {
+		loaded, err := config.LoadFile(*configFile)
+		if err != nil {
+			log.Fatal("Unable to load config file ", *configFile, ": ", err)
+		}
+		cfg = loaded
+	}
+	collectorOpts := cfg.CollectorOptions()
+	collectorOpts.CacheStandby = *cacheStandby
+	collectorOpts.DeviceLabel = *deviceLabel
+	collectorOpts.Filesystems = *filesystems
+	collectorOpts.Volumes = *volumes
+	collectorOpts.Enclosures = *enclosures
+	collectorOpts.NVMeVendorLogs = *nvmeVendorLogs
+	collectorOpts.DiskStats = *diskStats
+	collectorOpts.QuarantineFailures = *quarantineFailures
+	collectorOpts.QuarantineDuration = *quarantineDuration
+	collectorOpts.StateFile = *stateFile
+	collectorOpts.DriveDBUpdateInterval = *driveDBUpdate
+	if *collectionInterval > 0 {
+		collectorOpts.CollectionInterval = *collectionInterval
+	}
+	if *rescanInterval > 0 {
+		collectorOpts.RescanInterval = *rescanInterval
+	}
+	if *concurrency > 0 {
+		collectorOpts.Concurrency = *concurrency
+	}
+	if *maxLabels > 0 {
+		collectorOpts.MaxLabels = *maxLabels
+	}
+	if *maxSeriesPerDevice > 0 {
+		collectorOpts.MaxSeriesPerDevice = *maxSeriesPerDevice
+	}
+	if *temperatureLimit > 0 {
+		collectorOpts.TemperatureThreshold = *temperatureLimit
+	}
+	if *skipIdle > 0 {
+		collectorOpts.SkipIdle = *skipIdle
+	}
+	if *standbyCheck > 0 {
+		collectorOpts.StandbyCheckInterval = *standbyCheck
+	}
+	if *historySize > 0 {
+		collectorOpts.HistorySize = *historySize
 	}
+	collectorOpts.CollectStandby = collectorOpts.CollectStandby || *collectStandby
+	collectorOpts.LowPower = collectorOpts.LowPower || *lowPower
+	collectorOpts.PauseOnBattery = collectorOpts.PauseOnBattery || *pauseOnBattery
+	collectorOpts.HealthScore = collectorOpts.HealthScore || *healthScore
+	collectorOpts.FailureRisk = collectorOpts.FailureRisk || *failureRisk
+	collectorOpts.AttributeIncreases = collectorOpts.AttributeIncreases || *attrIncreases
+	collectorOpts.LegacyMetricNames = collectorOpts.LegacyMetricNames || *legacyNames
+	collectorOpts.CanonicalAttributeNames = collectorOpts.CanonicalAttributeNames || *canonicalNames
+	collectorOpts.DisableInfo = collectorOpts.DisableInfo || !*collectInfo
+	collectorOpts.DisableAttributes = collectorOpts.DisableAttributes || !*collectAttributes
+	collectorOpts.SelfTests = collectorOpts.SelfTests || *collectSelfTest
+	collectorOpts.ErrorLog = collectorOpts.ErrorLog || *collectErrorLog
+	collectorOpts.Security = collectorOpts.Security || *collectSecurity
+	collectorOpts.Capabilities = collectorOpts.Capabilities || *collectCaps
 
-	smartmonCollector, err := smart.NewCollector()
+	switch command {
+	case debugCmd.FullCommand():
+		debug(opts, collectorOpts)
+	case rulesCmd.FullCommand():
+		printRules(collectorOpts)
+	case dashboardCmd.FullCommand():
+		printDashboard(collectorOpts)
+	}
+
+	var notifications *notify.Dispatcher
+	if cfg.Notifications.Enabled() {
+		notifications = notify.New(cfg.Notifications)
+		// send the notifications of the last collection before exiting
+		defer notifications.Close()
+		collectorOpts.Notifier = notifications
+	}
+
+	var smartmonCollector collector
+	if *smartdAttrLogDir != "" {
+		log.Infoln("Reading smartd attribute logs in", *smartdAttrLogDir)
+		smartmonCollector = smart.NewSmartdCollector(*smartdAttrLogDir)
+	} else {
+		smartctlCollector, err := smart.NewCollector(opts, collectorOpts)
+		if err != nil {
+			log.Fatal("Unable to create collector: ", err)
+		}
+		smartmonCollector = smartctlCollector
+	}
+	labels, err := constLabels()
 	if err != nil {
-		panic("Unable to create collector")
+		log.Fatal(err)
+	}
+	registerer := prometheus.WrapRegistererWith(labels, prometheus.DefaultRegisterer)
+	if err := registerer.Register(version.NewCollector("smartmon_exporter")); err != nil {
+		log.Fatal("Unable to register collector: ", err)
+	}
+	if err := registerer.Register(smartmonCollector); err != nil {
+		log.Fatal("Unable to register collector: ", err)
+	}
+	if *smartdWarningsFile != "" {
+		if err := registerer.Register(smart.NewSmartdWarningsCollector(*smartdWarningsFile)); err != nil {
+			log.Fatal("Unable to register collector: ", err)
+		}
 	}
-	prometheus.MustRegister(smartmonCollector)
 
-	if strings.TrimSpace(*outputFile) != "" {
-		prometheus.WriteToTextfile(*outputFile, prometheus.DefaultGatherer)
+	if *oneshot {
+		code := runOneshot(os.Stdout, *outputFormat, prometheus.DefaultGatherer)
+		smartmonCollector.Close()
+		notifications.Close()
+		os.Exit(code)
+	} else if *telemetryMode == "otlp" {
+		log.Infoln("Pushing metrics to", *otlpEndpoint)
+		if err := runOTLP(prometheus.DefaultGatherer); err != nil {
+			log.Fatal("Unable to push metrics to ", *otlpEndpoint, ": ", err)
+		}
+		smartmonCollector.Close()
+	} else if strings.TrimSpace(*outputFile) != "" {
+		if err := writeOutputFile(*outputFile, *outputFormat, prometheus.DefaultGatherer); err != nil {
+			log.Fatal("Unable to write metrics to ", *outputFile, ": ", err)
+		}
+		smartmonCollector.Close()
+	} else if *pushGatewayURL != "" {
+		log.Infoln("Pushing metrics to", *pushGatewayURL)
+		if err := runPush(prometheus.DefaultGatherer); err != nil {
+			log.Fatal("Unable to push metrics to ", *pushGatewayURL, ": ", err)
+		}
+		smartmonCollector.Close()
+	} else if *remoteWriteURL != "" {
+		log.Infoln("Sending metrics to", *remoteWriteURL)
+		if err := runRemoteWrite(prometheus.DefaultGatherer); err != nil {
+			log.Fatal("Unable to send metrics to ", *remoteWriteURL, ": ", err)
+		}
+		smartmonCollector.Close()
+	} else if *influxDBURL != "" {
+		log.Infoln("Sending metrics to", *influxDBURL)
+		if err := runInfluxDB(prometheus.DefaultGatherer); err != nil {
+			log.Fatal("Unable to send metrics to ", *influxDBURL, ": ", err)
+		}
+		smartmonCollector.Close()
 	} else {
-		http.Handle("/metrics", promhttp.Handler())
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// the replayed outputs and the smartd logs are read without access
+		// to the devices
+		if *replayDir == "" && *smartdAttrLogDir == "" {
+			logPrivileges(opts)
+		}
+		mux := http.NewServeMux()
+		handlerOpts := promhttp.HandlerOpts{
+			EnableOpenMetrics:  true,
+			DisableCompression: *disableCompression,
+			Timeout:            *scrapeTimeout,
+		}
+		networks, err := parseCIDRs(*allowedCIDRs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var limiter *rateLimiter
+		if *rateLimit > 0 {
+			limiter = newRateLimiter(*rateLimit, *rateBurst)
+		}
+		if err := registerer.Register(requestsRejected); err != nil {
+			log.Fatal("Unable to register collector: ", err)
+		}
+		var reloader *tlsReloader
+		if *tlsCertFile != "" || *tlsKeyFile != "" || *tlsClientCAFile != "" {
+			reloader, err = newTLSReloader(*tlsCertFile, *tlsKeyFile, *tlsClientCAFile)
+			if err != nil {
+				log.Fatal("Unable to configure TLS: ", err)
+			}
+			reloader.reloadOnSIGHUP()
+		}
+		mux.Handle("/metrics", accessHandler(limitInFlight(metricsHandler(smartmonCollector, labels, handlerOpts), *maxRequests), networks, limiter))
+		devices, _ := smartmonCollector.(deviceReader)
+		api, err := newAPI(devices)
+		if err != nil {
+			log.Fatal("Unable to configure the API: ", err)
+		}
+		if h, ok := smartmonCollector.(attributeHistorian); ok {
+			api.history = h
+		}
+		mux.Handle(dashboardPath, accessHandler(dashboardHandler(collectorOpts), networks, limiter))
+		mux.Handle(apiDevicesPath, accessHandler(api, networks, limiter))
+		mux.Handle(apiDevicesPath+"/", accessHandler(api, networks, limiter))
+		mux.Handle(apiHistoryPath, accessHandler(http.HandlerFunc(api.attributeHistory), networks, limiter))
+		snapshotter, _ := smartmonCollector.(deviceSnapshotter)
+		mux.Handle(uiPath, accessHandler(uiHandler(), networks, limiter))
+		mux.Handle(uiDevicesPath, accessHandler(uiDevicesHandler(snapshotter), networks, limiter))
+		mux.Handle("/-/healthy", healthyHandler())
+		// the readiness check runs smartctl
+		mux.Handle("/-/ready", accessHandler(readyHandler(smartmonCollector), networks, limiter))
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(`<html>
 				 <head><title>S.M.A.R.T. Exporter</title></head>
 				 <body>
 				 <h1>S.M.A.R.T. Exporter</h1>
 				 <p><a href='` + "/metrics" + `'>Metrics</a></p>
+				 <p><a href='` + uiPath + `'>Devices</a></p>
 				 </body>
 				 </html>`))
 		})
 
-		log.Infoln("Listening on", *listenAddress)
-		log.Fatal(http.ListenAndServe(*listenAddress, nil))
+		registerPprof(mux, networks, limiter)
+
+		if *grpcListenAddress != "" {
+			if devices == nil {
+				log.Fatal("Serving gRPC requires the smartctl collector")
+			}
+			grpcServer, err := serveGRPC(devices, reloader, networks, limiter)
+			if err != nil {
+				log.Fatal("Unable to serve gRPC on ", *grpcListenAddress, ": ", err)
+			}
+			log.Infoln("Serving gRPC on", *grpcListenAddress)
+			// streams never complete on their own, stop without waiting for them
+			defer grpcServer.Stop()
+		}
+
+		if *agentxSocket != "" {
+			if snapshotter == nil {
+				log.Fatal("Serving SNMP requires the smartctl collector")
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if err := serveAgentX(ctx, snapshotter); err != nil {
+				log.Fatal("Unable to serve SNMP through ", *agentxSocket, ": ", err)
+			}
+			defer cancel()
+		}
+
+		if cfg.MQTT.Broker != "" {
+			if snapshotter == nil {
+				log.Fatal("Publishing to MQTT requires the smartctl collector")
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if err := runMQTT(ctx, cfg.MQTT, snapshotter); err != nil {
+				log.Fatal("Unable to publish to ", cfg.MQTT.Broker, ": ", err)
+			}
+			log.Infoln("Publishing the health of the devices to", cfg.MQTT.Broker)
+			defer cancel()
+		}
+
+		listeners, err := listen(*listenAddresses)
+		if err != nil {
+			log.Fatal("Unable to listen: ", err)
+		}
+		if reloader != nil {
+			listeners = tlsListeners(listeners, reloader.tlsConfig())
+		}
+		server := &http.Server{Handler: mux}
+		term := make(chan os.Signal, 1)
+		signal.Notify(term, os.Interrupt, syscall.SIGTERM)
+		shutdown := shutdownOnSignal(server, smartmonCollector, term, *shutdownTimeout)
+
+		served := make(chan error, len(listeners))
+		for _, l := range listeners {
+			log.Infoln("Listening on", l.Addr().Network(), l.Addr())
+			go func(l net.Listener) {
+				served <- server.Serve(l)
+			}(l)
+		}
+		for range listeners {
+			if err := <-served; err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}
+		// Serve returns as soon as Shutdown is called, wait for the
+		// in-flight scrapes to complete before exiting
+		<-shutdown
 	}
 
 }
+
+// logPrivileges logs when starting to serve if smartctl is expected to be unable to
+// access the devices, as smartmon_exporter_privileged reports on every
+// collection.  The devices are only scanned to check their nodes are
+// readable when smartctl has the capabilities.
+func logPrivileges(opts *smart.Options) {
+	if !opts.Privileged(nil) {
+		log.Infoln("Not running as root and smartctl lacks CAP_SYS_RAWIO, some metrics will not be available")
+		return
+	}
+	// a failed scan is reported by the collection
+	devices, err := smart.Scan(context.Background(), opts)
+	if err == nil && !opts.Privileged(devices) {
+		log.Infoln("Some device nodes are not readable by the exporter, some metrics will not be available")
+	}
+}
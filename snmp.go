@@ -0,0 +1,218 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/agentx"
+	"github.com/pgier/smartmon-exporter/smart"
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// agentxReconnectInterval is the wait before reconnecting to the master
+// agent after the session failed
+const agentxReconnectInterval = 30 * time.Second
+
+var (
+	agentxSocket        = kingpin.Flag("snmp.agentx-socket", "AgentX socket of the SNMP master agent, a unix socket path, e.g. /var/agentx/master, or tcp:host:port, on which to serve the health of the devices as the SMARTMON-MIB, disabled if empty.").Default("").String()
+	snmpBaseOID         = kingpin.Flag("snmp.base-oid", "OID under which the SMARTMON-MIB is registered with the master agent.").Default("1.3.6.1.4.1.8072.9999.9999.151").String()
+	snmpRefreshInterval = kingpin.Flag("snmp.refresh-interval", "Interval on which the state of the devices kept by the collector is served over SNMP, the devices are collected first if the exporter was not scraped within the interval.").Default("5m").Duration()
+)
+
+// The columns of smartmonDeviceTable, see contrib/SMARTMON-MIB.txt
+const (
+	snmpColumnIndex     = 1
+	snmpColumnName      = 2
+	snmpColumnType      = 3
+	snmpColumnModel     = 4
+	snmpColumnSerial    = 5
+	snmpColumnWWN       = 6
+	snmpColumnPowerMode = 7
+	snmpColumnHealth    = 8
+)
+
+// The values of smartmonDeviceHealth
+const (
+	snmpHealthOK      = 1
+	snmpHealthFailed  = 2
+	snmpHealthUnknown = 3
+)
+
+// snmpDevice is a row of smartmonDeviceTable
+type snmpDevice struct {
+	device    smart.Device
+	model     string
+	serial    string
+	wwn       string
+	powerMode smart.PowerMode
+	health    int32
+}
+
+// snmpTable holds the variables of the SMARTMON-MIB, refreshed on an
+// interval from the state of the devices kept by the collector so the
+// requests of the master agent, which times out after a few seconds, are
+// answered without running smartctl
+type snmpTable struct {
+	mtx       sync.Mutex
+	base      agentx.OID
+	variables []agentx.Variable
+}
+
+// serveAgentX serves the SMARTMON-MIB to the master agent on the AgentX
+// socket until the context is done, reconnecting when the session fails
+func serveAgentX(ctx context.Context, snapshotter deviceSnapshotter) error {
+	base, err := agentx.ParseOID(*snmpBaseOID)
+	if err != nil {
+		return err
+	}
+	table := &snmpTable{base: base}
+	table.refresh(snapshotter)
+	go func() {
+		ticker := time.NewTicker(*snmpRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				table.refresh(snapshotter)
+			}
+		}
+	}()
+	go func() {
+		for {
+			if err := table.serve(ctx, *agentxSocket); err != nil {
+				log.Errorln("AgentX session failed:", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(agentxReconnectInterval):
+			}
+		}
+	}()
+	return nil
+}
+
+// serve opens a session with the master agent on the socket and serves the
+// table until the session is closed or the context is done
+func (t *snmpTable) serve(ctx context.Context, socket string) error {
+	network, address := "unix", socket
+	if strings.HasPrefix(socket, "tcp:") {
+		network, address = "tcp", strings.TrimPrefix(socket, "tcp:")
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	session, err := agentx.Open(conn, "smartmon-exporter", t.base, t.snapshot)
+	if err != nil {
+		return err
+	}
+	log.Infoln("Serving SNMP under", t.base, "through", socket)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return session.Serve()
+}
+
+// snapshot returns the variables of the table
+func (t *snmpTable) snapshot() []agentx.Variable {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.variables
+}
+
+// refresh replaces the variables of the table with the state of the
+// devices as of their last collection, collecting them first unless the
+// exporter was scraped within the refresh interval
+func (t *snmpTable) refresh(snapshotter deviceSnapshotter) {
+	snapshotter.Refresh(*snmpRefreshInterval)
+	snapshots := snapshotter.DeviceSnapshots()
+	rows := make([]snmpDevice, 0, len(snapshots))
+	for _, s := range snapshots {
+		rows = append(rows, snmpDeviceOf(s))
+	}
+	variables := snmpVariables(t.base, rows)
+	t.mtx.Lock()
+	t.variables = variables
+	t.mtx.Unlock()
+}
+
+// snmpDeviceOf returns the row of the device, its health is unknown until
+// it was collected
+func snmpDeviceOf(s smart.DeviceSnapshot) snmpDevice {
+	row := snmpDevice{
+		device:    s.Device,
+		model:     s.Model,
+		serial:    s.Serial,
+		wwn:       s.WWN,
+		powerMode: s.PowerMode,
+		health:    snmpHealthUnknown,
+	}
+	switch s.Health {
+	case smart.StateFailed:
+		row.health = snmpHealthFailed
+	case smart.StatePassed:
+		row.health = snmpHealthOK
+	}
+	return row
+}
+
+// snmpVariables returns the variables of the SMARTMON-MIB sorted by OID,
+// the number of devices as smartmonDeviceCount (base.1.0) followed by the
+// columns of smartmonDeviceTable (base.2.1.column.index)
+func snmpVariables(base agentx.OID, rows []snmpDevice) []agentx.Variable {
+	variables := []agentx.Variable{
+		{OID: base.Append(1, 0), Type: agentx.TypeInteger, Value: int32(len(rows))},
+	}
+	entry := base.Append(2, 1)
+	for column := uint32(snmpColumnIndex); column <= snmpColumnHealth; column++ {
+		for i, row := range rows {
+			v := agentx.Variable{OID: entry.Append(column, uint32(i+1)), Type: agentx.TypeOctetString}
+			switch column {
+			case snmpColumnIndex:
+				v.Type, v.Value = agentx.TypeInteger, int32(i+1)
+			case snmpColumnName:
+				v.Value = row.device.Name
+			case snmpColumnType:
+				v.Value = row.device.Type
+			case snmpColumnModel:
+				v.Value = row.model
+			case snmpColumnSerial:
+				v.Value = row.serial
+			case snmpColumnWWN:
+				v.Value = row.wwn
+			case snmpColumnPowerMode:
+				v.Value = string(row.powerMode)
+			case snmpColumnHealth:
+				v.Type, v.Value = agentx.TypeInteger, row.health
+			}
+			variables = append(variables, v)
+		}
+	}
+	return variables
+}
@@ -0,0 +1,119 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/agentx"
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+// fakeSnapshotter serves the snapshots and records the refreshes
+type fakeSnapshotter struct {
+	snapshots []smart.DeviceSnapshot
+	refreshed []time.Duration
+}
+
+func (f *fakeSnapshotter) DeviceSnapshots() []smart.DeviceSnapshot {
+	return f.snapshots
+}
+
+func (f *fakeSnapshotter) Refresh(maxAge time.Duration) {
+	f.refreshed = append(f.refreshed, maxAge)
+}
+
+// testSnapshots are a healthy SATA disk and two disks behind a RAID
+// controller, one failed and one never collected
+var testSnapshots = []smart.DeviceSnapshot{
+	{
+		Device:    smart.Device{Name: "/dev/bus/0", Type: "megaraid,0"},
+		Key:       "/dev/bus/0:megaraid,0",
+		Model:     "ST4000NM0033",
+		Serial:    "Z1Z0ABCD",
+		PowerMode: smart.PowerModeActive,
+		Health:    smart.StateFailed,
+	},
+	{
+		Device: smart.Device{Name: "/dev/bus/0", Type: "megaraid,1"},
+		Key:    "/dev/bus/0:megaraid,1",
+		Health: smart.StateUnknown,
+	},
+	{
+		Device:    smart.Device{Name: "/dev/sda", Type: "sat"},
+		Key:       "/dev/sda",
+		Model:     "ST4000DM000-1F2168",
+		Serial:    "Z302SXYZ",
+		WWN:       "0x5000c500a1b2c3d4",
+		PowerMode: smart.PowerModeStandby,
+		Health:    smart.StatePassed,
+	},
+}
+
+func TestSNMPDeviceOf(t *testing.T) {
+	for i, health := range []int32{snmpHealthFailed, snmpHealthUnknown, snmpHealthOK} {
+		if row := snmpDeviceOf(testSnapshots[i]); row.health != health {
+			t.Errorf("expected health %d of %s, got %d", health, testSnapshots[i].Key, row.health)
+		}
+	}
+}
+
+func TestSNMPVariables(t *testing.T) {
+	rows := []snmpDevice{snmpDeviceOf(testSnapshots[0]), snmpDeviceOf(testSnapshots[2])}
+	variables := snmpVariables(agentx.OID{1, 3, 6, 1, 4, 1, 99}, rows)
+	formatted := make([]string, 0, len(variables))
+	for i, v := range variables {
+		if i > 0 && variables[i-1].OID.Compare(v.OID) >= 0 {
+			t.Errorf("expected %s after %s", v.OID, variables[i-1].OID)
+		}
+		formatted = append(formatted, fmt.Sprintf("%s %d %v", v.OID, v.Type, v.Value))
+	}
+	expected := []string{
+		"1.3.6.1.4.1.99.1.0 2 2",
+		"1.3.6.1.4.1.99.2.1.1.1 2 1",
+		"1.3.6.1.4.1.99.2.1.1.2 2 2",
+		"1.3.6.1.4.1.99.2.1.2.1 4 /dev/bus/0",
+		"1.3.6.1.4.1.99.2.1.2.2 4 /dev/sda",
+		"1.3.6.1.4.1.99.2.1.3.1 4 megaraid,0",
+		"1.3.6.1.4.1.99.2.1.3.2 4 sat",
+		"1.3.6.1.4.1.99.2.1.4.1 4 ST4000NM0033",
+		"1.3.6.1.4.1.99.2.1.4.2 4 ST4000DM000-1F2168",
+		"1.3.6.1.4.1.99.2.1.5.1 4 Z1Z0ABCD",
+		"1.3.6.1.4.1.99.2.1.5.2 4 Z302SXYZ",
+		"1.3.6.1.4.1.99.2.1.6.1 4 ",
+		"1.3.6.1.4.1.99.2.1.6.2 4 0x5000c500a1b2c3d4",
+		"1.3.6.1.4.1.99.2.1.7.1 4 active",
+		"1.3.6.1.4.1.99.2.1.7.2 4 standby",
+		"1.3.6.1.4.1.99.2.1.8.1 2 2",
+		"1.3.6.1.4.1.99.2.1.8.2 2 1",
+	}
+	if !reflect.DeepEqual(formatted, expected) {
+		t.Errorf("expected %v, got %v", expected, formatted)
+	}
+}
+
+func TestSNMPTableRefresh(t *testing.T) {
+	snapshotter := &fakeSnapshotter{snapshots: testSnapshots}
+	table := &snmpTable{base: agentx.OID{1, 3, 6, 1, 4, 1, 99}}
+	table.refresh(snapshotter)
+	if !reflect.DeepEqual(snapshotter.refreshed, []time.Duration{*snmpRefreshInterval}) {
+		t.Errorf("expected a refresh of the devices older than %v, got %v", *snmpRefreshInterval, snapshotter.refreshed)
+	}
+	variables := table.snapshot()
+	if count := variables[0]; count.Value != int32(3) {
+		t.Errorf("expected 3 devices, got %v", count.Value)
+	}
+}
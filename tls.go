@@ -0,0 +1,135 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/common/log"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	tlsCertFile     = kingpin.Flag("web.tls.cert-file", "Certificate to serve the metrics and the API over HTTPS on the TCP addresses.").Default("").String()
+	tlsKeyFile      = kingpin.Flag("web.tls.key-file", "Key of the --web.tls.cert-file certificate.").Default("").String()
+	tlsClientCAFile = kingpin.Flag("web.tls.client-ca-file", "CA certificates verifying the certificates the clients are then required to present (mTLS).").Default("").String()
+)
+
+// tlsReloader serves the certificate and the client CAs last loaded from
+// their files, which are reloaded on SIGHUP so renewed certificates are
+// picked up without restarting the exporter
+type tlsReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	// mtx protects config
+	mtx    sync.RWMutex
+	config *tls.Config
+}
+
+// newTLSReloader loads the certificate and the client CAs, client
+// certificates are not required if clientCAFile is empty
+func newTLSReloader(certFile, keyFile, clientCAFile string) (*tlsReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both a certificate and a key are required")
+	}
+	r := &tlsReloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the files again, the previous configuration is kept on error
+func (r *tlsReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.New("Unable to load certificate " + r.certFile + ": " + err.Error())
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if r.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.clientCAFile)
+		if err != nil {
+			return errors.New("Unable to load client CAs: " + err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("Unable to load client CAs: no certificate found in " + r.clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.mtx.Lock()
+	r.config = config
+	r.mtx.Unlock()
+	return nil
+}
+
+// reloadOnSIGHUP reloads the files whenever the exporter receives SIGHUP
+func (r *tlsReloader) reloadOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := r.reload(); err != nil {
+				log.Errorln(err, "keeping the previous certificates")
+				continue
+			}
+			log.Infoln("Reloaded the TLS certificates")
+		}
+	}()
+}
+
+// tlsConfig returns the configuration of the listeners, which serves the
+// last configuration loaded to every new connection, negotiating the
+// application protocols nextProtos if any, e.g. h2 for gRPC
+func (r *tlsReloader) tlsConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mtx.RLock()
+			defer r.mtx.RUnlock()
+			if len(nextProtos) == 0 {
+				return r.config, nil
+			}
+			config := r.config.Clone()
+			config.NextProtos = nextProtos
+			return config, nil
+		},
+	}
+}
+
+// tlsListeners serves TLS on the TCP listeners, the unix sockets are
+// protected by their file permissions instead
+func tlsListeners(listeners []net.Listener, config *tls.Config) []net.Listener {
+	wrapped := make([]net.Listener, len(listeners))
+	for i, l := range listeners {
+		if l.Addr().Network() == "tcp" {
+			l = tls.NewListener(l, config)
+		}
+		wrapped[i] = l
+	}
+	return wrapped
+}
@@ -0,0 +1,182 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for the common name and
+// its key to cert.pem and key.pem in dir, and returns the certificate
+func writeCertificate(t *testing.T, dir, commonName string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// servedCommonName returns the common name of the certificate served to a
+// new connection
+func servedCommonName(t *testing.T, r *tlsReloader) string {
+	config, err := r.tlsConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Subject.CommonName
+}
+
+func TestTLSReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := newTLSReloader(certFile, "", ""); err == nil {
+		t.Error("expected an error without a key")
+	}
+	if _, err := newTLSReloader(certFile, keyFile, ""); err == nil {
+		t.Error("expected an error with missing files")
+	}
+
+	writeCertificate(t, dir, "first")
+	r, err := newTLSReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCommonName(t, r); cn != "first" {
+		t.Errorf("expected the first certificate, got %s", cn)
+	}
+
+	// renewed
+	writeCertificate(t, dir, "second")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCommonName(t, r); cn != "second" {
+		t.Errorf("expected the renewed certificate, got %s", cn)
+	}
+
+	// a broken renewal keeps the previous certificate
+	if err := ioutil.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Error("expected an error reloading a broken certificate")
+	}
+	if cn := servedCommonName(t, r); cn != "second" {
+		t.Errorf("expected the previous certificate to be kept, got %s", cn)
+	}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, dir, "server")
+
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTLSReloader(certFile, keyFile, empty); err == nil {
+		t.Error("expected an error without client CA")
+	}
+
+	// the certificate is its own CA
+	r, err := newTLSReloader(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _ := r.tlsConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Errorf("expected the client certificates to be required, got %v", config.ClientAuth)
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected TLS 1.2 at least, got %x", config.MinVersion)
+	}
+}
+
+func TestTLSListeners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	listeners, err := listen([]string{"127.0.0.1:0", unixScheme + filepath.Join(dir, "smartmon.sock")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(listeners)
+
+	wrapped := tlsListeners(listeners, &tls.Config{})
+	if wrapped[0] == listeners[0] {
+		t.Error("expected TLS on the TCP listener")
+	}
+	if wrapped[1] != listeners[1] {
+		t.Error("expected no TLS on the unix socket")
+	}
+	if _, ok := wrapped[1].(*net.UnixListener); !ok {
+		t.Errorf("expected a unix listener, got %T", wrapped[1])
+	}
+}
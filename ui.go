@@ -0,0 +1,193 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+// uiPath serves the web UI of the health of the devices and uiDevicesPath
+// the state of the devices it renders
+const (
+	uiPath        = "/ui"
+	uiDevicesPath = "/ui/devices"
+)
+
+// uiMaxAge is the age of the last collection beyond which the devices are
+// collected again to serve their state, when the exporter is not scraped
+// and not collecting in the background
+const uiMaxAge = time.Minute
+
+// deviceSnapshotter is implemented by the collectors keeping the state of
+// the devices found by their last collection
+type deviceSnapshotter interface {
+	DeviceSnapshots() []smart.DeviceSnapshot
+	Refresh(maxAge time.Duration)
+}
+
+// uiDevice is the state of a device rendered by the web UI
+type uiDevice struct {
+	Key           string          `json:"key"`
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	Model         string          `json:"model"`
+	Serial        string          `json:"serial"`
+	PowerMode     smart.PowerMode `json:"power_mode"`
+	Health        string          `json:"health"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	Wear          *float64        `json:"wear_percent,omitempty"`
+	LastSelfTest  *selfTestResult `json:"last_self_test,omitempty"`
+	LastCollected *time.Time      `json:"last_collected,omitempty"`
+}
+
+// uiPage renders the table of the devices from the state of the devices
+// kept by the collector, so loading the page sends no command to the
+// devices.  The state is requested with a relative URL so the page also
+// works behind a reverse proxy serving the exporter under a prefix.
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>S.M.A.R.T. Exporter - Devices</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+th { background: #f0f0f0; }
+.passed { color: #1a7f37; }
+.failed { color: #cf222e; font-weight: bold; }
+.unknown { color: #6e7781; }
+#status { color: #6e7781; }
+</style>
+</head>
+<body>
+<h1>S.M.A.R.T. Exporter</h1>
+<p><a href="metrics">Metrics</a> | <a href="api/v1/devices">Device API</a> | <button id="refresh">Refresh</button> <span id="status"></span></p>
+<table>
+<thead>
+<tr><th>Device</th><th>Type</th><th>Model</th><th>Serial</th><th>Power mode</th><th>Health</th><th>Temperature</th><th>Wear</th><th>Last self-test</th><th>Last collected</th></tr>
+</thead>
+<tbody id="devices"></tbody>
+</table>
+<script>
+"use strict";
+
+function cell(row, text, className) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (className) {
+    td.className = className;
+  }
+}
+
+function selfTest(device) {
+  const test = device.last_self_test;
+  if (!test) {
+    return ["", ""];
+  }
+  return [test.description + ": " + test.status + " (" + test.lifetime_hours + "h)", test.passed ? "passed" : "failed"];
+}
+
+async function render() {
+  const status = document.getElementById("status");
+  status.textContent = "Loading...";
+  try {
+    const response = await fetch("ui/devices");
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    const devices = await response.json();
+    const tbody = document.getElementById("devices");
+    tbody.textContent = "";
+    for (const d of devices) {
+      const row = tbody.insertRow();
+      cell(row, d.name);
+      cell(row, d.type);
+      cell(row, d.model);
+      cell(row, d.serial);
+      cell(row, d.power_mode);
+      cell(row, d.health, d.health.toLowerCase());
+      cell(row, d.temperature !== undefined ? d.temperature + " °C" : "");
+      cell(row, d.wear_percent !== undefined ? d.wear_percent + " %" : "");
+      const [test, testClass] = selfTest(d);
+      cell(row, test, testClass);
+      cell(row, d.last_collected ? new Date(d.last_collected).toLocaleString() : "");
+    }
+    status.textContent = "Updated " + new Date().toLocaleTimeString();
+  } catch (e) {
+    status.textContent = "Unable to read the devices: " + e.message;
+  }
+}
+
+document.getElementById("refresh").addEventListener("click", render);
+render();
+</script>
+</body>
+</html>
+`
+
+// uiHandler serves the web UI
+func uiHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(uiPage))
+	})
+}
+
+// uiDevicesHandler serves the state of the devices kept by the collector,
+// which is nil if the collector keeps none
+func uiDevicesHandler(snapshotter deviceSnapshotter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if snapshotter == nil {
+			http.Error(w, "The state of the devices is only kept by the smartctl collector", http.StatusNotImplemented)
+			return
+		}
+		snapshotter.Refresh(uiMaxAge)
+		writeJSON(w, uiDevices(snapshotter.DeviceSnapshots()))
+	})
+}
+
+// uiDevices returns the devices rendered by the web UI
+func uiDevices(snapshots []smart.DeviceSnapshot) []uiDevice {
+	devices := make([]uiDevice, 0, len(snapshots))
+	for _, s := range snapshots {
+		d := uiDevice{
+			Key:         s.Key,
+			Name:        s.Name,
+			Type:        s.Type,
+			Model:       s.Model,
+			Serial:      s.Serial,
+			PowerMode:   s.PowerMode,
+			Health:      s.Health,
+			Temperature: s.Temperature,
+			Wear:        s.Wear,
+		}
+		if s.LastSelfTest != nil {
+			d.LastSelfTest = &selfTestResult{
+				Description:   s.LastSelfTest.Description,
+				Status:        s.LastSelfTest.Status,
+				Passed:        s.LastSelfTest.Passed,
+				LifetimeHours: s.LastSelfTest.LifetimeHours,
+			}
+		}
+		if !s.LastCollected.IsZero() {
+			lastCollected := s.LastCollected
+			d.LastCollected = &lastCollected
+		}
+		devices = append(devices, d)
+	}
+	return devices
+}
@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pgier/smartmon-exporter/smart"
+)
+
+func TestUIHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	uiHandler().ServeHTTP(rec, httptest.NewRequest("GET", uiPath, nil))
+	if contentType := rec.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("expected an HTML page, got %s", contentType)
+	}
+}
+
+func TestUIDevicesHandlerWithoutState(t *testing.T) {
+	rec := httptest.NewRecorder()
+	uiDevicesHandler(nil).ServeHTTP(rec, httptest.NewRequest("GET", uiDevicesPath, nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d, got %d", http.StatusNotImplemented, rec.Code)
+	}
+}
+
+func TestUIDevicesHandler(t *testing.T) {
+	temperature, wear := 34.0, 3.0
+	collected := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	sda := testSnapshots[2]
+	sda.Temperature = &temperature
+	sda.Wear = &wear
+	sda.LastSelfTest = &smart.SelfTest{Num: 1, Description: "Short offline", Status: "Completed without error", Passed: true, LifetimeHours: 1234}
+	sda.LastCollected = collected
+	snapshotter := &fakeSnapshotter{snapshots: []smart.DeviceSnapshot{testSnapshots[1], sda}}
+
+	rec := httptest.NewRecorder()
+	uiDevicesHandler(snapshotter).ServeHTTP(rec, httptest.NewRequest("GET", uiDevicesPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if !reflect.DeepEqual(snapshotter.refreshed, []time.Duration{uiMaxAge}) {
+		t.Errorf("expected a refresh of the devices older than %s, got %v", uiMaxAge, snapshotter.refreshed)
+	}
+
+	// the device never collected has no temperature, wear, self-test or
+	// time of collection
+	var devices []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &devices); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{
+			"key":        "/dev/bus/0:megaraid,1",
+			"name":       "/dev/bus/0",
+			"type":       "megaraid,1",
+			"model":      "",
+			"serial":     "",
+			"power_mode": "",
+			"health":     smart.StateUnknown,
+		},
+		{
+			"key":          "/dev/sda",
+			"name":         "/dev/sda",
+			"type":         "sat",
+			"model":        "ST4000DM000-1F2168",
+			"serial":       "Z302SXYZ",
+			"power_mode":   string(smart.PowerModeStandby),
+			"health":       smart.StatePassed,
+			"temperature":  34.0,
+			"wear_percent": 3.0,
+			"last_self_test": map[string]interface{}{
+				"description":    "Short offline",
+				"status":         "Completed without error",
+				"passed":         true,
+				"lifetime_hours": 1234.0,
+			},
+			"last_collected": "2026-10-15T10:00:00Z",
+		},
+	}
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("expected %v, got %v", expected, devices)
+	}
+}
@@ -0,0 +1,109 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// zabbixDeviceLabels are the labels identifying a device, reported as the
+// LLD macros {#DISK}, {#TYPE}, {#BY_ID}, {#WWN} and {#SERIAL}
+var zabbixDeviceLabels = []string{"disk", "type", "by_id", "wwn", "serial"}
+
+// zabbixOutput is the zabbix output, the low-level discovery data of the
+// devices along with the values of the items
+type zabbixOutput struct {
+	// Data lists the macros of the devices found by the scan
+	Data []map[string]string `json:"data"`
+	// Values are the values of the device series by disk, then by item
+	// key, and Global the values of the series of no device
+	Values map[string]map[string]float64 `json:"values"`
+	Global map[string]float64            `json:"global"`
+}
+
+// formatZabbix formats the metrics as the JSON of a Zabbix low-level
+// discovery rule, e.g.
+//
+//	{"data": [{"{#DISK}": "/dev/sda", "{#TYPE}": "sat", ...}],
+//	 "values": {"/dev/sda": {"smartmon_device_smart_healthy": 1, ...}},
+//	 "global": {"smartmon_devices_total": 1, ...}}
+//
+// The item prototypes of the discovered devices are dependent items of
+// the check, extracting their value with JSONPath preprocessing, e.g.
+// $.values["{#DISK}"]["smartmon_device_smart_healthy"].  The item key is the
+// metric name, followed by the labels other than the device labels, e.g.
+// smartmon_device_power_mode[mode=active].  The NaN and infinite values
+// which JSON cannot represent are left out.
+func formatZabbix(families []*dto.MetricFamily) ([]byte, error) {
+	out := zabbixOutput{
+		Data:   []map[string]string{},
+		Values: map[string]map[string]float64{},
+		Global: map[string]float64{},
+	}
+	for _, ts := range toWriteRequest(families, time.Now()).Timeseries {
+		labels := map[string]string{}
+		name := ""
+		extra := []string{}
+		for _, l := range ts.Labels {
+			switch {
+			case l.Name == model.MetricNameLabel:
+				name = l.Value
+			case isZabbixDeviceLabel(l.Name):
+				labels[l.Name] = l.Value
+			default:
+				extra = append(extra, l.Name+"="+l.Value)
+			}
+		}
+		if name == "smartmon_device_present" && ts.Samples[0].Value == 1 {
+			macros := map[string]string{}
+			for _, label := range zabbixDeviceLabels {
+				macros["{#"+strings.ToUpper(label)+"}"] = labels[label]
+			}
+			out.Data = append(out.Data, macros)
+		}
+		value := ts.Samples[0].Value
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		key := name
+		if len(extra) > 0 {
+			key += "[" + strings.Join(extra, ",") + "]"
+		}
+		disk, found := labels["disk"]
+		if !found {
+			out.Global[key] = value
+			continue
+		}
+		if out.Values[disk] == nil {
+			out.Values[disk] = map[string]float64{}
+		}
+		out.Values[disk][key] = value
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// isZabbixDeviceLabel returns true if the label identifies the device
+func isZabbixDeviceLabel(name string) bool {
+	for _, label := range zabbixDeviceLabels {
+		if name == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFormatZabbix(t *testing.T) {
+	families := parseFamilies(t, `# TYPE smartmon_device_present gauge
+smartmon_device_present{by_id="",disk="/dev/sda",serial="Z302SXYZ",type="sat",wwn="0x5000c500a1b2c3d4"} 1
+smartmon_device_present{by_id="",disk="/dev/sdb",serial="",type="sat",wwn=""} 0
+# TYPE smartmon_device_power_mode gauge
+smartmon_device_power_mode{by_id="",disk="/dev/sda",mode="active",serial="Z302SXYZ",type="sat",wwn="0x5000c500a1b2c3d4"} 1
+smartmon_device_power_mode{by_id="",disk="/dev/sda",mode="standby",serial="Z302SXYZ",type="sat",wwn="0x5000c500a1b2c3d4"} 0
+# TYPE smartmon_temperature_celsius_raw_value gauge
+smartmon_temperature_celsius_raw_value{by_id="",disk="/dev/sda",serial="Z302SXYZ",smart_id="194",type="sat",wwn="0x5000c500a1b2c3d4"} NaN
+# TYPE smartmon_devices_total gauge
+smartmon_devices_total 1
+`)
+	content, err := formatZabbix(families)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out zabbixOutput
+	if err := json.Unmarshal(content, &out); err != nil {
+		t.Fatalf("invalid JSON %s: %v", content, err)
+	}
+	expected := zabbixOutput{
+		// the absent devices are not discovered
+		Data: []map[string]string{
+			{"{#DISK}": "/dev/sda", "{#TYPE}": "sat", "{#BY_ID}": "", "{#WWN}": "0x5000c500a1b2c3d4", "{#SERIAL}": "Z302SXYZ"},
+		},
+		// the NaN is left out
+		Values: map[string]map[string]float64{
+			"/dev/sda": {
+				"smartmon_device_present":                  1,
+				"smartmon_device_power_mode[mode=active]":  1,
+				"smartmon_device_power_mode[mode=standby]": 0,
+			},
+			"/dev/sdb": {
+				"smartmon_device_present": 0,
+			},
+		},
+		Global: map[string]float64{
+			"smartmon_devices_total": 1,
+		},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %+v, got %+v", expected, out)
+	}
+}